@@ -0,0 +1,153 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/pborman/uuid"
+
+	authclient "github.com/CMSgov/bcda-app/bcda/auth/client"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/service"
+)
+
+// The ACO lifecycle operations below are shared by the admin API and bcdacli.
+
+// InvalidRequestError reports an operation that cannot be performed with the values provided
+type InvalidRequestError struct {
+	msg string
+}
+
+func (e InvalidRequestError) Error() string {
+	return e.msg
+}
+
+// ACONotFoundError reports an ACO that could not be retrieved
+type ACONotFoundError struct {
+	Err error
+}
+
+func (e ACONotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ACONotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// SSASError reports a failed request to SSAS
+type SSASError struct {
+	Err error
+}
+
+func (e SSASError) Error() string {
+	return e.Err.Error()
+}
+
+func (e SSASError) Unwrap() error {
+	return e.Err
+}
+
+var acoUUIDExp = regexp.MustCompile("[0-9a-f]{6}-([0-9a-f]{4}-){3}[0-9a-f]{12}")
+
+// CreateACO creates an ACO with an optional CMS ID
+func CreateACO(ctx context.Context, r models.Repository, name, cmsID string) (*models.ACO, error) {
+	if name == "" {
+		return nil, InvalidRequestError{"ACO name must be provided"}
+	}
+
+	var cmsIDPt *string
+	if cmsID != "" {
+		if !service.IsSupportedACO(cmsID) {
+			return nil, InvalidRequestError{"ACO CMS ID is invalid"}
+		}
+		cmsIDPt = &cmsID
+	}
+
+	id := uuid.NewRandom()
+	aco := models.ACO{Name: name, CMSID: cmsIDPt, UUID: id, ClientID: id.String()}
+	if err := r.CreateACO(ctx, aco); err != nil {
+		return nil, err
+	}
+
+	return &aco, nil
+}
+
+// GetACOByID retrieves the ACO identified by a CMS ID or UUID
+func GetACOByID(ctx context.Context, r models.Repository, acoID string) (*models.ACO, error) {
+	var (
+		aco *models.ACO
+		err error
+	)
+	if service.IsSupportedACO(acoID) {
+		aco, err = r.GetACOByCMSID(ctx, acoID)
+	} else if acoUUIDExp.MatchString(acoID) {
+		aco, err = r.GetACOByUUID(ctx, uuid.Parse(acoID))
+	} else {
+		return nil, InvalidRequestError{"ACO ID must be a supported CMS ID or UUID"}
+	}
+
+	if err != nil {
+		return nil, ACONotFoundError{err}
+	}
+	return aco, nil
+}
+
+// CreateGroup creates an SSAS group for one or more ACOs identified by CMS ID or UUID, returning the group's ID.
+// Groups for vendors serving several ACOs are not associated with any of them, since an ACO's group
+// is the one holding its own credentials. Groups created for a single ACO are associated with that ACO.
+func CreateGroup(ctx context.Context, r models.Repository, id, name string, acoIDs []string) (string, error) {
+	if id == "" || name == "" || len(acoIDs) == 0 {
+		return "", InvalidRequestError{"group ID, name, and ACO IDs are required"}
+	}
+
+	acos := make([]*models.ACO, 0, len(acoIDs))
+	cmsIDs := make([]string, 0, len(acoIDs))
+	for _, acoID := range acoIDs {
+		aco, err := GetACOByID(ctx, r, acoID)
+		if err != nil {
+			return "", err
+		}
+		if aco.CMSID == nil {
+			return "", InvalidRequestError{fmt.Sprintf("ACO %s does not have a CMS ID", acoID)}
+		}
+		acos, cmsIDs = append(acos, aco), append(cmsIDs, *aco.CMSID)
+	}
+
+	ssas, err := authclient.NewSSASClient()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := ssas.CreateGroup(id, name, cmsIDs...)
+	if err != nil {
+		return "", SSASError{err}
+	}
+
+	var g struct {
+		GroupID string `json:"group_id"`
+	}
+	if err = json.Unmarshal(b, &g); err != nil || g.GroupID == "" {
+		return "", SSASError{fmt.Errorf("unexpected response from SSAS: %s", string(b))}
+	}
+
+	if aco := acos[0]; len(acos) == 1 && aco.UUID != nil {
+		aco.GroupID = g.GroupID
+		if err = r.UpdateACO(ctx, aco.UUID, map[string]interface{}{"group_id": g.GroupID}); err != nil {
+			return g.GroupID, fmt.Errorf("group %s was created, but ACO could not be updated: %w", g.GroupID, err)
+		}
+	}
+
+	return g.GroupID, nil
+}
+
+// SetBlacklistState revokes (blacklisted) or restores the ACO's access to the API
+func SetBlacklistState(ctx context.Context, r models.Repository, cmsID string, blacklisted bool) error {
+	aco, err := r.GetACOByCMSID(ctx, cmsID)
+	if err != nil {
+		return ACONotFoundError{err}
+	}
+	return r.UpdateACO(ctx, aco.UUID, map[string]interface{}{"blacklisted": blacklisted})
+}
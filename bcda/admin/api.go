@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CMSgov/bcda-app/bcda/auth"
+	"github.com/CMSgov/bcda-app/bcda/auth/rsautils"
+	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/models/postgres"
+)
+
+// Handler serves the admin API, which exposes the ACO lifecycle operations available through bcdacli
+type Handler struct {
+	r models.Repository
+}
+
+func NewHandler() *Handler {
+	return &Handler{r: postgres.NewRepository(database.Connection)}
+}
+
+type createACORequest struct {
+	Name  string `json:"name"`
+	CMSID string `json:"cms_id"`
+}
+
+type createACOResponse struct {
+	UUID  string `json:"uuid"`
+	CMSID string `json:"cms_id,omitempty"`
+}
+
+type createGroupRequest struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// CMS IDs or UUIDs of the ACOs served by the group
+	ACOIDs []string `json:"aco_ids"`
+}
+
+type createGroupResponse struct {
+	GroupID string `json:"group_id"`
+}
+
+type savePublicKeyRequest struct {
+	PublicKey string `json:"public_key"`
+}
+
+type generateCredentialsRequest struct {
+	IPs []string `json:"ips"`
+}
+
+type generateCredentialsResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	ClientName   string `json:"client_name"`
+	SystemID     string `json:"system_id,omitempty"`
+}
+
+// CreateACO creates an ACO with an optional CMS ID
+func (h *Handler) CreateACO(w http.ResponseWriter, r *http.Request) {
+	var req createACORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "CreateACO", "", http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	aco, err := CreateACO(r.Context(), h.r, req.Name, req.CMSID)
+	if err != nil {
+		writeError(w, r, "CreateACO", req.CMSID, errorStatus(err), err)
+		return
+	}
+
+	audit(r, "CreateACO", aco.UUID.String(), nil)
+	writeJSON(w, http.StatusCreated, createACOResponse{UUID: aco.UUID.String(), CMSID: req.CMSID})
+}
+
+// CreateGroup creates an SSAS group for one or more ACOs identified by CMS ID or UUID.
+// Groups created for a single ACO are associated with that ACO.
+func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req createGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "CreateGroup", "", http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	groupID, err := CreateGroup(r.Context(), h.r, req.ID, req.Name, req.ACOIDs)
+	if err != nil {
+		writeError(w, r, "CreateGroup", req.ID, errorStatus(err), err)
+		return
+	}
+
+	audit(r, "CreateGroup", groupID, nil)
+	writeJSON(w, http.StatusCreated, createGroupResponse{GroupID: groupID})
+}
+
+// SavePublicKey validates and stores the PEM encoded public key for the ACO
+func (h *Handler) SavePublicKey(w http.ResponseWriter, r *http.Request) {
+	cmsID := chi.URLParam(r, "cmsId")
+	var req savePublicKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "SavePublicKey", cmsID, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	if _, err := rsautils.ReadPublicKey(req.PublicKey); err != nil {
+		writeError(w, r, "SavePublicKey", cmsID, http.StatusBadRequest, fmt.Errorf("invalid public key: %w", err))
+		return
+	}
+
+	aco, err := h.r.GetACOByCMSID(context.Background(), cmsID)
+	if err != nil {
+		writeError(w, r, "SavePublicKey", cmsID, http.StatusNotFound, err)
+		return
+	}
+
+	if err = h.r.UpdateACO(context.Background(), aco.UUID, map[string]interface{}{"public_key": req.PublicKey}); err != nil {
+		writeError(w, r, "SavePublicKey", cmsID, http.StatusInternalServerError, err)
+		return
+	}
+
+	audit(r, "SavePublicKey", cmsID, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BlacklistACO revokes the ACO's access to the API
+func (h *Handler) BlacklistACO(w http.ResponseWriter, r *http.Request) {
+	h.setBlacklistState(w, r, "BlacklistACO", true)
+}
+
+// UnblacklistACO restores the ACO's access to the API
+func (h *Handler) UnblacklistACO(w http.ResponseWriter, r *http.Request) {
+	h.setBlacklistState(w, r, "UnblacklistACO", false)
+}
+
+func (h *Handler) setBlacklistState(w http.ResponseWriter, r *http.Request, op string, blacklistState bool) {
+	cmsID := chi.URLParam(r, "cmsId")
+	if err := SetBlacklistState(r.Context(), h.r, cmsID, blacklistState); err != nil {
+		writeError(w, r, op, cmsID, errorStatus(err), err)
+		return
+	}
+
+	audit(r, op, cmsID, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GenerateClientCredentials registers a new system for the ACO and returns its credentials.
+// The request body is optional and may list the IP addresses the system is allowed to connect from.
+func (h *Handler) GenerateClientCredentials(w http.ResponseWriter, r *http.Request) {
+	cmsID := chi.URLParam(r, "cmsId")
+	var req generateCredentialsRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, "GenerateClientCredentials", cmsID, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+	}
+
+	aco, err := h.r.GetACOByCMSID(context.Background(), cmsID)
+	if err != nil {
+		writeError(w, r, "GenerateClientCredentials", cmsID, http.StatusNotFound, err)
+		return
+	}
+
+	// The public key is optional for SSAS, and not used by the ACO API
+	creds, err := auth.GetProvider().RegisterSystem(aco.UUID.String(), "", aco.GroupID, req.IPs...)
+	if err != nil {
+		writeError(w, r, "GenerateClientCredentials", cmsID, http.StatusInternalServerError,
+			fmt.Errorf("could not register system for %s: %w", cmsID, err))
+		return
+	}
+
+	audit(r, "GenerateClientCredentials", cmsID, nil)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusCreated, generateCredentialsResponse{ClientID: creds.ClientID,
+		ClientSecret: creds.ClientSecret, ClientName: creds.ClientName, SystemID: creds.SystemID})
+}
+
+// ListCredentials returns the credentials issued to the ACO
+func (h *Handler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	cmsID := chi.URLParam(r, "cmsId")
+	if _, err := h.r.GetACOByCMSID(context.Background(), cmsID); err != nil {
+		writeError(w, r, "ListCredentials", cmsID, http.StatusNotFound, err)
+		return
+	}
+
+	creds, err := auth.GetProvider().ListCredentials(cmsID)
+	if err != nil {
+		writeError(w, r, "ListCredentials", cmsID, http.StatusInternalServerError,
+			fmt.Errorf("could not list credentials for %s: %w", cmsID, err))
+		return
+	}
+
+	audit(r, "ListCredentials", cmsID, nil)
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// errorStatus returns the HTTP status code describing an error returned by one of the ACO lifecycle operations
+func errorStatus(err error) int {
+	var (
+		invalidErr  InvalidRequestError
+		notFoundErr ACONotFoundError
+		ssasErr     SSASError
+	)
+	switch {
+	case errors.As(err, &invalidErr):
+		return http.StatusBadRequest
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound
+	case errors.As(err, &ssasErr):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError audits the failed operation and returns the error to the caller.
+// Details of server errors are only written to the logs.
+func writeError(w http.ResponseWriter, r *http.Request, op, target string, status int, err error) {
+	audit(r, op, target, err)
+
+	msg := err.Error()
+	if status >= http.StatusInternalServerError {
+		log.Error(err)
+		msg = http.StatusText(status)
+	}
+	http.Error(w, msg, status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(body); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/conf"
+)
+
+type AdminAPITestSuite struct {
+	suite.Suite
+}
+
+func TestAdminAPITestSuite(t *testing.T) {
+	suite.Run(t, new(AdminAPITestSuite))
+}
+
+func (s *AdminAPITestSuite) TestCreateACO() {
+	tests := []struct {
+		name         string
+		body         string
+		repoErr      error
+		expectedCode int
+		expectedBody string
+	}{
+		{"Successful", `{"name": "Test ACO", "cms_id": "A0000"}`, nil, http.StatusCreated, `"cms_id":"A0000"`},
+		{"Without CMS ID", `{"name": "Test ACO"}`, nil, http.StatusCreated, `"uuid"`},
+		{"Invalid body", `{"name": `, nil, http.StatusBadRequest, "invalid request body"},
+		{"Missing name", `{"cms_id": "A0000"}`, nil, http.StatusBadRequest, "ACO name must be provided"},
+		{"Invalid CMS ID", `{"name": "Test ACO", "cms_id": "1234"}`, nil, http.StatusBadRequest, "ACO CMS ID is invalid"},
+		{"Repository error", `{"name": "Test ACO", "cms_id": "A0000"}`, errors.New("some error"), http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError)},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			repository := &models.MockRepository{}
+			repository.On("CreateACO", mock.Anything, mock.MatchedBy(func(aco models.ACO) bool {
+				return aco.Name == "Test ACO" && aco.ClientID == aco.UUID.String()
+			})).Return(tt.repoErr)
+			h := &Handler{r: repository}
+
+			rr := httptest.NewRecorder()
+			h.CreateACO(rr, httptest.NewRequest("POST", "/admin/acos", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func (s *AdminAPITestSuite) TestSetBlacklistState() {
+	aco := &models.ACO{UUID: uuid.NewRandom()}
+	repository := &models.MockRepository{}
+	repository.On("GetACOByCMSID", mock.Anything, "A0000").Return(aco, nil)
+	repository.On("GetACOByCMSID", mock.Anything, "A0001").Return(nil, errors.New("no ACO record found for A0001"))
+	repository.On("UpdateACO", mock.Anything, aco.UUID, map[string]interface{}{"blacklisted": true}).Return(nil).Once()
+	repository.On("UpdateACO", mock.Anything, aco.UUID, map[string]interface{}{"blacklisted": false}).Return(nil).Once()
+	h := &Handler{r: repository}
+
+	testLogger := test.NewLocal(logger)
+	defer testLogger.Reset()
+
+	rr := httptest.NewRecorder()
+	h.BlacklistACO(rr, newRequest("PUT", "/admin/acos/A0000/blacklist", "A0000", nil))
+	assert.Equal(s.T(), http.StatusNoContent, rr.Code)
+	assert.Equal(s.T(), "BlacklistACO", testLogger.LastEntry().Data["op"])
+	assert.Equal(s.T(), "OperationSucceeded", testLogger.LastEntry().Data["event"])
+	assert.Equal(s.T(), "admin-client", testLogger.LastEntry().Data["adminClientID"])
+
+	rr = httptest.NewRecorder()
+	h.UnblacklistACO(rr, newRequest("DELETE", "/admin/acos/A0000/blacklist", "A0000", nil))
+	assert.Equal(s.T(), http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.BlacklistACO(rr, newRequest("PUT", "/admin/acos/A0001/blacklist", "A0001", nil))
+	assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	assert.Contains(s.T(), rr.Body.String(), "no ACO record found for A0001")
+	assert.Equal(s.T(), "OperationFailed", testLogger.LastEntry().Data["event"])
+
+	repository.AssertExpectations(s.T())
+}
+
+func (s *AdminAPITestSuite) TestSavePublicKey() {
+	publicKey, err := ioutil.ReadFile("../../shared_files/ATO_public.pem")
+	assert.NoError(s.T(), err)
+
+	aco := &models.ACO{UUID: uuid.NewRandom()}
+	repository := &models.MockRepository{}
+	repository.On("GetACOByCMSID", mock.Anything, "A0000").Return(aco, nil)
+	repository.On("UpdateACO", mock.Anything, aco.UUID, map[string]interface{}{"public_key": string(publicKey)}).Return(nil)
+	h := &Handler{r: repository}
+
+	body, err := json.Marshal(savePublicKeyRequest{PublicKey: string(publicKey)})
+	assert.NoError(s.T(), err)
+	rr := httptest.NewRecorder()
+	h.SavePublicKey(rr, newRequest("PUT", "/admin/acos/A0000/public_key", "A0000", strings.NewReader(string(body))))
+	assert.Equal(s.T(), http.StatusNoContent, rr.Code)
+	repository.AssertExpectations(s.T())
+
+	rr = httptest.NewRecorder()
+	h.SavePublicKey(rr, newRequest("PUT", "/admin/acos/A0000/public_key", "A0000",
+		strings.NewReader(`{"public_key": "not a key"}`)))
+	assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	assert.Contains(s.T(), rr.Body.String(), "invalid public key")
+}
+
+func (s *AdminAPITestSuite) TestCreateGroup() {
+	var xData string
+	router := chi.NewRouter()
+	router.Post("/group", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(s.T(), json.NewDecoder(r.Body).Decode(&body))
+		xData = body["xdata"].(string)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"group_id": "%s"}`, body["group_id"])
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	origSSASURL, origSSASUseTLS := conf.GetEnv("SSAS_URL"), conf.GetEnv("SSAS_USE_TLS")
+	defer func() {
+		conf.SetEnv(s.T(), "SSAS_URL", origSSASURL)
+		conf.SetEnv(s.T(), "SSAS_USE_TLS", origSSASUseTLS)
+	}()
+	conf.SetEnv(s.T(), "SSAS_URL", server.URL)
+	conf.SetEnv(s.T(), "SSAS_USE_TLS", "false")
+
+	cmsID1, cmsID2 := "A0001", "A0002"
+	aco1, aco2 := &models.ACO{UUID: uuid.NewRandom(), CMSID: &cmsID1}, &models.ACO{UUID: uuid.NewRandom(), CMSID: &cmsID2}
+	repository := &models.MockRepository{}
+	repository.On("GetACOByCMSID", mock.Anything, "A0001").Return(aco1, nil)
+	repository.On("GetACOByUUID", mock.Anything, aco2.UUID).Return(aco2, nil)
+	repository.On("GetACOByCMSID", mock.Anything, "A0003").Return(nil, errors.New("no ACO record found for A0003"))
+	// Only the single ACO group is associated with the ACO
+	repository.On("UpdateACO", mock.Anything, aco1.UUID, map[string]interface{}{"group_id": "single-group"}).Return(nil).Once()
+	h := &Handler{r: repository}
+
+	rr := httptest.NewRecorder()
+	h.CreateGroup(rr, httptest.NewRequest("POST", "/admin/groups",
+		strings.NewReader(`{"id": "single-group", "name": "Single Group", "aco_ids": ["A0001"]}`)))
+	assert.Equal(s.T(), http.StatusCreated, rr.Code)
+	assert.JSONEq(s.T(), `{"group_id": "single-group"}`, rr.Body.String())
+	assert.JSONEq(s.T(), `{"cms_ids": ["A0001"]}`, xData)
+
+	// ACOs may be identified by their UUID as well as their CMS ID
+	rr = httptest.NewRecorder()
+	h.CreateGroup(rr, httptest.NewRequest("POST", "/admin/groups",
+		strings.NewReader(fmt.Sprintf(`{"id": "vendor-group", "name": "Vendor Group", "aco_ids": ["A0001", "%s"]}`, aco2.UUID))))
+	assert.Equal(s.T(), http.StatusCreated, rr.Code)
+	assert.JSONEq(s.T(), `{"cms_ids": ["A0001", "A0002"]}`, xData)
+
+	rr = httptest.NewRecorder()
+	h.CreateGroup(rr, httptest.NewRequest("POST", "/admin/groups",
+		strings.NewReader(`{"id": "missing-group", "name": "Missing Group", "aco_ids": ["A0003"]}`)))
+	assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.CreateGroup(rr, httptest.NewRequest("POST", "/admin/groups",
+		strings.NewReader(`{"id": "invalid-group", "name": "Invalid Group", "aco_ids": ["1234"]}`)))
+	assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	assert.Contains(s.T(), rr.Body.String(), "ACO ID must be a supported CMS ID or UUID")
+
+	rr = httptest.NewRecorder()
+	h.CreateGroup(rr, httptest.NewRequest("POST", "/admin/groups", strings.NewReader(`{"id": "no-acos", "name": "No ACOs"}`)))
+	assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	assert.Contains(s.T(), rr.Body.String(), "group ID, name, and ACO IDs are required")
+
+	repository.AssertExpectations(s.T())
+}
+
+func (s *AdminAPITestSuite) TestGenerateClientCredentialsUnknownACO() {
+	repository := &models.MockRepository{}
+	repository.On("GetACOByCMSID", mock.Anything, "A0001").Return(nil, errors.New("no ACO record found for A0001"))
+	h := &Handler{r: repository}
+
+	rr := httptest.NewRecorder()
+	h.GenerateClientCredentials(rr, newRequest("POST", "/admin/acos/A0001/credentials", "A0001", nil))
+	assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.GenerateClientCredentials(rr, newRequest("POST", "/admin/acos/A0001/credentials", "A0001", strings.NewReader(`{"ips": `)))
+	assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+}
+
+func newRequest(method, target, cmsID string, body *strings.Reader) *http.Request {
+	var req *http.Request
+	if body == nil {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, body)
+	}
+	req.SetBasicAuth("admin-client", "admin-secret")
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("cmsId", cmsID)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
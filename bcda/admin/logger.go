@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/CMSgov/bcda-app/conf"
+
+	"github.com/sirupsen/logrus"
+)
+
+var logger *logrus.Logger
+
+func init() {
+	logger = logrus.New()
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.Formatter.(*logrus.JSONFormatter).TimestampFormat = time.RFC3339Nano
+
+	filePath, success := conf.LookupEnv("BCDA_ADMIN_LOG")
+	if success {
+		/* #nosec -- 0640 permissions required for Splunk ingestion */
+		file, err := os.OpenFile(filepath.Clean(filePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+
+		if err == nil {
+			logger.SetOutput(file)
+		} else {
+			logger.Info("Failed to open Admin log file; using default stderr")
+		}
+	} else {
+		logger.Info("No Admin log location provided; using default stderr")
+	}
+}
+
+// audit records an operation performed through the admin API along with the admin client that performed it.
+// A nil err indicates that the operation succeeded.
+func audit(r *http.Request, op, target string, err error) {
+	adminID, _, _ := r.BasicAuth()
+	entry := logger.WithFields(logrus.Fields{
+		"adminClientID": adminID,
+		"op":            op,
+		"target":        target,
+	})
+
+	if err != nil {
+		entry.WithField("event", "OperationFailed").Print(err.Error())
+		return
+	}
+	entry.WithField("event", "OperationSucceeded").Print()
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/CMSgov/bcda-app/bcda/auth"
+	"github.com/CMSgov/bcda-app/bcda/monitoring"
+)
+
+// NewAdminRouter returns the router for the admin API. Every route requires the admin credentials.
+func NewAdminRouter(middlewares ...func(http.Handler) http.Handler) http.Handler {
+	r := chi.NewRouter()
+	m := monitoring.GetMonitor()
+	h := NewHandler()
+	r.Use(middlewares...)
+	r.Use(auth.RequireAdminAuth)
+	r.Route("/admin", func(r chi.Router) {
+		r.Post(m.WrapHandler("/acos", h.CreateACO))
+		r.Put(m.WrapHandler("/acos/{cmsId}/public_key", h.SavePublicKey))
+		r.Put(m.WrapHandler("/acos/{cmsId}/blacklist", h.BlacklistACO))
+		r.Delete(m.WrapHandler("/acos/{cmsId}/blacklist", h.UnblacklistACO))
+		r.Post(m.WrapHandler("/acos/{cmsId}/credentials", h.GenerateClientCredentials))
+		r.Get(m.WrapHandler("/acos/{cmsId}/credentials", h.ListCredentials))
+		r.Post(m.WrapHandler("/groups", h.CreateGroup))
+	})
+	return r
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CMSgov/bcda-app/bcda/auth"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/conf"
+)
+
+func TestAdminRoutesRequireAdminAuth(t *testing.T) {
+	adminID, adminSecret := "admin-client", "admin-secret"
+	hash, err := auth.NewHash(adminSecret)
+	assert.NoError(t, err)
+	conf.SetEnv(t, "BCDA_ADMIN_CLIENT_ID", adminID)
+	conf.SetEnv(t, "BCDA_ADMIN_SECRET_HASH", hash.String())
+	// Every route is requested with the wrong secret, which would otherwise lock out the admin client
+	conf.SetEnv(t, "AUTH_LOCKOUT_THRESHOLD", "100")
+	defer func() {
+		conf.UnsetEnv(t, "BCDA_ADMIN_CLIENT_ID")
+		conf.UnsetEnv(t, "BCDA_ADMIN_SECRET_HASH")
+		conf.UnsetEnv(t, "AUTH_LOCKOUT_THRESHOLD")
+		_ = auth.ClearLockout(models.LockoutIPAddress, "192.0.2.1")
+	}()
+
+	router := NewAdminRouter()
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/admin/acos"},
+		{"PUT", "/admin/acos/A0000/public_key"},
+		{"PUT", "/admin/acos/A0000/blacklist"},
+		{"DELETE", "/admin/acos/A0000/blacklist"},
+		{"POST", "/admin/acos/A0000/credentials"},
+		{"GET", "/admin/acos/A0000/credentials"},
+		{"POST", "/admin/groups"},
+	}
+
+	for _, route := range routes {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(route.method, route.path, nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "%s %s", route.method, route.path)
+
+		req := httptest.NewRequest(route.method, route.path, nil)
+		req.SetBasicAuth(adminID, "wrong-secret")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "%s %s", route.method, route.path)
+	}
+
+	// Valid admin credentials reach the handler
+	req := httptest.NewRequest("POST", "/admin/acos", strings.NewReader(`{"cms_id": "A0000"}`))
+	req.SetBasicAuth(adminID, adminSecret)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "ACO name must be provided")
+}
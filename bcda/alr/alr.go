@@ -0,0 +1,185 @@
+package alr
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/alr/csv"
+	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/models/postgres"
+	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/CMSgov/bcda-app/conf"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ALR deliveries are made up of one or more table CSVs that share an ACO and delivery timestamp.
+// Ex: P.A1234.ACO.QALR.2020Q4.D201231.T1200000_1-1.csv
+var alrFilenameRegexp = regexp.MustCompile(`^(P|T)\.(A\d{4})\.ACO\.(?:Q|A)ALR\.\d{4}(?:Q\d)?\.D(\d{6})\.T(\d{6})\d_1-\d+\.csv$`)
+
+type alrDelivery struct {
+	acoID     string
+	timestamp time.Time
+	filePaths []string
+	imported  bool
+	// deliveryDate is the modification time of the newest file in the delivery
+	deliveryDate time.Time
+}
+
+func (d alrDelivery) String() string {
+	return fmt.Sprintf("%s ALR delivered %s", d.acoID, d.timestamp.Format(time.RFC3339))
+}
+
+// ImportALRDirectory imports every ALR delivery found in the top level of the supplied directory.
+// Successfully imported files are moved to the pending deletion directory.
+func ImportALRDirectory(filePath string) (success, failure, skipped int, err error) {
+	deliveries, skipped, err := getALRDeliveries(filePath)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if len(deliveries) == 0 {
+		log.Info("Failed to find any ALR files in directory")
+		return 0, 0, skipped, nil
+	}
+
+	repo := postgres.NewAlrRepo(database.Connection)
+	for _, delivery := range deliveries {
+		if err := importALR(context.Background(), repo, delivery); err != nil {
+			log.Errorf("Failed to import %s: %s", delivery, err.Error())
+			failure++
+			continue
+		}
+		delivery.imported = true
+		success++
+	}
+
+	if err := cleanupALR(deliveries); err != nil {
+		log.Error(err)
+	}
+
+	if failure > 0 {
+		err = fmt.Errorf("one or more ALR deliveries failed to import correctly")
+		log.Error(err)
+	}
+	return success, failure, skipped, err
+}
+
+func importALR(ctx context.Context, repo *postgres.AlrRepository, delivery *alrDelivery) error {
+	alrs, err := csv.ToALR(delivery.filePaths...)
+	if err != nil {
+		return err
+	}
+
+	values := make([]models.Alr, len(alrs))
+	for i, a := range alrs {
+		values[i] = *a
+	}
+
+	if err := repo.AddAlr(ctx, delivery.acoID, delivery.timestamp, values); err != nil {
+		return err
+	}
+
+	log.Infof("Successfully imported %d ALR records for %s", len(values), delivery)
+	return nil
+}
+
+// getALRDeliveries groups the files found in dir by ACO and delivery timestamp.
+// Files that do not match the ALR naming convention are counted as skipped.
+func getALRDeliveries(dir string) ([]*alrDelivery, int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var skipped int
+	grouped := make(map[string]*alrDelivery)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		acoID, timestamp, err := parseALRFilename(info.Name())
+		if err != nil {
+			log.Warn(err)
+			skipped++
+			continue
+		}
+
+		key := fmt.Sprintf("%s:%s", acoID, timestamp.Format(time.RFC3339))
+		delivery, ok := grouped[key]
+		if !ok {
+			delivery = &alrDelivery{acoID: acoID, timestamp: timestamp}
+			grouped[key] = delivery
+		}
+		delivery.filePaths = append(delivery.filePaths, filepath.Join(dir, info.Name()))
+		if info.ModTime().After(delivery.deliveryDate) {
+			delivery.deliveryDate = info.ModTime()
+		}
+	}
+
+	deliveries := make([]*alrDelivery, 0, len(grouped))
+	for _, delivery := range grouped {
+		sort.Strings(delivery.filePaths)
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].timestamp.Equal(deliveries[j].timestamp) {
+			return deliveries[i].acoID < deliveries[j].acoID
+		}
+		return deliveries[i].timestamp.Before(deliveries[j].timestamp)
+	})
+
+	return deliveries, skipped, nil
+}
+
+func parseALRFilename(name string) (acoID string, timestamp time.Time, err error) {
+	parts := alrFilenameRegexp.FindStringSubmatch(name)
+	if len(parts) != 5 {
+		return "", time.Time{}, fmt.Errorf("invalid ALR filename for file: %s", name)
+	}
+
+	timestamp, err = time.Parse("060102150405", parts[3]+parts[4])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse date from ALR filename %s: %w", name, err)
+	}
+
+	return parts[2], timestamp, nil
+}
+
+func cleanupALR(deliveries []*alrDelivery) error {
+	errCount := 0
+	deleteThreshold := time.Hour * time.Duration(utils.GetEnvInt("BCDA_ETL_FILE_ARCHIVE_THRESHOLD_HR", 72))
+	for _, delivery := range deliveries {
+		if !delivery.imported && delivery.deliveryDate.Add(deleteThreshold).After(time.Now()) {
+			// Leave recent failures in place so they can be retried
+			continue
+		}
+
+		for _, path := range delivery.filePaths {
+			newpath := filepath.Join(conf.GetEnv("PENDING_DELETION_DIR"), filepath.Base(path))
+			if err := os.Rename(path, newpath); err != nil {
+				errCount++
+				log.Errorf("File %s failed to clean up properly: %v", path, err)
+				continue
+			}
+			if delivery.imported {
+				log.Infof("File %s successfully ingested and moved to the pending deletion dir", path)
+			} else {
+				log.Infof("File %s never ingested, moved to the pending deletion dir", path)
+			}
+		}
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("%d files could not be cleaned up", errCount)
+	}
+	return nil
+}
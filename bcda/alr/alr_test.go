@@ -0,0 +1,69 @@
+package alr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseALRFilename(t *testing.T) {
+	tests := []struct {
+		name      string
+		fileName  string
+		acoID     string
+		timestamp time.Time
+		errMsg    string
+	}{
+		{"Quarterly", "P.A1234.ACO.QALR.2020Q4.D201231.T1200000_1-1.csv", "A1234",
+			time.Date(2020, time.December, 31, 12, 0, 0, 0, time.UTC), ""},
+		{"Annual", "T.A9999.ACO.AALR.2020.D210115.T0830150_1-2.csv", "A9999",
+			time.Date(2021, time.January, 15, 8, 30, 15, 0, time.UTC), ""},
+		{"Unknown file", "P.A1234.ACO.ZC8Y20.D201231.T1200000", "", time.Time{}, "invalid ALR filename"},
+		{"Invalid date", "P.A1234.ACO.QALR.2020Q4.D201331.T1200000_1-1.csv", "", time.Time{}, "failed to parse date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acoID, timestamp, err := parseALRFilename(tt.fileName)
+			if tt.errMsg != "" {
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.acoID, acoID)
+			assert.True(t, tt.timestamp.Equal(timestamp))
+		})
+	}
+}
+
+func TestGetALRDeliveries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alr")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"P.A1234.ACO.QALR.2020Q4.D201231.T1200000_1-2.csv",
+		"P.A1234.ACO.QALR.2020Q4.D201231.T1200000_1-1.csv",
+		"P.A5678.ACO.QALR.2020Q3.D200930.T1200000_1-1.csv",
+		"README.txt",
+	}
+	for _, f := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, f), []byte("data"), 0600))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700))
+
+	deliveries, skipped, err := getALRDeliveries(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+	require.Len(t, deliveries, 2)
+
+	// Deliveries are ordered by timestamp and their files are sorted
+	assert.Equal(t, "A5678", deliveries[0].acoID)
+	assert.Equal(t, "A1234", deliveries[1].acoID)
+	assert.Equal(t, []string{filepath.Join(dir, files[1]), filepath.Join(dir, files[0])}, deliveries[1].filePaths)
+}
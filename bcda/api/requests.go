@@ -3,6 +3,8 @@ package api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -13,6 +15,7 @@ import (
 
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	fhircodes "github.com/google/fhir/go/proto/google/fhir/proto/stu3/codes_go_proto"
@@ -48,6 +51,9 @@ type Handler struct {
 	supportedResources map[string]struct{}
 
 	bbBasePath string
+
+	// Tracks the export requests whose queue jobs are still being enqueued
+	planners sync.WaitGroup
 }
 
 func NewHandler(resources []string, basePath string) *Handler {
@@ -75,6 +81,23 @@ func NewHandler(resources []string, basePath string) *Handler {
 	return h
 }
 
+// WaitForPlanners waits for the export requests to finish enqueueing their queue jobs, returning false
+// if they did not finish within the timeout. Jobs whose queue jobs were not all planned are failed by the reconciler.
+func (h *Handler) WaitForPlanners(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		h.planners.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 func (h *Handler) BulkPatientRequest(w http.ResponseWriter, r *http.Request) {
 	resourceTypes, err := h.validateRequest(r)
 	if err != nil {
@@ -112,6 +135,13 @@ func (h *Handler) BulkGroupRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only runout data is delivered per performance year
+	if _, ok := r.URL.Query()["runoutYear"]; ok && reqType != service.Runout {
+		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.RequestErr, "Invalid parameter: runoutYear is only supported by the runout group")
+		responseutils.WriteError(oo, w, http.StatusBadRequest)
+		return
+	}
+
 	resourceTypes, err := h.validateRequest(r)
 	if err != nil {
 		responseutils.WriteError(err, w, http.StatusBadRequest)
@@ -121,6 +151,119 @@ func (h *Handler) BulkGroupRequest(w http.ResponseWriter, r *http.Request) {
 	h.bulkRequest(resourceTypes, w, r, reqType)
 }
 
+// AttributionChanges returns the beneficiaries added to and removed from the ACO's attribution.
+// Only the "all" group is supported since runout files do not change attribution.
+func (h *Handler) AttributionChanges(w http.ResponseWriter, r *http.Request) {
+	conditions, ok := attributionConditions(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		since time.Time
+		err   error
+	)
+	if params, ok := r.URL.Query()["_since"]; ok {
+		if since, err = time.Parse(time.RFC3339Nano, params[0]); err != nil {
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.FormatErr, "Invalid date format supplied in _since parameter.  Date must be in FHIR Instant format.")
+			responseutils.WriteError(oo, w, http.StatusBadRequest)
+			return
+		} else if since.After(time.Now()) {
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.FormatErr, "Invalid date format supplied in _since parameter. Date must be a date that has already passed")
+			responseutils.WriteError(oo, w, http.StatusBadRequest)
+			return
+		}
+	}
+	conditions.Since = since
+
+	changes, err := h.Svc.GetAttributionChanges(r.Context(), conditions)
+	if err != nil {
+		writeAttributionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		log.Error(err)
+	}
+}
+
+// GroupRoster retrieves the beneficiaries currently attributed to the caller's ACO.
+// The roster is written as a FHIR Group resource by the supplied writer, allowing each API version
+// to use its own FHIR model.
+func (h *Handler) GroupRoster(w http.ResponseWriter, r *http.Request, write func(http.ResponseWriter, responseutils.GroupRoster)) {
+	conditions, ok := attributionConditions(w, r)
+	if !ok {
+		return
+	}
+
+	roster, err := h.Svc.GetAttributionRoster(r.Context(), conditions)
+	if err != nil {
+		writeAttributionError(w, err)
+		return
+	}
+
+	start, end := roster.Period()
+	write(w, responseutils.GroupRoster{
+		CMSID:       roster.CMSID,
+		MBIs:        roster.MBIs,
+		Start:       start,
+		End:         end,
+		LastUpdated: roster.File.Timestamp,
+		Active:      roster.AttributionDate.IsZero(),
+	})
+}
+
+// attributionConditions validates a request for the attribution of the "all" group and returns the
+// conditions identifying the caller's ACO. If the request is invalid, the error response has already been
+// written and false is returned.
+func attributionConditions(w http.ResponseWriter, r *http.Request) (service.RequestConditions, bool) {
+	if groupID := chi.URLParam(r, "groupId"); groupID != "all" {
+		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.RequestErr, "Invalid group ID")
+		responseutils.WriteError(oo, w, http.StatusBadRequest)
+		return service.RequestConditions{}, false
+	}
+
+	ad, err := readAuthData(r)
+	if err != nil {
+		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.TokenErr, "")
+		responseutils.WriteError(oo, w, http.StatusUnauthorized)
+		return service.RequestConditions{}, false
+	}
+
+	// Credentials serving multiple ACOs must select the ACO they are requesting data for
+	if ad.ACOID == "" && len(ad.ACOs) > 0 {
+		version, _ := getVersion(r.URL)
+		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.RequestErr,
+			fmt.Sprintf("Credential is authorized for multiple ACOs. Select an ACO using /api/%s/ACO/{cmsId}/...", version))
+		responseutils.WriteError(oo, w, http.StatusBadRequest)
+		return service.RequestConditions{}, false
+	}
+
+	return service.RequestConditions{
+		CMSID: ad.CMSID,
+		ACOID: uuid.Parse(ad.ACOID),
+	}, true
+}
+
+func writeAttributionError(w http.ResponseWriter, err error) {
+	log.Error(err)
+	var (
+		oo       *fhirmodels.OperationOutcome
+		respCode int
+	)
+	if _, ok := errors.Cause(err).(service.CCLFNotFoundError); ok {
+		oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+			responseutils.NotFoundErr, err.Error())
+		respCode = http.StatusNotFound
+	} else {
+		oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+			responseutils.InternalErr, "")
+		respCode = http.StatusInternalServerError
+	}
+	responseutils.WriteError(oo, w, respCode)
+}
+
 func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *http.Request, reqType service.RequestType) {
 	// Create context to encapsulate the entire workflow. In the future, we can define child context's for timing.
 	ctx := context.Background()
@@ -144,6 +287,14 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 		return
 	}
 
+	// Credentials serving multiple ACOs must select the ACO they are requesting data for
+	if ad.ACOID == "" && len(ad.ACOs) > 0 {
+		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.RequestErr,
+			fmt.Sprintf("Credential is authorized for multiple ACOs. Select an ACO using /api/%s/ACO/{cmsId}/...", version))
+		responseutils.WriteError(oo, w, http.StatusBadRequest)
+		return
+	}
+
 	bb, err := client.NewBlueButtonClient(client.NewConfig(h.bbBasePath))
 	if err != nil {
 		log.Error(err)
@@ -199,6 +350,15 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 		Status:     models.JobStatusPending,
 	}
 
+	// Populated while handling the request and used to enqueue the queue jobs once the job has been created
+	var (
+		conditions service.RequestConditions
+		plan       service.QueJobPlan
+		since      time.Time
+		// The job's created_at is set by the database, so this approximates it
+		createdAt = time.Now()
+	)
+
 	// Need to create job in transaction instead of the very end of the process because we need
 	// the newJob.ID field to be set in the associated queuejobs. By doing the job creation (and update)
 	// in a transaction, we can rollback if we encounter any errors with handling the data needed for the newJob
@@ -223,16 +383,13 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 			return
 		}
 
-		// We create the job after populating all of the data needed for the job (including inserting all of the queue jobs) to
-		// ensure that the job will be able to be processed and it WILL NOT BE stuck in the Pending state.
-		// For example, we write that the job has 10 queuejobs. We fail after inserting 9 queuejobs. The job will
-		// never move out of the IN_PROGRESS (or PENDING) state since we'll never be able to add the last queuejob.
+		// We create the job after verifying the request can be fulfilled, but before enqueuing any of the queue jobs.
+		// Enqueuing the queue jobs for the largest ACOs takes too long to complete within the request, so the
+		// queue jobs are enqueued in the background once the caller has been given the job's location.
 		//
 		// Since the queue jobs may (and do) exist in a different database, we cannot use a single transaction to encompass
-		// both adding queuejobs and adding the parent job.
-		//
-		// This does introduce an error scenario where we have queuejobs but no parent job.
-		// We've added logic into the worker to handle this situation.
+		// both adding queuejobs and adding the parent job. The job count remains unset until every queue job has been
+		// planned, which prevents the worker from completing the job early.
 		if err = tx.Commit(); err != nil {
 			log.Error(err.Error())
 			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.DbErr, "")
@@ -243,6 +400,18 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 		// We've successfully created the job
 		w.Header().Set("Content-Location", fmt.Sprintf("%s://%s/api/v1/jobs/%d", scheme, r.Host, newJob.ID))
 		w.WriteHeader(http.StatusAccepted)
+
+		priorityReq := service.PriorityRequest{
+			CMSID:     conditions.CMSID, // CMS ID, not the ACO uuid
+			Since:     (!since.IsZero() || conditions.ReqType == service.RetrieveNewBeneHistData),
+			ReqType:   conditions.ReqType,
+			CreatedAt: createdAt,
+		}
+		h.planners.Add(1)
+		go func() {
+			defer h.planners.Done()
+			h.enqueueQueJobs(newJob.ID, priorityReq, plan)
+		}()
 	}()
 
 	newJob.ID, err = rtx.CreateJob(ctx, newJob)
@@ -263,7 +432,6 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 	}
 	newJob.TransactionTime = b.Meta.LastUpdated
 
-	var since time.Time
 	// Decode the _since parameter (if it exists) so it can be persisted in job args
 	if params, ok := r.URL.Query()["_since"]; ok {
 		since, err = time.Parse(time.RFC3339Nano, params[0])
@@ -272,12 +440,11 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
 				responseutils.RequestErr, err.Error())
 			responseutils.WriteError(oo, w, http.StatusBadRequest)
+			return
 		}
 	}
 
-	var queJobs []*models.JobEnqueueArgs
-
-	conditions := service.RequestConditions{
+	conditions = service.RequestConditions{
 		ReqType:   reqType,
 		Resources: resourceTypes,
 
@@ -288,7 +455,11 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 		Since:           since,
 		TransactionTime: newJob.TransactionTime,
 	}
-	queJobs, err = h.Svc.GetQueJobs(ctx, conditions)
+	// The runoutYear parameter has already been validated
+	if params, ok := r.URL.Query()["runoutYear"]; ok {
+		conditions.RunoutYear, _ = strconv.Atoi(params[0])
+	}
+	plan, err = h.Svc.PlanQueJobs(ctx, conditions)
 	if err != nil {
 		log.Error(err)
 		var (
@@ -299,6 +470,14 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 			oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
 				responseutils.NotFoundErr, err.Error())
 			respCode = http.StatusNotFound
+		} else if yearErr := (service.RunoutYearNotConfiguredError{}); goerrors.As(err, &yearErr) {
+			oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.RequestErr, fmt.Sprintf("Invalid runoutYear parameter. %s.", yearErr.Error()))
+			respCode = http.StatusBadRequest
+		} else if limitedErr := (service.LimitedAccessError{}); goerrors.As(err, &limitedErr) {
+			oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.UnauthorizedErr, limitedErr.Error())
+			respCode = http.StatusForbidden
 		} else {
 			oo = responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
 				responseutils.InternalErr, err.Error())
@@ -307,31 +486,61 @@ func (h *Handler) bulkRequest(resourceTypes []string, w http.ResponseWriter, r *
 		responseutils.WriteError(oo, w, respCode)
 		return
 	}
-	newJob.JobCount = len(queJobs)
 
-	// We've now computed all of the fields necessary to populate a fully defined job
+	// We've now computed all of the fields necessary to populate the job. The job count is set once the queue jobs are enqueued.
 	if err = rtx.UpdateJob(ctx, newJob); err != nil {
 		log.Error(err.Error())
 		oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.DbErr, "")
 		responseutils.WriteError(oo, w, http.StatusInternalServerError)
 		return
 	}
+}
 
-	// Since we're enqueuing these queuejobs BEFORE we've created the actual job, we may encounter a transient
-	// error where the job does not exist. Since queuejobs are retried, the transient error will be resolved
-	// once we finish inserting the job.
-	for _, j := range queJobs {
-		sinceParam := (!since.IsZero() || conditions.ReqType == service.RetrieveNewBeneHistData)
-		jobPriority := h.Svc.GetJobPriority(conditions.CMSID, j.ResourceType, sinceParam) // first argument is the CMS ID, not the ACO uuid
+// enqueueQueJobs executes the plan for the job, enqueuing each batch of queue jobs as it is created.
+// The most recent batch is held back until the job count has been set. This guarantees that the worker
+// processing the final queue job sees the job count and is able to mark the job as completed.
+// If any of the queue jobs cannot be enqueued, the job is marked as failed.
+// The priority of each batch is computed from priorityReq using the batch's resource type.
+func (h *Handler) enqueueQueJobs(jobID uint, priorityReq service.PriorityRequest, plan service.QueJobPlan) {
+	// The request has already completed so we cannot rely on its context
+	ctx := context.Background()
 
-		if err = h.Enq.AddJob(*j, int(jobPriority)); err != nil {
-			log.Error(err)
-			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
-				responseutils.InternalErr, "")
-			responseutils.WriteError(oo, w, http.StatusInternalServerError)
-			return
+	var pending []*models.JobEnqueueArgs
+	addJobs := func(jobs []*models.JobEnqueueArgs) error {
+		priorityReq.ResourceType = jobs[0].ResourceType
+		priority := int(h.Svc.GetJobPriority(priorityReq))
+		// Planned queue jobs are recorded first, allowing queue jobs lost from the queue to be re-enqueued
+		if err := h.r.CreatePlannedQueueJobs(ctx, jobID, jobs, priority); err != nil {
+			return err
+		}
+		return h.Enq.AddJobs(jobs, priority)
+	}
+
+	count, err := plan.Execute(ctx, func(jobs []*models.JobEnqueueArgs) error {
+		if pending != nil {
+			if err := addJobs(pending); err != nil {
+				return err
+			}
 		}
+		pending = jobs
+		return nil
+	})
+	if err == nil {
+		err = h.r.UpdateJobCount(ctx, jobID, count)
+	}
+	if err == nil && pending != nil {
+		err = addJobs(pending)
+	}
+
+	if err != nil {
+		log.Errorf("Failed to enqueue queue jobs for job %d: %s", jobID, err.Error())
+		if err := h.r.UpdateJobStatus(ctx, jobID, models.JobStatusFailed); err != nil {
+			log.Errorf("Failed to mark job %d as failed: %s", jobID, err.Error())
+		}
+		return
 	}
+
+	log.Infof("Enqueued %d queue jobs for job %d", count, jobID)
 }
 
 func (h *Handler) validateRequest(r *http.Request) ([]string, *fhirmodels.OperationOutcome) {
@@ -377,6 +586,15 @@ func (h *Handler) validateRequest(r *http.Request) ([]string, *fhirmodels.Operat
 		}
 	}
 
+	// validate optional "runoutYear" parameter
+	params, ok = r.URL.Query()["runoutYear"]
+	if ok {
+		if year, err := strconv.Atoi(params[0]); err != nil || year < 2000 || year > time.Now().Year() {
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION, responseutils.FormatErr, "Invalid runoutYear parameter. runoutYear must be a four digit performance year that has already started.")
+			return nil, oo
+		}
+	}
+
 	//validate "_outputFormat" parameter
 	params, ok = r.URL.Query()["_outputFormat"]
 	if ok {
@@ -474,7 +692,8 @@ func GetJobTimeout() time.Duration {
 }
 
 func getVersion(url *url.URL) (string, error) {
-	re := regexp.MustCompile(`\/api\/(.*)\/[Patient|Group].*`)
+	// Only capture the version segment so ACO-selected paths (/api/v1/ACO/{cmsId}/...) share a version with their counterparts
+	re := regexp.MustCompile(`\/api\/(v\d+)\/`)
 	parts := re.FindStringSubmatch(url.Path)
 	if len(parts) != 2 {
 		return "", fmt.Errorf("unexpected path provided %s", url.Path)
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -19,7 +20,9 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres/postgrestest"
+	"github.com/CMSgov/bcda-app/bcda/responseutils"
 	"github.com/CMSgov/bcda-app/bcda/service"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/conf"
 
 	"github.com/go-chi/chi"
@@ -66,7 +69,6 @@ func (s *RequestsTestSuite) TearDownTest() {
 
 func (s *RequestsTestSuite) TestRunoutEnabled() {
 	conf.SetEnv(s.T(), "BCDA_ENABLE_RUNOUT", "true")
-	qj := []*models.JobEnqueueArgs{}
 	tests := []struct {
 		name string
 
@@ -75,24 +77,28 @@ func (s *RequestsTestSuite) TestRunoutEnabled() {
 	}{
 		{"Successful", nil, http.StatusAccepted},
 		{"No CCLF file found", service.CCLFNotFoundError{}, http.StatusNotFound},
+		{"Limited access", service.LimitedAccessError{CMSID: "A0000", Reason: "access ended"}, http.StatusForbidden},
 		{"Some other error", errors.New("Some other error"), http.StatusInternalServerError},
 	}
 
 	for _, tt := range tests {
 		s.T().Run(tt.name, func(t *testing.T) {
 			mockSvc := &service.MockService{}
-			var jobs []*models.JobEnqueueArgs
+			var plan service.QueJobPlan
 			if tt.errToReturn == nil {
-				jobs = qj
+				mockPlan := &service.MockQueJobPlan{}
+				mockPlan.On("Execute", mock.Anything, mock.Anything).Return(0, nil)
+				plan = mockPlan
 			}
 
-			mockSvc.On("GetQueJobs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(jobs, tt.errToReturn)
+			mockSvc.On("PlanQueJobs", mock.Anything, mock.Anything).Return(plan, tt.errToReturn)
 			h := NewHandler([]string{"ExplanationOfBenefit", "Coverage", "Patient"}, "/v1/fhir")
 			h.Svc = mockSvc
 
 			req := s.genGroupRequest("runout")
 			w := httptest.NewRecorder()
 			h.BulkGroupRequest(w, req)
+			h.planners.Wait()
 
 			resp := w.Result()
 			body, err := ioutil.ReadAll(resp.Body)
@@ -108,6 +114,50 @@ func (s *RequestsTestSuite) TestRunoutEnabled() {
 	}
 }
 
+func (s *RequestsTestSuite) TestRunoutYear() {
+	conf.SetEnv(s.T(), "BCDA_ENABLE_RUNOUT", "true")
+	tests := []struct {
+		name       string
+		runoutYear string
+		planErr    error
+		respCode   int
+		expYear    int
+	}{
+		{"Valid year", "2020", nil, http.StatusAccepted, 2020},
+		{"Unconfigured year", "2019", service.RunoutYearNotConfiguredError{PerformanceYear: 2019}, http.StatusBadRequest, 2019},
+		{"Two digit year", "20", nil, http.StatusBadRequest, 0},
+		{"Future year", strconv.Itoa(time.Now().Year() + 1), nil, http.StatusBadRequest, 0},
+		{"Not a number", "twenty", nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			mockPlan := &service.MockQueJobPlan{}
+			mockPlan.On("Execute", mock.Anything, mock.Anything).Return(0, nil)
+			mockSvc := &service.MockService{}
+			mockSvc.On("PlanQueJobs", mock.Anything, mock.MatchedBy(func(c service.RequestConditions) bool {
+				return c.RunoutYear == tt.expYear
+			})).Return(mockPlan, tt.planErr)
+			h := NewHandler([]string{"ExplanationOfBenefit", "Coverage", "Patient"}, "/v1/fhir")
+			h.Svc = mockSvc
+
+			req := s.genGroupRequest("runout")
+			req.URL.RawQuery = url.Values{"runoutYear": []string{tt.runoutYear}}.Encode()
+			w := httptest.NewRecorder()
+			h.BulkGroupRequest(w, req)
+			h.planners.Wait()
+
+			assert.Equal(t, tt.respCode, w.Result().StatusCode)
+			if tt.respCode == http.StatusBadRequest {
+				assert.Contains(t, w.Body.String(), "Invalid runoutYear parameter")
+			}
+			if tt.expYear == 0 {
+				mockSvc.AssertNotCalled(t, "PlanQueJobs", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
 func (s *RequestsTestSuite) TestRunoutDisabled() {
 	conf.SetEnv(s.T(), "BCDA_ENABLE_RUNOUT", "false")
 	req := s.genGroupRequest("runout")
@@ -123,6 +173,102 @@ func (s *RequestsTestSuite) TestRunoutDisabled() {
 	s.Contains(string(body), "Invalid group ID")
 }
 
+func (s *RequestsTestSuite) TestAttributionChanges() {
+	since := time.Now().Add(-24 * time.Hour).Round(time.Second)
+	changes := &service.AttributionChanges{CMSID: "ZYXWV", AddedCount: 1, Added: []string{"MBI1"}, Removed: []string{}}
+
+	tests := []struct {
+		name    string
+		groupID string
+		query   string
+
+		errToReturn error
+		respCode    int
+		expBody     string
+	}{
+		{"Successful", "all", "", nil, http.StatusOK, `"added":["MBI1"]`},
+		{"Successful with since", "all", "?_since=" + since.Format(time.RFC3339Nano), nil, http.StatusOK, `"addedCount":1`},
+		{"Invalid group", "runout", "", nil, http.StatusBadRequest, "Invalid group ID"},
+		{"Invalid since", "all", "?_since=yesterday", nil, http.StatusBadRequest, "Invalid date format supplied in _since parameter"},
+		{"No CCLF file found", "all", "", service.CCLFNotFoundError{}, http.StatusNotFound, "no CCLF0 file found"},
+		{"Some other error", "all", "", errors.New("Some other error"), http.StatusInternalServerError, "Internal Error"},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			mockSvc := &service.MockService{}
+			mockSvc.On("GetAttributionChanges", mock.Anything, mock.MatchedBy(func(conditions service.RequestConditions) bool {
+				return conditions.CMSID == "ZYXWV" && uuid.Equal(conditions.ACOID, s.acoID) &&
+					(tt.query == "") == conditions.Since.IsZero()
+			})).Return(changes, tt.errToReturn)
+			h := &Handler{Svc: mockSvc}
+
+			req := s.genGroupRequest(tt.groupID)
+			req.URL.RawQuery = strings.TrimPrefix(tt.query, "?")
+			w := httptest.NewRecorder()
+			h.AttributionChanges(w, req)
+
+			resp := w.Result()
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.respCode, resp.StatusCode)
+			assert.Contains(t, string(body), tt.expBody)
+		})
+	}
+}
+
+func (s *RequestsTestSuite) TestGroupRoster() {
+	timestamp := time.Date(2020, time.December, 1, 10, 0, 0, 0, time.UTC)
+	roster := &service.AttributionRoster{CMSID: "ZYXWV", MBIs: []string{"MBI1"}, PerformanceYear: 20,
+		File: service.AttributionFile{Timestamp: timestamp}}
+
+	tests := []struct {
+		name    string
+		groupID string
+
+		errToReturn error
+		respCode    int
+		expBody     string
+	}{
+		{"Successful", "all", nil, http.StatusOK, "MBI1"},
+		{"Invalid group", "runout", nil, http.StatusBadRequest, "Invalid group ID"},
+		{"No CCLF file found", "all", service.CCLFNotFoundError{}, http.StatusNotFound, "no CCLF0 file found"},
+		{"Some other error", "all", errors.New("Some other error"), http.StatusInternalServerError, "Internal Error"},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			mockSvc := &service.MockService{}
+			mockSvc.On("GetAttributionRoster", mock.Anything, mock.MatchedBy(func(conditions service.RequestConditions) bool {
+				return conditions.CMSID == "ZYXWV" && uuid.Equal(conditions.ACOID, s.acoID)
+			})).Return(roster, tt.errToReturn)
+			h := &Handler{Svc: mockSvc}
+
+			var written *responseutils.GroupRoster
+			w := httptest.NewRecorder()
+			h.GroupRoster(w, s.genGroupRequest(tt.groupID), func(w http.ResponseWriter, r responseutils.GroupRoster) {
+				written = &r
+				_, err := w.Write([]byte(strings.Join(r.MBIs, ",")))
+				assert.NoError(t, err)
+			})
+
+			resp := w.Result()
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.respCode, resp.StatusCode)
+			assert.Contains(t, string(body), tt.expBody)
+			if tt.respCode == http.StatusOK {
+				assert.Equal(t, &responseutils.GroupRoster{CMSID: "ZYXWV", MBIs: []string{"MBI1"},
+					Start:       time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+					End:         time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC),
+					LastUpdated: timestamp, Active: true}, written)
+			} else {
+				assert.Nil(t, written)
+			}
+		})
+	}
+}
+
 func (s *RequestsTestSuite) TestInvalidRequests() {
 	supportedTypes := []string{"ExplanationOfBenefit", "Coverage", "Patient"}
 	h := NewHandler(supportedTypes, "/v1/fhir")
@@ -159,6 +305,7 @@ func (s *RequestsTestSuite) TestInvalidRequests() {
 		{"Invalid output format (application/xml)", reqParams{outputFormat: "application/xml"}, nil, "_outputFormat parameter must be application/fhir+ndjson, application/ndjson, or ndjson"},
 		{"Invalid output format (x-custom)", reqParams{outputFormat: "x-custom"}, nil, "_outputFormat parameter must be application/fhir+ndjson, application/ndjson, or ndjson"},
 		{"Invalid query parameter (extra ?)", reqParams{}, map[string]string{"?_since": "2020-09-13T08:00:00.000-05:00"}, "Invalid parameter: query parameters cannot start with ?"},
+		{"Runout year outside of runout group", reqParams{}, map[string]string{"runoutYear": "2020"}, "Invalid parameter: runoutYear is only supported by the runout group"},
 	}
 
 	for _, tt := range tests {
@@ -254,17 +401,172 @@ func (s *RequestsTestSuite) TestBulkRequestWithOldJobPaths() {
 
 	resources := []string{"ExplanationOfBenefit", "Coverage", "Patient"}
 	mockSvc := &service.MockService{}
-	mockSvc.On("GetQueJobs", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	mockPlan := &service.MockQueJobPlan{}
+	mockPlan.On("Execute", mock.Anything, mock.Anything).Return(0, nil)
+	mockSvc.On("PlanQueJobs", mock.Anything, mock.Anything).Return(mockPlan, nil)
 	h := NewHandler(resources, "/v1/fhir")
 	h.Svc = mockSvc
 
 	req := s.genGroupRequest("all")
 	w := httptest.NewRecorder()
 	h.bulkRequest(resources, w, req, service.RetrieveNewBeneHistData)
+	h.planners.Wait()
 
 	s.Equal(http.StatusAccepted, w.Result().StatusCode)
 }
 
+// TestBulkRequestMultipleACOsWithoutSelection verifies that credentials serving multiple ACOs
+// must select an ACO before starting an export.
+func (s *RequestsTestSuite) TestBulkRequestMultipleACOsWithoutSelection() {
+	resources := []string{"ExplanationOfBenefit", "Coverage", "Patient"}
+	mockSvc := &service.MockService{}
+	h := NewHandler(resources, "/v1/fhir")
+	h.Svc = mockSvc
+
+	req := httptest.NewRequest("GET", "http://bcda.cms.gov/api/v1/Patient/$export", nil)
+	ad := auth.AuthData{TokenID: uuid.NewRandom().String(),
+		ACOs: []auth.AuthorizedACO{{ACOID: s.acoID.String(), CMSID: "A0000"}, {ACOID: uuid.New(), CMSID: "A0001"}}}
+	req = req.WithContext(context.WithValue(req.Context(), auth.AuthDataContextKey, ad))
+	w := httptest.NewRecorder()
+	h.BulkPatientRequest(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Result().StatusCode)
+	s.Contains(w.Body.String(), "Credential is authorized for multiple ACOs. Select an ACO using /api/v1/ACO/{cmsId}/...")
+	mockSvc.AssertNotCalled(s.T(), "PlanQueJobs", mock.Anything, mock.Anything)
+}
+
+// TestEnqueueQueJobs verifies the job count is set before the final batch of queue jobs is enqueued
+// and that the job is marked as failed when its queue jobs cannot be enqueued.
+func (s *RequestsTestSuite) TestEnqueueQueJobs() {
+	jobID, cmsID := uint(1234), "A0000"
+	batches := [][]*models.JobEnqueueArgs{
+		{{ID: int(jobID), ResourceType: "Patient"}},
+		{{ID: int(jobID), ResourceType: "ExplanationOfBenefit"}, {ID: int(jobID), ResourceType: "ExplanationOfBenefit"}},
+	}
+
+	tests := []struct {
+		name       string
+		executeErr error
+		enqueueErr error
+		failed     bool
+	}{
+		{"Successful", nil, nil, false},
+		{"Plan failed", errors.New("plan error"), nil, true},
+		{"Enqueue failed", nil, errors.New("enqueue error"), true},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			var calls []string
+			record := func(name string) func(mock.Arguments) {
+				return func(mock.Arguments) { calls = append(calls, name) }
+			}
+
+			mockPlan := &service.MockQueJobPlan{}
+			mockPlan.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				enqueue := args.Get(1).(func([]*models.JobEnqueueArgs) error)
+				for _, batch := range batches {
+					if err := enqueue(batch); err != nil {
+						return
+					}
+				}
+			}).Return(3, tt.executeErr)
+
+			mockSvc := &service.MockService{}
+			mockSvc.On("GetJobPriority", mock.Anything).Return(int16(10))
+			mockEnq := &queueing.MockEnqueuer{}
+			mockEnq.On("AddJobs", batches[0], 10).Run(record("AddJobs Patient")).Return(tt.enqueueErr)
+			mockEnq.On("AddJobs", batches[1], 10).Run(record("AddJobs ExplanationOfBenefit")).Return(nil)
+			mockRepo := &models.MockRepository{}
+			mockRepo.On("CreatePlannedQueueJobs", mock.Anything, jobID, mock.Anything, 10).Return(nil)
+			mockRepo.On("UpdateJobCount", mock.Anything, jobID, 3).Run(record("UpdateJobCount")).Return(nil)
+			mockRepo.On("UpdateJobStatus", mock.Anything, jobID, models.JobStatusFailed).Run(record("UpdateJobStatus")).Return(nil)
+
+			h := &Handler{Svc: mockSvc, Enq: mockEnq, r: mockRepo}
+			h.enqueueQueJobs(jobID, service.PriorityRequest{CMSID: cmsID, Since: true}, mockPlan)
+
+			if tt.failed {
+				assert.Contains(t, calls, "UpdateJobStatus")
+				assert.NotContains(t, calls, "UpdateJobCount")
+			} else {
+				assert.Equal(t, []string{"AddJobs Patient", "UpdateJobCount", "AddJobs ExplanationOfBenefit"}, calls)
+			}
+		})
+	}
+}
+
+// TestEnqueueQueJobsPriority verifies that each batch of queue jobs is enqueued in its resource type's tier.
+func (s *RequestsTestSuite) TestEnqueueQueJobsPriority() {
+	jobID, cmsID := uint(1234), "A0000"
+	batches := [][]*models.JobEnqueueArgs{
+		{{ID: int(jobID), ResourceType: "ExplanationOfBenefit"}, {ID: int(jobID), ResourceType: "ExplanationOfBenefit"}},
+		{{ID: int(jobID), ResourceType: "Patient"}},
+		{{ID: int(jobID), ResourceType: "ExplanationOfBenefit"}},
+	}
+
+	mockPlan := &service.MockQueJobPlan{}
+	mockPlan.On("Execute", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		enqueue := args.Get(1).(func([]*models.JobEnqueueArgs) error)
+		for _, batch := range batches {
+			s.NoError(enqueue(batch))
+		}
+	}).Return(4, nil)
+
+	mockSvc := &service.MockService{}
+	mockSvc.On("GetJobPriority", service.PriorityRequest{CMSID: cmsID, ResourceType: "ExplanationOfBenefit"}).Return(int16(100))
+	mockSvc.On("GetJobPriority", service.PriorityRequest{CMSID: cmsID, ResourceType: "Patient"}).Return(int16(20))
+	mockEnq := &queueing.MockEnqueuer{}
+	mockEnq.On("AddJobs", batches[0], 100).Return(nil).Once()
+	mockEnq.On("AddJobs", batches[1], 20).Return(nil).Once()
+	mockEnq.On("AddJobs", batches[2], 100).Return(nil).Once()
+	mockRepo := &models.MockRepository{}
+	mockRepo.On("CreatePlannedQueueJobs", mock.Anything, jobID, batches[0], 100).Return(nil).Once()
+	mockRepo.On("CreatePlannedQueueJobs", mock.Anything, jobID, batches[1], 20).Return(nil).Once()
+	mockRepo.On("CreatePlannedQueueJobs", mock.Anything, jobID, batches[2], 100).Return(nil).Once()
+	mockRepo.On("UpdateJobCount", mock.Anything, jobID, 4).Return(nil)
+
+	h := &Handler{Svc: mockSvc, Enq: mockEnq, r: mockRepo}
+	h.enqueueQueJobs(jobID, service.PriorityRequest{CMSID: cmsID}, mockPlan)
+
+	mockEnq.AssertExpectations(s.T())
+	mockRepo.AssertExpectations(s.T())
+}
+
+func (s *RequestsTestSuite) TestWaitForPlanners() {
+	h := &Handler{}
+	assert.True(s.T(), h.WaitForPlanners(time.Second))
+
+	h.planners.Add(1)
+	assert.False(s.T(), h.WaitForPlanners(10*time.Millisecond))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		h.planners.Done()
+	}()
+	assert.True(s.T(), h.WaitForPlanners(time.Second))
+}
+
+func (s *RequestsTestSuite) TestGetVersion() {
+	tests := []struct {
+		path    string
+		version string
+	}{
+		{"/api/v1/Patient/$export", "v1"},
+		{"/api/v1/Group/all/$export", "v1"},
+		{"/api/v2/ACO/A0000/Group/runout/$export", "v2"},
+		{"/api/v1/ACO/A0000/Patient/$export", "v1"},
+	}
+
+	for _, tt := range tests {
+		version, err := getVersion(&url.URL{Path: tt.path})
+		s.NoError(err)
+		s.Equal(tt.version, version, tt.path)
+	}
+
+	_, err := getVersion(&url.URL{Path: "/api/Group/$export"})
+	s.EqualError(err, "unexpected path provided /api/Group/$export")
+}
+
 func (s *RequestsTestSuite) genGroupRequest(groupID string) *http.Request {
 	req := httptest.NewRequest("GET", "http://bcda.cms.gov/api/v1/Group/$export", nil)
 
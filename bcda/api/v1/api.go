@@ -34,6 +34,12 @@ func init() {
 	h = api.NewHandler([]string{"Patient", "Coverage", "ExplanationOfBenefit"}, "/v1/fhir")
 }
 
+// WaitForPlanners waits for the export requests to finish enqueueing their queue jobs, returning false
+// if they did not finish within the timeout
+func WaitForPlanners(timeout time.Duration) bool {
+	return h.WaitForPlanners(timeout)
+}
+
 /*
 	swagger:route GET /api/v1/Patient/$export bulkData bulkPatientRequest
 
@@ -67,7 +73,7 @@ func BulkPatientRequest(w http.ResponseWriter, r *http.Request) {
 
 	The `all` identifier returns data for the group of all patients attributed to the requesting ACO.  If used when specifying `_since`: all claims data which has been updated since the specified date will be returned for beneficiaries which have been attributed to the ACO since before the specified date; and all historical claims data will be returned for beneficiaries which have been newly attributed to the ACO since the specified date.
 
-	The `runout` identifier returns claims runouts data.
+	The `runout` identifier returns claims runouts data. Use the `runoutYear` parameter to request the runout data for a specific performance year; otherwise the most recent runout data is returned.
 
 	Produces:
 	- application/fhir+json
@@ -86,6 +92,60 @@ func BulkGroupRequest(w http.ResponseWriter, r *http.Request) {
 	h.BulkGroupRequest(w, r)
 }
 
+/*
+	swagger:route GET /api/v1/Group/{groupId} attribution groupRoster
+
+	Get the beneficiaries attributed to the specified group identifier
+
+	Returns a FHIR Group resource identifying the beneficiaries, by MBI, that are attributed to your ACO in its latest CCLF8 delivery. Beneficiaries who have opted out of data sharing are excluded. The only supported Group identifier is `all`.
+
+	Produces:
+	- application/json
+
+	Security:
+		bearer_token:
+
+	Responses:
+		200: GroupResponse
+		400: badRequestResponse
+		401: invalidCredentials
+		403: forbiddenResponse
+		404: notFoundResponse
+		500: errorResponse
+*/
+func GroupRoster(w http.ResponseWriter, r *http.Request) {
+	h.GroupRoster(w, r, func(w http.ResponseWriter, roster responseutils.GroupRoster) {
+		responseutils.WriteGroup(responseutils.CreateGroup(roster), w)
+	})
+}
+
+/*
+	swagger:route GET /api/v1/Group/{groupId}/$attribution-changes attribution attributionChanges
+
+	Get attribution changes for the specified group identifier
+
+	Returns the MBIs of beneficiaries added to and removed from your ACO's attribution between two CCLF8 deliveries. The only supported Group identifier is `all`.
+
+	When `_since` is specified, the latest delivery is compared with the delivery in effect at that time; otherwise it is compared with the delivery that preceded it.
+
+	Produces:
+	- application/json
+
+	Security:
+		bearer_token:
+
+	Responses:
+		200: attributionChangesResponse
+		400: badRequestResponse
+		401: invalidCredentials
+		403: forbiddenResponse
+		404: notFoundResponse
+		500: errorResponse
+*/
+func AttributionChanges(w http.ResponseWriter, r *http.Request) {
+	h.AttributionChanges(w, r)
+}
+
 /*
 	swagger:route GET /api/v1/jobs/{jobId} job jobStatus
 
@@ -14,6 +14,7 @@ import (
 
 	api "github.com/CMSgov/bcda-app/bcda/api"
 	"github.com/CMSgov/bcda-app/bcda/constants"
+	"github.com/CMSgov/bcda-app/bcda/responseutils"
 	"github.com/CMSgov/bcda-app/bcda/servicemux"
 	"github.com/CMSgov/bcda-app/conf"
 
@@ -37,6 +38,12 @@ func init() {
 	}
 }
 
+// WaitForPlanners waits for the export requests to finish enqueueing their queue jobs, returning false
+// if they did not finish within the timeout
+func WaitForPlanners(timeout time.Duration) bool {
+	return h.WaitForPlanners(timeout)
+}
+
 /*
 	swagger:route GET /api/v2/Patient/$export bulkDataV2 bulkPatientRequestV2
 
@@ -70,7 +77,7 @@ func BulkPatientRequest(w http.ResponseWriter, r *http.Request) {
 
 	The `all` identifier returns data for the group of all patients attributed to the requesting ACO.  If used when specifying `_since`: all claims data which has been updated since the specified date will be returned for beneficiaries which have been attributed to the ACO since before the specified date; and all historical claims data will be returned for beneficiaries which have been newly attributed to the ACO since the specified date.
 
-	The `runout` identifier returns claims runouts data.
+	The `runout` identifier returns claims runouts data. Use the `runoutYear` parameter to request the runout data for a specific performance year; otherwise the most recent runout data is returned.
 
 	Produces:
 	- application/fhir+json
@@ -89,6 +96,51 @@ func BulkGroupRequest(w http.ResponseWriter, r *http.Request) {
 	h.BulkGroupRequest(w, r)
 }
 
+/*
+	swagger:route GET /api/v2/Group/{groupId} attributionV2 groupRosterV2
+
+	Get the beneficiaries attributed to the specified group identifier
+
+	Returns a FHIR R4 Group resource identifying the beneficiaries, by MBI, that are attributed to your ACO in its latest CCLF8 delivery. Beneficiaries who have opted out of data sharing are excluded. The only supported Group identifier is `all`.
+
+	Produces:
+	- application/json
+
+	Security:
+		bearer_token:
+
+	Responses:
+		200: GroupResponse
+		400: badRequestResponse
+		401: invalidCredentials
+		403: forbiddenResponse
+		404: notFoundResponse
+		500: errorResponse
+*/
+func GroupRoster(w http.ResponseWriter, r *http.Request) {
+	h.GroupRoster(w, r, writeGroup)
+}
+
+func writeGroup(w http.ResponseWriter, roster responseutils.GroupRoster) {
+	group := responseutils.CreateGroupR4(roster)
+
+	resource := &fhirresources.ContainedResource{
+		OneofResource: &fhirresources.ContainedResource_Group{Group: group},
+	}
+	b, err := marshaller.Marshal(resource)
+	if err != nil {
+		log.Errorf("Failed to marshal Group %s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(b); err != nil {
+		log.Errorf("Failed to write data %s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 /*
 	swagger:route GET /api/v2/metadata metadataV2 metadata
 
@@ -16,6 +16,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres/postgrestest"
+	"github.com/CMSgov/bcda-app/bcda/responseutils"
 	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/conf"
 
@@ -123,6 +124,43 @@ func (s *APITestSuite) TestMetadataResponse() {
 
 }
 
+func (s *APITestSuite) TestWriteGroup() {
+	timestamp := time.Date(2020, time.December, 1, 10, 0, 0, 0, time.UTC)
+	roster := responseutils.GroupRoster{
+		CMSID:       "A0000",
+		MBIs:        []string{"MBI1", "MBI2"},
+		Start:       time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		End:         time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC),
+		LastUpdated: timestamp,
+		Active:      true,
+	}
+	rr := httptest.NewRecorder()
+	writeGroup(rr, roster)
+
+	assert.Equal(s.T(), "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+
+	unmarshaller, err := jsonformat.NewUnmarshaller("UTC", jsonformat.R4)
+	assert.NoError(s.T(), err)
+	resource, err := unmarshaller.Unmarshal(rr.Body.Bytes())
+	assert.NoError(s.T(), err)
+	group := resource.(*fhirresources.ContainedResource).GetGroup()
+
+	assert.Equal(s.T(), "all", group.Id.Value)
+	assert.Equal(s.T(), timestamp.UnixNano()/int64(time.Microsecond), group.Meta.LastUpdated.ValueUs)
+	assert.True(s.T(), group.Active.Value)
+	assert.Equal(s.T(), fhircodes.GroupTypeCode_PERSON, group.Type.Value)
+	assert.Equal(s.T(), uint32(2), group.Quantity.Value)
+	assert.Len(s.T(), group.Member, 2)
+	for i, mbi := range roster.MBIs {
+		member := group.Member[i]
+		assert.Equal(s.T(), responseutils.MBISystem, member.Entity.Identifier.System.Value)
+		assert.Equal(s.T(), mbi, member.Entity.Identifier.Value.Value)
+		assert.Equal(s.T(), time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC).UnixNano()/int64(time.Microsecond),
+			member.Period.End.ValueUs)
+	}
+}
+
 func (s *APITestSuite) TestResourceTypes() {
 	tests := []struct {
 		name          string
@@ -70,6 +70,8 @@ func (p AlphaAuthPlugin) RegisterSystem(localID, publicKey, groupID string, ips
 		return Credentials{}, err
 	}
 
+	saveCredential(p.Repository, aco, aco.ClientID, "")
+
 	regEvent.clientID = aco.ClientID
 	operationSucceeded(regEvent)
 	return Credentials{ClientName: aco.Name, ClientID: localID, ClientSecret: s}, nil
@@ -111,6 +113,8 @@ func (p AlphaAuthPlugin) DeleteSystem(clientID string) error {
 		return err
 	}
 
+	revokeCredential(repository, clientID)
+
 	operationSucceeded(delEvent)
 	return nil
 }
@@ -157,6 +161,8 @@ func (p AlphaAuthPlugin) ResetSecret(clientID string) (Credentials, error) {
 		return Credentials{}, err
 	}
 
+	saveCredential(p.Repository, aco, clientID, "")
+
 	operationSucceeded(genEvent)
 	return Credentials{ClientName: aco.Name, ClientID: clientID, ClientSecret: s}, nil
 }
@@ -169,31 +175,39 @@ func (p AlphaAuthPlugin) GetVersion() (string, error) {
 	return "", errors.New("not yet implemented")
 }
 
+func (p AlphaAuthPlugin) ListCredentials(cmsID string) ([]CredentialInfo, error) {
+	return listCredentials(p.Repository, cmsID)
+}
+
 // MakeAccessToken manufactures an access token for the given credentials
 func (p AlphaAuthPlugin) MakeAccessToken(credentials Credentials) (string, error) {
 	tknEvent := event{op: "MakeAccessToken", trackingID: credentials.ClientID}
 	if credentials.ClientSecret == "" || credentials.ClientID == "" {
 		tknEvent.help = "missing or incomplete credentials"
 		operationFailed(tknEvent)
-		return "", fmt.Errorf("missing or incomplete credentials")
+		return "", fmt.Errorf("missing or incomplete credentials: %w", ErrInvalidCredentials)
 	}
 
 	if uuid.Parse(credentials.ClientID) == nil {
 		tknEvent.help = "missing or incomplete credentials"
 		operationFailed(tknEvent)
-		return "", fmt.Errorf("ClientID must be a valid UUID")
+		return "", fmt.Errorf("ClientID must be a valid UUID: %w", ErrInvalidCredentials)
 	}
 
 	aco, err := p.Repository.GetACOByClientID(context.Background(), credentials.ClientID)
 	if err != nil {
 		tknEvent.help = err.Error()
 		operationFailed(tknEvent)
-		return "", fmt.Errorf("invalid credentials; %s", err)
+		var notFound *models.NotFoundError
+		if errors.As(err, &notFound) {
+			return "", fmt.Errorf("%w; %s", ErrInvalidCredentials, err)
+		}
+		return "", fmt.Errorf("failed to retrieve ACO; %s", err)
 	}
 	if !Hash(aco.AlphaSecret).IsHashOf(credentials.ClientSecret) {
 		tknEvent.help = "IsHashOf failed"
 		operationFailed(tknEvent)
-		return "", fmt.Errorf("invalid credentials")
+		return "", ErrInvalidCredentials
 	}
 	issuedAt := time.Now().Unix()
 	expiresAt := time.Now().Add(TokenTTL).Unix()
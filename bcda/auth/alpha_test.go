@@ -173,6 +173,7 @@ func (s *AlphaAuthPluginTestSuite) TestAccessToken() {
 	assert.NotNil(s.T(), err)
 	assert.Empty(s.T(), ts)
 	assert.Contains(s.T(), err.Error(), "invalid credentials")
+	assert.ErrorIs(s.T(), err, auth.ErrInvalidCredentials)
 }
 
 func (s *AlphaAuthPluginTestSuite) TestRevokeAccessToken() {
@@ -1,8 +1,18 @@
 package auth
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
 )
 
 /*
@@ -11,6 +21,7 @@ import (
 	Get access token
 
 	Verifies Basic authentication credentials, and returns a JWT bearer token that can be presented to the other API endpoints.
+	Repeated failures lock out the client ID and source IP address for an increasing amount of time.
 
 	Produces:
 	- application/json
@@ -24,6 +35,7 @@ import (
 		200: tokenResponse
 		400: missingCredentials
 		401: invalidCredentials
+		429: lockedOut
 		500: serverError
 */
 func GetAuthToken(w http.ResponseWriter, r *http.Request) {
@@ -33,11 +45,27 @@ func GetAuthToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check for a lockout before verifying the secret to avoid spending time hashing on behalf of an attacker
+	ipAddress := sourceIP(r)
+	if until := lockedOut(repository, "GetAuthToken", clientId, ipAddress); !until.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
 	token, err := GetProvider().MakeAccessToken(Credentials{ClientID: clientId, ClientSecret: secret})
-	if err != nil {
+	if errors.Is(err, ErrInvalidCredentials) {
+		recordFailedAttempt(repository, "GetAuthToken", clientId, ipAddress)
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
+	} else if err != nil {
+		// The credentials could not be verified, which is not counted against the client
+		logger.Errorf("Failed to make access token for %s; %s", clientId, err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
 	}
+	clearFailedAttempts(repository, clientId)
+	recordTokenIssued(repository, clientId)
 
 	// https://tools.ietf.org/html/rfc6749#section-5.1
 	// not included: recommended field expires_in
@@ -74,3 +102,136 @@ func Welcome(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write([]byte(`{"success":"Welcome to the Beneficiary Claims Data API!"}`))
 }
+
+// introspectionResponse contains the fields defined in https://tools.ietf.org/html/rfc7662#section-2.2
+// along with the BCDA specific identifiers associated with the token.
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	SystemID  string `json:"system_id,omitempty"`
+	CMSID     string `json:"cms_id,omitempty"`
+	// CMSIDs is populated instead of CMSID for credentials serving multiple ACOs
+	CMSIDs []string `json:"cms_ids,omitempty"`
+}
+
+/*
+	swagger:route POST /auth/introspect auth IntrospectToken
+
+	Introspect access token
+
+	Reports whether the access token supplied in the token form parameter is active, along with the client, system, and ACO it was issued to.
+	Requires the admin credentials.
+
+	Consumes:
+	- application/x-www-form-urlencoded
+
+	Produces:
+	- application/json
+
+	Schemes: https
+
+	Security:
+		basic_auth:
+
+	Responses:
+		200: introspectionResponse
+		400: missingCredentials
+		401: invalidCredentials
+		500: serverError
+*/
+func IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tokenString := r.PostForm.Get("token")
+	if tokenString == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	body, err := json.Marshal(introspect(GetProvider(), repository, tokenString))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	_, err = w.Write(body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// introspect describes the token. Any token that cannot be authorized, or whose credential has been revoked
+// (or cannot be confirmed as not revoked), is reported as inactive without any additional detail.
+func introspect(p Provider, r models.Repository, tokenString string) introspectionResponse {
+	inactive := introspectionResponse{Active: false}
+	if err := p.AuthorizeAccess(tokenString); err != nil {
+		return inactive
+	}
+
+	t, err := p.VerifyToken(tokenString)
+	if err != nil {
+		return inactive
+	}
+	claims, ok := t.Claims.(*CommonClaims)
+	if !ok {
+		return inactive
+	}
+
+	resp := introspectionResponse{
+		Active:    true,
+		ClientID:  claims.ClientID,
+		TokenType: "access_token",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.Id,
+		SystemID:  claims.SystemID,
+	}
+
+	switch claims.Issuer {
+	case "ssas":
+		ad, err := adFromClaims(r, claims)
+		if err != nil {
+			return inactive
+		}
+		resp.CMSID = ad.CMSID
+		for _, aco := range ad.ACOs {
+			resp.CMSIDs = append(resp.CMSIDs, aco.CMSID)
+		}
+	case "okta":
+		aco, err := r.GetACOByClientID(context.Background(), claims.ClientID)
+		if err != nil {
+			return inactive
+		}
+		resp.CMSID = *aco.CMSID
+	default:
+		aco, err := r.GetACOByUUID(context.Background(), uuid.Parse(claims.ACOID))
+		if err != nil {
+			return inactive
+		}
+		resp.ClientID, resp.TokenID, resp.CMSID = aco.ClientID, claims.UUID, *aco.CMSID
+	}
+
+	// Look up the credential by client ID alone, since tokens for credentials serving several ACOs carry no single CMS ID
+	revoked, err := isCredentialRevoked(r, resp.ClientID)
+	if err != nil {
+		logger.Errorf("Failed to check whether credential %s is revoked; %s", resp.ClientID, err.Error())
+		return inactive
+	}
+	if revoked {
+		return inactive
+	}
+
+	return resp
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
@@ -23,6 +25,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/testUtils"
+	"github.com/CMSgov/bcda-app/conf"
 )
 
 type TokenResponse struct {
@@ -175,6 +178,99 @@ func (s *AuthAPITestSuite) TestWelcome() {
 	assert.Equal(s.T(), "Welcome to the Beneficiary Claims Data API!", respMap["success"])
 }
 
+func (s *AuthAPITestSuite) TestAuthTokenLockout() {
+	conf.SetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD", "2")
+	defer conf.UnsetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD")
+
+	clientID, ipAddress := uuid.New(), testUtils.GetRandomIPV4Address(s.T())
+	defer func() {
+		assert.NoError(s.T(), s.r.DeleteAuthLockout(context.Background(), models.LockoutIPAddress, ipAddress))
+	}()
+
+	requestToken := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/auth/token", nil)
+		req.RemoteAddr = ipAddress + ":1234"
+		req.SetBasicAuth(clientID, "not_a_secret")
+		http.HandlerFunc(auth.GetAuthToken).ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(s.T(), http.StatusUnauthorized, requestToken().Code)
+	assert.Equal(s.T(), http.StatusUnauthorized, requestToken().Code)
+
+	rr := requestToken()
+	assert.Equal(s.T(), http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(s.T(), rr.Header().Get("Retry-After"))
+
+	// Clearing the client ID lockout still leaves the IP address locked out
+	assert.NoError(s.T(), auth.ClearLockout(models.LockoutClientID, clientID))
+	assert.Equal(s.T(), http.StatusTooManyRequests, requestToken().Code)
+}
+
+func (s *AuthAPITestSuite) TestIntrospectToken() {
+	ctx := context.Background()
+	aco, err := s.r.GetACOByUUID(ctx, uuid.Parse(constants.DevACOUUID))
+	assert.NoError(s.T(), err)
+
+	assert.NoError(s.T(), s.r.UpdateACO(ctx, aco.UUID,
+		map[string]interface{}{"alpha_secret": ""}))
+
+	creds, err := auth.GetProvider().RegisterSystem(constants.DevACOUUID, "", "")
+	assert.NoError(s.T(), err)
+
+	req := httptest.NewRequest("POST", "/auth/token", nil)
+	req.SetBasicAuth(creds.ClientID, creds.ClientSecret)
+	http.HandlerFunc(auth.GetAuthToken).ServeHTTP(s.rr, req)
+	assert.Equal(s.T(), http.StatusOK, s.rr.Code)
+	t := TokenResponse{}
+	assert.NoError(s.T(), json.NewDecoder(s.rr.Body).Decode(&t))
+
+	introspect := func(token string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		form := url.Values{}
+		if token != "" {
+			form.Set("token", token)
+		}
+		req := httptest.NewRequest("POST", "/auth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		http.HandlerFunc(auth.IntrospectToken).ServeHTTP(rr, req)
+
+		var body map[string]interface{}
+		if rr.Code == http.StatusOK {
+			assert.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &body))
+		}
+		return rr.Code, body
+	}
+
+	code, _ := introspect("")
+	assert.Equal(s.T(), http.StatusBadRequest, code)
+
+	code, body := introspect("not_a_token")
+	assert.Equal(s.T(), http.StatusOK, code)
+	assert.Equal(s.T(), map[string]interface{}{"active": false}, body)
+
+	code, body = introspect(t.AccessToken)
+	assert.Equal(s.T(), http.StatusOK, code)
+	assert.Equal(s.T(), true, body["active"])
+	assert.Equal(s.T(), creds.ClientID, body["client_id"])
+	assert.Equal(s.T(), *aco.CMSID, body["cms_id"])
+	assert.NotEmpty(s.T(), body["jti"])
+
+	infos, err := auth.GetProvider().ListCredentials(*aco.CMSID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), infos, 1)
+	assert.Equal(s.T(), creds.ClientID, infos[0].ClientID)
+	assert.NotNil(s.T(), infos[0].LastTokenIssuedAt)
+	assert.False(s.T(), infos[0].Revoked)
+
+	// Tokens issued to a revoked credential are no longer active
+	assert.NoError(s.T(), s.r.RevokeCredentialByClientID(ctx, creds.ClientID))
+	code, body = introspect(t.AccessToken)
+	assert.Equal(s.T(), http.StatusOK, code)
+	assert.Equal(s.T(), map[string]interface{}{"active": false}, body)
+}
+
 func TestAuthAPITestSuite(t *testing.T) {
 	suite.Run(t, new(AuthAPITestSuite))
 }
@@ -41,6 +41,10 @@ type Credentials struct {
 	ClientName   string
 }
 
+// ErrInvalidCredentials is wrapped by the errors returned when a token is refused because the client's
+// credentials are not valid, as opposed to the token request failing
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 func init() {
 	logger = logrus.New()
 	logger.Formatter = &logrus.JSONFormatter{}
@@ -199,6 +203,9 @@ func (oc *OktaClient) RequestAccessToken(creds Credentials) (OktaToken, error) {
 
 	if resp.StatusCode >= 400 {
 		err = errors.New(resp.Status)
+		if resp.StatusCode == http.StatusUnauthorized {
+			err = fmt.Errorf("%s: %w", resp.Status, ErrInvalidCredentials)
+		}
 		logError(err, requestID).WithField("client_id", creds.ClientID).Info("unable to get access token")
 		return OktaToken{}, err
 	}
@@ -11,7 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/CMSgov/bcda-app/conf"
@@ -97,9 +96,24 @@ func tlsTransport() (*http.Transport, error) {
 }
 
 // CreateGroup POSTs to the SSAS /group endpoint to create a system.
-func (c *SSASClient) CreateGroup(id, name, acoCMSID string) ([]byte, error) {
-	b := fmt.Sprintf(`{"group_id": "%s", "name": "%s", "scopes": ["bcda-api"], "xdata": "{\"cms_ids\": [\"%s\"]}"}`, id, name, acoCMSID)
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/group", c.baseURL), strings.NewReader(b))
+// Systems in a group created with several ACO CMS IDs are authorized for all of those ACOs.
+func (c *SSASClient) CreateGroup(id, name string, acoCMSIDs ...string) ([]byte, error) {
+	xData, err := json.Marshal(struct {
+		CMSIDs []string `json:"cms_ids"`
+	}{acoCMSIDs})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid ACOs for new group_id %s", id))
+	}
+	b, err := json.Marshal(struct {
+		GroupID string   `json:"group_id"`
+		Name    string   `json:"name"`
+		Scopes  []string `json:"scopes"`
+		XData   string   `json:"xdata"`
+	}{id, name, []string{"bcda-api"}, string(xData)})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid input for new group_id %s", id))
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/group", c.baseURL), bytes.NewReader(b))
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("invalid input for new group_id %s", id))
 	}
@@ -318,7 +332,9 @@ func (c *SSASClient) GetToken(credentials Credentials) ([]byte, error) {
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("token request failed; %v: %w", resp.StatusCode, ErrInvalidCredentials)
+	} else if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("token request failed; %v", resp.StatusCode)
 	}
 
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+)
+
+// CredentialInfo describes a credential issued to an ACO and where it is in its lifecycle.
+type CredentialInfo struct {
+	ClientID          string     `json:"client_id"`
+	SystemID          string     `json:"system_id,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastTokenIssuedAt *time.Time `json:"last_token_issued_at,omitempty"`
+	Revoked           bool       `json:"revoked"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+}
+
+func listCredentials(r models.Repository, cmsID string) ([]CredentialInfo, error) {
+	credentials, err := r.GetCredentials(context.Background(), cmsID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]CredentialInfo, 0, len(credentials))
+	for _, c := range credentials {
+		info := CredentialInfo{ClientID: c.ClientID, SystemID: c.SystemID, CreatedAt: c.CreatedAt}
+		if !c.LastTokenIssuedAt.IsZero() {
+			t := c.LastTokenIssuedAt
+			info.LastTokenIssuedAt = &t
+		}
+		if !c.RevokedAt.IsZero() {
+			t := c.RevokedAt
+			info.Revoked, info.RevokedAt = true, &t
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// isCredentialRevoked returns true if the credential identified by clientID has been revoked.
+// Credentials missing from the inventory are not considered revoked.
+func isCredentialRevoked(r models.Repository, clientID string) (bool, error) {
+	credential, err := r.GetCredentialByClientID(context.Background(), clientID)
+	if err != nil {
+		return false, err
+	}
+	return credential != nil && !credential.RevokedAt.IsZero(), nil
+}
+
+// The functions below keep the credential inventory up to date. The inventory is bookkeeping, so failures
+// are logged rather than returned to avoid failing the underlying credential operation.
+
+func saveCredential(r models.Repository, aco *models.ACO, clientID, systemID string) {
+	var cmsID string
+	if aco.CMSID != nil {
+		cmsID = *aco.CMSID
+	}
+
+	err := r.SaveCredential(context.Background(),
+		models.Credential{ClientID: clientID, SystemID: systemID, ACOCMSID: cmsID})
+	if err != nil {
+		logger.Errorf("Failed to save credential %s; %s", clientID, err.Error())
+	}
+}
+
+// recordTokenIssued records a token issued through /auth/token. Tokens requested from the auth provider
+// directly (bypassing BCDA) are not recorded, so the credential's last token issuance may be earlier than its latest token.
+func recordTokenIssued(r models.Repository, clientID string) {
+	if err := r.RecordTokenIssued(context.Background(), clientID, time.Now()); err != nil {
+		logger.Errorf("Failed to record token issued for credential %s; %s", clientID, err.Error())
+	}
+}
+
+func revokeCredential(r models.Repository, clientID string) {
+	if err := r.RevokeCredentialByClientID(context.Background(), clientID); err != nil {
+		logger.Errorf("Failed to revoke credential %s; %s", clientID, err.Error())
+	}
+}
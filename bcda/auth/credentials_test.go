@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+)
+
+// tokenProvider authorizes every token and verifies it as the configured token.
+type tokenProvider struct {
+	Provider
+	token *jwt.Token
+}
+
+func (p tokenProvider) AuthorizeAccess(tokenString string) error {
+	return nil
+}
+
+func (p tokenProvider) VerifyToken(tokenString string) (*jwt.Token, error) {
+	return p.token, nil
+}
+
+func TestIntrospectRevokedCredential(t *testing.T) {
+	clientID := uuid.New()
+	// Credentials serving several ACOs do not carry a single CMS ID
+	claims := &CommonClaims{ClientID: clientID, SystemID: "system", Data: `{"cms_ids":["A0001","A0002"]}`,
+		StandardClaims: jwt.StandardClaims{Issuer: "ssas", Id: uuid.New()}}
+	p := tokenProvider{token: &jwt.Token{Claims: claims}}
+
+	tests := []struct {
+		name       string
+		credential *models.Credential
+		err        error
+		active     bool
+	}{
+		{"Active credential", &models.Credential{ClientID: clientID}, nil, true},
+		{"Credential missing from the inventory", nil, nil, true},
+		{"Revoked credential", &models.Credential{ClientID: clientID, RevokedAt: time.Now()}, nil, false},
+		{"Repository error", nil, errors.New("some error"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &models.MockRepository{}
+			for _, cmsID := range []string{"A0001", "A0002"} {
+				id := cmsID
+				repo.On("GetACOByCMSID", mock.Anything, cmsID).Return(&models.ACO{UUID: uuid.NewRandom(), CMSID: &id}, nil)
+			}
+			repo.On("GetCredentialByClientID", mock.Anything, clientID).Return(tt.credential, tt.err)
+
+			resp := introspect(p, repo, "token")
+			assert.Equal(t, tt.active, resp.Active)
+			if tt.active {
+				assert.Equal(t, []string{"A0001", "A0002"}, resp.CMSIDs)
+			} else {
+				assert.Equal(t, introspectionResponse{}, resp)
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/CMSgov/bcda-app/conf"
+)
+
+// lockoutPolicy determines how long a subject is locked out after repeated failed token or admin requests.
+// Once a subject reaches the threshold, every subsequent failure doubles the lockout duration
+// starting from base and never exceeding max. Failures older than max are forgotten.
+type lockoutPolicy struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+}
+
+func getLockoutPolicy() lockoutPolicy {
+	return lockoutPolicy{
+		threshold: utils.GetEnvInt("AUTH_LOCKOUT_THRESHOLD", 5),
+		base:      time.Duration(utils.GetEnvInt("AUTH_LOCKOUT_BASE_SECONDS", 30)) * time.Second,
+		max:       time.Duration(utils.GetEnvInt("AUTH_LOCKOUT_MAX_SECONDS", 3600)) * time.Second,
+	}
+}
+
+// lockoutDuration returns how long a subject with the given number of failed attempts is locked out.
+func (p lockoutPolicy) lockoutDuration(failedAttempts int) time.Duration {
+	if failedAttempts < p.threshold {
+		return 0
+	}
+
+	// Guard against overflow for subjects with a very large number of failures
+	exp := math.Min(float64(failedAttempts-p.threshold), 32)
+	d := time.Duration(float64(p.base) * math.Pow(2, exp))
+	if d > p.max || d <= 0 {
+		return p.max
+	}
+	return d
+}
+
+// sourceIP returns the IP address of the caller. The X-Forwarded-For header is only trusted when the request
+// was received from one of our proxies (AUTH_TRUSTED_PROXY_CIDRS). Each proxy appends the address it received the
+// request from, so the caller is the last address that is not one of our proxies. Earlier entries are supplied
+// by the caller and are ignored.
+func sourceIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	proxies := trustedProxies()
+	if !isTrustedProxy(proxies, remote) {
+		return remote
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip != "" && !isTrustedProxy(proxies, ip) {
+			return ip
+		}
+	}
+	return remote
+}
+
+// trustedProxies returns the networks of the proxies in front of the API, e.g. our load balancer
+func trustedProxies() []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, cidr := range strings.Split(conf.GetEnv("AUTH_TRUSTED_PROXY_CIDRS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Warnf("Ignoring invalid trusted proxy CIDR %s; %s", cidr, err.Error())
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func isTrustedProxy(proxies []*net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func lockoutSubjects(clientID, ipAddress string) map[models.LockoutType]string {
+	return map[models.LockoutType]string{
+		models.LockoutClientID:  clientID,
+		models.LockoutIPAddress: ipAddress,
+	}
+}
+
+// lockedOut returns the time when the longest active lockout for the client ID or IP address ends.
+// A zero time indicates that neither subject is locked out. op names the operation being authenticated.
+func lockedOut(r models.Repository, op, clientID, ipAddress string) time.Time {
+	var until time.Time
+	for lockoutType, subject := range lockoutSubjects(clientID, ipAddress) {
+		lockout, err := r.GetAuthLockout(context.Background(), lockoutType, subject)
+		if err != nil {
+			// Fail open so a database issue does not prevent every client from authenticating
+			logger.Errorf("Failed to retrieve %s lockout for %s; %s", lockoutType, subject, err.Error())
+			continue
+		}
+		if lockout != nil && lockout.LockedUntil.After(time.Now()) && lockout.LockedUntil.After(until) {
+			until = lockout.LockedUntil
+		}
+	}
+
+	if !until.IsZero() {
+		authLockoutRejected(event{op: op, clientID: clientID, ipAddress: ipAddress,
+			help: fmt.Sprintf("locked out until %s", until.Format(time.RFC3339))})
+	}
+	return until
+}
+
+// recordFailedAttempt increments the failed attempts for the client ID and IP address,
+// locking out each subject whose failures exceed the policy threshold.
+func recordFailedAttempt(r models.Repository, op, clientID, ipAddress string) {
+	policy := getLockoutPolicy()
+	authAttemptFailed(event{op: op, clientID: clientID, ipAddress: ipAddress})
+
+	for lockoutType, subject := range lockoutSubjects(clientID, ipAddress) {
+		ctx := context.Background()
+		now := time.Now()
+		failedAttempts, err := r.IncrementAuthLockout(ctx, lockoutType, subject, now.Add(-policy.max))
+		if err != nil {
+			logger.Errorf("Failed to record failed attempt for %s %s; %s", lockoutType, subject, err.Error())
+			continue
+		}
+
+		if d := policy.lockoutDuration(failedAttempts); d > 0 {
+			if err = r.LockAuthLockout(ctx, lockoutType, subject, now.Add(d)); err != nil {
+				logger.Errorf("Failed to lock out %s %s; %s", lockoutType, subject, err.Error())
+				continue
+			}
+			authLockedOut(event{op: op, clientID: clientID, ipAddress: ipAddress,
+				help: fmt.Sprintf("%s %s locked out for %s after %d failed attempts",
+					lockoutType, subject, d, failedAttempts)})
+		}
+	}
+}
+
+// clearFailedAttempts removes any failed attempts associated with the client ID after a successful request.
+func clearFailedAttempts(r models.Repository, clientID string) {
+	ctx := context.Background()
+	lockout, err := r.GetAuthLockout(ctx, models.LockoutClientID, clientID)
+	if err != nil || lockout == nil {
+		return
+	}
+
+	if err = r.DeleteAuthLockout(ctx, models.LockoutClientID, clientID); err != nil {
+		logger.Errorf("Failed to clear failed attempts for %s; %s", clientID, err.Error())
+	}
+}
+
+// ClearLockout removes the lockout associated with the client ID or IP address, allowing the subject
+// to request tokens immediately.
+func ClearLockout(lockoutType models.LockoutType, subject string) error {
+	clearEvent := event{op: "ClearLockout", help: fmt.Sprintf("%s %s", lockoutType, subject)}
+	switch lockoutType {
+	case models.LockoutClientID:
+		clearEvent.clientID = subject
+	case models.LockoutIPAddress:
+		clearEvent.ipAddress = subject
+	default:
+		return fmt.Errorf("unsupported lockout type %s", lockoutType)
+	}
+
+	if err := repository.DeleteAuthLockout(context.Background(), lockoutType, subject); err != nil {
+		clearEvent.help = err.Error()
+		operationFailed(clearEvent)
+		return err
+	}
+
+	authLockoutCleared(clearEvent)
+	return nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/conf"
+)
+
+type LockoutTestSuite struct {
+	suite.Suite
+}
+
+func TestLockoutTestSuite(t *testing.T) {
+	suite.Run(t, new(LockoutTestSuite))
+}
+
+func (s *LockoutTestSuite) TestLockoutDuration() {
+	policy := lockoutPolicy{threshold: 3, base: time.Second, max: 10 * time.Second}
+
+	tests := []struct {
+		failedAttempts int
+		expected       time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(s.T(), tt.expected, policy.lockoutDuration(tt.failedAttempts), "failed attempts %d", tt.failedAttempts)
+	}
+}
+
+func (s *LockoutTestSuite) TestSourceIP() {
+	conf.SetEnv(s.T(), "AUTH_TRUSTED_PROXY_CIDRS", "10.0.0.0/8, not-a-cidr")
+	defer conf.UnsetEnv(s.T(), "AUTH_TRUSTED_PROXY_CIDRS")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"Direct", "192.168.1.1:4321", "", "192.168.1.1"},
+		{"Direct with spoofed header", "192.168.1.1:4321", "1.1.1.1", "192.168.1.1"},
+		{"Trusted proxy", "10.0.0.1:4321", "1.1.1.1, 192.168.1.1", "192.168.1.1"},
+		{"Trusted proxy chain", "10.0.0.1:4321", "1.1.1.1, 192.168.1.1, 10.0.0.2", "192.168.1.1"},
+		{"Trusted proxy without header", "10.0.0.1:4321", "", "10.0.0.1"},
+		{"Invalid address", "not-an-address", "", "not-an-address"},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/auth/token", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			assert.Equal(t, tt.expected, sourceIP(req))
+		})
+	}
+}
+
+func (s *LockoutTestSuite) TestRecordFailedAttempt() {
+	conf.SetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD", "2")
+	conf.SetEnv(s.T(), "AUTH_LOCKOUT_BASE_SECONDS", "60")
+	conf.SetEnv(s.T(), "AUTH_LOCKOUT_MAX_SECONDS", "600")
+	defer func() {
+		conf.UnsetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD")
+		conf.UnsetEnv(s.T(), "AUTH_LOCKOUT_BASE_SECONDS")
+		conf.UnsetEnv(s.T(), "AUTH_LOCKOUT_MAX_SECONDS")
+	}()
+
+	// Failures older than the max lockout are forgotten
+	resetBefore := mock.MatchedBy(func(t time.Time) bool {
+		return t.Before(time.Now().Add(-599*time.Second)) && t.After(time.Now().Add(-601*time.Second))
+	})
+
+	clientID, ipAddress := "some-client", "10.0.0.1"
+	repo := &models.MockRepository{}
+	// First failure for the client ID, second failure for the IP address locks out the IP address
+	repo.On("IncrementAuthLockout", mock.Anything, models.LockoutClientID, clientID, resetBefore).Return(1, nil)
+	repo.On("IncrementAuthLockout", mock.Anything, models.LockoutIPAddress, ipAddress, resetBefore).Return(2, nil)
+	repo.On("LockAuthLockout", mock.Anything, models.LockoutIPAddress, ipAddress, mock.MatchedBy(func(t time.Time) bool {
+		return t.After(time.Now().Add(59 * time.Second))
+	})).Return(nil)
+
+	recordFailedAttempt(repo, "GetAuthToken", clientID, ipAddress)
+	repo.AssertExpectations(s.T())
+	repo.AssertNotCalled(s.T(), "LockAuthLockout", mock.Anything, models.LockoutClientID, clientID, mock.Anything)
+
+	repo = &models.MockRepository{}
+	repo.On("IncrementAuthLockout", mock.Anything, models.LockoutClientID, clientID, resetBefore).Return(1, nil)
+	repo.On("IncrementAuthLockout", mock.Anything, models.LockoutIPAddress, ipAddress, resetBefore).
+		Return(0, errors.New("some error"))
+
+	recordFailedAttempt(repo, "GetAuthToken", clientID, ipAddress)
+	repo.AssertExpectations(s.T())
+	repo.AssertNotCalled(s.T(), "LockAuthLockout", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *LockoutTestSuite) TestLockedOut() {
+	clientID, ipAddress := "some-client", "10.0.0.1"
+	later, latest := time.Now().Add(time.Minute), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		client   *models.AuthLockout
+		ip       *models.AuthLockout
+		ipErr    error
+		expected time.Time
+	}{
+		{"No lockouts", nil, nil, nil, time.Time{}},
+		{"Expired lockout", &models.AuthLockout{LockedUntil: time.Now().Add(-time.Minute)}, nil, nil, time.Time{}},
+		{"Client locked out", &models.AuthLockout{LockedUntil: later}, nil, errors.New("some error"), later},
+		{"Both locked out", &models.AuthLockout{LockedUntil: later}, &models.AuthLockout{LockedUntil: latest}, nil, latest},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			repo := &models.MockRepository{}
+			repo.On("GetAuthLockout", mock.Anything, models.LockoutClientID, clientID).Return(tt.client, nil)
+			repo.On("GetAuthLockout", mock.Anything, models.LockoutIPAddress, ipAddress).Return(tt.ip, tt.ipErr)
+			assert.True(t, tt.expected.Equal(lockedOut(repo, "GetAuthToken", clientID, ipAddress)))
+		})
+	}
+}
+
+func (s *LockoutTestSuite) TestClearLockout() {
+	origRepository := repository
+	defer func() { repository = origRepository }()
+
+	repo := &models.MockRepository{}
+	repo.On("DeleteAuthLockout", mock.Anything, models.LockoutClientID, "some-client").Return(nil)
+	repo.On("DeleteAuthLockout", mock.Anything, models.LockoutIPAddress, "10.0.0.1").Return(errors.New("no lockout found"))
+	repository = repo
+
+	assert.NoError(s.T(), ClearLockout(models.LockoutClientID, "some-client"))
+	assert.EqualError(s.T(), ClearLockout(models.LockoutIPAddress, "10.0.0.1"), "no lockout found")
+	assert.EqualError(s.T(), ClearLockout(models.LockoutType("unknown"), "value"), "unsupported lockout type unknown")
+	repo.AssertExpectations(s.T())
+}
@@ -15,6 +15,7 @@ type event struct {
 	clientID   string
 	elapsed    time.Duration
 	help       string
+	ipAddress  string
 	op         string
 	tokenID    string
 	trackingID string
@@ -60,6 +61,9 @@ func mergeNonEmpty(data event) *logrus.Entry {
 	if data.tokenID != "" {
 		entry = entry.WithField("tokenID", data.tokenID)
 	}
+	if data.ipAddress != "" {
+		entry = entry.WithField("ipAddress", data.ipAddress)
+	}
 
 	return entry
 }
@@ -95,3 +99,19 @@ func serviceHalted(data event) {
 func serviceStarted(data event) {
 	mergeNonEmpty(data).WithField("event", "ServiceStarted").Print(data.help)
 }
+
+func authAttemptFailed(data event) {
+	mergeNonEmpty(data).WithField("event", "AuthAttemptFailed").Print(data.help)
+}
+
+func authLockedOut(data event) {
+	mergeNonEmpty(data).WithField("event", "AuthLockedOut").Print(data.help)
+}
+
+func authLockoutRejected(data event) {
+	mergeNonEmpty(data).WithField("event", "AuthLockoutRejected").Print(data.help)
+}
+
+func authLockoutCleared(data event) {
+	mergeNonEmpty(data).WithField("event", "AuthLockoutCleared").Print(data.help)
+}
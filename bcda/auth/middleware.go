@@ -2,11 +2,13 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"math"
 	"net/http"
 	"regexp"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-chi/chi"
@@ -17,6 +19,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres"
 	"github.com/CMSgov/bcda-app/bcda/responseutils"
+	"github.com/CMSgov/bcda-app/conf"
 )
 
 // Use context keys for storing/retrieving data in the http Context
@@ -84,6 +87,7 @@ func ParseToken(next http.Handler) http.Handler {
 				ad.ACOID = aco.UUID.String()
 				ad.CMSID = *aco.CMSID
 				ad.Blacklisted = aco.Blacklisted
+				ad.Termination = aco.TerminationDetails
 
 			default:
 				aco, err := repository.GetACOByUUID(context.Background(), uuid.Parse(claims.ACOID))
@@ -96,6 +100,7 @@ func ParseToken(next http.Handler) http.Handler {
 				ad.ACOID = claims.ACOID
 				ad.CMSID = *aco.CMSID
 				ad.Blacklisted = aco.Blacklisted
+				ad.Termination = aco.TerminationDetails
 			}
 		}
 		ctx := context.WithValue(r.Context(), TokenContextKey, token)
@@ -144,11 +149,38 @@ func CheckBlacklist(next http.Handler) http.Handler {
 			responseutils.WriteError(oo, w, http.StatusForbidden)
 			return
 		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CheckLimitedAccess rejects export requests from an entity whose limited access has ended.
+// It only applies to kickoffs, so the entity can still check on and download the jobs started before its cutoff date.
+func CheckLimitedAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ad, ok := r.Context().Value(AuthDataContextKey).(AuthData)
+		if !ok {
+			log.Error()
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.NotFoundErr, "AuthData not found")
+			responseutils.WriteError(oo, w, http.StatusNotFound)
+			return
+		}
+
+		if t := ad.Termination; t != nil && t.IsLimited() && t.CutoffPassed(time.Now()) {
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.UnauthorizedErr, fmt.Sprintf("ACO (CMS_ID: %s) has limited access which ended on %s",
+					ad.CMSID, t.CutoffDate.Format(time.RFC3339)))
+			responseutils.WriteError(oo, w, http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 func RequireTokenJobMatch(next http.Handler) http.Handler {
+	// Credentials serving multiple ACOs are checked against the blacklist once the job's ACO is known
+	checkBlacklist := CheckBlacklist(next)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ad, ok := r.Context().Value(AuthDataContextKey).(AuthData)
 		if !ok {
@@ -179,8 +211,9 @@ func RequireTokenJobMatch(next http.Handler) http.Handler {
 			return
 		}
 
-		// ACO did not create the job
-		if !strings.EqualFold(ad.ACOID, job.ACOID.String()) {
+		// Credential is not authorized for the ACO that created the job
+		jobAD, ok := ad.ForACOID(job.ACOID.String())
+		if !ok {
 			log.Errorf("ACO %s does not have access to job ID %d %s",
 				ad.ACOID, job.ID, job.ACOID)
 			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
@@ -188,6 +221,87 @@ func RequireTokenJobMatch(next http.Handler) http.Handler {
 			responseutils.WriteError(oo, w, http.StatusNotFound)
 			return
 		}
+
+		h := next
+		if ad.ACOID == "" {
+			h = checkBlacklist
+		}
+
+		// Downstream handlers act on behalf of the ACO that created the job
+		ctx := context.WithValue(r.Context(), AuthDataContextKey, jobAD)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SelectACO narrows the auth data to the ACO identified by the cmsId URL parameter.
+// Credentials serving multiple ACOs use it to specify which ACO the request is made on behalf of.
+func SelectACO(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ad, ok := r.Context().Value(AuthDataContextKey).(AuthData)
+		if !ok {
+			log.Error()
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.NotFoundErr, "AuthData not found")
+			responseutils.WriteError(oo, w, http.StatusNotFound)
+			return
+		}
+
+		cmsID := chi.URLParam(r, "cmsId")
+		acoAD, ok := ad.ForACO(cmsID)
+		if !ok {
+			log.Errorf("Client %s is not authorized for ACO %s", ad.ClientID, cmsID)
+			oo := responseutils.CreateOpOutcome(fhircodes.IssueSeverityCode_ERROR, fhircodes.IssueTypeCode_EXCEPTION,
+				responseutils.UnauthorizedErr, fmt.Sprintf("Not authorized for ACO (CMS_ID: %s)", cmsID))
+			responseutils.WriteError(oo, w, http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), AuthDataContextKey, acoAD)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAdminAuth verifies that the request presents the admin credentials through Basic authentication.
+// The admin client ID is configured via BCDA_ADMIN_CLIENT_ID and the hash of its secret via BCDA_ADMIN_SECRET_HASH.
+// If either value is missing, all requests are rejected.
+//
+// Failed attempts are tracked and locked out in the same way as token requests, sharing the caller's IP address
+// lockout with the token endpoint.
+func RequireAdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminEvent := event{op: "RequireAdminAuth"}
+		clientID, secret, ok := r.BasicAuth()
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		adminEvent.clientID = clientID
+
+		adminID, adminHash := conf.GetEnv("BCDA_ADMIN_CLIENT_ID"), conf.GetEnv("BCDA_ADMIN_SECRET_HASH")
+		if adminID == "" || adminHash == "" {
+			adminEvent.help = "admin credentials are not configured"
+			operationFailed(adminEvent)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		// Check for a lockout before verifying the secret to avoid spending time hashing on behalf of an attacker
+		ipAddress := sourceIP(r)
+		adminEvent.ipAddress = ipAddress
+		if until := lockedOut(repository, adminEvent.op, clientID, ipAddress); !until.IsZero() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(clientID), []byte(adminID)) != 1 || !Hash(adminHash).IsHashOf(secret) {
+			recordFailedAttempt(repository, adminEvent.op, clientID, ipAddress)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		clearFailedAttempts(repository, clientID)
+
+		operationSucceeded(adminEvent)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/models/postgres/postgrestest"
 	"github.com/CMSgov/bcda-app/bcda/testUtils"
@@ -20,6 +22,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/auth"
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/conf"
 )
 
 var mockHandler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {}
@@ -244,6 +247,65 @@ func (s *MiddlewareTestSuite) TestRequireTokenJobMatchWithRightACO() {
 	assert.Equal(s.T(), 200, s.rr.Code)
 }
 
+// TestRequireTokenJobMatchWithMultipleACOs validates that credentials serving multiple ACOs
+// can access jobs created for any of their ACOs, subject to the blacklist.
+func (s *MiddlewareTestSuite) TestRequireTokenJobMatchWithMultipleACOs() {
+	db := database.Connection
+
+	j := models.Job{
+		ACOID:      uuid.Parse("DBBD1CE1-AE24-435C-807D-ED45953077D3"),
+		RequestURL: "/api/v1/ExplanationOfBenefit/$export",
+		Status:     models.JobStatusFailed,
+	}
+	postgrestest.CreateJobs(s.T(), db, &j)
+	jobID := strconv.Itoa(int(j.ID))
+
+	otherACO := auth.AuthorizedACO{ACOID: uuid.New(), CMSID: "A0001"}
+	tests := []struct {
+		name         string
+		acos         []auth.AuthorizedACO
+		expectedCode int
+	}{
+		{"Authorized for job ACO", []auth.AuthorizedACO{otherACO, {ACOID: j.ACOID.String(), CMSID: "A0000"}}, http.StatusOK},
+		{"Job ACO blacklisted", []auth.AuthorizedACO{otherACO, {ACOID: j.ACOID.String(), CMSID: "A0000", Blacklisted: true}}, http.StatusForbidden},
+		{"Not authorized for job ACO", []auth.AuthorizedACO{otherACO}, http.StatusNotFound},
+	}
+
+	handler := auth.RequireTokenJobMatch(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Downstream handlers should see the ACO that created the job
+		ad := r.Context().Value(auth.AuthDataContextKey).(auth.AuthData)
+		assert.True(s.T(), strings.EqualFold(j.ACOID.String(), ad.ACOID))
+		assert.Equal(s.T(), "A0000", ad.CMSID)
+	}))
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("jobID", jobID)
+
+			ctx := context.WithValue(context.Background(), auth.AuthDataContextKey, auth.AuthData{ACOs: tt.acos})
+			req, err := http.NewRequestWithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx), "GET", s.server.URL, nil)
+			assert.NoError(t, err)
+
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+
+	// The blacklist of credentials serving a single ACO is checked before the job is known, so it is not
+	// checked again, regardless of the requests previously handled
+	rr := httptest.NewRecorder()
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("jobID", jobID)
+	ctx := context.WithValue(context.Background(), auth.AuthDataContextKey,
+		auth.AuthData{ACOID: j.ACOID.String(), CMSID: "A0000", Blacklisted: true})
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx), "GET", s.server.URL, nil)
+	assert.NoError(s.T(), err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(s.T(), http.StatusOK, rr.Code)
+}
+
 // TestRequireTokenACOMatchInvalidToken validates that we return a 404
 // If the caller does not supply the auth data
 func (s *MiddlewareTestSuite) TestRequireTokenACOMatchInvalidToken() {
@@ -287,6 +349,7 @@ func (s *MiddlewareTestSuite) TestRequireTokenACOMatchInvalidToken() {
 func (s *MiddlewareTestSuite) TestCheckBlacklist() {
 	blacklisted := testUtils.RandomHexID()[0:4]
 	notBlacklisted := testUtils.RandomHexID()[0:4]
+	limitedEnded := &models.Termination{BlacklistType: models.Limited, CutoffDate: time.Now().Add(-time.Hour)}
 
 	handler := auth.CheckBlacklist(mockHandler)
 	tests := []struct {
@@ -299,6 +362,48 @@ func (s *MiddlewareTestSuite) TestCheckBlacklist() {
 		{"Blacklisted ACO", &auth.AuthData{CMSID: blacklisted, Blacklisted: true}, http.StatusForbidden,
 			fmt.Sprintf("ACO (CMS_ID: %s) is unauthorized", blacklisted)},
 		{"Non-blacklisted ACO", &auth.AuthData{CMSID: notBlacklisted, Blacklisted: false}, http.StatusOK, ""},
+		// The cutoff only applies to kickoffs, so jobs started before the cutoff remain reachable
+		{"Limited ACO after cutoff", &auth.AuthData{CMSID: notBlacklisted, Termination: limitedEnded}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			ctx := context.Background()
+			if tt.ad != nil {
+				ctx = context.WithValue(ctx, auth.AuthDataContextKey, *tt.ad)
+			}
+			req, err := http.NewRequestWithContext(ctx, "GET", "", nil)
+			assert.NoError(t, err)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedMessage)
+		})
+	}
+
+}
+
+func (s *MiddlewareTestSuite) TestCheckLimitedAccess() {
+	cmsID := testUtils.RandomHexID()[0:4]
+	cutoff := time.Now().Add(-time.Hour).Round(time.Second)
+	limitedEnded := &models.Termination{BlacklistType: models.Limited, CutoffDate: cutoff}
+	limitedActive := &models.Termination{BlacklistType: models.Limited, CutoffDate: time.Now().Add(time.Hour)}
+	involuntary := &models.Termination{BlacklistType: models.Involuntary, CutoffDate: cutoff}
+
+	handler := auth.CheckLimitedAccess(mockHandler)
+	tests := []struct {
+		name            string
+		ad              *auth.AuthData
+		expectedCode    int
+		expectedMessage string
+	}{
+		{"No auth data found", nil, http.StatusNotFound, "AuthData not found"},
+		{"No termination", &auth.AuthData{CMSID: cmsID}, http.StatusOK, ""},
+		{"Limited ACO after cutoff", &auth.AuthData{CMSID: cmsID, Termination: limitedEnded}, http.StatusForbidden,
+			fmt.Sprintf("ACO (CMS_ID: %s) has limited access which ended on %s", cmsID, cutoff.Format(time.RFC3339))},
+		{"Limited ACO before cutoff", &auth.AuthData{CMSID: cmsID, Termination: limitedActive}, http.StatusOK, ""},
+		{"Non-limited termination", &auth.AuthData{CMSID: cmsID, Termination: involuntary}, http.StatusOK, ""},
 	}
 
 	for _, tt := range tests {
@@ -316,7 +421,132 @@ func (s *MiddlewareTestSuite) TestCheckBlacklist() {
 			assert.Contains(t, rr.Body.String(), tt.expectedMessage)
 		})
 	}
+}
+
+func (s *MiddlewareTestSuite) TestSelectACO() {
+	singleACO := auth.AuthData{ACOID: uuid.New(), CMSID: "A0000"}
+	multiACO := auth.AuthData{ClientID: "vendor-client", ACOs: []auth.AuthorizedACO{
+		{ACOID: uuid.New(), CMSID: "A0001"},
+		{ACOID: uuid.New(), CMSID: "A0002", Blacklisted: true},
+	}}
+
+	tests := []struct {
+		name            string
+		ad              *auth.AuthData
+		cmsID           string
+		expectedCode    int
+		expectedACO     string
+		expectedMessage string
+	}{
+		{"No auth data found", nil, "A0000", http.StatusNotFound, "", "AuthData not found"},
+		{"Single ACO credential", &singleACO, "A0000", http.StatusOK, singleACO.ACOID, ""},
+		{"Single ACO credential with other ACO", &singleACO, "A0001", http.StatusForbidden, "", "Not authorized for ACO (CMS_ID: A0001)"},
+		{"Multiple ACO credential", &multiACO, "A0001", http.StatusOK, multiACO.ACOs[0].ACOID, ""},
+		{"Multiple ACO credential with other ACO", &multiACO, "A0000", http.StatusForbidden, "", "Not authorized for ACO (CMS_ID: A0000)"},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			var selected auth.AuthData
+			handler := auth.SelectACO(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				selected = r.Context().Value(auth.AuthDataContextKey).(auth.AuthData)
+			}))
+
+			rr := httptest.NewRecorder()
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("cmsId", tt.cmsID)
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+			if tt.ad != nil {
+				ctx = context.WithValue(ctx, auth.AuthDataContextKey, *tt.ad)
+			}
+			req, err := http.NewRequestWithContext(ctx, "GET", "", nil)
+			assert.NoError(t, err)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedMessage)
+			assert.Equal(t, tt.expectedACO, selected.ACOID)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.cmsID, selected.CMSID)
+			}
+		})
+	}
+
+	// Selecting an ACO carries over its blacklist and termination state
+	ad, ok := multiACO.ForACO("A0002")
+	assert.True(s.T(), ok)
+	assert.True(s.T(), ad.Blacklisted)
+	assert.Equal(s.T(), "vendor-client", ad.ClientID)
+}
+
+func (s *MiddlewareTestSuite) TestRequireAdminAuth() {
+	adminID, adminSecret := uuid.New(), uuid.New()
+	hash, err := auth.NewHash(adminSecret)
+	assert.NoError(s.T(), err)
+
+	handler := auth.RequireAdminAuth(mockHandler)
+	ipAddress := testUtils.GetRandomIPV4Address(s.T())
+	defer func() {
+		// Failed attempts are tracked against the admin client ID and the caller's IP address
+		_ = auth.ClearLockout(models.LockoutClientID, adminID)
+		_ = auth.ClearLockout(models.LockoutIPAddress, ipAddress)
+	}()
+	tests := []struct {
+		name         string
+		configured   bool
+		setAuth      bool
+		clientID     string
+		secret       string
+		expectedCode int
+	}{
+		{"Valid credentials", true, true, adminID, adminSecret, http.StatusOK},
+		{"No credentials", true, false, "", "", http.StatusUnauthorized},
+		{"Invalid client ID", true, true, uuid.New(), adminSecret, http.StatusUnauthorized},
+		{"Invalid secret", true, true, adminID, uuid.New(), http.StatusUnauthorized},
+		{"Admin credentials not configured", false, true, adminID, adminSecret, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			if tt.configured {
+				conf.SetEnv(t, "BCDA_ADMIN_CLIENT_ID", adminID)
+				conf.SetEnv(t, "BCDA_ADMIN_SECRET_HASH", hash.String())
+			} else {
+				conf.UnsetEnv(t, "BCDA_ADMIN_CLIENT_ID")
+				conf.UnsetEnv(t, "BCDA_ADMIN_SECRET_HASH")
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/auth/introspect", nil)
+			req.RemoteAddr = ipAddress + ":1234"
+			if tt.setAuth {
+				req.SetBasicAuth(tt.clientID, tt.secret)
+			}
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedCode, rr.Code)
+		})
+	}
+
+	// Repeated failures lock out the caller, even once it presents valid credentials
+	conf.SetEnv(s.T(), "BCDA_ADMIN_CLIENT_ID", adminID)
+	conf.SetEnv(s.T(), "BCDA_ADMIN_SECRET_HASH", hash.String())
+	conf.SetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD", "2")
+	defer conf.UnsetEnv(s.T(), "AUTH_LOCKOUT_THRESHOLD")
+	request := func(secret string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/acos", nil)
+		req.RemoteAddr = ipAddress + ":1234"
+		req.SetBasicAuth(adminID, secret)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	assert.Equal(s.T(), http.StatusUnauthorized, request(uuid.New()).Code)
+	rr := request(adminSecret)
+	assert.Equal(s.T(), http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(s.T(), rr.Header().Get("Retry-After"))
 
+	conf.UnsetEnv(s.T(), "BCDA_ADMIN_CLIENT_ID")
+	conf.UnsetEnv(s.T(), "BCDA_ADMIN_SECRET_HASH")
 }
 
 func TestMiddlewareTestSuite(t *testing.T) {
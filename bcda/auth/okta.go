@@ -11,6 +11,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pborman/uuid"
 )
 
 type OktaBackend interface {
@@ -53,6 +54,11 @@ func (o OktaAuthPlugin) RegisterSystem(localID, publicKey, groupID string, ips .
 	}
 
 	id, secret, name, err := o.backend.AddClientApplication(localID)
+	if err == nil {
+		if aco, err := o.repository.GetACOByUUID(context.Background(), uuid.Parse(localID)); err == nil {
+			saveCredential(o.repository, aco, id, "")
+		}
+	}
 
 	return Credentials{
 		ClientID:     id,
@@ -73,12 +79,22 @@ func (o OktaAuthPlugin) GetVersion() (string, error) {
 	return "", errors.New("not yet implemented")
 }
 
+func (o OktaAuthPlugin) ListCredentials(cmsID string) ([]CredentialInfo, error) {
+	return listCredentials(o.repository, cmsID)
+}
+
 func (o OktaAuthPlugin) ResetSecret(clientID string) (Credentials, error) {
 	clientSecret, err := o.backend.GenerateNewClientSecret(clientID)
 	if err != nil {
 		return Credentials{}, err
 	}
 
+	if aco, err := o.repository.GetACOByClientID(context.Background(), clientID); err == nil {
+		saveCredential(o.repository, aco, clientID, "")
+	} else {
+		logger.Errorf("Failed to save credential %s; %s", clientID, err.Error())
+	}
+
 	c := Credentials{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -93,6 +109,7 @@ func (o OktaAuthPlugin) RevokeSystemCredentials(clientID string) error {
 		return err
 	}
 
+	revokeCredential(o.repository, clientID)
 	return nil
 }
 
@@ -101,11 +118,11 @@ func (o OktaAuthPlugin) MakeAccessToken(creds Credentials) (string, error) {
 	clientID := creds.ClientID
 
 	if clientID == "" {
-		return "", fmt.Errorf("client ID required")
+		return "", fmt.Errorf("client ID required: %w", ErrInvalidCredentials)
 	}
 
 	if creds.ClientSecret == "" {
-		return "", fmt.Errorf("client secret required")
+		return "", fmt.Errorf("client secret required: %w", ErrInvalidCredentials)
 	}
 
 	clientCreds := client.Credentials{ClientID: clientID, ClientSecret: creds.ClientSecret}
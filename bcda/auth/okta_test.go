@@ -60,6 +60,22 @@ func (s *OktaAuthPluginTestSuite) TestResetSecret() {
 	assert.Nil(s.T(), err)
 	assert.NotEqual(s.T(), "", c.ClientSecret)
 
+	// Resetting the secret of a known ACO's credential restarts its lifecycle in the inventory
+	c, err = s.o.ResetSecret(KnownClientID)
+	assert.Nil(s.T(), err)
+	assert.NotEqual(s.T(), "", c.ClientSecret)
+	aco := postgrestest.GetACOByUUID(s.T(), database.Connection, uuid.Parse(KnownFixtureACO))
+	credentials, err := s.o.ListCredentials(*aco.CMSID)
+	assert.Nil(s.T(), err)
+	var found bool
+	for _, credential := range credentials {
+		if credential.ClientID == KnownClientID {
+			found = true
+			assert.False(s.T(), credential.Revoked)
+		}
+	}
+	assert.True(s.T(), found)
+
 	invalidClientID := "IDontexist"
 	c, err = s.o.ResetSecret(invalidClientID)
 	assert.Equal(s.T(), "404 Not Found", err.Error())
@@ -73,7 +89,8 @@ func (s *OktaAuthPluginTestSuite) TestRevokeSystemCredentials() {
 func (s *OktaAuthPluginTestSuite) TestMakeAccessToken() {
 	ts, err := s.o.MakeAccessToken(Credentials{ClientID: "", ClientSecret: ""})
 	assert.Empty(s.T(), ts)
-	assert.EqualError(s.T(), err, "client ID required")
+	assert.EqualError(s.T(), err, "client ID required: invalid credentials")
+	assert.ErrorIs(s.T(), err, ErrInvalidCredentials)
 
 	mockID := "MockID"
 	mockSecret := "MockSecret"
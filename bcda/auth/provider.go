@@ -75,8 +75,52 @@ type AuthData struct {
 	SystemID    string
 	CMSID       string
 	Blacklisted bool
+	// Termination describes the termination state of the ACO, nil if the ACO has not been terminated
+	Termination *models.Termination
+	// ACOs contains every ACO that a credential serving multiple ACOs is authorized for.
+	// ACOID and CMSID are empty for these credentials until an ACO is selected.
+	ACOs []AuthorizedACO
 }
 
+// AuthorizedACO describes an ACO that a credential is authorized to act on behalf of
+type AuthorizedACO struct {
+	ACOID       string
+	CMSID       string
+	Blacklisted bool
+	Termination *models.Termination
+}
+
+// ForACO returns the auth data narrowed to the ACO identified by cmsID.
+// The boolean is false if the credential is not authorized for the ACO.
+func (ad AuthData) ForACO(cmsID string) (AuthData, bool) {
+	return ad.narrow(func(aco AuthorizedACO) bool { return strings.EqualFold(aco.CMSID, cmsID) })
+}
+
+// ForACOID returns the auth data narrowed to the ACO identified by acoID.
+// The boolean is false if the credential is not authorized for the ACO.
+func (ad AuthData) ForACOID(acoID string) (AuthData, bool) {
+	return ad.narrow(func(aco AuthorizedACO) bool { return strings.EqualFold(aco.ACOID, acoID) })
+}
+
+func (ad AuthData) narrow(match func(aco AuthorizedACO) bool) (AuthData, bool) {
+	if ad.ACOID != "" && match(AuthorizedACO{ACOID: ad.ACOID, CMSID: ad.CMSID}) {
+		return ad, true
+	}
+
+	for _, aco := range ad.ACOs {
+		if match(aco) {
+			ad.ACOID, ad.CMSID = aco.ACOID, aco.CMSID
+			ad.Blacklisted, ad.Termination = aco.Blacklisted, aco.Termination
+			return ad, true
+		}
+	}
+	return ad, false
+}
+
+// ErrInvalidCredentials is wrapped by the errors returned by MakeAccessToken when the credentials are not valid,
+// as opposed to the provider being unable to verify them
+var ErrInvalidCredentials = client.ErrInvalidCredentials
+
 type Credentials struct {
 	ClientID     string    `json:"client_id"`
 	ClientSecret string    `json:"client_secret"`
@@ -118,4 +162,7 @@ type Provider interface {
 
 	// GetVersion gets the version of the provider
 	GetVersion() (string, error)
+
+	// ListCredentials returns the credentials issued to the ACO identified by cmsID
+	ListCredentials(cmsID string) ([]CredentialInfo, error)
 }
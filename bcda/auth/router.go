@@ -12,6 +12,7 @@ func NewAuthRouter(middlewares ...func(http.Handler) http.Handler) http.Handler
 	m := monitoring.GetMonitor()
 	r.Use(middlewares...)
 	r.Post(m.WrapHandler("/auth/token", GetAuthToken))
+	r.With(RequireAdminAuth).Post(m.WrapHandler("/auth/introspect", IntrospectToken))
 	r.With(ParseToken, RequireTokenAuth, CheckBlacklist).Get(m.WrapHandler("/auth/welcome", Welcome))
 	return r
 }
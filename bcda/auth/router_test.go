@@ -35,6 +35,11 @@ func (s *AuthRouterTestSuite) TestAuthTokenRoute() {
 	assert.Equal(s.T(), http.StatusBadRequest, res.StatusCode)
 }
 
+func (s *AuthRouterTestSuite) TestIntrospectRoute() {
+	res := s.reqAuthRoute("POST", "/auth/introspect", nil)
+	assert.Equal(s.T(), http.StatusUnauthorized, res.StatusCode)
+}
+
 func TestAuthRouterTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthRouterTestSuite))
 }
@@ -57,6 +57,8 @@ func (s SSASPlugin) RegisterSystem(localID, publicKey, groupID string, ips ...st
 		return creds, errors.Wrapf(err, "could not update ACO %s with client and system IDs", *aco.CMSID)
 	}
 
+	saveCredential(s.repository, aco, creds.ClientID, creds.SystemID)
+
 	return creds, nil
 }
 
@@ -75,6 +77,14 @@ func (s SSASPlugin) GetVersion() (string, error) {
 	return s.client.GetVersion()
 }
 
+// ListCredentials returns the credentials issued to the ACO identified by cmsID.
+// SSAS cannot list an ACO's systems, so the credentials are read from BCDA's inventory. The inventory holds the
+// credentials created through BCDA, along with each ACO's credentials from before the inventory was introduced.
+// Credentials created in SSAS directly are not listed, and tokens requested from SSAS directly are not recorded.
+func (s SSASPlugin) ListCredentials(cmsID string) ([]CredentialInfo, error) {
+	return listCredentials(s.repository, cmsID)
+}
+
 // ResetSecret creates new or replaces existing credentials for the given ssasID.
 func (s SSASPlugin) ResetSecret(clientID string) (Credentials, error) {
 	creds := Credentials{}
@@ -94,12 +104,21 @@ func (s SSASPlugin) ResetSecret(clientID string) (Credentials, error) {
 		return creds, err
 	}
 
+	saveCredential(s.repository, aco, creds.ClientID, aco.SystemID)
+
 	return creds, nil
 }
 
 // RevokeSystemCredentials revokes any existing credentials for the given clientID.
 func (s SSASPlugin) RevokeSystemCredentials(ssasID string) error {
-	return s.client.DeleteCredentials(ssasID)
+	if err := s.client.DeleteCredentials(ssasID); err != nil {
+		return err
+	}
+
+	if err := s.repository.RevokeCredentialBySystemID(context.Background(), ssasID); err != nil {
+		logger.Errorf("Failed to revoke credential for system %s; %s", ssasID, err.Error())
+	}
+	return nil
 }
 
 // MakeAccessToken mints an access token for the given credentials.
@@ -151,17 +170,31 @@ func adFromClaims(r models.Repository, claims *CommonClaims) (AuthData, error) {
 		return ad, fmt.Errorf("can't decode data claim %s; %v", d, err)
 	}
 
-	if len(xData.IDList) != 1 {
-		return ad, fmt.Errorf("expected one id in list; got %v; source %s", xData.IDList, claims.Data)
+	if len(xData.IDList) == 0 {
+		return ad, fmt.Errorf("expected at least one id in list; source %s", claims.Data)
+	}
+
+	// Credentials belonging to a group that serves several ACOs must select an ACO on each request
+	if len(xData.IDList) > 1 {
+		for _, cmsID := range xData.IDList {
+			var aco *models.ACO
+			if aco, err = r.GetACOByCMSID(context.Background(), cmsID); err != nil {
+				return ad, fmt.Errorf("no aco for cmsID %s; %v", cmsID, err)
+			}
+			ad.ACOs = append(ad.ACOs, AuthorizedACO{ACOID: aco.UUID.String(), CMSID: cmsID,
+				Blacklisted: aco.Blacklisted, Termination: aco.TerminationDetails})
+		}
+		return ad, nil
 	}
 	ad.CMSID = xData.IDList[0]
 
 	var aco *models.ACO
-	if aco, err = repository.GetACOByCMSID(context.Background(), ad.CMSID); err != nil {
+	if aco, err = r.GetACOByCMSID(context.Background(), ad.CMSID); err != nil {
 		return ad, fmt.Errorf("no aco for cmsID %s; %v", ad.CMSID, err)
 	}
 	ad.ACOID = aco.UUID.String()
 	ad.Blacklisted = aco.Blacklisted
+	ad.Termination = aco.TerminationDetails
 
 	return ad, nil
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/go-chi/render"
 	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -204,6 +205,7 @@ func (s *SSASPluginTestSuite) TestMakeAccessToken() {
 	assert.NotNil(s.T(), err)
 	assert.Empty(s.T(), ts)
 	assert.Contains(s.T(), err.Error(), "401")
+	assert.ErrorIs(s.T(), err, ErrInvalidCredentials)
 
 	ts, err = s.p.MakeAccessToken(Credentials{})
 	assert.NotNil(s.T(), err)
@@ -281,6 +283,33 @@ func (s *SSASPluginTestSuite) TestVerifyToken() {
 	assert.Equal(s.T(), "mock-id", tc.Id)
 }
 
+func TestADFromClaims(t *testing.T) {
+	acoA := &models.ACO{UUID: uuid.NewRandom(), CMSID: &[]string{"A0001"}[0]}
+	acoB := &models.ACO{UUID: uuid.NewRandom(), CMSID: &[]string{"A0002"}[0], Blacklisted: true}
+
+	repo := &models.MockRepository{}
+	repo.On("GetACOByCMSID", mock.Anything, "A0001").Return(acoA, nil)
+	repo.On("GetACOByCMSID", mock.Anything, "A0002").Return(acoB, nil)
+
+	ad, err := adFromClaims(repo, &CommonClaims{ClientID: "client", SystemID: "system", Data: `{"cms_ids":["A0001"]}`})
+	assert.NoError(t, err)
+	assert.Equal(t, "A0001", ad.CMSID)
+	assert.Equal(t, acoA.UUID.String(), ad.ACOID)
+
+	ad, err = adFromClaims(repo, &CommonClaims{ClientID: "client", SystemID: "system", Data: `{"cms_ids":["A0001","A0002"]}`})
+	assert.NoError(t, err)
+	assert.Empty(t, ad.CMSID)
+	assert.Equal(t, []AuthorizedACO{
+		{ACOID: acoA.UUID.String(), CMSID: "A0001"},
+		{ACOID: acoB.UUID.String(), CMSID: "A0002", Blacklisted: true},
+	}, ad.ACOs)
+
+	repo.On("GetACOByCMSID", mock.Anything, "A0003").Return(nil, sql.ErrNoRows)
+	_, err = adFromClaims(repo, &CommonClaims{ClientID: "client", SystemID: "system", Data: `{"cms_ids":["A0003"]}`})
+	assert.EqualError(t, err, "no aco for cmsID A0003; "+sql.ErrNoRows.Error())
+	repo.AssertExpectations(t)
+}
+
 func TestSSASPluginSuite(t *testing.T) {
 	suite.Run(t, new(SSASPluginTestSuite))
 }
@@ -4,26 +4,39 @@ import (
 	"archive/zip"
 	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/CMSgov/bcda-app/bcda/admin"
+	"github.com/CMSgov/bcda-app/bcda/alr"
+	apiv1 "github.com/CMSgov/bcda-app/bcda/api/v1"
+	apiv2 "github.com/CMSgov/bcda-app/bcda/api/v2"
 	"github.com/CMSgov/bcda-app/bcda/auth"
-	authclient "github.com/CMSgov/bcda-app/bcda/auth/client"
 	"github.com/CMSgov/bcda-app/bcda/auth/rsautils"
 	"github.com/CMSgov/bcda-app/bcda/cclf"
 	cclfUtils "github.com/CMSgov/bcda-app/bcda/cclf/testutils"
 	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
+	"github.com/CMSgov/bcda-app/bcda/ingest"
+	"github.com/CMSgov/bcda-app/bcda/mockbfd"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres"
 	"github.com/CMSgov/bcda-app/bcda/service"
@@ -31,9 +44,9 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/suppression"
 	"github.com/CMSgov/bcda-app/bcda/utils"
 	"github.com/CMSgov/bcda-app/bcda/web"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/conf"
 
-	"github.com/pborman/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -63,8 +76,20 @@ func setUpApp() *cli.App {
 		return nil
 	}
 	var acoName, acoCMSID, acoID, accessToken, acoSize, filePath, dirToDelete, environment, groupID, groupName, ips, fileType string
+	var clientID, ipAddress string
 	var thresholdHr int
-	var httpPort, httpsPort int
+	var httpPort, httpsPort, healthPort int
+	var dryRun bool
+	var reportPath string
+	var since string
+	var mockBFD mockbfd.Config
+	var certFile, keyFile, clientCAFile string
+	var latencyMS int
+	var resourceType string
+	var sinceParam, runout bool
+	var ageMinutes int
+	var jobID, deadLetterID uint
+	var jobStatus string
 	app.Commands = []cli.Command{
 		{
 			Name:  "start-api",
@@ -127,11 +152,45 @@ func setUpApp() *cli.App {
 					IdleTimeout:  time.Duration(utils.GetEnvInt("FILESERVER_IDLE_TIMEOUT", 120)) * time.Second,
 				}
 
+				adminServer := &http.Server{
+					Handler:      web.NewAdminRouter(),
+					ReadTimeout:  time.Duration(utils.GetEnvInt("API_READ_TIMEOUT", 10)) * time.Second,
+					WriteTimeout: time.Duration(utils.GetEnvInt("API_WRITE_TIMEOUT", 20)) * time.Second,
+					IdleTimeout:  time.Duration(utils.GetEnvInt("API_IDLE_TIMEOUT", 120)) * time.Second,
+				}
+
 				smux := servicemux.New(httpsAddr)
 				smux.AddServer(fileserver, "/data")
 				smux.AddServer(auth, "/auth")
+				smux.AddServer(adminServer, "/admin")
 				smux.AddServer(api, "")
+
+				// Export requests enqueue their queue jobs in the background after responding, so give them a chance
+				// to finish before exiting. The worker's reconciler cannot re-plan an interrupted request, so once
+				// the planning grace period has passed the job is failed and the client must submit the request again.
+				sigs := make(chan os.Signal, 1)
+				signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+				stopped := make(chan struct{})
+				go func() {
+					defer close(stopped)
+					sig := <-sigs
+					timeout := time.Duration(utils.GetEnvInt("API_SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second
+					log.Infof("Received %s, waiting up to %s for requests to finish and export requests to enqueue their queue jobs", sig, timeout)
+					deadline := time.Now().Add(timeout)
+
+					// Stop accepting requests and let the active requests finish, so no more queue jobs are planned
+					ctx, cancel := context.WithDeadline(context.Background(), deadline)
+					defer cancel()
+					if err := smux.Shutdown(ctx); err != nil {
+						log.Warnf("Failed to shut down the servers: %s", err)
+					}
+					if !apiv1.WaitForPlanners(time.Until(deadline)) || !apiv2.WaitForPlanners(time.Until(deadline)) {
+						log.Warn("Timed out waiting for export requests to enqueue their queue jobs")
+					}
+				}()
+
 				smux.Serve()
+				<-stopped
 
 				return nil
 			},
@@ -153,7 +212,7 @@ func setUpApp() *cli.App {
 				},
 				cli.StringFlag{
 					Name:        "aco-id",
-					Usage:       "CMS ID or UUID of ACO associated with group",
+					Usage:       "CMS ID or UUID of ACO associated with group; separate multiple ACOs with commas for a vendor serving several ACOs",
 					Destination: &acoID,
 				},
 			},
@@ -326,6 +385,61 @@ func setUpApp() *cli.App {
 				return nil
 			},
 		},
+		{
+			Name:     "list-credentials",
+			Category: "Authentication tools",
+			Usage:    "List the credentials issued to an ACO specified by ACO CMS ID",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "cms-id",
+					Usage:       "CMS ID of ACO",
+					Destination: &acoCMSID,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if acoCMSID == "" {
+					return errors.New("ACO CMS ID (--cms-id) is required")
+				}
+				msg, err := listCredentials(acoCMSID)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(app.Writer, msg)
+				return nil
+			},
+		},
+		{
+			Name:     "clear-auth-lockout",
+			Category: "Authentication tools",
+			Usage:    "Clear the token request lockout for a client ID or source IP address",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "client-id",
+					Usage:       "Client ID that is locked out",
+					Destination: &clientID,
+				},
+				cli.StringFlag{
+					Name:        "ip",
+					Usage:       "Source IP address that is locked out",
+					Destination: &ipAddress,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if (clientID == "") == (ipAddress == "") {
+					return errors.New("exactly one of client ID (--client-id) or IP address (--ip) is required")
+				}
+
+				lockoutType, subject := models.LockoutClientID, clientID
+				if ipAddress != "" {
+					lockoutType, subject = models.LockoutIPAddress, ipAddress
+				}
+				if err := auth.ClearLockout(lockoutType, subject); err != nil {
+					return err
+				}
+				fmt.Fprintf(app.Writer, "Lockout cleared for %s %s\n", lockoutType, subject)
+				return nil
+			},
+		},
 		{
 			Name:     "archive-job-files",
 			Category: "Cleanup",
@@ -388,8 +502,25 @@ func setUpApp() *cli.App {
 					Usage:       "Directory where CCLF files are located",
 					Destination: &filePath,
 				},
+				cli.BoolFlag{
+					Name:        "dry-run",
+					Usage:       "Validate the CCLF files and report any problems without importing them",
+					Destination: &dryRun,
+				},
+				cli.StringFlag{
+					Name:        "report",
+					Usage:       "File to write the dry run report to. Defaults to stdout",
+					Destination: &reportPath,
+				},
 			},
 			Action: func(c *cli.Context) error {
+				if dryRun {
+					report, err := cclf.ValidateCCLFDirectory(filePath)
+					if err != nil {
+						return err
+					}
+					return writeDryRunReport(app.Writer, reportPath, report)
+				}
 				success, failure, skipped, err := cclf.ImportCCLFDirectory(filePath)
 				fmt.Fprintf(app.Writer, "Completed CCLF import.  Successfully imported %v files.  Failed to import %v files.  Skipped %v files.  See logs for more details.", success, failure, skipped)
 				return err
@@ -426,13 +557,247 @@ func setUpApp() *cli.App {
 					Usage:       "Directory where suppression files are located",
 					Destination: &filePath,
 				},
+				cli.BoolFlag{
+					Name:        "dry-run",
+					Usage:       "Validate the suppression files and report any problems without importing them",
+					Destination: &dryRun,
+				},
+				cli.StringFlag{
+					Name:        "report",
+					Usage:       "File to write the dry run report to. Defaults to stdout",
+					Destination: &reportPath,
+				},
 			},
 			Action: func(c *cli.Context) error {
+				if dryRun {
+					report, err := suppression.ValidateSuppressionDirectory(filePath)
+					if err != nil {
+						return err
+					}
+					return writeDryRunReport(app.Writer, reportPath, report)
+				}
 				s, f, sk, err := suppression.ImportSuppressionDirectory(filePath)
 				fmt.Fprintf(app.Writer, "Completed 1-800-MEDICARE suppression data import.\nFiles imported: %v\nFiles failed: %v\nFiles skipped: %v\n", s, f, sk)
 				return err
 			},
 		},
+		{
+			Name:     "attribution-diff",
+			Category: "Reporting",
+			Usage:    "List the beneficiaries added to and removed from an ACO's attribution between CCLF8 deliveries",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "cms-id",
+					Usage:       "CMS ID of ACO",
+					Destination: &acoCMSID,
+				},
+				cli.StringFlag{
+					Name:        "since",
+					Usage:       "Compare against the delivery in effect at this time (RFC3339) instead of the previous delivery",
+					Destination: &since,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return attributionDiff(app.Writer, acoCMSID, since)
+			},
+		},
+		{
+			Name:     "suppression-report",
+			Category: "Reporting",
+			Usage:    "List the beneficiaries attributed to an ACO that are suppressed, along with the reason for each suppression",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "cms-id",
+					Usage:       "CMS ID of ACO",
+					Destination: &acoCMSID,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return suppressionReport(app.Writer, acoCMSID)
+			},
+		},
+		{
+			Name:     "job-priority",
+			Category: "Reporting",
+			Usage:    "Show the priority that the queue jobs of a hypothetical request would be enqueued with",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "cms-id",
+					Usage:       "CMS ID of ACO",
+					Destination: &acoCMSID,
+				},
+				cli.StringFlag{
+					Name:        "type",
+					Usage:       "Resource type requested (Patient, Coverage, or ExplanationOfBenefit)",
+					Destination: &resourceType,
+				},
+				cli.BoolFlag{
+					Name:        "since",
+					Usage:       "Request only retrieves data for a limited timeframe",
+					Destination: &sinceParam,
+				},
+				cli.BoolFlag{
+					Name:        "runout",
+					Usage:       "Request retrieves runout data",
+					Destination: &runout,
+				},
+				cli.IntFlag{
+					Name:        "age",
+					Usage:       "Minutes since the job was created",
+					Destination: &ageMinutes,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return jobPriority(app.Writer, acoCMSID, resourceType, sinceParam, runout, ageMinutes)
+			},
+		},
+		{
+			Name:     "job",
+			Category: "Job management",
+			Usage:    "Inspect and manipulate export jobs and their queue jobs",
+			Subcommands: []cli.Command{
+				{
+					Name:  "show",
+					Usage: "Show a job along with its files and the queue jobs that remain in the queue",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the job",
+							Destination: &jobID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return showJob(app.Writer, jobID)
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "List an ACO's jobs",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:        "cms-id",
+							Usage:       "CMS ID of ACO",
+							Destination: &acoCMSID,
+						},
+						cli.StringFlag{
+							Name:        "status",
+							Usage:       "Comma separated list of job statuses to list (e.g. Pending,In Progress)",
+							Destination: &jobStatus,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return listJobs(app.Writer, acoCMSID, jobStatus)
+					},
+				},
+				{
+					Name:  "cancel",
+					Usage: "Cancel a pending or in progress job and remove its queue jobs from the queue",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the job",
+							Destination: &jobID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return cancelJob(app.Writer, jobID)
+					},
+				},
+				{
+					Name:  "requeue",
+					Usage: "Retry the failed queue jobs of a pending or in progress job immediately",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the job",
+							Destination: &jobID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return requeueJob(app.Writer, jobID)
+					},
+				},
+				{
+					Name:  "fail",
+					Usage: "Fail a pending or in progress job and remove its queue jobs from the queue",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the job",
+							Destination: &jobID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return failJob(app.Writer, jobID)
+					},
+				},
+			},
+		},
+		{
+			Name:     "dead-letter",
+			Category: "Job management",
+			Usage:    "Inspect, replay, and discard queue jobs that were dead-lettered because they could never be worked on successfully",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "List the dead-lettered queue jobs",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "job-id",
+							Usage:       "Only list the dead-lettered queue jobs of this job",
+							Destination: &jobID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return listDeadLetters(app.Writer, jobID)
+					},
+				},
+				{
+					Name:  "replay",
+					Usage: "Enqueue a dead-lettered queue job of a pending or in progress job again",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the dead-lettered queue job",
+							Destination: &deadLetterID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return replayDeadLetter(app.Writer, deadLetterID)
+					},
+				},
+				{
+					Name:  "discard",
+					Usage: "Discard a dead-lettered queue job, failing its job if it is pending or in progress",
+					Flags: []cli.Flag{
+						cli.UintFlag{
+							Name:        "id",
+							Usage:       "ID of the dead-lettered queue job",
+							Destination: &deadLetterID,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return discardDeadLetter(app.Writer, deadLetterID)
+					},
+				},
+			},
+		},
+		{
+			Name:     "ingest-daemon",
+			Category: "Data import",
+			Usage:    "Watch the CCLF, suppression, and ALR inbound directories and import new deliveries",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:        "health-port",
+					Usage:       "Port to use for the daemon's health endpoint",
+					Value:       3006,
+					Destination: &healthPort,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return runIngestDaemon(healthPort)
+			},
+		},
 		{
 			Name:     "delete-dir-contents",
 			Category: "Cleanup",
@@ -506,7 +871,7 @@ func setUpApp() *cli.App {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				return setBlacklistState(acoCMSID, true)
+				return admin.SetBlacklistState(context.Background(), r, acoCMSID, true)
 			},
 		},
 		{
@@ -521,7 +886,66 @@ func setUpApp() *cli.App {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				return setBlacklistState(acoCMSID, false)
+				return admin.SetBlacklistState(context.Background(), r, acoCMSID, false)
+			},
+		},
+		{
+			Name:     "serve-mock-bfd",
+			Category: "Development tools",
+			Usage:    "Serve the synthetic beneficiary data as a stand-in for Blue Button over HTTPS with mutual TLS",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:        "https-port",
+					Usage:       "Port to use for https",
+					Value:       8443,
+					Destination: &httpsPort,
+				},
+				cli.StringFlag{
+					Name:        "cert-file",
+					Usage:       "Server certificate",
+					Destination: &certFile,
+				},
+				cli.StringFlag{
+					Name:        "key-file",
+					Usage:       "Server private key",
+					Destination: &keyFile,
+				},
+				cli.StringFlag{
+					Name:        "client-ca-file",
+					Usage:       "CA certificate(s) used to verify client certificates",
+					Destination: &clientCAFile,
+				},
+				cli.StringFlag{
+					Name:        "data-dir",
+					Usage:       "Directory containing the synthetic beneficiary data",
+					Value:       "shared_files/synthetic_beneficiary_data",
+					Destination: &mockBFD.DataDir,
+				},
+				cli.IntFlag{
+					Name:        "latency-ms",
+					Usage:       "Latency added to every request",
+					Destination: &latencyMS,
+				},
+				cli.Float64Flag{
+					Name:        "error-rate",
+					Usage:       "Fraction (0-1) of requests that fail",
+					Destination: &mockBFD.ErrorRate,
+				},
+				cli.IntFlag{
+					Name:        "error-status",
+					Usage:       "Status code returned by failed requests",
+					Value:       http.StatusServiceUnavailable,
+					Destination: &mockBFD.ErrorStatus,
+				},
+				cli.IntFlag{
+					Name:        "retry-after",
+					Usage:       "Seconds returned in the Retry-After header of failed requests",
+					Destination: &mockBFD.RetryAfter,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				mockBFD.Latency = time.Duration(latencyMS) * time.Millisecond
+				return serveMockBFD(mockBFD, httpsPort, certFile, keyFile, clientCAFile)
 			},
 		},
 	}
@@ -529,81 +953,21 @@ func setUpApp() *cli.App {
 }
 
 func createGroup(id, name, acoID string) (string, error) {
-	if id == "" || name == "" || acoID == "" {
-		return "", errors.New("ID (--id), name (--name), and ACO ID (--aco-id) are required")
-	}
-
-	var (
-		aco *models.ACO
-		err error
-	)
-
-	if match := service.IsSupportedACO(acoID); match {
-		aco, err = r.GetACOByCMSID(context.Background(), acoID)
-		if err != nil {
-			return "", err
-		}
-	} else if match, err := regexp.MatchString("[0-9a-f]{6}-([0-9a-f]{4}-){3}[0-9a-f]{12}", acoID); err == nil && match {
-		aco, err = r.GetACOByUUID(context.Background(), uuid.Parse(acoID))
-		if err != nil {
-			return "", err
-		}
-	} else {
-		return "", errors.New("ACO ID (--aco-id) must be a supported CMS ID or UUID")
-	}
-
-	ssas, err := authclient.NewSSASClient()
-	if err != nil {
-		return "", err
-	}
-
-	b, err := ssas.CreateGroup(id, name, *aco.CMSID)
-	if err != nil {
-		return "", err
-	}
-
-	var g map[string]interface{}
-	err = json.Unmarshal(b, &g)
-	if err != nil {
-		return "", err
-	}
-
-	ssasID := g["group_id"].(string)
-	if aco.UUID != nil {
-		aco.GroupID = ssasID
-
-		err := r.UpdateACO(context.Background(), aco.UUID,
-			map[string]interface{}{"group_id": ssasID})
-		if err != nil {
-			return ssasID, errors.Wrapf(err, "group %s was created, but ACO could not be updated", ssasID)
+	// Groups for vendors serving several ACOs are created with a comma separated list of ACO IDs
+	var acoIDs []string
+	for _, identifier := range strings.Split(acoID, ",") {
+		if identifier = strings.TrimSpace(identifier); identifier != "" {
+			acoIDs = append(acoIDs, identifier)
 		}
 	}
-
-	return ssasID, nil
+	return admin.CreateGroup(context.Background(), r, id, name, acoIDs)
 }
 
 func createACO(name, cmsID string) (string, error) {
-	if name == "" {
-		return "", errors.New("ACO name (--name) must be provided")
-	}
-
-	var cmsIDPt *string
-	if cmsID != "" {
-		match := service.IsSupportedACO(cmsID)
-		if !match {
-			return "", errors.New("ACO CMS ID (--cms-id) is invalid")
-		}
-		cmsIDPt = &cmsID
-	}
-
-	id := uuid.NewRandom()
-	aco := models.ACO{Name: name, CMSID: cmsIDPt, UUID: id, ClientID: id.String()}
-
-	err := r.CreateACO(context.Background(), aco)
+	aco, err := admin.CreateACO(context.Background(), r, name, cmsID)
 	if err != nil {
 		return "", err
 	}
-
 	return aco.UUID.String(), nil
 }
 
@@ -624,6 +988,24 @@ func generateClientCredentials(acoCMSID string, ips []string) (string, error) {
 	return msg, nil
 }
 
+func listCredentials(acoCMSID string) (string, error) {
+	if _, err := r.GetACOByCMSID(context.Background(), acoCMSID); err != nil {
+		return "", err
+	}
+
+	creds, err := auth.GetProvider().ListCredentials(acoCMSID)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not list credentials for %s", acoCMSID)
+	}
+
+	b, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
 func revokeAccessToken(accessToken string) error {
 	if accessToken == "" {
 		return errors.New("Access token (--access-token) must be provided")
@@ -716,16 +1098,6 @@ func cleanupJobData(jobID uint, rootDirs ...string) error {
 	return nil
 }
 
-func setBlacklistState(cmsID string, blacklistState bool) error {
-	ctx := context.Background()
-	aco, err := r.GetACOByCMSID(ctx, cmsID)
-	if err != nil {
-		return err
-	}
-	return r.UpdateACO(context.Background(), aco.UUID,
-		map[string]interface{}{"blacklisted": blacklistState})
-}
-
 // CCLF file name pattern and regex
 const cclfPattern = `((?:T|P).*\.ZC[A-B0-9]*)Y(\d{2}\.D\d{6}\.T\d{7})`
 
@@ -811,3 +1183,570 @@ func genPublicKey(publicKey io.Reader) (string, error) {
 	}
 	return string(k), nil
 }
+
+// writeDryRunReport writes the report as JSON to the file found at path, or to w if no path is supplied.
+// An error is returned when the report contains any problems so callers can rely on the exit status.
+func writeDryRunReport(w io.Writer, path string, report *dryrun.Report) error {
+	if path != "" {
+		f, err := os.Create(filepath.Clean(path))
+		if err != nil {
+			return errors.Wrap(err, "failed to create dry run report")
+		}
+		defer utils.CloseFileAndLogError(f)
+		w = f
+	}
+
+	if err := report.Write(w); err != nil {
+		return errors.Wrap(err, "failed to write dry run report")
+	}
+
+	if count := report.ProblemCount(); count > 0 {
+		return fmt.Errorf("dry run found %d problems in %s", count, report.Directory)
+	}
+	return nil
+}
+
+// runIngestDaemon polls each configured inbound directory until the process is asked to stop.
+// A source is disabled when its inbound directory is not configured.
+func runIngestDaemon(healthPort int) error {
+	candidates := []ingest.Source{
+		{Name: "cclf", Dir: conf.GetEnv("CCLF_INBOUND_DIR"), Import: cclf.ImportCCLFDirectory},
+		{Name: "suppression", Dir: conf.GetEnv("SUPPRESSION_INBOUND_DIR"), Import: suppression.ImportSuppressionDirectory},
+		{Name: "alr", Dir: conf.GetEnv("ALR_INBOUND_DIR"), Import: alr.ImportALRDirectory},
+	}
+
+	var sources []ingest.Source
+	for _, src := range candidates {
+		if src.Dir == "" {
+			log.Infof("No inbound directory configured for %s deliveries", src.Name)
+			continue
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return errors.New("no inbound directories configured; set CCLF_INBOUND_DIR, SUPPRESSION_INBOUND_DIR, or ALR_INBOUND_DIR")
+	}
+
+	cfg := ingest.Config{
+		PollInterval: time.Duration(utils.GetEnvInt("INGEST_POLL_INTERVAL_SEC", 60)) * time.Second,
+		StableFor:    time.Duration(utils.GetEnvInt("INGEST_STABLE_SEC", 120)) * time.Second,
+		RetryAfter:   time.Duration(utils.GetEnvInt("INGEST_RETRY_INTERVAL_MIN", 60)) * time.Minute,
+	}
+	daemon := ingest.NewDaemon(cfg, ingest.NewAdvisoryLocker(db), sources...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/_health", daemon)
+	srv := &http.Server{
+		Handler:      mux,
+		Addr:         fmt.Sprintf(":%d", healthPort),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Infof("Received %s, stopping ingest daemon after the current import completes", sig)
+		cancel()
+	}()
+
+	log.Infof("Starting ingest daemon. Polling every %s", cfg.PollInterval)
+	daemon.Run(ctx)
+
+	return srv.Shutdown(context.Background())
+}
+
+// serveMockBFD serves the synthetic beneficiary data until the process is interrupted.
+// Clients must present a certificate signed by one of the CAs found in the clientCAFile.
+func serveMockBFD(cfg mockbfd.Config, port int, certFile, keyFile, clientCAFile string) error {
+	if certFile == "" || keyFile == "" || clientCAFile == "" {
+		return errors.New("server certificate (--cert-file), private key (--key-file), and client CA (--client-ca-file) are required")
+	}
+
+	caCert, err := ioutil.ReadFile(filepath.Clean(clientCAFile))
+	if err != nil {
+		return errors.Wrap(err, "could not read client CA file")
+	}
+	clientCAs := x509.NewCertPool()
+	if ok := clientCAs.AppendCertsFromPEM(caCert); !ok {
+		return errors.New("could not append client CA certificate(s)")
+	}
+
+	// Beneficiaries are looked up by the MBIs that have been imported via CCLF
+	mbis := func(ctx context.Context) ([]string, error) {
+		rows, err := db.QueryContext(ctx, "SELECT DISTINCT mbi FROM cclf_beneficiaries")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var mbis []string
+		for rows.Next() {
+			var mbi string
+			if err := rows.Scan(&mbi); err != nil {
+				return nil, err
+			}
+			mbis = append(mbis, mbi)
+		}
+		return mbis, rows.Err()
+	}
+
+	handler, err := mockbfd.NewServer(cfg, mbis)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Handler: handler,
+		Addr:    fmt.Sprintf(":%d", port),
+		TLSConfig: &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+			MinVersion: tls.VersionTLS12,
+		},
+		ReadTimeout: 5 * time.Second,
+		// Allow for the injected latency
+		WriteTimeout: 30*time.Second + cfg.Latency,
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Infof("Received %s, stopping mock BFD", sig)
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Error(err)
+		}
+	}()
+
+	log.Infof("Serving mock BFD on port %d from %s", port, cfg.DataDir)
+	if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// attributionDiff writes the attribution changes for the ACO as JSON.
+func attributionDiff(w io.Writer, cmsID, since string) error {
+	if cmsID == "" {
+		return errors.New("CMS ID (--cms-id) is required")
+	}
+
+	var sinceTime time.Time
+	if since != "" {
+		var err error
+		if sinceTime, err = time.Parse(time.RFC3339Nano, since); err != nil {
+			return errors.Wrapf(err, "invalid since value %s, expected RFC3339 format", since)
+		}
+	}
+
+	ctx := context.Background()
+	aco, err := r.GetACOByCMSID(ctx, cmsID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find ACO %s", cmsID)
+	}
+
+	cfg, err := service.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load service config")
+	}
+
+	changes, err := service.NewService(r, cfg, "").GetAttributionChanges(ctx,
+		service.RequestConditions{CMSID: cmsID, ACOID: aco.UUID, Since: sinceTime})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(changes)
+}
+
+// jobPriority writes the priority and lane of the queue jobs created for a hypothetical request
+// along with how those queue jobs are aged while they wait to be worked on.
+func jobPriority(w io.Writer, cmsID, resourceType string, since, runout bool, ageMinutes int) error {
+	if cmsID == "" {
+		return errors.New("CMS ID (--cms-id) is required")
+	}
+	if !utils.ContainsString([]string{"Patient", "Coverage", "ExplanationOfBenefit"}, resourceType) {
+		return fmt.Errorf("invalid resource type (--type) %q, expected Patient, Coverage, or ExplanationOfBenefit", resourceType)
+	}
+
+	cfg, err := service.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load service config")
+	}
+
+	req := service.PriorityRequest{CMSID: cmsID, ResourceType: resourceType, Since: since, ReqType: service.DefaultRequest,
+		CreatedAt: time.Now().Add(-time.Duration(ageMinutes) * time.Minute)}
+	if runout {
+		req.ReqType = service.Runout
+	}
+	priority := service.NewService(r, cfg, "").GetJobPriority(req)
+
+	fmt.Fprintf(w, "Priority: %d\nLane: %s\n", priority, queueing.Lane(resourceType))
+	if aging := cfg.PriorityAging; aging.Enabled() {
+		fmt.Fprintf(w, "Every %d minute(s) a queue job waits, its priority is reduced by %d "+
+			"without moving ahead of the priority tier above it (tiers: %v)\n",
+			aging.ThresholdMinutes, aging.Step, cfg.PriorityTiers())
+	} else {
+		fmt.Fprintln(w, "Queue jobs are not aged")
+	}
+	return nil
+}
+
+// showJob writes the job along with the files it produced and its queue jobs that remain in the queue.
+// Queue jobs that have errored are retried by the worker until they succeed and include the last error encountered.
+func showJob(w io.Writer, jobID uint) error {
+	if jobID == 0 {
+		return errors.New("job ID (--id) is required")
+	}
+
+	ctx := context.Background()
+	job, err := r.GetJobByID(ctx, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find job %d", jobID)
+	}
+
+	cmsID := "unknown"
+	if aco, err := r.GetACOByUUID(ctx, job.ACOID); err != nil {
+		log.Warnf("Unable to find ACO %s for job %d: %s", job.ACOID, jobID, err.Error())
+	} else if aco.CMSID != nil {
+		cmsID = *aco.CMSID
+	}
+
+	keys, err := r.GetJobKeys(ctx, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to retrieve files for job %d", jobID)
+	}
+
+	queueJobs, err := queueing.GetQueueJobs(database.QueueConnection, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to retrieve queue jobs for job %d", jobID)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", job.ID)
+	fmt.Fprintf(tw, "ACO:\t%s (%s)\n", cmsID, job.ACOID)
+	fmt.Fprintf(tw, "Status:\t%s\n", job.StatusMessage())
+	fmt.Fprintf(tw, "Request URL:\t%s\n", job.RequestURL)
+	fmt.Fprintf(tw, "BFD transaction time:\t%s\n", formatTime(job.TransactionTime))
+	fmt.Fprintf(tw, "Queue jobs completed:\t%d of %d\n", len(keys), job.JobCount)
+	fmt.Fprintf(tw, "Queue jobs dead-lettered:\t%d\n", job.DeadLetterCount)
+	fmt.Fprintf(tw, "Created:\t%s\n", formatTime(job.CreatedAt))
+	fmt.Fprintf(tw, "Updated:\t%s\n", formatTime(job.UpdatedAt))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nFiles (%d):\n", len(keys))
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE TYPE\tFILE")
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t%s\n", key.ResourceType, strings.TrimSpace(key.FileName))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nQueue jobs in the queue (%d):\n", len(queueJobs))
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE JOB\tRESOURCE TYPE\tBENEFICIARIES\tPRIORITY\tRUN AT\tWORKING\tERRORS\tLAST ERROR")
+	for _, queueJob := range queueJobs {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%t\t%d\t%s\n", queueJob.ID, queueJob.Args.ResourceType,
+			len(queueJob.Args.BeneficiaryIDs), queueJob.Priority, formatTime(queueJob.RunAt), queueJob.Working,
+			queueJob.ErrorCount, firstLine(queueJob.LastError))
+	}
+	return tw.Flush()
+}
+
+// listJobs writes the ACO's jobs with the given statuses, or all of its jobs when no statuses are given.
+func listJobs(w io.Writer, cmsID, statuses string) error {
+	if cmsID == "" {
+		return errors.New("CMS ID (--cms-id) is required")
+	}
+
+	var jobStatuses []models.JobStatus
+	if statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			jobStatus := models.JobStatus(strings.TrimSpace(status))
+			if !isJobStatus(jobStatus) {
+				return fmt.Errorf("invalid job status (--status) %q", jobStatus)
+			}
+			jobStatuses = append(jobStatuses, jobStatus)
+		}
+	}
+
+	ctx := context.Background()
+	aco, err := r.GetACOByCMSID(ctx, cmsID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find ACO %s", cmsID)
+	}
+
+	jobs, err := r.GetJobs(ctx, aco.UUID, jobStatuses...)
+	if err != nil {
+		return err
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tQUEUE JOBS\tQUEUED\tERRORS\tCREATED\tUPDATED\tREQUEST URL")
+	for _, job := range jobs {
+		queueJobs, err := queueing.GetQueueJobs(database.QueueConnection, job.ID)
+		if err != nil {
+			return errors.Wrapf(err, "unable to retrieve queue jobs for job %d", job.ID)
+		}
+		var errorCount int32
+		for _, queueJob := range queueJobs {
+			errorCount += queueJob.ErrorCount
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", job.ID, job.Status, job.JobCount, len(queueJobs),
+			errorCount, formatTime(job.CreatedAt), formatTime(job.UpdatedAt), job.RequestURL)
+	}
+	return tw.Flush()
+}
+
+// cancelJob cancels the pending or in progress job, removing its queue jobs from the queue.
+func cancelJob(w io.Writer, jobID uint) error {
+	if jobID == 0 {
+		return errors.New("job ID (--id) is required")
+	}
+
+	cfg, err := service.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load service config")
+	}
+
+	ctx := context.Background()
+	if _, err := service.NewService(r, cfg, "").CancelJob(ctx, jobID); err != nil {
+		return errors.Wrapf(err, "unable to cancel job %d", jobID)
+	}
+	fmt.Fprintf(w, "Cancelled job %d\n", jobID)
+
+	return removeQueueJobs(ctx, w, jobID)
+}
+
+// requeueJob schedules the failed queue jobs of the pending or in progress job to be retried immediately.
+// Queue jobs being worked on are not requeued, so a queue job is never worked on by more than one worker.
+func requeueJob(w io.Writer, jobID uint) error {
+	if jobID == 0 {
+		return errors.New("job ID (--id) is required")
+	}
+
+	job, err := r.GetJobByID(context.Background(), jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find job %d", jobID)
+	}
+	if job.Status != models.JobStatusPending && job.Status != models.JobStatusInProgress {
+		return fmt.Errorf("job %d is %s, only the queue jobs of pending or in progress jobs can be requeued", jobID, job.Status)
+	}
+
+	count, err := queueing.RetryFailedQueueJobs(database.QueueConnection, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to requeue queue jobs for job %d", jobID)
+	}
+	fmt.Fprintf(w, "Requeued %d failed queue job(s) for job %d\n", count, jobID)
+	return nil
+}
+
+// failJob fails the pending or in progress job, removing its queue jobs from the queue.
+func failJob(w io.Writer, jobID uint) error {
+	if jobID == 0 {
+		return errors.New("job ID (--id) is required")
+	}
+
+	ctx := context.Background()
+	job, err := r.GetJobByID(ctx, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find job %d", jobID)
+	}
+	if job.Status != models.JobStatusPending && job.Status != models.JobStatusInProgress {
+		return fmt.Errorf("job %d is %s, only pending or in progress jobs can be failed", jobID, job.Status)
+	}
+
+	// The job may have been completed (or failed) by a worker since it was retrieved
+	if err := r.UpdateJobStatusCheckStatus(ctx, jobID, job.Status, models.JobStatusFailed); err != nil {
+		return errors.Wrapf(err, "unable to fail job %d", jobID)
+	}
+	fmt.Fprintf(w, "Failed job %d\n", jobID)
+
+	return removeQueueJobs(ctx, w, jobID)
+}
+
+// removeQueueJobs removes the queue jobs of a job that was cancelled or failed from the queue.
+func removeQueueJobs(ctx context.Context, w io.Writer, jobID uint) error {
+	count, err := queueing.DeleteQueueJobs(database.QueueConnection, jobID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to remove queue jobs for job %d", jobID)
+	}
+	fmt.Fprintf(w, "Removed %d queue job(s) from the queue\n", count)
+
+	// The job's queue jobs will never need to be re-enqueued
+	if err := r.DeletePlannedQueueJobs(ctx, jobID); err != nil {
+		log.Warnf("Failed to delete planned queue jobs for job %d. Will continue. %s", jobID, err.Error())
+	}
+	return nil
+}
+
+// listDeadLetters writes the dead-lettered queue jobs of the job, or all of them when jobID is zero.
+func listDeadLetters(w io.Writer, jobID uint) error {
+	deadLetters, err := r.GetDeadLetterQueueJobs(context.Background(), jobID)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tJOB\tRESOURCE TYPE\tPRIORITY\tERRORS\tCREATED\tREASON\tLAST ERROR")
+	for _, deadLetter := range deadLetters {
+		resourceType := "-"
+		var args models.JobEnqueueArgs
+		if err := json.Unmarshal(deadLetter.Args, &args); err == nil && args.ResourceType != "" {
+			resourceType = args.ResourceType
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%d\t%s\t%s\t%s\n", deadLetter.ID, deadLetter.JobID, resourceType,
+			deadLetter.Priority, deadLetter.ErrorCount, formatTime(deadLetter.CreatedAt), deadLetter.Reason,
+			firstLine(deadLetter.LastError))
+	}
+	return tw.Flush()
+}
+
+// replayDeadLetter enqueues the dead-lettered queue job with its original args and priority.
+// The queue job is planned again, so it can be re-enqueued if it is lost from the queue.
+func replayDeadLetter(w io.Writer, id uint) error {
+	if id == 0 {
+		return errors.New("dead-lettered queue job ID (--id) is required")
+	}
+
+	ctx := context.Background()
+	deadLetter, err := r.GetDeadLetterQueueJob(ctx, id)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find dead-lettered queue job %d", id)
+	}
+
+	var args models.JobEnqueueArgs
+	if err := json.Unmarshal(deadLetter.Args, &args); err != nil {
+		return fmt.Errorf("dead-lettered queue job %d cannot be replayed since its args are invalid, discard it instead: %s",
+			id, err.Error())
+	}
+
+	job, err := r.GetJobByID(ctx, uint(args.ID))
+	if err != nil {
+		return errors.Wrapf(err, "unable to find job %d", args.ID)
+	}
+	if job.Status != models.JobStatusPending && job.Status != models.JobStatusInProgress {
+		return fmt.Errorf("job %d is %s, only the queue jobs of pending or in progress jobs can be replayed", job.ID, job.Status)
+	}
+
+	if err := r.CreatePlannedQueueJobs(ctx, job.ID, []*models.JobEnqueueArgs{&args}, deadLetter.Priority); err != nil {
+		return errors.Wrapf(err, "unable to plan queue job for job %d", job.ID)
+	}
+	if err := queueing.NewEnqueuer().AddJob(args, deadLetter.Priority); err != nil {
+		return errors.Wrapf(err, "unable to enqueue queue job for job %d", job.ID)
+	}
+	if err := r.DeleteDeadLetterQueueJob(ctx, id); err != nil {
+		return errors.Wrapf(err, "replayed dead-lettered queue job %d but was unable to delete it, delete it before replaying it again", id)
+	}
+
+	fmt.Fprintf(w, "Replayed dead-lettered queue job %d of job %d\n", id, job.ID)
+	return nil
+}
+
+// discardDeadLetter deletes the dead-lettered queue job. Since the job can no longer be completed,
+// the job is failed if it is pending or in progress.
+func discardDeadLetter(w io.Writer, id uint) error {
+	if id == 0 {
+		return errors.New("dead-lettered queue job ID (--id) is required")
+	}
+
+	ctx := context.Background()
+	deadLetter, err := r.GetDeadLetterQueueJob(ctx, id)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find dead-lettered queue job %d", id)
+	}
+	if err := r.DeleteDeadLetterQueueJob(ctx, id); err != nil {
+		return errors.Wrapf(err, "unable to discard dead-lettered queue job %d", id)
+	}
+	fmt.Fprintf(w, "Discarded dead-lettered queue job %d\n", id)
+
+	if deadLetter.JobID == 0 {
+		return nil
+	}
+	job, err := r.GetJobByID(ctx, deadLetter.JobID)
+	if err != nil {
+		log.Warnf("Unable to find job %d of discarded dead-lettered queue job %d: %s", deadLetter.JobID, id, err.Error())
+		return nil
+	}
+	if job.Status != models.JobStatusPending && job.Status != models.JobStatusInProgress {
+		return nil
+	}
+	return failJob(w, job.ID)
+}
+
+func isJobStatus(status models.JobStatus) bool {
+	for _, s := range models.AllJobStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// firstLine returns the first line of a (possibly multi-line) error message
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// suppressionReport writes the suppressions that apply to the ACO's attributed beneficiaries as CSV.
+func suppressionReport(w io.Writer, cmsID string) error {
+	if cmsID == "" {
+		return errors.New("CMS ID (--cms-id) is required")
+	}
+
+	ctx := context.Background()
+	aco, err := r.GetACOByCMSID(ctx, cmsID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find ACO %s", cmsID)
+	}
+
+	cfg, err := service.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load service config")
+	}
+
+	suppressions, err := service.NewService(r, cfg, "").GetSuppressionReport(ctx,
+		service.RequestConditions{CMSID: cmsID, ACOID: aco.UUID})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"mbi", "reason", "suppression_mbi", "effective_date", "aco_cms_id", "suppression_file_id"}); err != nil {
+		return err
+	}
+	for _, s := range suppressions {
+		record := []string{s.MBI, string(s.Reason), s.SuppressionMBI, s.EffectiveDt.Format("2006-01-02"),
+			s.ACOCMSID, strconv.FormatUint(uint64(s.SuppressionFileID), 10)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
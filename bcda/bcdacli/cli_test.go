@@ -3,9 +3,13 @@ package bcdacli
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -13,17 +17,24 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/auth"
+	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
 	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/models/postgres"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres/postgrestest"
+	"github.com/CMSgov/bcda-app/bcda/service"
 	"github.com/CMSgov/bcda-app/bcda/testUtils"
 	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
+	workerpostgres "github.com/CMSgov/bcda-app/bcdaworker/repository/postgres"
 	"github.com/CMSgov/bcda-app/conf"
 
 	"github.com/go-chi/chi"
@@ -234,6 +245,61 @@ func (s *CLITestSuite) TestResetSecretCLI() {
 
 }
 
+func (s *CLITestSuite) TestListCredentials() {
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+	assert := assert.New(s.T())
+
+	cmsID := "A8880"
+	aco := postgrestest.GetACOByCMSID(s.T(), s.db, cmsID)
+	aco.AlphaSecret = ""
+	postgrestest.UpdateACO(s.T(), s.db, aco)
+
+	creds, err := auth.GetProvider().RegisterSystem(aco.UUID.String(), "", aco.GroupID)
+	assert.NoError(err)
+
+	args := []string{"bcda", "list-credentials", "--cms-id", cmsID}
+	assert.NoError(s.testApp.Run(args))
+	var infos []auth.CredentialInfo
+	assert.NoError(json.Unmarshal(buf.Bytes(), &infos))
+	assert.Len(infos, 1)
+	assert.Equal(creds.ClientID, infos[0].ClientID)
+	assert.False(infos[0].Revoked)
+	buf.Reset()
+
+	args = []string{"bcda", "list-credentials"}
+	assert.EqualError(s.testApp.Run(args), "ACO CMS ID (--cms-id) is required")
+
+	args = []string{"bcda", "list-credentials", "--cms-id", "BLAH"}
+	assert.EqualError(s.testApp.Run(args), "no ACO record found for BLAH")
+	assert.Empty(buf)
+}
+
+func (s *CLITestSuite) TestClearAuthLockout() {
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+	assert := assert.New(s.T())
+
+	clientID := uuid.New()
+	repository := postgres.NewRepository(s.db)
+	assert.NoError(repository.SaveAuthLockout(context.Background(),
+		models.AuthLockout{Type: models.LockoutClientID, Subject: clientID, FailedAttempts: 10, LockedUntil: time.Now().Add(time.Hour)}))
+
+	args := []string{"bcda", "clear-auth-lockout", "--client-id", clientID}
+	assert.NoError(s.testApp.Run(args))
+	assert.Contains(buf.String(), fmt.Sprintf("Lockout cleared for client_id %s", clientID))
+	buf.Reset()
+
+	// Lockout no longer exists
+	assert.EqualError(s.testApp.Run(args), fmt.Sprintf("no lockout found for client_id %s", clientID))
+
+	args = []string{"bcda", "clear-auth-lockout"}
+	assert.EqualError(s.testApp.Run(args), "exactly one of client ID (--client-id) or IP address (--ip) is required")
+
+	args = []string{"bcda", "clear-auth-lockout", "--client-id", clientID, "--ip", "127.0.0.1"}
+	assert.EqualError(s.testApp.Run(args), "exactly one of client ID (--client-id) or IP address (--ip) is required")
+}
+
 func (s *CLITestSuite) TestArchiveExpiring() {
 	assert := assert.New(s.T())
 
@@ -577,6 +643,46 @@ func (s *CLITestSuite) TestCreateGroup() {
 	assert.Equal("test-create-group-id", buf.String())
 }
 
+func (s *CLITestSuite) TestCreateGroup_MultipleACOs() {
+	var xData string
+	router := chi.NewRouter()
+	router.Post("/group", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Fatal(err)
+		}
+		xData = body["xdata"].(string)
+		w.WriteHeader(http.StatusCreated)
+		_, err := w.Write([]byte(`{ "ID": 101, "group_id": "test-vendor-group-id" }`))
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+	server := httptest.NewServer(router)
+
+	origSSASURL := conf.GetEnv("SSAS_URL")
+	conf.SetEnv(s.T(), "SSAS_URL", server.URL)
+	defer conf.SetEnv(s.T(), "SSAS_URL", origSSASURL)
+
+	origSSASUseTLS := conf.GetEnv("SSAS_USE_TLS")
+	conf.SetEnv(s.T(), "SSAS_USE_TLS", "false")
+	defer conf.SetEnv(s.T(), "SSAS_USE_TLS", origSSASUseTLS)
+
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+
+	before := postgrestest.GetACOByCMSID(s.T(), s.db, "A9994")
+	args := []string{"bcda", "create-group", "--id", "unit-test-vendor-group", "--name", "Unit Test Vendor Group", "--aco-id", "A9994, A9995"}
+	err := s.testApp.Run(args)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "test-vendor-group-id", buf.String())
+	assert.JSONEq(s.T(), `{"cms_ids": ["A9994", "A9995"]}`, xData)
+
+	// Vendor groups are not associated with any single ACO
+	after := postgrestest.GetACOByCMSID(s.T(), s.db, "A9994")
+	assert.Equal(s.T(), before.GroupID, after.GroupID)
+}
+
 func (s *CLITestSuite) TestCreateGroup_InvalidACOID() {
 	buf := new(bytes.Buffer)
 	s.testApp.Writer = buf
@@ -584,7 +690,7 @@ func (s *CLITestSuite) TestCreateGroup_InvalidACOID() {
 	// Invalid format
 	args := []string{"bcda", "create-group", "--id", "invalid-aco-id-group", "--name", "Invalid ACO ID Group", "--aco-id", "1234"}
 	err := s.testApp.Run(args)
-	assert.EqualError(s.T(), err, "ACO ID (--aco-id) must be a supported CMS ID or UUID")
+	assert.EqualError(s.T(), err, "ACO ID must be a supported CMS ID or UUID")
 	assert.Empty(s.T(), buf.String())
 	buf.Reset()
 
@@ -633,7 +739,7 @@ func (s *CLITestSuite) TestCreateACO() {
 	// No parameters
 	args = []string{"bcda", "create-aco"}
 	err = s.testApp.Run(args)
-	assert.Equal("ACO name (--name) must be provided", err.Error())
+	assert.Equal("ACO name must be provided", err.Error())
 	assert.Equal(0, buf.Len())
 	buf.Reset()
 
@@ -641,14 +747,14 @@ func (s *CLITestSuite) TestCreateACO() {
 	badACO := ""
 	args = []string{"bcda", "create-aco", "--name", badACO}
 	err = s.testApp.Run(args)
-	assert.Equal("ACO name (--name) must be provided", err.Error())
+	assert.Equal("ACO name must be provided", err.Error())
 	assert.Equal(0, buf.Len())
 	buf.Reset()
 
 	// ACO name without flag
 	args = []string{"bcda", "create-aco", ACOName}
 	err = s.testApp.Run(args)
-	assert.Equal("ACO name (--name) must be provided", err.Error())
+	assert.Equal("ACO name must be provided", err.Error())
 	assert.Equal(0, buf.Len())
 	buf.Reset()
 
@@ -662,7 +768,7 @@ func (s *CLITestSuite) TestCreateACO() {
 	// Invalid CMS ID
 	args = []string{"bcda", "create-aco", "--name", ACOName, "--cms-id", "ABCDE"}
 	err = s.testApp.Run(args)
-	assert.Equal("ACO CMS ID (--cms-id) is invalid", err.Error())
+	assert.Equal("ACO CMS ID is invalid", err.Error())
 	assert.Equal(0, buf.Len())
 	buf.Reset()
 }
@@ -685,13 +791,40 @@ func (s *CLITestSuite) TestImportCCLFDirectory() {
 	err := s.testApp.Run(args)
 	assert.Nil(err)
 	assert.Contains(buf.String(), "Completed CCLF import.")
-	assert.Contains(buf.String(), "Successfully imported 2 files.")
+	// CCLF0, CCLF8, and CCLF9
+	assert.Contains(buf.String(), "Successfully imported 3 files.")
 	assert.Contains(buf.String(), "Failed to import 0 files.")
 	assert.Contains(buf.String(), "Skipped 1 files.")
 
 	buf.Reset()
 }
 
+func (s *CLITestSuite) TestImportCCLFDirectory_DryRun() {
+	assert := assert.New(s.T())
+
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+
+	path, cleanup := testUtils.CopyToTemporaryDirectory(s.T(), "../../shared_files/cclf/archives/valid2/")
+	defer cleanup()
+
+	args := []string{"bcda", "import-cclf-directory", "--directory", path, "--dry-run"}
+	err := s.testApp.Run(args)
+	// The ACOB archive does not follow the naming convention
+	assert.EqualError(err, fmt.Sprintf("dry run found 1 problems in %s", path))
+
+	var report dryrun.Report
+	assert.NoError(json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal("cclf", report.FileType)
+	assert.Len(report.Files, 4)
+
+	// Nothing is imported or moved
+	files, err := ioutil.ReadDir(path)
+	assert.NoError(err)
+	assert.Len(files, 4)
+	assert.Empty(postgrestest.GetCCLFFilesByCMSID(s.T(), s.db, "A0002"))
+}
+
 func (s *CLITestSuite) TestDeleteDirectoryContents() {
 	assert := assert.New(s.T())
 	buf := new(bytes.Buffer)
@@ -790,6 +923,161 @@ func (s *CLITestSuite) TestImportSuppressionDirectory_Failed() {
 	assert.Contains(buf.String(), "Files skipped: 0")
 }
 
+func (s *CLITestSuite) TestImportSuppressionDirectory_DryRun() {
+	assert := assert.New(s.T())
+
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+
+	path, cleanup := testUtils.CopyToTemporaryDirectory(s.T(), "../../shared_files/synthetic1800MedicareFiles/test2/")
+	defer cleanup()
+
+	reportFile, err := ioutil.TempFile("", "report")
+	assert.NoError(err)
+	defer os.Remove(reportFile.Name())
+
+	args := []string{"bcda", "import-suppression-directory", "--directory", path, "--dry-run", "--report", reportFile.Name()}
+	err = s.testApp.Run(args)
+	assert.NoError(err)
+	assert.Empty(buf.String())
+
+	b, err := ioutil.ReadFile(reportFile.Name())
+	assert.NoError(err)
+	var report dryrun.Report
+	assert.NoError(json.Unmarshal(b, &report))
+	assert.Equal("suppression", report.FileType)
+	assert.Len(report.Files, 2)
+	assert.Equal(0, report.ProblemCount())
+
+	assert.Empty(postgrestest.GetSuppressionFileByName(s.T(), s.db,
+		"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000010",
+		"T#EFT.ON.ACO.NGD1800.DPRF.D190816.T0241391"))
+}
+
+func (s *CLITestSuite) TestIngestDaemon_NoInboundDirectories() {
+	for _, key := range []string{"CCLF_INBOUND_DIR", "SUPPRESSION_INBOUND_DIR", "ALR_INBOUND_DIR"} {
+		defer conf.SetEnv(s.T(), key, conf.GetEnv(key))
+		conf.UnsetEnv(s.T(), key)
+	}
+
+	args := []string{"bcda", "ingest-daemon", "--health-port", "0"}
+	err := s.testApp.Run(args)
+	assert.EqualError(s.T(), err, "no inbound directories configured; set CCLF_INBOUND_DIR, SUPPRESSION_INBOUND_DIR, or ALR_INBOUND_DIR")
+}
+
+func (s *CLITestSuite) TestServeMockBFD_InvalidArgs() {
+	args := []string{"bcda", "serve-mock-bfd", "--https-port", "0"}
+	err := s.testApp.Run(args)
+	assert.EqualError(s.T(), err, "server certificate (--cert-file), private key (--key-file), and client CA (--client-ca-file) are required")
+
+	args = []string{"bcda", "serve-mock-bfd", "--https-port", "0", "--cert-file", "../../shared_files/localhost.crt",
+		"--key-file", "../../shared_files/localhost.key", "--client-ca-file", "../../shared_files/localhost.crt",
+		"--data-dir", "../../shared_files/synthetic_beneficiary_data", "--error-rate", "1.5"}
+	err = s.testApp.Run(args)
+	assert.EqualError(s.T(), err, "invalid error rate 1.500000, must be between 0 and 1")
+}
+
+func (s *CLITestSuite) TestAttributionDiff() {
+	assert := assert.New(s.T())
+	cmsID := testUtils.RandomHexID()[0:4]
+	aco := models.ACO{UUID: uuid.NewUUID(), CMSID: &cmsID}
+	postgrestest.CreateACO(s.T(), s.db, aco)
+	defer func() {
+		postgrestest.DeleteCCLFFilesByCMSID(s.T(), s.db, cmsID)
+		postgrestest.DeleteACO(s.T(), s.db, aco.UUID)
+	}()
+
+	oldFile := &models.CCLFFile{CCLFNum: 8, ACOCMSID: cmsID, Name: "old" + cmsID, Timestamp: time.Now().Add(-2 * time.Hour),
+		PerformanceYear: 20, ImportStatus: constants.ImportComplete, Type: models.FileTypeDefault}
+	newFile := &models.CCLFFile{CCLFNum: 8, ACOCMSID: cmsID, Name: "new" + cmsID, Timestamp: time.Now().Add(-1 * time.Hour),
+		PerformanceYear: 20, ImportStatus: constants.ImportComplete, Type: models.FileTypeDefault}
+	postgrestest.CreateCCLFFile(s.T(), s.db, oldFile)
+	postgrestest.CreateCCLFFile(s.T(), s.db, newFile)
+	for _, mbi := range []string{"MBI1", "MBI2"} {
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &models.CCLFBeneficiary{FileID: oldFile.ID, MBI: mbi})
+	}
+	// The opted out beneficiary is omitted from the changes, the same as it is from exports
+	optedOutMBI := testUtils.RandomMBI(s.T())
+	for _, mbi := range []string{"MBI2", "MBI3", optedOutMBI} {
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &models.CCLFBeneficiary{FileID: newFile.ID, MBI: mbi})
+	}
+	fileID := uint(rand.Int31())
+	defer postgrestest.DeleteSuppressionFileByID(s.T(), s.db, fileID)
+	assert.NoError(postgres.NewRepository(s.db).CreateSuppression(context.Background(), models.Suppression{FileID: fileID,
+		MBI: optedOutMBI, PrefIndicator: "N", EffectiveDt: time.Now().Add(-24 * time.Hour), ACOCMSID: cmsID}))
+
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+	assert.NoError(s.testApp.Run([]string{"bcda", "attribution-diff", "--cms-id", cmsID}))
+
+	var changes service.AttributionChanges
+	assert.NoError(json.Unmarshal(buf.Bytes(), &changes))
+	assert.Equal(newFile.Name, changes.CurrentFile.Name)
+	assert.Equal(oldFile.Name, changes.PreviousFile.Name)
+	assert.Equal([]string{"MBI3"}, changes.Added)
+	assert.Equal([]string{"MBI1"}, changes.Removed)
+	assert.Equal(1, changes.AddedCount)
+	assert.Equal(1, changes.RemovedCount)
+
+	// Comparing against a time before either delivery treats every beneficiary as added
+	buf.Reset()
+	since := time.Now().Add(-3 * time.Hour).Format(time.RFC3339)
+	assert.NoError(s.testApp.Run([]string{"bcda", "attribution-diff", "--cms-id", cmsID, "--since", since}))
+	changes = service.AttributionChanges{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &changes))
+	assert.Nil(changes.PreviousFile)
+	assert.Equal([]string{"MBI2", "MBI3"}, changes.Added)
+
+	assert.EqualError(s.testApp.Run([]string{"bcda", "attribution-diff"}), "CMS ID (--cms-id) is required")
+	assert.Contains(s.testApp.Run([]string{"bcda", "attribution-diff", "--cms-id", cmsID, "--since", "yesterday"}).Error(),
+		"invalid since value yesterday")
+}
+
+func (s *CLITestSuite) TestSuppressionReport() {
+	assert := assert.New(s.T())
+	cmsID := testUtils.RandomHexID()[0:4]
+	aco := models.ACO{UUID: uuid.NewUUID(), CMSID: &cmsID}
+	postgrestest.CreateACO(s.T(), s.db, aco)
+	defer func() {
+		postgrestest.DeleteCCLFFilesByCMSID(s.T(), s.db, cmsID)
+		postgrestest.DeleteACO(s.T(), s.db, aco.UUID)
+	}()
+
+	cclfFile := &models.CCLFFile{CCLFNum: 8, ACOCMSID: cmsID, Name: "suppression" + cmsID, Timestamp: time.Now().Add(-2 * time.Hour),
+		PerformanceYear: 20, ImportStatus: constants.ImportComplete, Type: models.FileTypeDefault}
+	postgrestest.CreateCCLFFile(s.T(), s.db, cclfFile)
+	mbis := []string{testUtils.RandomMBI(s.T()), testUtils.RandomMBI(s.T()), testUtils.RandomMBI(s.T())}
+	sort.Strings(mbis)
+	for _, mbi := range mbis {
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &models.CCLFBeneficiary{FileID: cclfFile.ID, MBI: mbi})
+	}
+
+	fileID := uint(rand.Int31())
+	defer postgrestest.DeleteSuppressionFileByID(s.T(), s.db, fileID)
+	effectiveDt := time.Now().Add(-24 * time.Hour)
+	for _, suppression := range []models.Suppression{
+		{FileID: fileID, MBI: mbis[0], PrefIndicator: "N", EffectiveDt: effectiveDt, ACOCMSID: cmsID},
+		{FileID: fileID, MBI: mbis[1], PrefIndicator: "Y", EffectiveDt: effectiveDt, SAMHSAPrefIndicator: "N", SAMHSAEffectiveDt: effectiveDt},
+	} {
+		assert.NoError(postgres.NewRepository(s.db).CreateSuppression(context.Background(), suppression))
+	}
+
+	buf := new(bytes.Buffer)
+	s.testApp.Writer = buf
+	assert.NoError(s.testApp.Run([]string{"bcda", "suppression-report", "--cms-id", cmsID}))
+
+	records, err := csv.NewReader(buf).ReadAll()
+	assert.NoError(err)
+	fileIDStr, dateStr := strconv.FormatUint(uint64(fileID), 10), effectiveDt.Format("2006-01-02")
+	assert.Equal([][]string{
+		{"mbi", "reason", "suppression_mbi", "effective_date", "aco_cms_id", "suppression_file_id"},
+		{mbis[0], string(models.SuppressionReasonOptOut), mbis[0], dateStr, cmsID, fileIDStr},
+		{mbis[1], string(models.SuppressionReasonSAMHSA), mbis[1], dateStr, "", fileIDStr},
+	}, records)
+
+	assert.EqualError(s.testApp.Run([]string{"bcda", "suppression-report"}), "CMS ID (--cms-id) is required")
+}
+
 func (s *CLITestSuite) TestBlacklistACO() {
 	blacklistedCMSID := testUtils.RandomHexID()[0:4]
 	notBlacklistedCMSID := testUtils.RandomHexID()[0:4]
@@ -816,6 +1104,200 @@ func (s *CLITestSuite) TestBlacklistACO() {
 	s.True(postgrestest.GetACOByUUID(s.T(), s.db, notBlacklistedACO.UUID).Blacklisted)
 }
 
+func (s *CLITestSuite) TestJobPriority() {
+	assert := assert.New(s.T())
+
+	tests := []struct {
+		name   string
+		args   []string
+		expOut string
+		expErr string
+	}{
+		{"Small resources", []string{"--cms-id", "A0000", "--type", "Patient"}, "Priority: 20\nLane: Patient\n", ""},
+		{"Limited timeframe", []string{"--cms-id", "A0000", "--type", "ExplanationOfBenefit", "--since"},
+			"Priority: 30\nLane: ExplanationOfBenefit\n", ""},
+		{"Default", []string{"--cms-id", "A0000", "--type", "ExplanationOfBenefit", "--runout"},
+			"Priority: 100\nLane: ExplanationOfBenefit\n", ""},
+		{"Missing CMS ID", []string{"--type", "Patient"}, "", "CMS ID (--cms-id) is required"},
+		{"Invalid resource type", []string{"--cms-id", "A0000", "--type", "Claim"}, "",
+			`invalid resource type (--type) "Claim", expected Patient, Coverage, or ExplanationOfBenefit`},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			s.testApp.Writer = buf
+			err := s.testApp.Run(append([]string{"bcda", "job-priority"}, tt.args...))
+			if tt.expErr != "" {
+				assert.EqualError(err, tt.expErr)
+				return
+			}
+			assert.NoError(err)
+			assert.True(strings.HasPrefix(buf.String(), tt.expOut), buf.String())
+		})
+	}
+}
+
+func (s *CLITestSuite) TestJobCommands() {
+	assert := assert.New(s.T())
+
+	cmsID := testUtils.RandomHexID()[0:4]
+	aco := models.ACO{UUID: uuid.NewRandom(), Name: uuid.New(), CMSID: &cmsID}
+	postgrestest.CreateACO(s.T(), s.db, aco)
+	defer postgrestest.DeleteACO(s.T(), s.db, aco.UUID)
+
+	transactionTime := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	job := models.Job{ACOID: aco.UUID, RequestURL: "/api/v1/Patient/$export", Status: models.JobStatusInProgress,
+		TransactionTime: transactionTime, JobCount: 3}
+	completed := models.Job{ACOID: aco.UUID, RequestURL: "/api/v1/Group/all/$export", Status: models.JobStatusCompleted,
+		TransactionTime: transactionTime, JobCount: 1}
+	postgrestest.CreateJobs(s.T(), s.db, &job, &completed)
+	defer postgrestest.DeleteJobsByACOID(s.T(), s.db, aco.UUID)
+	postgrestest.CreateJobKeys(s.T(), s.db, models.JobKey{JobID: job.ID, FileName: "patient.ndjson", ResourceType: "Patient"})
+	defer postgrestest.DeleteJobKeysByJobIDs(s.T(), s.db, job.ID)
+
+	queueDB := database.QueueConnection
+	args := []*models.JobEnqueueArgs{
+		{ID: int(job.ID), ACOID: aco.UUID.String(), ResourceType: "Patient", BeneficiaryIDs: []string{"1", "2"}},
+		{ID: int(job.ID), ACOID: aco.UUID.String(), ResourceType: "Patient", BeneficiaryIDs: []string{"3"}},
+	}
+	assert.NoError(queueing.NewEnqueuer().AddJobs(args, 20))
+	defer func() {
+		_, err := queueing.DeleteQueueJobs(queueDB, job.ID)
+		assert.NoError(err)
+	}()
+	// One of the queue jobs has failed and is waiting to be retried
+	_, err := queueDB.Exec(`UPDATE que_jobs SET error_count = 2, last_error = $1, run_at = now() + interval '1 hour'
+		WHERE job_id = (SELECT MIN(job_id) FROM que_jobs WHERE CAST(args ->> 'ID' AS INTEGER) = $2)`,
+		"failed to process job: connection refused\nstack trace", int(job.ID))
+	assert.NoError(err)
+
+	run := func(args ...string) (string, error) {
+		buf := new(bytes.Buffer)
+		s.testApp.Writer = buf
+		err := s.testApp.Run(append([]string{"bcda", "job"}, args...))
+		return buf.String(), err
+	}
+
+	out, err := run("show", "--id", strconv.Itoa(int(job.ID)))
+	assert.NoError(err)
+	assert.Regexp(fmt.Sprintf(`ACO:\s+%s \(%s\)`, cmsID, aco.UUID), out)
+	assert.Regexp(`BFD transaction time:\s+2021-03-01T12:00:00Z`, out)
+	assert.Regexp(`Queue jobs completed:\s+1 of 3`, out)
+	assert.Regexp(`Patient\s+patient.ndjson`, out)
+	assert.Contains(out, "Queue jobs in the queue (2):")
+	assert.Regexp(`Patient\s+2\s+20\s+\S+\s+false\s+2\s+failed to process job: connection refused\n`, out)
+	assert.NotContains(out, "stack trace")
+
+	out, err = run("list", "--cms-id", cmsID, "--status", "In Progress")
+	assert.NoError(err)
+	assert.Regexp(fmt.Sprintf(`%d\s+In Progress\s+3\s+2\s+2\s+`, job.ID), out)
+	assert.NotContains(out, completed.RequestURL)
+
+	out, err = run("list", "--cms-id", cmsID)
+	assert.NoError(err)
+	assert.Contains(out, completed.RequestURL)
+
+	_, err = run("list", "--cms-id", cmsID, "--status", "Running")
+	assert.EqualError(err, `invalid job status (--status) "Running"`)
+
+	out, err = run("requeue", "--id", strconv.Itoa(int(job.ID)))
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("Requeued 1 failed queue job(s) for job %d\n", job.ID), out)
+	queueJobs, err := queueing.GetQueueJobs(queueDB, job.ID)
+	assert.NoError(err)
+	for _, queueJob := range queueJobs {
+		assert.True(queueJob.RunAt.Before(time.Now()))
+	}
+
+	out, err = run("fail", "--id", strconv.Itoa(int(job.ID)))
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("Failed job %d\nRemoved 2 queue job(s) from the queue\n", job.ID), out)
+	assert.Equal(models.JobStatusFailed, postgrestest.GetJobByID(s.T(), s.db, job.ID).Status)
+	queueJobs, err = queueing.GetQueueJobs(queueDB, job.ID)
+	assert.NoError(err)
+	assert.Empty(queueJobs)
+
+	// Only pending and in progress jobs can be manipulated
+	_, err = run("requeue", "--id", strconv.Itoa(int(job.ID)))
+	assert.EqualError(err, fmt.Sprintf("job %d is Failed, only the queue jobs of pending or in progress jobs can be requeued", job.ID))
+	_, err = run("fail", "--id", strconv.Itoa(int(completed.ID)))
+	assert.EqualError(err, fmt.Sprintf("job %d is Completed, only pending or in progress jobs can be failed", completed.ID))
+	_, err = run("cancel", "--id", strconv.Itoa(int(completed.ID)))
+	assert.Contains(err.Error(), fmt.Sprintf("unable to cancel job %d", completed.ID))
+
+	_, err = run("show")
+	assert.EqualError(err, "job ID (--id) is required")
+}
+
+func (s *CLITestSuite) TestDeadLetterCommands() {
+	assert := assert.New(s.T())
+	ctx := context.Background()
+
+	job := models.Job{ACOID: s.testACO.UUID, Status: models.JobStatusInProgress, JobCount: 2}
+	postgrestest.CreateJobs(s.T(), s.db, &job)
+	defer postgrestest.DeleteJobsByACOID(s.T(), s.db, s.testACO.UUID)
+	defer func() {
+		_, err := queueing.DeleteQueueJobs(database.QueueConnection, job.ID)
+		assert.NoError(err)
+		assert.NoError(r.DeletePlannedQueueJobs(ctx, job.ID))
+	}()
+
+	workerRepository := workerpostgres.NewRepository(s.db)
+	args, err := json.Marshal(models.JobEnqueueArgs{ID: int(job.ID), ResourceType: "Patient", BeneficiaryIDs: []string{"1"}})
+	assert.NoError(err)
+	assert.NoError(workerRepository.CreateDeadLetterQueueJob(ctx, models.DeadLetterQueueJob{JobID: job.ID, QueJobID: rand.Int63(),
+		Args: args, Priority: 20, ErrorCount: 3, LastError: "could not retrieve job from database", Reason: "job not found after 4 attempt(s)"}))
+	assert.NoError(workerRepository.CreateDeadLetterQueueJob(ctx, models.DeadLetterQueueJob{JobID: job.ID, QueJobID: rand.Int63(),
+		Args: []byte(`{"ID": "invalid"}`), Reason: "failed to deserialize args"}))
+	assert.Equal(2, postgrestest.GetJobByID(s.T(), s.db, job.ID).DeadLetterCount)
+
+	deadLetters, err := r.GetDeadLetterQueueJobs(ctx, job.ID)
+	assert.NoError(err)
+	assert.Len(deadLetters, 2)
+	replayable, invalid := deadLetters[0], deadLetters[1]
+
+	run := func(args ...string) (string, error) {
+		buf := new(bytes.Buffer)
+		s.testApp.Writer = buf
+		err := s.testApp.Run(append([]string{"bcda", "dead-letter"}, args...))
+		return buf.String(), err
+	}
+
+	out, err := run("list", "--job-id", strconv.Itoa(int(job.ID)))
+	assert.NoError(err)
+	assert.Regexp(fmt.Sprintf(`%d\s+%d\s+Patient\s+20\s+3\s+\S+\s+job not found after 4 attempt\(s\)\s+could not retrieve job from database`,
+		replayable.ID, job.ID), out)
+	assert.Regexp(fmt.Sprintf(`%d\s+%d\s+-\s+0\s+0\s+\S+\s+failed to deserialize args`, invalid.ID, job.ID), out)
+
+	out, err = run("replay", "--id", strconv.Itoa(int(replayable.ID)))
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("Replayed dead-lettered queue job %d of job %d\n", replayable.ID, job.ID), out)
+	assert.Equal(1, postgrestest.GetJobByID(s.T(), s.db, job.ID).DeadLetterCount)
+	queueJobs, err := queueing.GetQueueJobs(database.QueueConnection, job.ID)
+	assert.NoError(err)
+	assert.Len(queueJobs, 1)
+	assert.Equal(int16(20), queueJobs[0].Priority)
+	// The replayed queue job is planned again so it can be recovered if it is lost
+	assert.NotZero(queueJobs[0].Args.PlannedQueueJobID)
+
+	_, err = run("replay", "--id", strconv.Itoa(int(invalid.ID)))
+	assert.Contains(err.Error(), fmt.Sprintf("dead-lettered queue job %d cannot be replayed since its args are invalid", invalid.ID))
+
+	out, err = run("discard", "--id", strconv.Itoa(int(invalid.ID)))
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("Discarded dead-lettered queue job %d\nFailed job %d\nRemoved 1 queue job(s) from the queue\n",
+		invalid.ID, job.ID), out)
+	failed := postgrestest.GetJobByID(s.T(), s.db, job.ID)
+	assert.Equal(models.JobStatusFailed, failed.Status)
+	assert.Equal(0, failed.DeadLetterCount)
+
+	_, err = run("discard", "--id", strconv.Itoa(int(invalid.ID)))
+	assert.Contains(err.Error(), fmt.Sprintf("unable to find dead-lettered queue job %d", invalid.ID))
+	_, err = run("replay")
+	assert.EqualError(err, "dead-lettered queue job ID (--id) is required")
+}
+
 func getRandomPort(t *testing.T) int {
 	listener, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -11,8 +11,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/stdlib"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -108,7 +110,7 @@ func importCCLF0(ctx context.Context, fileMetadata *cclfFileMetadata) (map[strin
 		if len(bytes.TrimSpace(b)) > 0 {
 			filetype := string(bytes.TrimSpace(b[fileNumStart:fileNumEnd]))
 
-			if filetype == "CCLF8" {
+			if filetype == "CCLF8" || filetype == "CCLF9" {
 				if validator == nil {
 					validator = make(map[string]cclfFileValidator)
 				}
@@ -151,7 +153,21 @@ func importCCLF0(ctx context.Context, fileMetadata *cclfFileMetadata) (map[strin
 	return validator, nil
 }
 
-func importCCLF8(ctx context.Context, fileMetadata *cclfFileMetadata) (err error) {
+// copyFunc copies the records captured in the scanner to the table associated with the CCLF file.
+// It returns the number of rows written along with any error that occurred.
+type copyFunc func(ctx context.Context, conn *pgx.Conn, scanner *bufio.Scanner, fileID uint, reportInterval int) (int, error)
+
+func importCCLF8(ctx context.Context, importDB *sql.DB, fileMetadata *cclfFileMetadata) error {
+	return importCCLF(ctx, importDB, fileMetadata, CopyFrom, "beneficiaries")
+}
+
+func importCCLF9(ctx context.Context, importDB *sql.DB, fileMetadata *cclfFileMetadata) error {
+	return importCCLF(ctx, importDB, fileMetadata, CopyXrefsFrom, "beneficiary crosswalk")
+}
+
+// importCCLF copies the file's records using a connection from importDB. The file's record is maintained through
+// the shared connection pool, so an import holding an importDB connection never waits on another one.
+func importCCLF(ctx context.Context, importDB *sql.DB, fileMetadata *cclfFileMetadata, copyFrom copyFunc, tableDesc string) (err error) {
 	db := database.Connection
 
 	repository := postgres.NewRepository(db)
@@ -239,15 +255,15 @@ func importCCLF8(ctx context.Context, fileMetadata *cclfFileMetadata) (err error
 	sc := bufio.NewScanner(rc)
 
 	// Open transaction to encompass entire CCLF file ingest.
-	conn, err := stdlib.AcquireConn(db)
+	conn, err := stdlib.AcquireConn(importDB)
 	if err != nil {
 		return errors.Wrap(err, "failed to acquire connection")
 	}
-	defer utils.CloseAndLog(logrus.WarnLevel, func() error { return stdlib.ReleaseConn(db, conn) })
+	defer utils.CloseAndLog(logrus.WarnLevel, func() error { return stdlib.ReleaseConn(importDB, conn) })
 
-	importedCount, err := CopyFrom(ctx, conn, sc, cclfFile.ID, utils.GetEnvInt("CCLF_IMPORT_STATUS_RECORDS_INTERVAL", 10000))
+	importedCount, err := copyFrom(ctx, conn, sc, cclfFile.ID, utils.GetEnvInt("CCLF_IMPORT_STATUS_RECORDS_INTERVAL", 10000))
 	if err != nil {
-		return errors.Wrap(err, "failed to copy data to beneficiaries table")
+		return errors.Wrapf(err, "failed to copy data to %s table", tableDesc)
 	}
 
 	updateImportStatus(ctx, repository, fileMetadata, constants.ImportComplete)
@@ -281,48 +297,25 @@ func ImportCCLFDirectory(filePath string) (success, failure, skipped int, err er
 
 	acoOrder := orderACOs(cclfMap)
 
-	for _, acoID := range acoOrder {
-		func() {
-			ctx, c := metrics.NewParent(ctx, "ImportCCLFDirectory#processACOs")
-			defer c()
-			for _, cclfFiles := range cclfMap[acoID] {
-				var cclf0, cclf8 *cclfFileMetadata
-				for _, cclf := range cclfFiles {
-					if cclf.cclfNum == 0 {
-						cclf0 = cclf
-					} else if cclf.cclfNum == 8 {
-						cclf8 = cclf
-					}
-				}
-				cclfvalidator, err := importCCLF0(ctx, cclf0)
-				if err != nil {
-					fmt.Printf("Failed to import CCLF0 file: %s, Skipping CCLF8 file: %s.\n ", cclf0, cclf8)
-					log.Errorf("Failed to import CCLF0 file: %s, Skipping CCLF8 file: %s ", cclf0, cclf8)
-					failure++
-					skipped += 2
-					continue
-				} else {
-					success++
-				}
-				err = validate(ctx, cclf8, cclfvalidator)
-				if err != nil {
-					fmt.Printf("Failed to validate CCLF8 file: %s.\n", cclf8)
-					log.Errorf("Failed to validate CCLF8 file: %s", cclf8)
-					failure++
-				} else {
-					if err = importCCLF8(ctx, cclf8); err != nil {
-						fmt.Printf("Failed to import CCLF8 file: %s %s.\n", cclf8, err)
-						log.Errorf("Failed to import CCLF8 file: %s %s", cclf8, err)
-						failure++
-					} else {
-						cclf8.imported = true
-						success++
-					}
-				}
-				cclf0.imported = cclf8 != nil && cclf8.imported
-			}
-		}()
+	workers := utils.GetEnvInt("CCLF_IMPORT_WORKERS", 1)
+	// The records are copied through a pool of their own, bounding the number of files being written to the
+	// database at once independent of the number of workers reading and validating archives.
+	maxDBConns := utils.GetEnvInt("CCLF_IMPORT_MAX_DB_CONNS", workers)
+	if maxDBConns < 1 {
+		maxDBConns = 1
+	}
+	importDB, err := database.NewConnection(maxDBConns)
+	if err != nil {
+		return 0, 0, skipped, errors.Wrap(err, "failed to open import connection pool")
 	}
+	defer utils.CloseAndLog(logrus.WarnLevel, importDB.Close)
+
+	sc, f, sk := importACOs(ctx, acoOrder, workers, func(ctx context.Context, acoID string) (success, failure, skipped int) {
+		ctx, c := metrics.NewParent(ctx, "ImportCCLFDirectory#processACOs")
+		defer c()
+		return importACO(ctx, importDB, cclfMap[acoID])
+	})
+	success, failure, skipped = success+sc, failure+f, skipped+sk
 
 	if err = func() error {
 		ctx, c := metrics.NewParent(ctx, "ImportCCLFDirectory#cleanupCCLF")
@@ -342,6 +335,108 @@ func ImportCCLFDirectory(filePath string) (success, failure, skipped int, err er
 	return success, failure, skipped, err
 }
 
+// importACOs runs importFunc for each ACO using the supplied number of workers.
+// ACOs are handed to the workers in the order given, so higher priority ACOs always start first.
+// The returned counts are the totals across all of the ACOs.
+func importACOs(ctx context.Context, acoOrder []string, workers int,
+	importFunc func(ctx context.Context, acoID string) (success, failure, skipped int)) (success, failure, skipped int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	acoIDs := make(chan string)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for acoID := range acoIDs {
+				sc, f, sk := importFunc(ctx, acoID)
+				mu.Lock()
+				success, failure, skipped = success+sc, failure+f, skipped+sk
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, acoID := range acoOrder {
+		acoIDs <- acoID
+	}
+	close(acoIDs)
+	wg.Wait()
+
+	return success, failure, skipped
+}
+
+// importACO imports all of the CCLF files delivered for a single ACO, copying their records through importDB.
+func importACO(ctx context.Context, importDB *sql.DB, acoFiles map[metadataKey][]*cclfFileMetadata) (success, failure, skipped int) {
+	for _, cclfFiles := range acoFiles {
+		var cclf0, cclf8, cclf9 *cclfFileMetadata
+		for _, cclf := range cclfFiles {
+			switch cclf.cclfNum {
+			case 0:
+				cclf0 = cclf
+			case 8:
+				cclf8 = cclf
+			case 9:
+				cclf9 = cclf
+			}
+		}
+		cclfvalidator, err := importCCLF0(ctx, cclf0)
+		if err != nil {
+			fmt.Printf("Failed to import CCLF0 file: %s, Skipping CCLF8 file: %s.\n ", cclf0, cclf8)
+			log.Errorf("Failed to import CCLF0 file: %s, Skipping CCLF8 file: %s ", cclf0, cclf8)
+			failure++
+			skipped += 2
+			if cclf9 != nil {
+				skipped++
+			}
+			continue
+		} else {
+			success++
+		}
+		err = validate(ctx, cclf8, cclfvalidator)
+		if err != nil {
+			fmt.Printf("Failed to validate CCLF8 file: %s.\n", cclf8)
+			log.Errorf("Failed to validate CCLF8 file: %s", cclf8)
+			failure++
+		} else {
+			if err = importCCLF8(ctx, importDB, cclf8); err != nil {
+				fmt.Printf("Failed to import CCLF8 file: %s %s.\n", cclf8, err)
+				log.Errorf("Failed to import CCLF8 file: %s %s", cclf8, err)
+				failure++
+			} else {
+				cclf8.imported = true
+				success++
+			}
+		}
+		cclf0.imported = cclf8 != nil && cclf8.imported
+
+		// The crosswalk is optional since it only contains beneficiaries whose identifiers have changed
+		if cclf9 == nil {
+			continue
+		}
+
+		if err = validate(ctx, cclf9, cclfvalidator); err != nil {
+			fmt.Printf("Failed to validate CCLF9 file: %s.\n", cclf9)
+			log.Errorf("Failed to validate CCLF9 file: %s", cclf9)
+			failure++
+		} else if err = importCCLF9(ctx, importDB, cclf9); err != nil {
+			fmt.Printf("Failed to import CCLF9 file: %s %s.\n", cclf9, err)
+			log.Errorf("Failed to import CCLF9 file: %s %s", cclf9, err)
+			failure++
+		} else {
+			cclf9.imported = true
+			success++
+		}
+	}
+
+	return success, failure, skipped
+}
+
 func orderACOs(cclfMap map[string]map[metadataKey][]*cclfFileMetadata) []string {
 	var acoOrder []string
 
@@ -368,7 +463,7 @@ func orderACOs(cclfMap map[string]map[metadataKey][]*cclfFileMetadata) []string
 
 func getPriorityACOs(db *sql.DB) []string {
 	const query = `
-    SELECT trim(both '["]' from g.x_data::json->>'cms_ids') "aco_id" 
+    SELECT DISTINCT json_array_elements_text(g.x_data::json->'cms_ids') "aco_id" 
     FROM systems s JOIN groups g ON s.group_id=g.group_id 
     WHERE s.deleted_at IS NULL AND g.group_id IN (SELECT group_id FROM groups WHERE x_data LIKE '%A%' and x_data NOT LIKE '%A999%') AND
     s.id IN (SELECT system_id FROM secrets WHERE deleted_at IS NULL);
@@ -411,9 +506,10 @@ func validate(ctx context.Context, fileMetadata *cclfFileMetadata, cclfFileValid
 	log.Infof("Validating CCLF%d file %s...", fileMetadata.cclfNum, fileMetadata)
 
 	var key string
-	if fileMetadata.cclfNum == 8 {
-		key = "CCLF8"
-	} else {
+	switch fileMetadata.cclfNum {
+	case 8, 9:
+		key = fmt.Sprintf("CCLF%d", fileMetadata.cclfNum)
+	default:
 		fmt.Printf("Unknown file type when validating file: %s.\n", fileMetadata)
 		err := fmt.Errorf("unknown file type when validating file: %s", fileMetadata)
 		log.Error(err)
@@ -433,7 +529,13 @@ func validate(ctx context.Context, fileMetadata *cclfFileMetadata, cclfFileValid
 	defer close()
 
 	count := 0
-	validator := cclfFileValidator[key]
+	validator, ok := cclfFileValidator[key]
+	if !ok {
+		fmt.Printf("No %s record details found in CCLF0 file for file %s.\n", key, fileMetadata)
+		err := fmt.Errorf("no %s record details found in CCLF0 file for file %s", key, fileMetadata)
+		log.Error(err)
+		return err
+	}
 	var rawFile *zip.File
 
 	for _, f := range r.File {
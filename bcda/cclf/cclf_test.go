@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -90,7 +91,8 @@ func (s *CCLFTestSuite) TestImportCCLFDirectory_PriorityACOs() {
 
 	sc, f, sk, err := ImportCCLFDirectory(filepath.Join(s.basePath, "cclf/archives/valid/"))
 	assert.Nil(err)
-	assert.Equal(6, sc)
+	// CCLF0 and CCLF8 files for each ACO along with the CCLF9 file for A0001
+	assert.Equal(7, sc)
 	assert.Equal(0, f)
 	assert.Equal(1, sk)
 
@@ -102,6 +104,70 @@ func (s *CCLFTestSuite) TestImportCCLFDirectory_PriorityACOs() {
 	assert.True(aco2fs[0].ID < aco3fs[0].ID)
 }
 
+func (s *CCLFTestSuite) TestImportCCLFDirectory_Workers() {
+	conf.SetEnv(s.T(), "CCLF_REF_DATE", "181201")
+	conf.SetEnv(s.T(), "CCLF_IMPORT_WORKERS", "3")
+	conf.SetEnv(s.T(), "CCLF_IMPORT_MAX_DB_CONNS", "2")
+	defer func() {
+		conf.UnsetEnv(s.T(), "CCLF_IMPORT_WORKERS")
+		conf.UnsetEnv(s.T(), "CCLF_IMPORT_MAX_DB_CONNS")
+	}()
+
+	for _, cmsID := range []string{"A9989", "A9988", "A0001"} {
+		postgrestest.DeleteCCLFFilesByCMSID(s.T(), s.db, cmsID)
+	}
+
+	sc, f, sk, err := ImportCCLFDirectory(filepath.Join(s.basePath, "cclf/archives/valid/"))
+	s.NoError(err)
+	s.Equal(7, sc)
+	s.Equal(0, f)
+	s.Equal(1, sk)
+
+	for _, cmsID := range []string{"A9989", "A9988", "A0001"} {
+		for _, cclfFile := range postgrestest.GetCCLFFilesByCMSID(s.T(), s.db, cmsID) {
+			s.Equal(constants.ImportComplete, cclfFile.ImportStatus)
+		}
+	}
+}
+
+func (s *CCLFTestSuite) TestImportACOs() {
+	acoOrder := []string{"A0001", "A0002", "A0003", "A0004", "A0005", "A0006"}
+
+	// A single worker imports the ACOs in the order supplied
+	var started []string
+	sc, f, sk := importACOs(context.Background(), acoOrder, 1, func(ctx context.Context, acoID string) (int, int, int) {
+		started = append(started, acoID)
+		return 2, 1, 0
+	})
+	s.Equal(acoOrder, started)
+	s.Equal(12, sc)
+	s.Equal(6, f)
+	s.Equal(0, sk)
+
+	// Multiple workers never exceed the limit and report the same totals
+	var (
+		mu              sync.Mutex
+		running, maxRan int
+	)
+	sc, f, sk = importACOs(context.Background(), acoOrder, 3, func(ctx context.Context, acoID string) (int, int, int) {
+		mu.Lock()
+		running++
+		if running > maxRan {
+			maxRan = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return 2, 1, 1
+	})
+	s.Equal(12, sc)
+	s.Equal(6, f)
+	s.Equal(6, sk)
+	s.True(maxRan > 1 && maxRan <= 3, "expected between 2 and 3 concurrent imports, found %d", maxRan)
+}
+
 func (s *CCLFTestSuite) TestImportCCLF0() {
 	ctx := context.Background()
 	assert := assert.New(s.T())
@@ -113,6 +179,7 @@ func (s *CCLFTestSuite) TestImportCCLF0() {
 	validator, err := importCCLF0(ctx, cclf0metadata)
 	assert.Nil(err)
 	assert.Equal(cclfFileValidator{totalRecordCount: 7, maxRecordLength: 549}, validator["CCLF8"])
+	assert.Equal(cclfFileValidator{totalRecordCount: 6, maxRecordLength: 54}, validator["CCLF9"])
 
 	// negative
 	cclf0metadata = &cclfFileMetadata{}
@@ -159,6 +226,10 @@ func (s *CCLFTestSuite) TestValidate() {
 	cclfvalidator = map[string]cclfFileValidator{"CCLF8": {totalRecordCount: 2, maxRecordLength: 549}}
 	err = validate(ctx, cclf8metadata, cclfvalidator)
 	assert.EqualError(err, "maximum record count reached for file CCLF8 (expected: 2, actual: 3)")
+
+	// CCLF0 does not contain details for the file
+	err = validate(ctx, cclf8metadata, map[string]cclfFileValidator{"CCLF9": {totalRecordCount: 6, maxRecordLength: 54}})
+	assert.EqualError(err, "no CCLF8 record details found in CCLF0 file for file T.BCD.A0001.ZC8Y18.D181120.T1000009")
 }
 
 func (s *CCLFTestSuite) TestImportCCLF8() {
@@ -179,7 +250,7 @@ func (s *CCLFTestSuite) TestImportCCLF8() {
 		filePath:  filepath.Join(s.basePath, "cclf/archives/valid/T.BCD.A0001.ZCY18.D181121.T1000000"),
 	}
 
-	err := importCCLF8(context.Background(), metadata)
+	err := importCCLF8(context.Background(), s.db, metadata)
 	if err != nil {
 		s.FailNow("importCCLF8() error: %s", err.Error())
 	}
@@ -204,11 +275,53 @@ func (s *CCLFTestSuite) TestImportCCLF8() {
 	assert.Equal("1A69B98CD35", mbis[5])
 }
 
+func (s *CCLFTestSuite) TestImportCCLF9() {
+	assert := assert.New(s.T())
+
+	acoID := "A0001"
+	postgrestest.DeleteCCLFFilesByCMSID(s.T(), s.db, acoID)
+	defer postgrestest.DeleteCCLFFilesByCMSID(s.T(), s.db, acoID)
+
+	fileTime, _ := time.Parse(time.RFC3339, "2018-11-20T10:00:00Z")
+	metadata := &cclfFileMetadata{
+		name:      "T.BCD.A0001.ZC9Y18.D181120.T1000010",
+		env:       "test",
+		acoID:     acoID,
+		cclfNum:   9,
+		perfYear:  18,
+		timestamp: fileTime,
+		filePath:  filepath.Join(s.basePath, "cclf/archives/valid/T.BCD.A0001.ZCY18.D181122.T1000000"),
+	}
+
+	err := importCCLF9(context.Background(), s.db, metadata)
+	if err != nil {
+		s.FailNow("importCCLF9() error: %s", err.Error())
+	}
+
+	file := postgrestest.GetCCLFFilesByName(s.T(), s.db, metadata.name)[0]
+	assert.Equal(9, file.CCLFNum)
+	assert.Equal(constants.ImportComplete, file.ImportStatus)
+
+	// Only the MBI crosswalk entries are returned
+	xrefs, err := postgres.NewRepository(s.db).GetMBIXrefs(context.Background(), acoID)
+	assert.NoError(err)
+	assert.Len(xrefs, 3)
+	for _, xref := range xrefs {
+		assert.Equal(file.ID, xref.FileID)
+		assert.Equal(models.XrefIndicatorMBI, xref.XrefIndicator)
+	}
+	sort.Slice(xrefs, func(i, j int) bool { return xrefs[i].CurrentNum < xrefs[j].CurrentNum })
+	assert.Equal("1A69B98CD33", xrefs[0].CurrentNum)
+	assert.Equal("1A69B98CD32", xrefs[0].PrevNum)
+	assert.Equal("1960-01-01", xrefs[0].PrevsEfctDt)
+	assert.Equal("2017-06-11", xrefs[0].PrevsObsltDt)
+}
+
 func (s *CCLFTestSuite) TestImportCCLF8_Invalid() {
 	assert := assert.New(s.T())
 
 	var metadata *cclfFileMetadata
-	err := importCCLF8(context.Background(), metadata)
+	err := importCCLF8(context.Background(), s.db, metadata)
 	assert.EqualError(err, "CCLF file not found")
 
 	// since we do not have the correct number of characters, the import should fail.
@@ -222,7 +335,7 @@ func (s *CCLFTestSuite) TestImportCCLF8_Invalid() {
 		perfYear:  20,
 		filePath:  fileName,
 	}
-	err = importCCLF8(context.Background(), metadata)
+	err = importCCLF8(context.Background(), s.db, metadata)
 	// This error indicates that we did not supply enough characters for the MBI
 	assert.Contains(err.Error(), "invalid byte sequence for encoding \"UTF8\": 0x00")
 }
@@ -326,7 +439,7 @@ func (s *CCLFTestSuite) TestCleanupCCLF() {
 
 func (s *CCLFTestSuite) TestGetPriorityACOs() {
 	query := regexp.QuoteMeta(`
-	SELECT trim(both '["]' from g.x_data::json->>'cms_ids') "aco_id" 
+	SELECT DISTINCT json_array_elements_text(g.x_data::json->'cms_ids') "aco_id" 
 	FROM systems s JOIN groups g ON s.group_id=g.group_id 
 	WHERE s.deleted_at IS NULL AND g.group_id IN (SELECT group_id FROM groups WHERE x_data LIKE '%A%' and x_data NOT LIKE '%A999%') AND
 	s.id IN (SELECT system_id FROM secrets WHERE deleted_at IS NULL);
@@ -404,7 +517,7 @@ func (s *CCLFTestSuite) TestImportRunoutCCLF() {
 				filePath:  fileName,
 			}
 
-			s.NoError(importCCLF8(context.Background(), metadata))
+			s.NoError(importCCLF8(context.Background(), s.db, metadata))
 
 			cclfFile := postgrestest.GetCCLFFilesByName(s.T(), db, cclfName)[0]
 			assert.Equal(t, tt.fileType, cclfFile.Type)
@@ -0,0 +1,153 @@
+package cclf
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
+)
+
+// ValidateCCLFDirectory runs the same filename, CCLF0 and record checks as ImportCCLFDirectory
+// without writing to the database or moving any files. Every problem found is captured in the returned report.
+func ValidateCCLFDirectory(filePath string) (*dryrun.Report, error) {
+	report := dryrun.NewReport(filePath, "cclf")
+	cclfMap, err := validateCCLFArchives(filePath, report)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	acoIDs := make([]string, 0, len(cclfMap))
+	for acoID := range cclfMap {
+		acoIDs = append(acoIDs, acoID)
+	}
+	sort.Strings(acoIDs)
+
+	for _, acoID := range acoIDs {
+		keys := make([]metadataKey, 0, len(cclfMap[acoID]))
+		for key := range cclfMap[acoID] {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].perfYear == keys[j].perfYear {
+				return keys[i].fileType < keys[j].fileType
+			}
+			return keys[i].perfYear < keys[j].perfYear
+		})
+
+		for _, key := range keys {
+			var cclf0, cclf8, cclf9 *cclfFileMetadata
+			for _, cclf := range cclfMap[acoID][key] {
+				switch cclf.cclfNum {
+				case 0:
+					cclf0 = cclf
+				case 8:
+					cclf8 = cclf
+				case 9:
+					cclf9 = cclf
+				}
+			}
+
+			var validator map[string]cclfFileValidator
+			if cclf0 != nil {
+				f := report.AddFile(cclf0.filePath, cclf0.name)
+				if validator, err = importCCLF0(ctx, cclf0); err != nil {
+					f.AddProblem(err.Error())
+				}
+				f.Records = len(validator)
+				if cclf8 == nil {
+					f.AddProblem("CCLF8 file not found")
+				}
+			}
+
+			for _, cclf := range []*cclfFileMetadata{cclf8, cclf9} {
+				if cclf == nil {
+					continue
+				}
+				f := report.AddFile(cclf.filePath, cclf.name)
+				if cclf0 == nil {
+					f.AddProblem("CCLF0 file not found")
+				}
+				validateCCLFRecords(cclf, validator, f)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// validateCCLFRecords checks the length of every record against the details found in the CCLF0 file
+// and parses every record the same way the importer does.
+// When the CCLF0 file could not be read, only the record parsing is performed.
+func validateCCLFRecords(fileMetadata *cclfFileMetadata, validator map[string]cclfFileValidator, f *dryrun.FileReport) {
+	key := fmt.Sprintf("CCLF%d", fileMetadata.cclfNum)
+	details, hasDetails := validator[key]
+	if validator != nil && !hasDetails {
+		f.AddProblem(fmt.Sprintf("no %s record details found in CCLF0 file", key))
+	}
+
+	parse := func(b []byte) error {
+		_, err := parseCCLF8Record(b)
+		return err
+	}
+	if fileMetadata.cclfNum == 9 {
+		parse = func(b []byte) error {
+			_, err := parseCCLF9Record(b)
+			return err
+		}
+	}
+
+	r, err := zip.OpenReader(filepath.Clean(fileMetadata.filePath))
+	if err != nil {
+		f.AddProblem(fmt.Sprintf("could not read archive: %s", err.Error()))
+		return
+	}
+	defer r.Close()
+
+	var rawFile *zip.File
+	for _, zf := range r.File {
+		if zf.Name == fileMetadata.name {
+			rawFile = zf
+		}
+	}
+	if rawFile == nil {
+		f.AddProblem(fmt.Sprintf("file %s not found in archive", fileMetadata.name))
+		return
+	}
+
+	rc, err := rawFile.Open()
+	if err != nil {
+		f.AddProblem(fmt.Sprintf("could not read file: %s", err.Error()))
+		return
+	}
+	defer rc.Close()
+
+	sc := bufio.NewScanner(rc)
+	line := 0
+	for sc.Scan() {
+		line++
+		b := sc.Bytes()
+		length := len(bytes.TrimSpace(b))
+		if hasDetails && (length == 0 || length > details.maxRecordLength) {
+			f.AddLineProblem(line, fmt.Sprintf("incorrect record length (expected: %d, actual: %d)", details.maxRecordLength, length))
+			continue
+		}
+
+		f.Records++
+		if err := parse(b); err != nil {
+			f.AddLineProblem(line, err.Error())
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.AddProblem(fmt.Sprintf("failed to read file: %s", err.Error()))
+	}
+
+	if hasDetails && f.Records > details.totalRecordCount {
+		f.AddProblem(fmt.Sprintf("maximum record count reached (expected: %d, actual: %d)", details.totalRecordCount, f.Records))
+	}
+}
@@ -0,0 +1,84 @@
+package cclf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
+)
+
+func (s *CCLFTestSuite) TestValidateCCLFDirectory() {
+	dir := filepath.Join(s.basePath, "cclf/archives/valid")
+	report, err := ValidateCCLFDirectory(dir)
+	s.NoError(err)
+	s.Equal("cclf", report.FileType)
+	s.Len(report.Files, 8)
+
+	// The only problem is the archive that does not follow the naming convention
+	s.Equal(1, report.ProblemCount())
+	records := make(map[string]int)
+	for _, f := range report.Files {
+		if len(f.Problems) > 0 {
+			s.Equal("T.BCD.ACOB.ZC0Y18.D181120.T0001000", filepath.Base(f.Path))
+			s.Contains(f.Problems[0].Message, "invalid name ('T.BCD.ACOB.ZC0Y18.D181120.T0001000') for CCLF archive")
+		}
+		records[f.Name] = f.Records
+	}
+	s.Equal(7, records["T.BCD.A0001.ZC8Y18.D181120.T1000009"])
+	s.Equal(6, records["T.BCD.A0001.ZC9Y18.D181120.T1000010"])
+
+	// Nothing was moved, including the archive that would normally be moved to the pending deletion dir
+	infos, err := ioutil.ReadDir(dir)
+	s.NoError(err)
+	s.Len(infos, 8)
+}
+
+func (s *CCLFTestSuite) TestValidateCCLFDirectory_MissingFiles() {
+	report, err := ValidateCCLFDirectory(filepath.Join(s.basePath, "cclf/archives/8/valid"))
+	s.NoError(err)
+	s.Len(report.Files, 1)
+	s.Equal([]dryrun.Problem{{Message: "CCLF0 file not found"}}, report.Files[0].Problems)
+
+	report, err = ValidateCCLFDirectory(filepath.Join(s.basePath, "cclf/archives/0/missing_data"))
+	s.NoError(err)
+	var cclf0 *dryrun.FileReport
+	for _, f := range report.Files {
+		if f.Name == "T.BCD.A0001.ZC0Y18.D181120.T1000013" {
+			cclf0 = f
+		}
+	}
+	s.NotNil(cclf0)
+	s.Equal([]dryrun.Problem{
+		{Message: "duplicate CCLF8 file type found from CCLF0 file"},
+		{Message: "CCLF8 file not found"},
+	}, cclf0.Problems)
+}
+
+func (s *CCLFTestSuite) TestValidateCCLFRecords() {
+	fileName, cclfName := createTemporaryCCLF8ZipFile(s.T(), "1A00A00AA00  \nA 1\n1A00A00AA01  \n\n1A00A00AA02  ")
+	defer os.Remove(fileName)
+	metadata := &cclfFileMetadata{cclfNum: 8, name: cclfName, filePath: fileName}
+
+	f := &dryrun.FileReport{}
+	validateCCLFRecords(metadata, map[string]cclfFileValidator{"CCLF8": {totalRecordCount: 3, maxRecordLength: 11}}, f)
+	s.Equal(4, f.Records)
+	s.Equal([]dryrun.Problem{
+		{Line: 2, Message: "invalid CCLF8 record length 3, expected at least 11"},
+		{Line: 4, Message: "incorrect record length (expected: 11, actual: 0)"},
+		{Message: "maximum record count reached (expected: 3, actual: 4)"},
+	}, f.Problems)
+
+	// Without the CCLF0 details we can still parse the records
+	f = &dryrun.FileReport{}
+	validateCCLFRecords(metadata, nil, f)
+	s.Equal(5, f.Records)
+	s.Equal([]dryrun.Problem{
+		{Line: 2, Message: "invalid CCLF8 record length 3, expected at least 11"},
+		{Line: 4, Message: "invalid CCLF8 record length 0, expected at least 11"},
+	}, f.Problems)
+
+	f = &dryrun.FileReport{}
+	validateCCLFRecords(metadata, map[string]cclfFileValidator{"CCLF9": {}}, f)
+	s.Equal("no CCLF8 record details found in CCLF0 file", f.Problems[0].Message)
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/service"
 	"github.com/CMSgov/bcda-app/bcda/utils"
@@ -27,16 +28,28 @@ type metadataKey struct {
 // processCCLFArchives walks through all of the CCLF files captured in the root path and generates
 // a mapping between CMS_ID + perf year and associated CCLF Metadata
 func processCCLFArchives(rootPath string) (map[string]map[metadataKey][]*cclfFileMetadata, int, error) {
-	p := &processor{0, make(map[string]map[metadataKey][]*cclfFileMetadata)}
+	p := &processor{cclfMap: make(map[string]map[metadataKey][]*cclfFileMetadata)}
 	if err := filepath.Walk(rootPath, p.walk); err != nil {
 		return nil, 0, err
 	}
 	return p.cclfMap, p.skipped, nil
 }
 
+// validateCCLFArchives behaves like processCCLFArchives but records any unusable archives or files
+// in the report instead of moving them to the pending deletion directory.
+func validateCCLFArchives(rootPath string, report *dryrun.Report) (map[string]map[metadataKey][]*cclfFileMetadata, error) {
+	p := &processor{cclfMap: make(map[string]map[metadataKey][]*cclfFileMetadata), report: report}
+	if err := filepath.Walk(rootPath, p.walk); err != nil {
+		return nil, err
+	}
+	return p.cclfMap, nil
+}
+
 type processor struct {
 	skipped int
 	cclfMap map[string]map[metadataKey][]*cclfFileMetadata
+	// report is only set when performing a dry run
+	report *dryrun.Report
 }
 
 func (p *processor) walk(path string, info os.FileInfo, err error) error {
@@ -63,6 +76,9 @@ func (p *processor) walk(path string, info os.FileInfo, err error) error {
 		msg := fmt.Sprintf("Skipping %s: file is not a CCLF archive.", path)
 		fmt.Println(msg)
 		log.Warn(msg)
+		if p.report != nil {
+			p.report.AddFile(path, "").AddProblem("file is not a CCLF archive")
+		}
 		return nil
 	}
 	if err = zipReader.Close(); err != nil {
@@ -90,6 +106,9 @@ func (p *processor) walk(path string, info os.FileInfo, err error) error {
 			msg := fmt.Sprintf("Unknown file found: %s.", f.Name)
 			fmt.Println(msg)
 			log.Error(msg)
+			if p.report != nil {
+				p.report.AddFile(path, f.Name).AddProblem(err.Error())
+			}
 			continue
 		}
 
@@ -110,6 +129,10 @@ func (p *processor) handleArchiveError(path string, info os.FileInfo, cause erro
 	msg := fmt.Sprintf("Skipping CCLF archive (%s): %s.", info.Name(), cause)
 	fmt.Println(msg)
 	log.Warn(msg)
+	if p.report != nil {
+		p.report.AddFile(path, "").AddProblem(cause.Error())
+		return nil
+	}
 	err := checkDeliveryDate(path, info.ModTime())
 	if err != nil {
 		err = fmt.Errorf("error moving unknown file %s to pending deletion dir", path)
@@ -138,19 +161,19 @@ func getCCLFFileMetadata(cmsID, fileName string) (cclfFileMetadata, error) {
 	var metadata cclfFileMetadata
 	const (
 		prefix = `(P|T)\.`
-		suffix = `\.ZC(0|8)(Y|R)(\d{2})\.(D\d{6}\.T\d{6})\d`
+		suffix = `\.ZC(0|8|9)(Y|R)(\d{2})\.(D\d{6}\.T\d{6})\d`
 		aco    = `(?:\.ACO)`
 		bcd    = `(?:BCD\.)`
 
-		// CCLF filename convention for SSP with BCD identifier: P.BCD.A****.ZC[0|8][Y|R]**.Dyymmdd.Thhmmsst
+		// CCLF filename convention for SSP with BCD identifier: P.BCD.A****.ZC[0|8|9][Y|R]**.Dyymmdd.Thhmmsst
 		ssp = `A\d{4}`
-		// CCLF filename convention for NGACO: P.V***.ACO.ZC[0|8][Y|R].Dyymmdd.Thhmmsst
+		// CCLF filename convention for NGACO: P.V***.ACO.ZC[0|8|9][Y|R].Dyymmdd.Thhmmsst
 		ngaco = `V\d{3}`
-		// CCLF file name convention for CEC: P.CEC.ZC[0|8][Y|R].Dyymmdd.Thhmmsst
+		// CCLF file name convention for CEC: P.CEC.ZC[0|8|9][Y|R].Dyymmdd.Thhmmsst
 		cec = `CEC`
 		// CCLF file name convention for CKCC: P.C****.ACO.ZC(Y|R)**.Dyymmdd.Thhmmsst
 		ckcc = `C\d{4}`
-		// CCLF file name convention for KCF: P.K****.ACO.ZC[0|8](Y|R)**.Dyymmdd.Thhmmsst
+		// CCLF file name convention for KCF: P.K****.ACO.ZC[0|8|9](Y|R)**.Dyymmdd.Thhmmsst
 		kcf = `K\d{4}`
 		// CCLF file name convention for DC: P.D****.ACO.ZC(Y|R)**.Dyymmdd.Thhmmsst
 		dc = `D\d{4}`
@@ -62,14 +62,15 @@ func (s *FileProcessorTestSuite) TestProcessCCLFArchives() {
 		skipped      int
 		numCCLF0     int // Expected count for the cmsID, perfYear above
 		numCCLF8     int // Expected count for the cmsID, perfYear above
+		numCCLF9     int // Expected count for the cmsID, perfYear above
 	}{
-		{filepath.Join(s.basePath, "cclf/archives/valid/"), 2, 1, 1, 1},
-		{filepath.Join(s.basePath, "cclf/archives/bcd/"), 2, 1, 1, 1},
-		{filepath.Join(s.basePath, "cclf/mixed/with_invalid_filenames/"), 2, 5, 1, 1},
-		{filepath.Join(s.basePath, "cclf/mixed/0/valid_names/"), 3, 3, 3, 0},
-		{filepath.Join(s.basePath, "cclf/archives/8/valid/"), 5, 0, 0, 5},
-		{filepath.Join(s.basePath, "cclf/files/9/valid_names/"), 0, 4, 0, 0},
-		{filepath.Join(s.basePath, "cclf/mixed/with_folders/"), 2, 13, 1, 1},
+		{filepath.Join(s.basePath, "cclf/archives/valid/"), 3, 1, 1, 1, 1},
+		{filepath.Join(s.basePath, "cclf/archives/bcd/"), 2, 1, 1, 1, 0},
+		{filepath.Join(s.basePath, "cclf/mixed/with_invalid_filenames/"), 2, 5, 1, 1, 0},
+		{filepath.Join(s.basePath, "cclf/mixed/0/valid_names/"), 3, 3, 3, 0, 0},
+		{filepath.Join(s.basePath, "cclf/archives/8/valid/"), 5, 0, 0, 5, 0},
+		{filepath.Join(s.basePath, "cclf/files/9/valid_names/"), 0, 4, 0, 0, 0},
+		{filepath.Join(s.basePath, "cclf/mixed/with_folders/"), 2, 13, 1, 1, 0},
 	}
 
 	for _, tt := range tests {
@@ -79,18 +80,22 @@ func (s *FileProcessorTestSuite) TestProcessCCLFArchives() {
 			assert.NoError(t, err)
 			assert.Equal(t, tt.skipped, skipped)
 			assert.Equal(t, tt.numCCLFFiles, len(cclfFiles))
-			var numCCLF0, numCCLF8 int
+			var numCCLF0, numCCLF8, numCCLF9 int
 			for _, cclfFile := range cclfFiles {
-				if cclfFile.cclfNum == 0 {
+				switch cclfFile.cclfNum {
+				case 0:
 					numCCLF0++
-				} else if cclfFile.cclfNum == 8 {
+				case 8:
 					numCCLF8++
-				} else {
+				case 9:
+					numCCLF9++
+				default:
 					assert.Fail(t, "Unexpected CCLF num received %d", cclfFile.cclfNum)
 				}
 			}
 			assert.Equal(t, tt.numCCLF0, numCCLF0)
 			assert.Equal(t, tt.numCCLF8, numCCLF8)
+			assert.Equal(t, tt.numCCLF9, numCCLF9)
 		})
 	}
 }
@@ -201,6 +206,7 @@ func TestGetCCLFMetadata(t *testing.T) {
 	assert.NoError(t, err)
 	sspProdFile, sspTestFile, sspRunoutFile := gen(sspProd, validTime), gen(sspTest, validTime),
 		strings.Replace(gen(sspProd, validTime), "ZC8Y", "ZC8R", 1)
+	sspCrosswalkFile := strings.Replace(gen(sspProd, validTime), "ZC8Y", "ZC9Y", 1)
 	cecProdFile, cecTestFile := gen(cecProd, validTime), gen(cecTest, validTime)
 	ngacoProdFile, ngacoTestFile := gen(ngacoProd, validTime), gen(ngacoTest, validTime)
 	ckccProdFile, ckccTestFile := gen(ckccProd, validTime), gen(ckccTest, validTime)
@@ -214,7 +220,7 @@ func TestGetCCLFMetadata(t *testing.T) {
 		errMsg   string
 		metadata cclfFileMetadata
 	}{
-		{"Non CCLF0 or CCLF8 file", sspID, "P.A0001.ACO.ZC9Y18.D190108.T2355000", "invalid filename", cclfFileMetadata{}},
+		{"Non CCLF0, CCLF8, or CCLF9 file", sspID, "P.BCD.A0001.ZC1Y18.D190108.T2355000", "invalid filename", cclfFileMetadata{}},
 		{"Unsupported CCLF file type", "Z9999", "P.Z0001.ACO.ZC8Y18.D190108.T2355000", "invalid filename", cclfFileMetadata{}},
 		{"Invalid date (no 13th month)", sspID, "T.BCD.A0001.ZC0Y18.D181320.T0001000", "failed to parse date", cclfFileMetadata{}},
 		{"CCLF file too old", sspID, gen(sspProd, startUTC.Add(-365*24*time.Hour)), "out of range", cclfFileMetadata{}},
@@ -230,6 +236,17 @@ func TestGetCCLFMetadata(t *testing.T) {
 				fileType:  models.FileTypeDefault,
 			},
 		},
+		{"CCLF9 SSP file", sspID, sspCrosswalkFile, "",
+			cclfFileMetadata{
+				env:       "production",
+				name:      sspCrosswalkFile,
+				cclfNum:   9,
+				acoID:     sspID,
+				timestamp: validTime,
+				perfYear:  perfYear,
+				fileType:  models.FileTypeDefault,
+			},
+		},
 		{"Test SSP file", sspID, sspTestFile, "",
 			cclfFileMetadata{
 				env:       "test",
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/CMSgov/bcda-app/bcda/cclf/metrics"
@@ -90,11 +91,22 @@ func (importer *cclf8Importer) Err() error {
 // Since calls the bufio.Scanner.Bytes() does not advance the cursor, this call
 // can be invoked multiple times and return the same result.
 func (importer *cclf8Importer) getMBI() string {
-	const (
-		mbiStart, mbiEnd = 0, 11
-	)
 	b := importer.scanner.Bytes()
-	return string(bytes.TrimSpace(b[mbiStart:mbiEnd]))
+	return string(bytes.TrimSpace(b[cclf8MBIStart:cclf8MBIEnd]))
+}
+
+const cclf8MBIStart, cclf8MBIEnd = 0, 11
+
+// parseCCLF8Record returns the MBI contained in a CCLF8 record
+func parseCCLF8Record(b []byte) (string, error) {
+	if len(b) < cclf8MBIEnd {
+		return "", fmt.Errorf("invalid CCLF8 record length %d, expected at least %d", len(b), cclf8MBIEnd)
+	}
+	mbi := string(bytes.TrimSpace(b[cclf8MBIStart:cclf8MBIEnd]))
+	if mbi == "" {
+		return "", errors.New("CCLF8 record is missing an MBI")
+	}
+	return mbi, nil
 }
 
 // CopyFrom writes all of the beneficiary data captured in the scanner to the beneficiaries table.
@@ -111,3 +123,100 @@ func CopyFrom(ctx context.Context, conn *pgx.Conn, scanner *bufio.Scanner, fileI
 	tableName := pgx.Identifier([]string{"cclf_beneficiaries"})
 	return conn.CopyFrom(tableName, []string{"file_id", "mbi"}, importer)
 }
+
+// A cclf9Importer is not safe for concurrent use by multiple goroutines.
+// It should be scoped to a single *sql.Tx
+type cclf9Importer struct {
+	ctx context.Context
+
+	scanner        *bufio.Scanner
+	reportInterval int
+	cclfFileID     uint // CCLFFile ID that will be associated with all created crosswalk entries
+
+	importCount int
+}
+
+func (importer *cclf9Importer) Next() bool {
+	return importer.scanner.Scan()
+}
+
+func (importer *cclf9Importer) Values() ([]interface{}, error) {
+	close := metrics.NewChild(importer.ctx, "importCCLF9-xrefcreate")
+	defer close()
+
+	fields, err := parseCCLF9Record(importer.scanner.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Use Int4 because we store file_id as an integer
+	fileID := &pgtype.Int4{}
+	if err := fileID.Set(importer.cclfFileID); err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{fileID}
+	for _, field := range fields {
+		value := &pgtype.Text{}
+		if err := value.Set(field); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	importer.importCount++
+	if importer.importCount%importer.reportInterval == 0 {
+		fmt.Printf("CCLF9 records imported: %d\n", importer.importCount)
+	}
+
+	return values, nil
+}
+
+// Err allows us to report back the the CopyFrom if and when
+// the underlying context has been stopped.
+func (importer *cclf9Importer) Err() error {
+	return importer.ctx.Err()
+}
+
+// parseCCLF9Record returns the crosswalk indicator, current and previous identifiers,
+// and the previous identifier's effective and obsolete dates contained in a CCLF9 record.
+func parseCCLF9Record(b []byte) ([]string, error) {
+	const (
+		xrefIndStart, xrefIndEnd           = 0, 1
+		currNumStart, currNumEnd           = 1, 12
+		prevNumStart, prevNumEnd           = 12, 23
+		prevsEfctDtStart, prevsEfctDtEnd   = 23, 33
+		prevsObsltDtStart, prevsObsltDtEnd = 33, 43
+	)
+
+	if len(b) < prevsObsltDtEnd {
+		return nil, fmt.Errorf("invalid CCLF9 record length %d, expected at least %d", len(b), prevsObsltDtEnd)
+	}
+
+	var fields []string
+	for _, field := range [][2]int{
+		{xrefIndStart, xrefIndEnd},
+		{currNumStart, currNumEnd},
+		{prevNumStart, prevNumEnd},
+		{prevsEfctDtStart, prevsEfctDtEnd},
+		{prevsObsltDtStart, prevsObsltDtEnd},
+	} {
+		fields = append(fields, string(bytes.TrimSpace(b[field[0]:field[1]])))
+	}
+	return fields, nil
+}
+
+// CopyXrefsFrom writes all of the beneficiary crosswalk data captured in the scanner to the cclf_beneficiary_xrefs table.
+// It returns the number of rows written along with any error that occurred.
+func CopyXrefsFrom(ctx context.Context, conn *pgx.Conn, scanner *bufio.Scanner, fileID uint, reportInterval int) (int, error) {
+	importer := &cclf9Importer{
+		scanner:    scanner,
+		ctx:        ctx,
+		cclfFileID: fileID,
+
+		reportInterval: reportInterval,
+	}
+	tableName := pgx.Identifier([]string{"cclf_beneficiary_xrefs"})
+	return conn.CopyFrom(tableName,
+		[]string{"file_id", "xref_indicator", "current_num", "prev_num", "prevs_efct_dt", "prevs_obslt_dt"}, importer)
+}
@@ -34,6 +34,9 @@ type Timer interface {
 	// newChild creates a timer (child) from the parent via the supplied context.
 	newChild(parentCtx context.Context, name string) (close func())
 
+	// record reports a single value for the named metric, e.g. the number of records imported.
+	record(name string, value float64)
+
 	// Close cleans up all resources associated with the Timer. If any pending metrics
 	// have not been reported, close will flush the result out.
 	Close()
@@ -62,6 +65,12 @@ func NewChild(ctx context.Context, name string) func() {
 	return t.newChild(ctx, name)
 }
 
+// RecordValue reports a value for the named metric using the Timer found within the supplied context
+func RecordValue(ctx context.Context, name string, value float64) {
+	t := fromContext(ctx)
+	t.record(name, value)
+}
+
 var defaultTimer = &noopTimer{}
 
 // fromContext returns the Timer associated with the context.
@@ -134,6 +143,10 @@ func (t *timer) newChild(parentCtx context.Context, name string) (close func())
 	}
 }
 
+func (t *timer) record(name string, value float64) {
+	t.nr.RecordCustomMetric(name, value)
+}
+
 func (t *timer) Close() {
 	const SHUTDOWN_TIMEOUT = 30 * time.Second
 	t.nr.Shutdown(SHUTDOWN_TIMEOUT)
@@ -153,6 +166,9 @@ func (t *noopTimer) newChild(parentCtx context.Context, name string) (close func
 	return noop
 }
 
+func (t *noopTimer) record(name string, value float64) {
+}
+
 func (t *noopTimer) Close() {
 }
 
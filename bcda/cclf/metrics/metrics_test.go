@@ -108,3 +108,28 @@ func (s *MetricTestSuite) TestDefaultTimer() {
 	assert.NotNil(s.T(), t)
 	assert.IsType(s.T(), &noopTimer{}, t)
 }
+
+func (s *MetricTestSuite) TestRecordValue() {
+	timer := &recordingTimer{values: make(map[string]float64)}
+	ctx := NewContext(context.Background(), timer)
+	RecordValue(ctx, "Records", 10)
+	assert.Equal(s.T(), map[string]float64{"Records": 10}, timer.values)
+
+	// Values recorded without a Timer are dropped by the default timer
+	RecordValue(context.Background(), "Dropped", 10)
+	assert.Equal(s.T(), map[string]float64{"Records": 10}, timer.values)
+
+	// The NewRelic backed timer reports the value without error
+	RecordValue(NewContext(context.Background(), s.timer), "Records", 10)
+	assert.Empty(s.T(), s.hook.AllEntries())
+}
+
+// recordingTimer captures the recorded values
+type recordingTimer struct {
+	noopTimer
+	values map[string]float64
+}
+
+func (t *recordingTimer) record(name string, value float64) {
+	t.values[name] = value
+}
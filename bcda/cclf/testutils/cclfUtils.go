@@ -120,8 +120,8 @@ func ImportCCLFPackage(acoSize, environment string, fileType models.CCLFFileType
 		return err
 	}
 	fmt.Printf("Completed CCLF import.  Successfully imported %d files.  Failed to import %d files.  Skipped %d files.  See logs for more details.\n", success, failure, skipped)
-	if success != 2 {
-		err = errors.New("did not import 2 files")
+	if success != 3 {
+		err = errors.New("did not import 3 files")
 		return err
 	}
 
@@ -11,13 +11,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
-
 	"github.com/CMSgov/bcda-app/bcda/client/fhir"
 	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
 	"github.com/CMSgov/bcda-app/bcda/monitoring"
@@ -29,6 +28,8 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/crypto/pbkdf2"
 )
 
@@ -39,6 +40,11 @@ const (
 	jobIDHeader    = "BULK-JOBID"
 )
 
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "bcda_fhir_request_duration_seconds",
+	Help: "Latency of requests made to FHIR sources (including BFD)",
+}, []string{"source", "resource_type", "status"})
+
 // BlueButtonConfig holds the configuration settings needed to create a BlueButtonClient
 // TODO (BCDA-3755): Move the other env vars used in NewBlueButtonClient to this struct
 type BlueButtonConfig struct {
@@ -60,10 +66,12 @@ type ClaimsWindow struct {
 }
 
 type APIClient interface {
-	GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, claimsWindow ClaimsWindow) (*models.Bundle, error)
+	GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, claimsWindow ClaimsWindow, excludeSAMHSA bool) (*models.Bundle, error)
 	GetPatient(patientID, jobID, cmsID, since string, transactionTime time.Time) (*models.Bundle, error)
 	GetCoverage(beneficiaryID, jobID, cmsID, since string, transactionTime time.Time) (*models.Bundle, error)
 	GetPatientByIdentifierHash(hashedIdentifier string) (string, error)
+	// WithContext returns a copy of the client whose requests (and the waits between them) stop once ctx is done
+	WithContext(ctx context.Context) APIClient
 }
 
 type BlueButtonClient struct {
@@ -72,8 +80,15 @@ type BlueButtonClient struct {
 	maxTries      uint64
 	retryInterval time.Duration
 
+	// limiter and breaker are shared by all clients in the process
+	limiter *rateLimiter
+	breaker *circuitBreaker
+
 	bbServer   string
 	bbBasePath string
+
+	// ctx is attached to every request. A nil ctx is treated as context.Background().
+	ctx context.Context
 }
 
 // Ensure BlueButtonClient satisfies the interface
@@ -136,12 +151,27 @@ func NewBlueButtonClient(config BlueButtonConfig) (*BlueButtonClient, error) {
 		timeout = 500
 	}
 
-	hl := &httpLogger{transport, logger}
+	hl := &httpLogger{transport, logger, "bfd"}
 	httpClient := &http.Client{Transport: hl, Timeout: time.Duration(timeout) * time.Millisecond}
 	client := fhir.NewClient(httpClient, pageSize)
 	maxTries := uint64(utils.GetEnvInt("BB_REQUEST_MAX_TRIES", 3))
 	retryInterval := time.Duration(utils.GetEnvInt("BB_REQUEST_RETRY_INTERVAL_MS", 1000)) * time.Millisecond
-	return &BlueButtonClient{client, maxTries, retryInterval, config.BBServer, config.BBBasePath}, nil
+	limiter, breaker := sharedThrottle()
+	return &BlueButtonClient{client, maxTries, retryInterval, limiter, breaker, config.BBServer, config.BBBasePath,
+		context.Background()}, nil
+}
+
+func (bbc *BlueButtonClient) WithContext(ctx context.Context) APIClient {
+	c := *bbc
+	c.ctx = ctx
+	return &c
+}
+
+func (bbc *BlueButtonClient) requestContext() context.Context {
+	if bbc.ctx == nil {
+		return context.Background()
+	}
+	return bbc.ctx
 }
 
 func (bbc *BlueButtonClient) GetPatient(patientID, jobID, cmsID, since string, transactionTime time.Time) (*models.Bundle, error) {
@@ -186,7 +216,9 @@ func (bbc *BlueButtonClient) GetCoverage(beneficiaryID, jobID, cmsID, since stri
 	return bbc.getBundleData(u, jobID, cmsID, nil)
 }
 
-func (bbc *BlueButtonClient) GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, claimsWindow ClaimsWindow) (*models.Bundle, error) {
+// GetExplanationOfBenefit retrieves the beneficiary's claims. When excludeSAMHSA is set,
+// claims containing substance abuse (SAMHSA) data are omitted from the response.
+func (bbc *BlueButtonClient) GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, claimsWindow ClaimsWindow, excludeSAMHSA bool) (*models.Bundle, error) {
 	// ServiceDate only uses yyyy-mm-dd
 	const svcDateFmt = "2006-01-02"
 
@@ -194,7 +226,9 @@ func (bbc *BlueButtonClient) GetExplanationOfBenefit(patientID, jobID, cmsID, si
 	header.Add("IncludeTaxNumbers", "true")
 	params := GetDefaultParams()
 	params.Set("patient", patientID)
-	params.Set("excludeSAMHSA", "true")
+	if excludeSAMHSA {
+		params.Set("excludeSAMHSA", "true")
+	}
 
 	if !claimsWindow.LowerBound.IsZero() {
 		params.Add("service-date", fmt.Sprintf("ge%s", claimsWindow.LowerBound.Format(svcDateFmt)))
@@ -254,12 +288,8 @@ func (bbc *BlueButtonClient) tryBundleRequest(u *url.URL, jobID, cmsID string, h
 		err     error
 	)
 
-	eb := backoff.NewExponentialBackOff()
-	eb.InitialInterval = bbc.retryInterval
-	b := backoff.WithMaxRetries(eb, bbc.maxTries)
-
-	err = backoff.RetryNotify(func() error {
-		req, err := http.NewRequest("GET", u.String(), nil)
+	err = bbc.retry(func() error {
+		req, err := http.NewRequestWithContext(bbc.requestContext(), "GET", u.String(), nil)
 		if err != nil {
 			logger.Error(err)
 			return err
@@ -279,15 +309,10 @@ func (bbc *BlueButtonClient) tryBundleRequest(u *url.URL, jobID, cmsID string, h
 			logger.Error(err)
 		}
 		return err
-	},
-		b,
-		func(err error, d time.Duration) {
-			logger.Infof("Blue Button request failed %s. Retry in %s", err.Error(), d.String())
-		},
-	)
+	})
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("blue button request failed %d time(s) %s", bbc.maxTries, err.Error())
+		return nil, nil, err
 	}
 
 	return result, nextURL, nil
@@ -298,14 +323,10 @@ func (bbc *BlueButtonClient) getRawData(u *url.URL) (string, error) {
 	txn := m.Start(u.Path, nil, nil)
 	defer m.End(txn)
 
-	eb := backoff.NewExponentialBackOff()
-	eb.InitialInterval = bbc.retryInterval
-	b := backoff.WithMaxRetries(eb, bbc.maxTries)
-
 	var result string
 
-	err := backoff.RetryNotify(func() error {
-		req, err := http.NewRequest("GET", u.String(), nil)
+	err := bbc.retry(func() error {
+		req, err := http.NewRequestWithContext(bbc.requestContext(), "GET", u.String(), nil)
 		if err != nil {
 			logger.Error(err)
 			return err
@@ -317,20 +338,21 @@ func (bbc *BlueButtonClient) getRawData(u *url.URL) (string, error) {
 			logger.Error(err)
 		}
 		return err
-	},
-		b,
-		func(err error, d time.Duration) {
-			logger.Infof("Blue Button request failed %s. Retry in %s", err, d.String())
-		},
-	)
+	})
 
 	if err != nil {
-		return "", fmt.Errorf("blue button request failed %d time(s) %s", bbc.maxTries, err.Error())
+		return "", err
 	}
 
 	return result, nil
 }
 
+// retry executes the request using exponential backoff. Requests are throttled by the shared rate limiter
+// and short-circuited while BFD is degraded.
+func (bbc *BlueButtonClient) retry(request func() error) error {
+	return retryRequest(bbc.requestContext(), "blue button", bbc.maxTries, bbc.retryInterval, bbc.limiter, bbc.breaker, request)
+}
+
 func (bbc *BlueButtonClient) getURL(path string, params url.Values) (*url.URL, error) {
 	u, err := url.Parse(fmt.Sprintf("%s%s/%s/", bbc.bbServer, bbc.bbBasePath, path))
 	if err != nil {
@@ -398,14 +420,21 @@ func updateParamWithLastUpdated(params *url.Values, since string, transactionTim
 type httpLogger struct {
 	t *http.Transport
 	l *logrus.Logger
+	// source names the FHIR source in the request metrics
+	source string
 }
 
 func (h *httpLogger) RoundTrip(req *http.Request) (*http.Response, error) {
 	go h.logRequest(req.Clone(context.Background()))
+	start := time.Now()
 	resp, err := h.t.RoundTrip(req)
+	status := "error"
 	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
 		h.logResponse(req, resp)
 	}
+	requestDuration.WithLabelValues(h.source, path.Base(strings.TrimSuffix(req.URL.Path, "/")), status).
+		Observe(time.Since(start).Seconds())
 	return resp, err
 }
 
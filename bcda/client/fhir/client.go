@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
 )
@@ -21,6 +22,18 @@ type Client interface {
 
 type BundleEntry map[string]interface{}
 
+// ResponseError is returned when the service responds with an error status code.
+// RetryAfter is populated when the service indicates how long we should wait before trying again.
+type ResponseError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("received incorrect status code %d body %s", e.StatusCode, e.Body)
+}
+
 func NewClient(httpClient *http.Client, pageSize int) Client {
 	if pageSize == 0 {
 		return &singleClient{httpClient}
@@ -140,8 +153,8 @@ func getResponse(c *http.Client, req *http.Request) (body []byte, err error) {
 	if resp.StatusCode >= http.StatusBadRequest {
 		// Attempt to read the body in case it offers valuable troubleshooting info
 		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received incorrect status code %d body %s",
-			resp.StatusCode, string(body))
+		return nil, &ResponseError{StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")), Body: string(body)}
 	}
 	body, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -150,3 +163,26 @@ func getResponse(c *http.Client, req *http.Request) (body []byte, err error) {
 
 	return body, nil
 }
+
+// parseRetryAfter supports both forms of the Retry-After header: a number of seconds or an HTTP date.
+// A zero duration is returned when the header is absent or cannot be parsed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
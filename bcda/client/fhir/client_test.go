@@ -2,6 +2,7 @@ package fhir
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 	"path"
 	"strconv"
 	"testing"
+	"time"
 
 	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
 	"github.com/stretchr/testify/assert"
@@ -104,6 +106,47 @@ func TestRawRequest(t *testing.T) {
 	assert.Equal(t, msg, resp)
 }
 
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryAfter    string
+		expRetryAfter time.Duration
+	}{
+		{"No Retry-After", "", 0},
+		{"Retry-After seconds", "120", 2 * time.Minute},
+		{"Invalid Retry-After", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				http.Error(w, "slow down", http.StatusTooManyRequests)
+			}))
+			defer s.Close()
+
+			req, err := http.NewRequest("GET", s.URL, nil)
+			assert.NoError(t, err)
+
+			_, err = NewClient(http.DefaultClient, 0).DoRaw(req)
+			var respErr *ResponseError
+			if assert.True(t, errors.As(err, &respErr)) {
+				assert.Equal(t, http.StatusTooManyRequests, respErr.StatusCode)
+				assert.Equal(t, tt.expRetryAfter, respErr.RetryAfter)
+				assert.Contains(t, err.Error(), "received incorrect status code 429 body slow down")
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterDate(t *testing.T) {
+	d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 59*time.Minute && d <= time.Hour, "unexpected duration %s", d)
+	assert.Zero(t, parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+}
+
 func assertEqualsBundle(t *testing.T, pathToExpected string, actual *models.Bundle) {
 	data, err := ioutil.ReadFile(pathToExpected)
 	assert.NoError(t, err)
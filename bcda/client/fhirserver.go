@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client/fhir"
+	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
+	"github.com/CMSgov/bcda-app/bcda/monitoring"
+)
+
+const defaultMBIHashSystem = "https://bluebutton.cms.gov/resources/identifier/mbi-hash"
+
+// ErrSAMHSAUnsupported is returned when claims that must exclude substance abuse treatment data are requested
+// from a source that cannot exclude them
+var ErrSAMHSAUnsupported = errors.New("excluding SAMHSA claims is not supported")
+
+// FHIRServerClient retrieves resources from a FHIR server using only standard FHIR search parameters.
+// Unlike the BlueButtonClient, it does not rely on any BFD specific headers or extensions.
+// NOTE: Since excludeSAMHSA is a BFD extension, claims that must exclude substance abuse treatment data
+// cannot be retrieved from a source.
+type FHIRServerClient struct {
+	client fhir.Client
+
+	maxTries      uint64
+	retryInterval time.Duration
+
+	// Each source has its own limiter and breaker so a degraded source does not impact BFD
+	limiter *rateLimiter
+	breaker *circuitBreaker
+
+	name          string
+	baseURL       string
+	authToken     string
+	mbiHashSystem string
+
+	// ctx is attached to every request. A nil ctx is treated as context.Background().
+	ctx context.Context
+}
+
+// Ensure FHIRServerClient satisfies the interface
+var _ APIClient = &FHIRServerClient{}
+
+func NewFHIRServerClient(source FHIRSource) (*FHIRServerClient, error) {
+	if _, err := url.Parse(source.URL); err != nil {
+		return nil, fmt.Errorf("invalid URL for FHIR source %s: %w", source.Name, err)
+	}
+
+	timeout := source.TimeoutMS
+	if timeout <= 0 {
+		timeout = 10000
+	}
+	maxTries := source.MaxTries
+	if maxTries <= 0 {
+		maxTries = 3
+	}
+	retryInterval := source.RetryIntervalMS
+	if retryInterval <= 0 {
+		retryInterval = 1000
+	}
+	mbiHashSystem := source.MBIHashSystem
+	if mbiHashSystem == "" {
+		mbiHashSystem = defaultMBIHashSystem
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	httpClient := &http.Client{Transport: &httpLogger{transport, logger, source.Name},
+		Timeout: time.Duration(timeout) * time.Millisecond}
+	return &FHIRServerClient{
+		client:        fhir.NewClient(httpClient, source.PageSize),
+		maxTries:      uint64(maxTries),
+		retryInterval: time.Duration(retryInterval) * time.Millisecond,
+		limiter:       newRateLimiter(float64(source.RequestsPerSecond), float64(source.RequestsPerSecond)),
+		breaker:       newCircuitBreaker(0, 0),
+		name:          source.Name,
+		baseURL:       strings.TrimSuffix(source.URL, "/"),
+		authToken:     source.AuthToken,
+		mbiHashSystem: mbiHashSystem,
+	}, nil
+}
+
+func (c *FHIRServerClient) GetPatient(patientID, jobID, cmsID, since string, transactionTime time.Time) (*models.Bundle, error) {
+	params := GetDefaultParams()
+	params.Set("_id", patientID)
+	updateParamWithLastUpdated(&params, since, transactionTime)
+
+	return c.getBundleData(c.getURL("Patient", params), jobID, cmsID)
+}
+
+func (c *FHIRServerClient) GetPatientByIdentifierHash(hashedIdentifier string) (string, error) {
+	params := GetDefaultParams()
+	params.Set("identifier", fmt.Sprintf("%s|%s", c.mbiHashSystem, hashedIdentifier))
+
+	u := c.getURL("Patient", params)
+	m := monitoring.GetMonitor()
+	txn := m.Start(u.Path, nil, nil)
+	defer m.End(txn)
+
+	var result string
+	err := c.retry(func() error {
+		req, err := c.newRequest(u, "", "")
+		if err != nil {
+			return err
+		}
+
+		result, err = c.client.DoRaw(req)
+		if err != nil {
+			logger.Error(err)
+		}
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+func (c *FHIRServerClient) GetCoverage(beneficiaryID, jobID, cmsID, since string, transactionTime time.Time) (*models.Bundle, error) {
+	params := GetDefaultParams()
+	params.Set("beneficiary", beneficiaryID)
+	updateParamWithLastUpdated(&params, since, transactionTime)
+
+	return c.getBundleData(c.getURL("Coverage", params), jobID, cmsID)
+}
+
+// GetExplanationOfBenefit retrieves the beneficiary's claims. Since the FHIR specification has no means of
+// excluding substance abuse treatment claims, ErrSAMHSAUnsupported is returned when excludeSAMHSA is set.
+func (c *FHIRServerClient) GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, claimsWindow ClaimsWindow, excludeSAMHSA bool) (*models.Bundle, error) {
+	if excludeSAMHSA {
+		return nil, fmt.Errorf("%s cannot exclude substance abuse treatment claims: %w", c.name, ErrSAMHSAUnsupported)
+	}
+
+	// ServiceDate only uses yyyy-mm-dd
+	const svcDateFmt = "2006-01-02"
+
+	params := GetDefaultParams()
+	params.Set("patient", patientID)
+
+	if !claimsWindow.LowerBound.IsZero() {
+		params.Add("service-date", fmt.Sprintf("ge%s", claimsWindow.LowerBound.Format(svcDateFmt)))
+	}
+	if !claimsWindow.UpperBound.IsZero() {
+		params.Add("service-date", fmt.Sprintf("le%s", claimsWindow.UpperBound.Format(svcDateFmt)))
+	}
+
+	updateParamWithLastUpdated(&params, since, transactionTime)
+
+	return c.getBundleData(c.getURL("ExplanationOfBenefit", params), jobID, cmsID)
+}
+
+func (c *FHIRServerClient) getBundleData(u *url.URL, jobID, cmsID string) (*models.Bundle, error) {
+	m := monitoring.GetMonitor()
+	txn := m.Start(u.Path, nil, nil)
+	defer m.End(txn)
+
+	var b *models.Bundle
+	for u != nil {
+		var (
+			result  *models.Bundle
+			nextURL *url.URL
+		)
+		err := c.retry(func() error {
+			req, err := c.newRequest(u, jobID, cmsID)
+			if err != nil {
+				return err
+			}
+
+			result, nextURL, err = c.client.DoBundleRequest(req)
+			if err != nil {
+				logger.Error(err)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if b == nil {
+			b = result
+		} else {
+			b.Entries = append(b.Entries, result.Entries...)
+		}
+		u = nextURL
+	}
+
+	return b, nil
+}
+
+func (c *FHIRServerClient) newRequest(u *url.URL, jobID, cmsID string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", u.String(), nil)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	req.Header.Add("Accept", "application/fhir+json")
+	if c.authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+c.authToken)
+	}
+	if jobID != "" {
+		req.Header.Add(jobIDHeader, jobID)
+	}
+	if cmsID != "" {
+		req.Header.Add(clientIDHeader, cmsID)
+	}
+
+	return req, nil
+}
+
+func (c *FHIRServerClient) retry(request func() error) error {
+	return retryRequest(c.requestContext(), c.name, c.maxTries, c.retryInterval, c.limiter, c.breaker, request)
+}
+
+func (c *FHIRServerClient) WithContext(ctx context.Context) APIClient {
+	cc := *c
+	cc.ctx = ctx
+	return &cc
+}
+
+func (c *FHIRServerClient) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *FHIRServerClient) getURL(resourceType string, params url.Values) *url.URL {
+	// baseURL has already been validated when the client was created
+	u, _ := url.Parse(fmt.Sprintf("%s/%s", c.baseURL, resourceType))
+	u.RawQuery = params.Encode()
+	return u
+}
@@ -0,0 +1,147 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client"
+	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFHIRServerClient(t *testing.T) {
+	var requests []*http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.URL.Query().Get("_getpages") == "" && r.URL.Path != "/fhir/Patient" {
+			// Force the client to follow the next link
+			fmt.Fprintf(w, `{"resourceType":"Bundle","link":[{"relation":"next","url":"http://%s%s?_getpages=1"}],"entry":[{"resource":{"id":"1"}}]}`,
+				r.Host, r.URL.Path)
+			return
+		}
+		fmt.Fprint(w, `{"resourceType":"Bundle","entry":[{"resource":{"id":"2"}}]}`)
+	}))
+	defer ts.Close()
+
+	c, err := client.NewFHIRServerClient(client.FHIRSource{Name: "hapi", URL: ts.URL + "/fhir/", AuthToken: "token", PageSize: 1})
+	assert.NoError(t, err)
+
+	transactionTime := time.Now()
+	claimsWindow := client.ClaimsWindow{LowerBound: time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC),
+		UpperBound: time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name        string
+		call        func() (*models.Bundle, error)
+		expPath     string
+		expParams   map[string][]string
+		expEntries  int
+		expRequests int
+	}{
+		{"Patient",
+			func() (*models.Bundle, error) { return c.GetPatient("123", "1", "A0000", "", transactionTime) },
+			"/fhir/Patient", map[string][]string{"_id": {"123"}}, 1, 1},
+		{"Coverage",
+			func() (*models.Bundle, error) {
+				return c.GetCoverage("123", "1", "A0000", "gt2020-02-14", transactionTime)
+			},
+			"/fhir/Coverage", map[string][]string{"beneficiary": {"123"},
+				"_lastUpdated": {"le" + transactionTime.Format(time.RFC3339Nano), "gt2020-02-14"}}, 2, 2},
+		{"ExplanationOfBenefit",
+			func() (*models.Bundle, error) {
+				return c.GetExplanationOfBenefit("123", "1", "A0000", "", transactionTime, claimsWindow, false)
+			},
+			"/fhir/ExplanationOfBenefit", map[string][]string{"patient": {"123"},
+				"service-date": {"ge2017-12-31", "le2020-12-31"}}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			bundle, err := tt.call()
+			assert.NoError(t, err)
+			assert.Len(t, requests, tt.expRequests)
+			// Entries are combined across all of the pages
+			assert.Len(t, bundle.Entries, tt.expEntries)
+
+			req := requests[0]
+			assert.Equal(t, tt.expPath, req.URL.Path)
+			for key, values := range tt.expParams {
+				assert.Equal(t, values, req.URL.Query()[key])
+			}
+			assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+			// BFD specific parameters and headers should not be sent
+			assert.Empty(t, req.URL.Query().Get("excludeSAMHSA"))
+			assert.Empty(t, req.Header.Get("BlueButton-OriginalQueryId"))
+		})
+	}
+
+	// Request latency is recorded per source and resource type, including the requests for subsequent pages
+	rr := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/_metrics", nil))
+	assert.Contains(t, rr.Body.String(),
+		`bcda_fhir_request_duration_seconds_count{resource_type="Coverage",source="hapi",status="200"} 2`)
+	assert.Contains(t, rr.Body.String(),
+		`bcda_fhir_request_duration_seconds_count{resource_type="Patient",source="hapi",status="200"} 1`)
+}
+
+// TestFHIRServerClientExcludeSAMHSA verifies that claims are not retrieved when substance abuse treatment
+// claims must be excluded, since a source cannot exclude them
+func TestFHIRServerClientExcludeSAMHSA(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		fmt.Fprint(w, `{"resourceType":"Bundle"}`)
+	}))
+	defer ts.Close()
+
+	c, err := client.NewFHIRServerClient(client.FHIRSource{Name: "hapi", URL: ts.URL})
+	assert.NoError(t, err)
+	bundle, err := c.GetExplanationOfBenefit("123", "1", "A0000", "", time.Now(), client.ClaimsWindow{}, true)
+	assert.Nil(t, bundle)
+	assert.ErrorIs(t, err, client.ErrSAMHSAUnsupported)
+	assert.Equal(t, 0, count)
+}
+
+func TestFHIRServerClientPatientByIdentifierHash(t *testing.T) {
+	var identifier string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identifier = r.URL.Query().Get("identifier")
+		fmt.Fprint(w, `{"resourceType":"Bundle"}`)
+	}))
+	defer ts.Close()
+
+	c, err := client.NewFHIRServerClient(client.FHIRSource{Name: "hapi", URL: ts.URL})
+	assert.NoError(t, err)
+	resp, err := c.GetPatientByIdentifierHash("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"resourceType":"Bundle"}`, resp)
+	assert.Equal(t, "https://bluebutton.cms.gov/resources/identifier/mbi-hash|hash", identifier)
+
+	c, err = client.NewFHIRServerClient(client.FHIRSource{Name: "hapi", URL: ts.URL, MBIHashSystem: "urn:mbi-hash"})
+	assert.NoError(t, err)
+	_, err = c.GetPatientByIdentifierHash("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "urn:mbi-hash|hash", identifier)
+}
+
+func TestFHIRServerClientErrors(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	c, err := client.NewFHIRServerClient(client.FHIRSource{Name: "hapi", URL: ts.URL, RetryIntervalMS: 1})
+	assert.NoError(t, err)
+	bundle, err := c.GetPatient("123", "1", "A0000", "", time.Now())
+	assert.Nil(t, bundle)
+	assert.Contains(t, err.Error(), "hapi request failed 3 time(s)")
+	// Client errors are not retried
+	assert.Equal(t, 1, count)
+}
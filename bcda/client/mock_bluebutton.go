@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
@@ -18,14 +19,19 @@ type MockBlueButtonClient struct {
 	MBI  *string
 }
 
-func (bbc *MockBlueButtonClient) GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, serviceDate ClaimsWindow) (*models.Bundle, error) {
-	args := bbc.Called(patientID, jobID, cmsID, since, transactionTime, serviceDate)
+func (bbc *MockBlueButtonClient) GetExplanationOfBenefit(patientID, jobID, cmsID, since string, transactionTime time.Time, serviceDate ClaimsWindow, excludeSAMHSA bool) (*models.Bundle, error) {
+	args := bbc.Called(patientID, jobID, cmsID, since, transactionTime, serviceDate, excludeSAMHSA)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Bundle), args.Error(1)
 }
 
+// WithContext returns the mock itself so expectations set on the mock still apply
+func (bbc *MockBlueButtonClient) WithContext(ctx context.Context) APIClient {
+	return bbc
+}
+
 func (bbc *MockBlueButtonClient) GetPatientByIdentifierHash(hashedIdentifier string) (string, error) {
 	args := bbc.Called(hashedIdentifier)
 	return args.String(0), args.Error(1)
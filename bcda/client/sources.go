@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/CMSgov/bcda-app/conf"
+	"github.com/sirupsen/logrus"
+)
+
+// Resource types that can be retrieved from a FHIR source
+var supportedResourceTypes = map[string]struct{}{
+	"Coverage":             {},
+	"ExplanationOfBenefit": {},
+	"Patient":              {},
+}
+
+// SourceConfig describes the FHIR servers that resources can be retrieved from.
+// Resource types without an entry in ResourceSources are retrieved from BFD.
+type SourceConfig struct {
+	Sources []FHIRSource `conf:"fhir_sources"`
+	// Maps a resource type (ex: Patient) to the name of the source that serves it
+	ResourceSources map[string]string `conf:"fhir_resource_sources"`
+}
+
+// FHIRSource contains the settings needed to communicate with a FHIR server
+type FHIRSource struct {
+	Name string `conf:"name"`
+	URL  string `conf:"url"`
+	// Optional bearer token supplied on every request
+	AuthToken string `conf:"auth_token"`
+	// Identifier system used when searching for a patient by their hashed MBI
+	MBIHashSystem     string `conf:"mbi_hash_system"`
+	PageSize          int    `conf:"page_size"`
+	TimeoutMS         int    `conf:"timeout_ms"`
+	MaxTries          int    `conf:"max_tries"`
+	RetryIntervalMS   int    `conf:"retry_interval_ms"`
+	RequestsPerSecond int    `conf:"requests_per_second"`
+}
+
+func LoadSourceConfig() (*SourceConfig, error) {
+	cfg := &SourceConfig{}
+	if err := conf.Checkout(cfg); err != nil {
+		return nil, err
+	}
+
+	// Avoid logging the entire config since it may contain credentials
+	logrus.Infof("Successfully loaded %d FHIR source(s) serving %v.", len(cfg.Sources), cfg.ResourceSources)
+	return cfg, nil
+}
+
+// Sources resolves the client used to retrieve each resource type
+type Sources struct {
+	// Resource type -> client for the configured source
+	clients map[string]APIClient
+}
+
+// NewSources validates the config and creates a client for each configured source.
+// Clients are shared across requests so each source's rate limit applies to the entire process.
+func NewSources(cfg SourceConfig) (*Sources, error) {
+	sources := make(map[string]APIClient, len(cfg.Sources))
+	for _, source := range cfg.Sources {
+		if source.Name == "" || source.URL == "" {
+			return nil, fmt.Errorf("FHIR source must have a name and URL %+v", source)
+		}
+		if _, ok := sources[source.Name]; ok {
+			return nil, fmt.Errorf("duplicate FHIR source %s", source.Name)
+		}
+		c, err := NewFHIRServerClient(source)
+		if err != nil {
+			return nil, err
+		}
+		sources[source.Name] = c
+	}
+
+	clients := make(map[string]APIClient, len(cfg.ResourceSources))
+	for resourceType, name := range cfg.ResourceSources {
+		if _, ok := supportedResourceTypes[resourceType]; !ok {
+			return nil, fmt.Errorf("unsupported resource type %s for FHIR source %s", resourceType, name)
+		}
+		c, ok := sources[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown FHIR source %s for resource type %s", name, resourceType)
+		}
+		clients[resourceType] = c
+	}
+
+	return &Sources{clients: clients}, nil
+}
+
+// Client returns the client used to retrieve the resource type. Resource types that have not
+// been mapped to a source are retrieved from BFD using the supplied base path.
+func (s *Sources) Client(resourceType, bbBasePath string) (APIClient, error) {
+	if c, ok := s.clients[resourceType]; ok {
+		return c, nil
+	}
+
+	// Avoid returning a typed nil
+	bb, err := NewBlueButtonClient(NewConfig(bbBasePath))
+	if err != nil {
+		return nil, err
+	}
+	return bb, nil
+}
@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/CMSgov/bcda-app/bcda/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSourceConfig(t *testing.T) {
+	// No sources are configured by default
+	cfg, err := client.LoadSourceConfig()
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.Sources)
+	assert.Empty(t, cfg.ResourceSources)
+}
+
+func TestNewSources(t *testing.T) {
+	hapi := client.FHIRSource{Name: "hapi", URL: "http://localhost:8080/fhir"}
+	tests := []struct {
+		name   string
+		cfg    client.SourceConfig
+		expErr string
+	}{
+		{"Valid", client.SourceConfig{Sources: []client.FHIRSource{hapi},
+			ResourceSources: map[string]string{"Patient": "hapi", "Coverage": "hapi"}}, ""},
+		{"No sources", client.SourceConfig{}, ""},
+		{"Missing URL", client.SourceConfig{Sources: []client.FHIRSource{{Name: "hapi"}}},
+			"FHIR source must have a name and URL"},
+		{"Duplicate source", client.SourceConfig{Sources: []client.FHIRSource{hapi, hapi}},
+			"duplicate FHIR source hapi"},
+		{"Unknown source", client.SourceConfig{Sources: []client.FHIRSource{hapi},
+			ResourceSources: map[string]string{"Patient": "synthea"}}, "unknown FHIR source synthea for resource type Patient"},
+		{"Unsupported resource", client.SourceConfig{Sources: []client.FHIRSource{hapi},
+			ResourceSources: map[string]string{"Claim": "hapi"}}, "unsupported resource type Claim for FHIR source hapi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := client.NewSources(tt.cfg)
+			if tt.expErr != "" {
+				assert.Nil(t, sources)
+				assert.Contains(t, err.Error(), tt.expErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, sources)
+		})
+	}
+}
+
+func TestSourcesClient(t *testing.T) {
+	sources, err := client.NewSources(client.SourceConfig{
+		Sources:         []client.FHIRSource{{Name: "hapi", URL: "http://localhost:8080/fhir"}},
+		ResourceSources: map[string]string{"Patient": "hapi", "Coverage": "hapi"}})
+	assert.NoError(t, err)
+
+	patient, err := sources.Client("Patient", "/v1/fhir")
+	assert.NoError(t, err)
+	assert.IsType(t, &client.FHIRServerClient{}, patient)
+
+	// Clients are shared across resource types served by the same source
+	coverage, err := sources.Client("Coverage", "/v1/fhir")
+	assert.NoError(t, err)
+	assert.Same(t, patient, coverage)
+}
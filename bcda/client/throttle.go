@@ -0,0 +1,277 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client/fhir"
+	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/cenkalti/backoff/v4"
+)
+
+// ErrBFDUnavailable is returned when requests to BFD are short-circuited because BFD is degraded.
+// Callers should stop issuing requests and try again later.
+var ErrBFDUnavailable = errors.New("blue button is unavailable")
+
+var (
+	throttleOnce  sync.Once
+	sharedLimiter *rateLimiter
+	sharedBreaker *circuitBreaker
+)
+
+// sharedThrottle returns the rate limiter and circuit breaker shared by every BlueButtonClient
+// in the process. This ensures that all of the workers respect a single budget when talking to BFD.
+func sharedThrottle() (*rateLimiter, *circuitBreaker) {
+	throttleOnce.Do(func() {
+		rate := float64(utils.GetEnvInt("BB_REQUESTS_PER_SECOND", 50))
+		burst := float64(utils.GetEnvInt("BB_REQUEST_BURST", int(rate)))
+		sharedLimiter = newRateLimiter(rate, burst)
+
+		threshold := utils.GetEnvInt("BB_CIRCUIT_FAILURE_THRESHOLD", 20)
+		cooldown := time.Duration(utils.GetEnvInt("BB_CIRCUIT_COOLDOWN_MS", 30000)) * time.Millisecond
+		sharedBreaker = newCircuitBreaker(threshold, cooldown)
+	})
+	return sharedLimiter, sharedBreaker
+}
+
+// rateLimiter is a token bucket that limits the rate of requests made to BFD.
+// BFD can also ask us to pause all requests (via Retry-After) which applies to every caller.
+type rateLimiter struct {
+	mu sync.Mutex
+
+	// rate is the number of tokens added per second. A non-positive rate disables the limit.
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// resumeAt is the earliest time that a request may be made
+	resumeAt time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now(),
+		now: time.Now, sleep: sleep}
+}
+
+// wait blocks until a request may be made or the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for d := l.reserve(); d > 0; d = l.reserve() {
+		if err := l.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sleep pauses for the duration, returning early with the context's error once the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token if one is available. Otherwise, it returns how long the caller should wait
+// before trying again.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Before(l.resumeAt) {
+		return l.resumeAt.Sub(now)
+	}
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// pause prevents any requests from being made for the supplied duration
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resumeAt := l.now().Add(d); resumeAt.After(l.resumeAt) {
+		l.resumeAt = resumeAt
+	}
+}
+
+// circuitBreaker stops requests from being made to BFD once it has seen threshold consecutive failures.
+// After the cooldown has elapsed, a single request is allowed through to probe whether BFD has recovered.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	// threshold is the number of consecutive failures that opens the circuit. A non-positive threshold disables the breaker.
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	now func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow returns ErrBFDUnavailable if the request should not be made
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.threshold <= 0 || cb.failures < cb.threshold {
+		return nil
+	}
+
+	if cb.now().Sub(cb.openedAt) < cb.cooldown || cb.probing {
+		return ErrBFDUnavailable
+	}
+
+	cb.probing = true
+	return nil
+}
+
+// record tracks the outcome of a request that was allowed through the breaker
+func (cb *circuitBreaker) record(degraded bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+	if !degraded {
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+	}
+}
+
+// release gives up a request that was allowed through the breaker without recording an outcome,
+// e.g. because the request was cancelled, so that a subsequent request may probe BFD
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+}
+
+// classifyError determines whether a failed request should be retried and whether the failure indicates that BFD is degraded.
+// retryAfter is populated when BFD asked us to wait before making any further requests.
+func classifyError(err error) (retryable, degraded bool, retryAfter time.Duration) {
+	if err == nil {
+		return false, false, 0
+	}
+
+	// The caller gave up on the request, which says nothing about the health of the server
+	if errors.Is(err, context.Canceled) {
+		return false, false, 0
+	}
+
+	var respErr *fhir.ResponseError
+	if errors.As(err, &respErr) {
+		switch {
+		case respErr.StatusCode == http.StatusTooManyRequests || respErr.StatusCode >= http.StatusInternalServerError:
+			return true, true, respErr.RetryAfter
+		default:
+			// Remaining 4xx errors are caused by the request and will not succeed on a retry
+			return false, false, 0
+		}
+	}
+
+	// Timeouts and connection failures
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, true, 0
+	}
+
+	return true, false, 0
+}
+
+// retryRequest executes the request using exponential backoff. Requests wait on the rate limiter and are short-circuited
+// by the circuit breaker. Only failures that may succeed on a subsequent attempt are retried.
+// Waiting on the limiter and between attempts stops once the context is done.
+func retryRequest(ctx context.Context, name string, maxTries uint64, retryInterval time.Duration, limiter *rateLimiter,
+	breaker *circuitBreaker, request func() error) error {
+	eb := backoff.NewExponentialBackOff()
+	eb.InitialInterval = retryInterval
+	b := backoff.WithContext(backoff.WithMaxRetries(eb, maxTries), ctx)
+
+	err := backoff.RetryNotify(func() error {
+		if err := breaker.allow(); err != nil {
+			return backoff.Permanent(err)
+		}
+		if err := limiter.wait(ctx); err != nil {
+			breaker.release()
+			return backoff.Permanent(err)
+		}
+
+		err := request()
+		retryable, degraded, retryAfter := classifyError(err)
+		if errors.Is(err, context.Canceled) {
+			// A cancelled request says nothing about the health of BFD
+			breaker.release()
+		} else {
+			breaker.record(degraded)
+		}
+		if err == nil {
+			return nil
+		}
+
+		// Honour the server's request to back off. This applies to all requests sharing the limiter.
+		if retryAfter > 0 {
+			logger.Warnf("%s requested that we retry after %s", name, retryAfter)
+			limiter.pause(retryAfter)
+		}
+
+		if !retryable {
+			return backoff.Permanent(err)
+		}
+		return err
+	},
+		b,
+		func(err error, d time.Duration) {
+			logger.Infof("%s request failed %s. Retry in %s", name, err.Error(), d.String())
+		},
+	)
+
+	if errors.Is(err, ErrBFDUnavailable) {
+		return fmt.Errorf("%s request not attempted: %w", name, err)
+	} else if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%s request cancelled: %w", name, err)
+	} else if err != nil {
+		return fmt.Errorf("%s request failed %d time(s) %w", name, maxTries, err)
+	}
+
+	return nil
+}
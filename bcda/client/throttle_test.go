@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client/fhir"
+	models "github.com/CMSgov/bcda-app/bcda/models/fhir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	var slept []time.Duration
+	l := newRateLimiter(2, 2)
+	l.last = now
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	// Burst is available immediately
+	assert.NoError(t, l.wait(context.Background()))
+	assert.NoError(t, l.wait(context.Background()))
+	assert.Empty(t, slept)
+
+	// Subsequent requests are limited to the configured rate
+	assert.NoError(t, l.wait(context.Background()))
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, slept)
+
+	// Pausing delays all requests
+	slept = nil
+	l.pause(time.Minute)
+	assert.NoError(t, l.wait(context.Background()))
+	assert.Equal(t, time.Minute, slept[0])
+
+	// A shorter pause does not override the existing one
+	l.pause(time.Hour)
+	l.pause(time.Second)
+	assert.Equal(t, time.Hour, l.reserve())
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		assert.Zero(t, l.reserve())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	// Successful requests reset the failure count
+	cb.record(true)
+	cb.record(false)
+	cb.record(true)
+	assert.NoError(t, cb.allow())
+
+	cb.record(true)
+	assert.Equal(t, ErrBFDUnavailable, cb.allow())
+
+	// After the cooldown, only a single probe is allowed through
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.allow())
+	assert.Equal(t, ErrBFDUnavailable, cb.allow())
+
+	// Failed probe re-opens the circuit
+	cb.record(true)
+	assert.Equal(t, ErrBFDUnavailable, cb.allow())
+
+	// A released probe allows another probe without closing the circuit
+	now = now.Add(time.Minute)
+	assert.NoError(t, cb.allow())
+	cb.release()
+	assert.NoError(t, cb.allow())
+	assert.Equal(t, ErrBFDUnavailable, cb.allow())
+	cb.release()
+
+	assert.NoError(t, cb.allow())
+	cb.record(false)
+	assert.NoError(t, cb.allow())
+	assert.NoError(t, cb.allow())
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expRetryable  bool
+		expDegraded   bool
+		expRetryAfter time.Duration
+	}{
+		{"No error", nil, false, false, 0},
+		{"Too many requests", &fhir.ResponseError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}, true, true, time.Second},
+		{"Service unavailable", &fhir.ResponseError{StatusCode: http.StatusServiceUnavailable}, true, true, 0},
+		{"Internal server error", &fhir.ResponseError{StatusCode: http.StatusInternalServerError}, true, true, 0},
+		{"Bad request", &fhir.ResponseError{StatusCode: http.StatusBadRequest}, false, false, 0},
+		{"Not found", &fhir.ResponseError{StatusCode: http.StatusNotFound}, false, false, 0},
+		{"Timeout", &url.Error{Op: "Get", URL: "https://bfd", Err: timeoutError{}}, true, true, 0},
+		{"Other", errors.New("unexpected end of JSON input"), true, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, degraded, retryAfter := classifyError(tt.err)
+			assert.Equal(t, tt.expRetryable, retryable)
+			assert.Equal(t, tt.expDegraded, degraded)
+			assert.Equal(t, tt.expRetryAfter, retryAfter)
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxTries    uint64
+		errs        []error
+		expRequests int
+		expErr      error
+	}{
+		{"Success", 3, nil, 1, nil},
+		{"Recovers", 3, []error{&fhir.ResponseError{StatusCode: http.StatusServiceUnavailable}}, 2, nil},
+		{"Not retried", 3, []error{&fhir.ResponseError{StatusCode: http.StatusBadRequest}}, 1,
+			&fhir.ResponseError{StatusCode: http.StatusBadRequest}},
+		{"Retries exhausted", 2, []error{
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError},
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError},
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError}},
+			3, &fhir.ResponseError{StatusCode: http.StatusInternalServerError}},
+		// Third failure opens the circuit so no further requests are made
+		{"Circuit opened", 4, []error{
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError},
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError},
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError},
+			&fhir.ResponseError{StatusCode: http.StatusInternalServerError}},
+			3, ErrBFDUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := &stubClient{errs: tt.errs}
+			bbc := &BlueButtonClient{client: fc, maxTries: tt.maxTries, retryInterval: time.Millisecond,
+				limiter: newRateLimiter(0, 0), breaker: newCircuitBreaker(3, time.Minute)}
+
+			u, err := url.Parse("https://bfd/v1/fhir/metadata")
+			assert.NoError(t, err)
+			_, err = bbc.getRawData(u)
+			assert.Equal(t, tt.expRequests, fc.requests)
+
+			if tt.expErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Contains(t, err.Error(), tt.expErr.Error())
+			if tt.expErr == ErrBFDUnavailable {
+				assert.True(t, errors.Is(err, ErrBFDUnavailable))
+			}
+		})
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	now := time.Now()
+	var slept time.Duration
+	limiter := newRateLimiter(0, 0)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+
+	fc := &stubClient{errs: []error{&fhir.ResponseError{StatusCode: http.StatusTooManyRequests, RetryAfter: 5 * time.Second}}}
+	bbc := &BlueButtonClient{client: fc, maxTries: 1, retryInterval: time.Millisecond,
+		limiter: limiter, breaker: newCircuitBreaker(3, time.Minute)}
+
+	u, err := url.Parse("https://bfd/v1/fhir/metadata")
+	assert.NoError(t, err)
+	_, err = bbc.getRawData(u)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fc.requests)
+	assert.Equal(t, 5*time.Second, slept)
+}
+
+func TestRetryCancelled(t *testing.T) {
+	// The Retry-After wait is interrupted once the client's context is cancelled
+	fc := &stubClient{errs: []error{&fhir.ResponseError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	bbc := (&BlueButtonClient{client: fc, maxTries: 3, retryInterval: time.Millisecond,
+		limiter: newRateLimiter(0, 0), breaker: newCircuitBreaker(3, time.Minute)}).WithContext(ctx).(*BlueButtonClient)
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	u, err := url.Parse("https://bfd/v1/fhir/metadata")
+	assert.NoError(t, err)
+	_, err = bbc.getRawData(u)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, fc.requests)
+}
+
+func TestRetryReleasesProbe(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(1, time.Minute)
+	breaker.now = func() time.Time { return now }
+	breaker.record(true)
+	now = now.Add(time.Minute)
+
+	// The probe is cancelled while waiting on the limiter, so it is never made
+	limiter := newRateLimiter(0, 0)
+	limiter.pause(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var requests int
+	err := retryRequest(ctx, "BFD", 3, time.Millisecond, limiter, breaker, func() error {
+		requests++
+		return nil
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Zero(t, requests)
+
+	// Another request may probe BFD
+	err = retryRequest(context.Background(), "BFD", 3, time.Millisecond, newRateLimiter(0, 0), breaker, func() error {
+		requests++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+// stubClient returns the supplied errors (in order) before succeeding
+type stubClient struct {
+	errs     []error
+	requests int
+}
+
+func (c *stubClient) DoBundleRequest(req *http.Request) (*models.Bundle, *url.URL, error) {
+	_, err := c.DoRaw(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &models.Bundle{}, nil, nil
+}
+
+func (c *stubClient) DoRaw(req *http.Request) (string, error) {
+	c.requests++
+	if c.requests <= len(c.errs) {
+		return "", c.errs[c.requests-1]
+	}
+	return "{}", nil
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
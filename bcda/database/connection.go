@@ -37,6 +37,17 @@ func init() {
 		time.Duration(cfg.HealthCheckSec)*time.Second)
 }
 
+// NewConnection opens a connection pool to the BCDA database that is separate from Connection,
+// allowing callers to bound their own use of the database. The pool is limited to maxOpenConns connections.
+func NewConnection(maxOpenConns int) (*sql.DB, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxOpenConns, cfg.MaxIdleConns = maxOpenConns, maxOpenConns
+	return createDB(cfg)
+}
+
 func createDB(cfg *Config) (*sql.DB, error) {
 	dc := stdlib.DriverConfig{
 		ConnConfig: pgx.ConnConfig{
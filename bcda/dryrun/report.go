@@ -0,0 +1,81 @@
+// Package dryrun describes the results of validating a directory of deliveries without importing it.
+package dryrun
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Report lists every problem found in a directory of deliveries
+type Report struct {
+	Directory string        `json:"directory"`
+	FileType  string        `json:"file_type"`
+	Files     []*FileReport `json:"files"`
+}
+
+// FileReport contains the problems found in a single delivered file.
+// For archives that contain multiple files, Name identifies the file inside of the archive.
+type FileReport struct {
+	Path     string    `json:"path"`
+	Name     string    `json:"name,omitempty"`
+	Records  int       `json:"records"`
+	Problems []Problem `json:"problems"`
+	// OmittedProblems counts the line level problems that were not listed
+	// once the file reached MaxLineProblems.
+	OmittedProblems int `json:"omitted_problems,omitempty"`
+
+	lineProblems int
+}
+
+// Problem describes an issue that would prevent the file from being imported.
+// Line is the 1-based line number within the file, or omitted if the problem applies to the entire file.
+type Problem struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// MaxLineProblems caps the number of line level problems listed for a single file
+// so a badly formatted delivery does not produce an enormous report.
+const MaxLineProblems = 100
+
+func NewReport(directory, fileType string) *Report {
+	return &Report{Directory: directory, FileType: fileType, Files: []*FileReport{}}
+}
+
+// AddFile adds an entry for the supplied file to the report
+func (r *Report) AddFile(path, name string) *FileReport {
+	f := &FileReport{Path: path, Name: name, Problems: []Problem{}}
+	r.Files = append(r.Files, f)
+	return f
+}
+
+// ProblemCount returns the total number of problems found across all files
+func (r *Report) ProblemCount() int {
+	var count int
+	for _, f := range r.Files {
+		count += len(f.Problems) + f.OmittedProblems
+	}
+	return count
+}
+
+// Write marshals the report as JSON to the supplied writer
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// AddProblem records a problem that applies to the entire file
+func (f *FileReport) AddProblem(message string) {
+	f.Problems = append(f.Problems, Problem{Message: message})
+}
+
+// AddLineProblem records a problem found on a specific line of the file
+func (f *FileReport) AddLineProblem(line int, message string) {
+	f.lineProblems++
+	if f.lineProblems > MaxLineProblems {
+		f.OmittedProblems++
+		return
+	}
+	f.Problems = append(f.Problems, Problem{Line: line, Message: message})
+}
@@ -0,0 +1,46 @@
+package dryrun
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	r := NewReport("/inbound", "cclf")
+	f := r.AddFile("/inbound/archive", "file")
+	f.Records = 2
+	f.AddProblem("missing header")
+	f.AddLineProblem(3, "bad record")
+	r.AddFile("/inbound/other", "")
+
+	assert.Equal(t, 2, r.ProblemCount())
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.Write(&buf))
+	assert.JSONEq(t, `{
+		"directory": "/inbound",
+		"file_type": "cclf",
+		"files": [
+			{"path": "/inbound/archive", "name": "file", "records": 2, "problems": [
+				{"message": "missing header"},
+				{"line": 3, "message": "bad record"}
+			]},
+			{"path": "/inbound/other", "records": 0, "problems": []}
+		]
+	}`, buf.String())
+}
+
+func TestMaxLineProblems(t *testing.T) {
+	r := NewReport("/inbound", "suppression")
+	f := r.AddFile("/inbound/file", "")
+	f.AddProblem("no trailer")
+	for i := 1; i <= MaxLineProblems+5; i++ {
+		f.AddLineProblem(i, "bad record")
+	}
+
+	assert.Len(t, f.Problems, MaxLineProblems+1)
+	assert.Equal(t, 5, f.OmittedProblems)
+	assert.Equal(t, MaxLineProblems+6, r.ProblemCount())
+}
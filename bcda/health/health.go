@@ -1,6 +1,10 @@
 package health
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+
 	"github.com/CMSgov/bcda-app/bcda/client"
 	"github.com/CMSgov/bcda-app/bcda/database"
 	_ "github.com/jackc/pgx"
@@ -18,6 +22,38 @@ func IsDatabaseOK() bool {
 	return true
 }
 
+func IsQueueDatabaseOK() bool {
+	pool := database.QueueConnection
+	c, err := pool.Acquire()
+	if err != nil {
+		log.Error("Health check: queue database connection error: ", err.Error())
+		return false
+	}
+	defer pool.Release(c)
+
+	if err := c.Ping(context.Background()); err != nil {
+		log.Error("Health check: queue database ping error: ", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// IsDirectoryWritable verifies that files can be created in the directory
+func IsDirectoryWritable(dir string) bool {
+	f, err := ioutil.TempFile(dir, ".health")
+	if err != nil {
+		log.Errorf("Health check: directory %s is not writable: %s", dir, err.Error())
+		return false
+	}
+
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		log.Errorf("Health check: failed to remove %s: %s", f.Name(), err.Error())
+	}
+	return true
+}
+
 func IsBlueButtonOK() bool {
 	bbc, err := client.NewBlueButtonClient(client.NewConfig("/v1/fhir"))
 	if err != nil {
@@ -0,0 +1,24 @@
+package health
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDirectoryWritable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.True(t, IsDirectoryWritable(dir))
+	// The file used to verify the directory is removed
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	assert.False(t, IsDirectoryWritable(filepath.Join(dir, "missing")))
+}
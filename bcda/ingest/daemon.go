@@ -0,0 +1,294 @@
+// Package ingest provides a long running daemon that watches the inbound directories
+// for new deliveries and dispatches them to the matching importer.
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Importer imports the deliveries found in dir. Importers must move the files they have
+// finished with out of dir. Any file left behind is considered failed and will be retried.
+type Importer func(dir string) (success, failure, skipped int, err error)
+
+// Source is an inbound directory and the importer used for the deliveries placed in it
+type Source struct {
+	Name   string
+	Dir    string
+	Import Importer
+}
+
+type Config struct {
+	PollInterval time.Duration
+	// StableFor is how long a file's size and modification time must remain unchanged
+	// before we consider it fully written.
+	StableFor time.Duration
+	// RetryAfter is how long we wait before retrying a delivery that failed to import.
+	// A delivery that is replaced is retried as soon as it is stable.
+	RetryAfter time.Duration
+}
+
+type Daemon struct {
+	cfg     Config
+	sources []Source
+	locker  Locker
+	now     func() time.Time
+
+	// observed and retryAt are keyed by the delivery's path.
+	// They are only accessed by the polling goroutine.
+	observed map[string]observation
+	retryAt  map[string]time.Time
+
+	mu     sync.RWMutex
+	status Status
+}
+
+type observation struct {
+	size    int64
+	modTime time.Time
+	// since is when we first saw the file with this size and modification time
+	since time.Time
+}
+
+func NewDaemon(cfg Config, locker Locker, sources ...Source) *Daemon {
+	status := Status{Sources: make(map[string]*SourceStatus, len(sources))}
+	for _, src := range sources {
+		status.Sources[src.Name] = &SourceStatus{Dir: src.Dir}
+	}
+
+	return &Daemon{
+		cfg:      cfg,
+		sources:  sources,
+		locker:   locker,
+		now:      time.Now,
+		observed: make(map[string]observation),
+		retryAt:  make(map[string]time.Time),
+		status:   status,
+	}
+}
+
+// Run polls the inbound directories until the context is cancelled
+func (d *Daemon) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.Poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll makes a single pass over every source, importing the deliveries that are ready
+func (d *Daemon) Poll(ctx context.Context) {
+	for _, src := range d.sources {
+		if ctx.Err() != nil {
+			return
+		}
+		d.pollSource(ctx, src)
+	}
+
+	now := d.now()
+	d.mu.Lock()
+	d.status.LastPoll = &now
+	d.mu.Unlock()
+}
+
+func (d *Daemon) pollSource(ctx context.Context, src Source) {
+	ready, pending, err := d.readyDeliveries(src)
+	if err != nil {
+		log.Errorf("Failed to read %s inbound directory %s: %s", src.Name, src.Dir, err.Error())
+		d.updateStatus(src, pending, err, nil)
+		return
+	}
+
+	if len(ready) == 0 {
+		d.updateStatus(src, pending, nil, nil)
+		return
+	}
+
+	keys := make([]string, len(ready))
+	for i, name := range ready {
+		keys[i] = fmt.Sprintf("ingest:%s:%s", src.Name, name)
+	}
+
+	locked, release, err := d.locker.TryLock(ctx, keys...)
+	if err != nil {
+		log.Errorf("Failed to lock %s deliveries: %s", src.Name, err.Error())
+		d.updateStatus(src, pending+len(ready), err, nil)
+		return
+	}
+	defer release()
+
+	claimed := make(map[string]struct{}, len(locked))
+	for _, key := range locked {
+		claimed[key] = struct{}{}
+	}
+
+	var names []string
+	for i, name := range ready {
+		if _, ok := claimed[keys[i]]; !ok {
+			log.Infof("Skipping %s delivery %s: it is being imported by another daemon", src.Name, name)
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		d.updateStatus(src, pending, nil, nil)
+		return
+	}
+
+	success, failure, skipped, err := d.importDeliveries(src, names)
+	if err != nil {
+		log.Errorf("Failed to import %s deliveries: %s", src.Name, err.Error())
+	} else {
+		log.Infof("Completed %s import. Files imported: %d Files failed: %d Files skipped: %d",
+			src.Name, success, failure, skipped)
+	}
+
+	now := d.now()
+	d.updateStatus(src, pending, nil, func(s *SourceStatus) {
+		s.LastRun = &now
+		s.Success, s.Failure, s.Skipped = success, failure, skipped
+		s.Error = ""
+		if err != nil {
+			s.Error = err.Error()
+		}
+	})
+}
+
+// readyDeliveries returns the names of the files in the source's directory that have been fully written
+// along with a count of the files we are still waiting on.
+// Hidden files are ignored since they are typically partial uploads or our own staging directories.
+func (d *Daemon) readyDeliveries(src Source) (ready []string, pending int, err error) {
+	infos, err := ioutil.ReadDir(src.Dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := d.now()
+	seen := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			log.Warnf("Ignoring %s in %s inbound directory: not a regular file", info.Name(), src.Name)
+			continue
+		}
+
+		path := filepath.Join(src.Dir, info.Name())
+		seen[path] = struct{}{}
+
+		obs, ok := d.observed[path]
+		if !ok || obs.size != info.Size() || !obs.modTime.Equal(info.ModTime()) {
+			d.observed[path] = observation{size: info.Size(), modTime: info.ModTime(), since: now}
+			delete(d.retryAt, path)
+			pending++
+			continue
+		}
+
+		if now.Sub(obs.since) < d.cfg.StableFor {
+			pending++
+			continue
+		}
+
+		if at, ok := d.retryAt[path]; ok && now.Before(at) {
+			pending++
+			continue
+		}
+
+		ready = append(ready, info.Name())
+	}
+
+	// Forget about any files that have been removed from the directory
+	for path := range d.observed {
+		if filepath.Dir(path) != filepath.Clean(src.Dir) {
+			continue
+		}
+		if _, ok := seen[path]; !ok {
+			delete(d.observed, path)
+			delete(d.retryAt, path)
+		}
+	}
+
+	return ready, pending, nil
+}
+
+// importDeliveries hard links the claimed deliveries into a staging directory and runs the importer against it.
+// Staging keeps the importer from picking up deliveries that are still being written or that are claimed
+// by another daemon. Once the importer has moved a staged file out, the delivery is removed from the inbound directory.
+func (d *Daemon) importDeliveries(src Source, names []string) (success, failure, skipped int, err error) {
+	staging, err := ioutil.TempDir(src.Dir, ".ingest-")
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(staging); err != nil {
+			log.Warnf("Failed to remove staging directory %s: %s", staging, err.Error())
+		}
+	}()
+
+	var staged []string
+	for _, name := range names {
+		if err := os.Link(filepath.Join(src.Dir, name), filepath.Join(staging, name)); err != nil {
+			// Another daemon may have finished importing the delivery before we obtained the lock
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, 0, 0, fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+		staged = append(staged, name)
+	}
+
+	if len(staged) == 0 {
+		return 0, 0, 0, nil
+	}
+
+	success, failure, skipped, err = src.Import(staging)
+
+	for _, name := range staged {
+		path := filepath.Join(src.Dir, name)
+		if _, statErr := os.Stat(filepath.Join(staging, name)); os.IsNotExist(statErr) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Errorf("Failed to remove imported delivery %s: %s", path, err.Error())
+			}
+			delete(d.observed, path)
+			delete(d.retryAt, path)
+			continue
+		}
+
+		log.Warnf("%s delivery %s was not imported. Will retry after %s", src.Name, name, d.cfg.RetryAfter)
+		d.retryAt[path] = d.now().Add(d.cfg.RetryAfter)
+	}
+
+	return success, failure, skipped, err
+}
+
+func (d *Daemon) updateStatus(src Source, pending int, pollErr error, update func(s *SourceStatus)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := d.status.Sources[src.Name]
+	s.Pending = pending
+	s.PollError = ""
+	if pollErr != nil {
+		s.PollError = pollErr.Error()
+	}
+	if update != nil {
+		update(s)
+	}
+}
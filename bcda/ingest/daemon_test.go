@@ -0,0 +1,247 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DaemonTestSuite struct {
+	suite.Suite
+	inbound, imported string
+
+	now time.Time
+	cfg Config
+
+	// importedNames holds the staged file names seen by the importer
+	importedNames []string
+	importErr     error
+	// heldKeys are locked by some other daemon
+	heldKeys map[string]struct{}
+}
+
+func TestDaemonTestSuite(t *testing.T) {
+	suite.Run(t, new(DaemonTestSuite))
+}
+
+func (s *DaemonTestSuite) SetupTest() {
+	var err error
+	s.inbound, err = ioutil.TempDir("", "inbound")
+	s.NoError(err)
+	s.imported, err = ioutil.TempDir("", "imported")
+	s.NoError(err)
+
+	s.now = time.Now()
+	s.cfg = Config{PollInterval: time.Second, StableFor: time.Minute, RetryAfter: time.Hour}
+	s.importedNames = nil
+	s.importErr = nil
+	s.heldKeys = make(map[string]struct{})
+}
+
+func (s *DaemonTestSuite) TearDownTest() {
+	os.RemoveAll(s.inbound)
+	os.RemoveAll(s.imported)
+}
+
+func (s *DaemonTestSuite) TestImportsStableDeliveries() {
+	d := s.newDaemon(s.moveImporter)
+	s.writeFile("delivery1", "data")
+
+	// First sighting starts the stability window
+	d.Poll(context.Background())
+	s.Empty(s.importedNames)
+	s.Equal(1, d.Status().Sources["test"].Pending)
+
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+	s.Equal([]string{"delivery1"}, s.importedNames)
+	s.NoFileExists(filepath.Join(s.inbound, "delivery1"))
+	s.FileExists(filepath.Join(s.imported, "delivery1"))
+
+	status := d.Status()
+	s.NotNil(status.LastPoll)
+	s.NotNil(status.Sources["test"].LastRun)
+	s.Equal(1, status.Sources["test"].Success)
+	s.Equal(0, status.Sources["test"].Pending)
+	s.Empty(status.Sources["test"].Error)
+
+	// Staging directories are cleaned up
+	infos, err := ioutil.ReadDir(s.inbound)
+	s.NoError(err)
+	s.Empty(infos)
+}
+
+func (s *DaemonTestSuite) TestWaitsForFilesStillBeingWritten() {
+	d := s.newDaemon(s.moveImporter)
+	s.writeFile("delivery1", "data")
+	d.Poll(context.Background())
+
+	// The file grows before the window ends which restarts the window
+	s.now = s.now.Add(s.cfg.StableFor / 2)
+	s.writeFile("delivery1", "more data")
+	d.Poll(context.Background())
+
+	s.now = s.now.Add(s.cfg.StableFor / 2)
+	d.Poll(context.Background())
+	s.Empty(s.importedNames)
+	s.FileExists(filepath.Join(s.inbound, "delivery1"))
+
+	s.now = s.now.Add(s.cfg.StableFor / 2)
+	d.Poll(context.Background())
+	s.Equal([]string{"delivery1"}, s.importedNames)
+}
+
+func (s *DaemonTestSuite) TestIgnoresHiddenFilesAndDirectories() {
+	d := s.newDaemon(s.moveImporter)
+	s.writeFile(".partial-upload", "data")
+	s.NoError(os.Mkdir(filepath.Join(s.inbound, "subdir"), 0700))
+
+	d.Poll(context.Background())
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+
+	s.Empty(s.importedNames)
+	s.Nil(d.Status().Sources["test"].LastRun)
+	s.FileExists(filepath.Join(s.inbound, ".partial-upload"))
+}
+
+func (s *DaemonTestSuite) TestSkipsDeliveriesLockedElsewhere() {
+	d := s.newDaemon(s.moveImporter)
+	s.writeFile("delivery1", "data")
+	s.writeFile("delivery2", "data")
+	s.heldKeys["ingest:test:delivery1"] = struct{}{}
+
+	d.Poll(context.Background())
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+
+	s.Equal([]string{"delivery2"}, s.importedNames)
+	s.FileExists(filepath.Join(s.inbound, "delivery1"))
+	s.NoFileExists(filepath.Join(s.inbound, "delivery2"))
+}
+
+func (s *DaemonTestSuite) TestRetriesFailedDeliveries() {
+	s.importErr = errors.New("import failed")
+	d := s.newDaemon(func(dir string) (int, int, int, error) {
+		for _, name := range s.names(dir) {
+			s.importedNames = append(s.importedNames, name)
+		}
+		return 0, len(s.importedNames), 0, s.importErr
+	})
+	s.writeFile("delivery1", "data")
+
+	d.Poll(context.Background())
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+	s.Equal([]string{"delivery1"}, s.importedNames)
+	s.FileExists(filepath.Join(s.inbound, "delivery1"))
+	s.Equal("import failed", d.Status().Sources["test"].Error)
+	s.Equal(http.StatusServiceUnavailable, s.health(d).Code)
+
+	// Not retried until the retry interval has passed
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+	s.Len(s.importedNames, 1)
+	s.Equal(1, d.Status().Sources["test"].Pending)
+
+	s.now = s.now.Add(s.cfg.RetryAfter)
+	d.Poll(context.Background())
+	s.Len(s.importedNames, 2)
+}
+
+func (s *DaemonTestSuite) TestLockError() {
+	d := s.newDaemon(s.moveImporter)
+	d.locker = lockerFunc(func(ctx context.Context, keys ...string) ([]string, func(), error) {
+		return nil, nil, errors.New("connection refused")
+	})
+	s.writeFile("delivery1", "data")
+
+	d.Poll(context.Background())
+	s.now = s.now.Add(s.cfg.StableFor)
+	d.Poll(context.Background())
+
+	s.Empty(s.importedNames)
+	s.Equal("connection refused", d.Status().Sources["test"].PollError)
+	s.Equal(1, d.Status().Sources["test"].Pending)
+}
+
+func (s *DaemonTestSuite) TestHealth() {
+	d := s.newDaemon(s.moveImporter)
+	d.Poll(context.Background())
+
+	rr := s.health(d)
+	s.Equal(http.StatusOK, rr.Code)
+
+	var status Status
+	s.NoError(json.Unmarshal(rr.Body.Bytes(), &status))
+	s.NotNil(status.LastPoll)
+	s.Equal(s.inbound, status.Sources["test"].Dir)
+}
+
+func TestMissingDirectory(t *testing.T) {
+	d := NewDaemon(Config{}, nil, Source{Name: "missing", Dir: "/does/not/exist"})
+	d.Poll(context.Background())
+	assert.Contains(t, d.Status().Sources["missing"].PollError, "no such file or directory")
+}
+
+func (s *DaemonTestSuite) newDaemon(importer Importer) *Daemon {
+	d := NewDaemon(s.cfg, lockerFunc(s.tryLock), Source{Name: "test", Dir: s.inbound, Import: importer})
+	d.now = func() time.Time { return s.now }
+	return d
+}
+
+// moveImporter imports every file by moving it out of the staging directory
+func (s *DaemonTestSuite) moveImporter(dir string) (success, failure, skipped int, err error) {
+	for _, name := range s.names(dir) {
+		s.NoError(os.Rename(filepath.Join(dir, name), filepath.Join(s.imported, name)))
+		s.importedNames = append(s.importedNames, name)
+		success++
+	}
+	return success, 0, 0, nil
+}
+
+func (s *DaemonTestSuite) tryLock(ctx context.Context, keys ...string) ([]string, func(), error) {
+	var locked []string
+	for _, key := range keys {
+		if _, ok := s.heldKeys[key]; !ok {
+			locked = append(locked, key)
+		}
+	}
+	return locked, func() {}, nil
+}
+
+func (s *DaemonTestSuite) writeFile(name, data string) {
+	s.NoError(ioutil.WriteFile(filepath.Join(s.inbound, name), []byte(data), 0600))
+}
+
+func (s *DaemonTestSuite) names(dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	s.NoError(err)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names
+}
+
+func (s *DaemonTestSuite) health(d *Daemon) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	d.ServeHTTP(rr, httptest.NewRequest("GET", "/_health", nil))
+	return rr
+}
+
+type lockerFunc func(ctx context.Context, keys ...string) ([]string, func(), error)
+
+func (f lockerFunc) TryLock(ctx context.Context, keys ...string) ([]string, func(), error) {
+	return f(ctx, keys...)
+}
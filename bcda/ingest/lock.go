@@ -0,0 +1,58 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Locker claims deliveries so that daemons running on other hosts do not import them at the same time.
+type Locker interface {
+	// TryLock attempts to obtain every key without blocking. It returns the keys that were obtained
+	// and a function that releases them. Keys held by another daemon are omitted from the result.
+	TryLock(ctx context.Context, keys ...string) (locked []string, release func(), err error)
+}
+
+// advisoryLocker uses Postgres session level advisory locks. The locks are held on a dedicated
+// connection so they are released by the database if the daemon dies mid import.
+// The two key form is used so our locks never collide with the single key locks taken by que.
+type advisoryLocker struct {
+	db *sql.DB
+}
+
+// NewAdvisoryLocker returns a locker backed by Postgres advisory locks
+func NewAdvisoryLocker(db *sql.DB) Locker {
+	return &advisoryLocker{db}
+}
+
+func (l *advisoryLocker) TryLock(ctx context.Context, keys ...string) ([]string, func(), error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to obtain connection for ingest locks: %w", err)
+	}
+
+	release := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock_all()"); err != nil {
+			log.Warnf("Failed to release ingest locks %s", err.Error())
+		}
+		if err := conn.Close(); err != nil {
+			log.Warnf("Failed to close ingest lock connection %s", err.Error())
+		}
+	}
+
+	var locked []string
+	for _, key := range keys {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext('bcda-ingest'), hashtext($1))", key).Scan(&ok); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to lock %s: %w", key, err)
+		}
+		if ok {
+			locked = append(locked, key)
+		}
+	}
+
+	return locked, release, nil
+}
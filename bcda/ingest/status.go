@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Status struct {
+	LastPoll *time.Time               `json:"last_poll,omitempty"`
+	Sources  map[string]*SourceStatus `json:"sources"`
+}
+
+type SourceStatus struct {
+	Dir string `json:"dir"`
+	// Pending is the number of deliveries waiting to be fully written or retried
+	Pending int `json:"pending"`
+	// PollError is set when the most recent poll could not read or lock the source's deliveries
+	PollError string `json:"poll_error,omitempty"`
+
+	// Results of the most recent import run
+	LastRun *time.Time `json:"last_run,omitempty"`
+	Success int        `json:"success"`
+	Failure int        `json:"failure"`
+	Skipped int        `json:"skipped"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// Status returns a copy of the daemon's last run status
+func (d *Daemon) Status() Status {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	status := Status{LastPoll: d.status.LastPoll, Sources: make(map[string]*SourceStatus, len(d.status.Sources))}
+	for name, s := range d.status.Sources {
+		copied := *s
+		status.Sources[name] = &copied
+	}
+	return status
+}
+
+// ServeHTTP reports the daemon's last run status.
+// The daemon is unhealthy if any source failed its most recent poll or import.
+func (d *Daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := d.Status()
+
+	code := http.StatusOK
+	for _, s := range status.Sources {
+		if s.PollError != "" || s.Error != "" {
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Errorf("Failed to write ingest status %s", err.Error())
+	}
+}
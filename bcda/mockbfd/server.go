@@ -0,0 +1,387 @@
+/*
+Package mockbfd provides a stand-in for the Blue Button (BFD) FHIR API. It serves the synthetic beneficiary
+data found in shared_files/synthetic_beneficiary_data so the API to worker flow can run without access to BFD.
+
+Every beneficiary shares the same synthetic resources. The BFD patient ID and MBI found in the templates are
+replaced with values derived from the requested beneficiary.
+*/
+package mockbfd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Values found in the synthetic data that are replaced with the requested beneficiary's values
+	templatePatientID = "20000000000001"
+	templateMBI       = "-1Q03Z002871"
+
+	mbiHashSystem = "https://bluebutton.cms.gov/resources/identifier/mbi-hash"
+	dateLayout    = "2006-01-02"
+)
+
+// Config controls the data that is served and the failures that are injected into the responses
+type Config struct {
+	// Directory containing the Patient, Coverage, ExplanationOfBenefit, and Metadata files
+	DataDir string
+	// Added to every request to simulate a slow BFD
+	Latency time.Duration
+	// Fraction (0-1) of requests that fail with ErrorStatus
+	ErrorRate   float64
+	ErrorStatus int
+	// Seconds returned in the Retry-After header of injected errors. Zero omits the header.
+	RetryAfter int
+}
+
+// MBISource returns the MBIs of the beneficiaries that can be found using an mbi-hash lookup
+type MBISource func(ctx context.Context) ([]string, error)
+
+type Server struct {
+	cfg       Config
+	templates map[string]string
+	mbis      MBISource
+
+	mu     sync.RWMutex
+	byHash map[string]string // MBI hash -> MBI
+	byID   map[string]string // Patient ID -> MBI
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+var resourceTypes = []string{"Patient", "Coverage", "ExplanationOfBenefit", "Metadata"}
+
+func NewServer(cfg Config, mbis MBISource) (*Server, error) {
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 1 {
+		return nil, fmt.Errorf("invalid error rate %f, must be between 0 and 1", cfg.ErrorRate)
+	}
+	if cfg.ErrorStatus == 0 {
+		cfg.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	templates := make(map[string]string, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		data, err := ioutil.ReadFile(filepath.Join(cfg.DataDir, resourceType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read synthetic %s data: %w", resourceType, err)
+		}
+		templates[resourceType] = string(data)
+	}
+
+	return &Server{cfg: cfg, templates: templates, mbis: mbis,
+		byHash: make(map[string]string), byID: make(map[string]string),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+// PatientID returns the BFD patient ID served for the beneficiary
+func PatientID(mbi string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(mbi))
+	// Mimic the negative IDs used by BFD for synthetic beneficiaries
+	return fmt.Sprintf("-%014d", h.Sum64()%100000000000000)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.cfg.Latency > 0 {
+		select {
+		case <-time.After(s.cfg.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if s.injectError() {
+		if s.cfg.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(s.cfg.RetryAfter))
+		}
+		http.Error(w, "injected error", s.cfg.ErrorStatus)
+		return
+	}
+
+	params := r.URL.Query()
+	var (
+		bundle map[string]interface{}
+		err    error
+	)
+	// Resource type is the last element of the path (ex: /v1/fhir/Patient/) allowing any base path to be used
+	switch resourceType := path.Base(r.URL.Path); resourceType {
+	case "metadata":
+		w.Header().Set("Content-Type", "application/fhir+json")
+		fmt.Fprint(w, s.templates["Metadata"])
+		return
+	case "Patient":
+		bundle, err = s.patients(r.Context(), params)
+	case "Coverage":
+		bundle, err = s.bundle(resourceType, params.Get("beneficiary"))
+	case "ExplanationOfBenefit":
+		bundle, err = s.bundle(resourceType, params.Get("patient"))
+		if err == nil {
+			err = filterServiceDate(bundle, params["service-date"])
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported resource type %s", resourceType), http.StatusNotFound)
+		return
+	}
+
+	if err == nil {
+		err = filterLastUpdated(bundle, params["_lastUpdated"])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page(bundle, r)
+
+	w.Header().Set("Content-Type", "application/fhir+json")
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		log.Errorf("Failed to write %s response %s", r.URL.Path, err.Error())
+	}
+}
+
+func (s *Server) injectError() bool {
+	if s.cfg.ErrorRate == 0 {
+		return false
+	}
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+	return s.rand.Float64() < s.cfg.ErrorRate
+}
+
+// patients supports searching by ID or by the beneficiary's hashed MBI
+func (s *Server) patients(ctx context.Context, params url.Values) (map[string]interface{}, error) {
+	if id := params.Get("_id"); id != "" {
+		s.mu.RLock()
+		mbi := s.byID[id]
+		s.mu.RUnlock()
+		return s.render("Patient", id, mbi)
+	}
+
+	identifier := params.Get("identifier")
+	if !strings.HasPrefix(identifier, mbiHashSystem+"|") {
+		return nil, fmt.Errorf("unsupported Patient search %s", params.Encode())
+	}
+
+	mbi, err := s.lookup(ctx, strings.TrimPrefix(identifier, mbiHashSystem+"|"))
+	if err != nil {
+		return nil, err
+	}
+	if mbi == "" {
+		return map[string]interface{}{"resourceType": "Bundle", "type": "searchset", "total": 0}, nil
+	}
+	return s.render("Patient", PatientID(mbi), mbi)
+}
+
+// lookup returns the MBI matching the hash. If the hash is not found, the MBIs are reloaded
+// since beneficiaries may have been imported after the server was started.
+func (s *Server) lookup(ctx context.Context, hash string) (string, error) {
+	s.mu.RLock()
+	mbi, ok := s.byHash[hash]
+	s.mu.RUnlock()
+	if ok || s.mbis == nil {
+		return mbi, nil
+	}
+
+	mbis, err := s.mbis(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load MBIs: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, mbi := range mbis {
+		if _, ok := s.byID[PatientID(mbi)]; ok {
+			continue
+		}
+		s.byHash[client.HashIdentifier(mbi)] = mbi
+		s.byID[PatientID(mbi)] = mbi
+	}
+	return s.byHash[hash], nil
+}
+
+func (s *Server) bundle(resourceType, patientID string) (map[string]interface{}, error) {
+	if patientID == "" {
+		return nil, fmt.Errorf("patient is required to search for %s", resourceType)
+	}
+	// Coverage and ExplanationOfBenefit searches may reference the patient (ex: Patient/-19990000000001)
+	patientID = strings.TrimPrefix(patientID, "Patient/")
+
+	s.mu.RLock()
+	mbi := s.byID[patientID]
+	s.mu.RUnlock()
+	return s.render(resourceType, patientID, mbi)
+}
+
+func (s *Server) render(resourceType, patientID, mbi string) (map[string]interface{}, error) {
+	data := strings.Replace(s.templates[resourceType], templatePatientID, patientID, -1)
+	if mbi != "" {
+		data = strings.Replace(data, templateMBI, mbi, -1)
+	}
+
+	var bundle map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &bundle); err != nil {
+		return nil, err
+	}
+	// Links are regenerated when paging the results
+	delete(bundle, "link")
+	return bundle, nil
+}
+
+// filterLastUpdated removes the entries that do not satisfy the _lastUpdated parameters.
+// Resources without a lastUpdated value use the value associated with the bundle.
+func filterLastUpdated(bundle map[string]interface{}, values []string) error {
+	var bundleUpdated string
+	if meta, ok := bundle["meta"].(map[string]interface{}); ok {
+		bundleUpdated, _ = meta["lastUpdated"].(string)
+	}
+
+	return filterEntries(bundle, values, time.RFC3339Nano, func(resource map[string]interface{}) []time.Time {
+		updated := bundleUpdated
+		if meta, ok := resource["meta"].(map[string]interface{}); ok {
+			if v, ok := meta["lastUpdated"].(string); ok {
+				updated = v
+			}
+		}
+		t, err := time.Parse(time.RFC3339Nano, updated)
+		if err != nil {
+			return nil
+		}
+		return []time.Time{t, t}
+	})
+}
+
+// filterServiceDate removes the claims whose billable period does not overlap the service-date parameters
+func filterServiceDate(bundle map[string]interface{}, values []string) error {
+	return filterEntries(bundle, values, dateLayout, func(resource map[string]interface{}) []time.Time {
+		period, ok := resource["billablePeriod"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		start, err := time.Parse(dateLayout, fmt.Sprint(period["start"]))
+		if err != nil {
+			return nil
+		}
+		end, err := time.Parse(dateLayout, fmt.Sprint(period["end"]))
+		if err != nil {
+			end = start
+		}
+		return []time.Time{start, end}
+	})
+}
+
+// filterEntries keeps the entries whose [start, end] range satisfy all of the date parameters (ex: ge2020-01-01).
+// Entries without a date are always kept.
+func filterEntries(bundle map[string]interface{}, values []string, layout string,
+	dates func(resource map[string]interface{}) []time.Time) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	type bound struct {
+		prefix string
+		t      time.Time
+	}
+	var bounds []bound
+	for _, value := range values {
+		if len(value) < 3 {
+			return fmt.Errorf("invalid date parameter %s", value)
+		}
+		prefix, date := value[:2], value[2:]
+		t, err := time.Parse(layout, date)
+		if err != nil {
+			// Support date only values for date time parameters
+			if t, err = time.Parse(dateLayout, date); err != nil {
+				return fmt.Errorf("invalid date parameter %s: %w", value, err)
+			}
+		}
+		switch prefix {
+		case "gt", "ge", "lt", "le":
+			bounds = append(bounds, bound{prefix, t})
+		default:
+			return fmt.Errorf("unsupported date prefix %s", prefix)
+		}
+	}
+
+	entries, _ := bundle["entry"].([]interface{})
+	filtered := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		resource, _ := entry.(map[string]interface{})["resource"].(map[string]interface{})
+		d := dates(resource)
+		keep := true
+		for _, b := range bounds {
+			if d == nil {
+				break
+			}
+			start, end := d[0], d[1]
+			switch b.prefix {
+			case "gt":
+				keep = keep && end.After(b.t)
+			case "ge":
+				keep = keep && !end.Before(b.t)
+			case "lt":
+				keep = keep && start.Before(b.t)
+			case "le":
+				keep = keep && !start.After(b.t)
+			}
+		}
+		if keep {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	bundle["entry"] = filtered
+	return nil
+}
+
+// page limits the entries to the page requested via _count and startIndex. A next link is added when
+// there are more entries.
+func page(bundle map[string]interface{}, r *http.Request) {
+	entries, _ := bundle["entry"].([]interface{})
+	bundle["total"] = len(entries)
+
+	params := r.URL.Query()
+	count, _ := strconv.Atoi(params.Get("_count"))
+	if count <= 0 {
+		return
+	}
+	start, _ := strconv.Atoi(params.Get("startIndex"))
+	if start < 0 || start > len(entries) {
+		start = len(entries)
+	}
+	end := start + count
+	if end > len(entries) {
+		end = len(entries)
+	}
+	bundle["entry"] = entries[start:end]
+
+	if end < len(entries) {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		params.Set("startIndex", strconv.Itoa(end))
+		next := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: params.Encode()}
+		bundle["link"] = []map[string]string{{"relation": "next", "url": next.String()}}
+	}
+}
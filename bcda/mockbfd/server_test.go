@@ -0,0 +1,181 @@
+package mockbfd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/client"
+	"github.com/CMSgov/bcda-app/bcda/client/fhir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const dataDir = "../../shared_files/synthetic_beneficiary_data"
+
+type ServerTestSuite struct {
+	suite.Suite
+	mbis []string
+	ts   *httptest.Server
+	c    *client.FHIRServerClient
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.mbis = []string{"1A00A00AA00"}
+	server, err := NewServer(Config{DataDir: dataDir}, func(ctx context.Context) ([]string, error) {
+		return s.mbis, nil
+	})
+	s.NoError(err)
+	s.ts = httptest.NewServer(server)
+
+	s.c, err = client.NewFHIRServerClient(client.FHIRSource{Name: "mockbfd", URL: s.ts.URL + "/v1/fhir", PageSize: 10})
+	s.NoError(err)
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.ts.Close()
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) TestPatientByIdentifierHash() {
+	mbi := s.mbis[0]
+	resp, err := s.c.GetPatientByIdentifierHash(client.HashIdentifier(mbi))
+	s.NoError(err)
+
+	var bundle struct {
+		Entry []struct {
+			Resource struct {
+				ID         string `json:"id"`
+				Identifier []struct {
+					System string `json:"system"`
+					Value  string `json:"value"`
+				} `json:"identifier"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	s.NoError(json.Unmarshal([]byte(resp), &bundle))
+	s.Len(bundle.Entry, 1)
+	patient := bundle.Entry[0].Resource
+	s.Equal(PatientID(mbi), patient.ID)
+	s.Contains(patient.Identifier, struct {
+		System string `json:"system"`
+		Value  string `json:"value"`
+	}{"http://hl7.org/fhir/sid/us-mbi", mbi})
+
+	// Beneficiaries imported after the first lookup are found
+	s.mbis = append(s.mbis, "2B00B00BB00")
+	resp, err = s.c.GetPatientByIdentifierHash(client.HashIdentifier("2B00B00BB00"))
+	s.NoError(err)
+	s.Contains(resp, PatientID("2B00B00BB00"))
+
+	resp, err = s.c.GetPatientByIdentifierHash("unknown")
+	s.NoError(err)
+	s.Contains(resp, `"total":0`)
+}
+
+func (s *ServerTestSuite) TestResources() {
+	patientID := PatientID(s.mbis[0])
+	now := time.Now()
+
+	patient, err := s.c.GetPatient(patientID, "1", "A0000", "", now)
+	s.NoError(err)
+	s.Len(patient.Entries, 1)
+	s.Equal(patientID, patient.Entries[0]["resource"].(map[string]interface{})["id"])
+
+	coverage, err := s.c.GetCoverage(patientID, "1", "A0000", "", now)
+	s.NoError(err)
+	s.Len(coverage.Entries, 3)
+
+	// Entries are retrieved across multiple pages
+	eob, err := s.c.GetExplanationOfBenefit(patientID, "1", "A0000", "", now, client.ClaimsWindow{}, false)
+	s.NoError(err)
+	s.Len(eob.Entries, 33)
+	for _, entry := range eob.Entries {
+		s.Equal("Patient/"+patientID, entry["resource"].(map[string]interface{})["patient"].(map[string]interface{})["reference"])
+	}
+
+	// Synthetic data was last updated in 2018
+	eob, err = s.c.GetExplanationOfBenefit(patientID, "1", "A0000", "gt2019-01-01T00:00:00Z", now, client.ClaimsWindow{}, false)
+	s.NoError(err)
+	s.Empty(eob.Entries)
+
+	eob, err = s.c.GetExplanationOfBenefit(patientID, "1", "A0000", "", now,
+		client.ClaimsWindow{LowerBound: time.Date(2000, 6, 1, 0, 0, 0, 0, time.UTC), UpperBound: time.Date(2000, 6, 30, 0, 0, 0, 0, time.UTC)}, false)
+	s.NoError(err)
+	s.NotEmpty(eob.Entries)
+	s.Less(len(eob.Entries), 33)
+}
+
+func (s *ServerTestSuite) TestMetadata() {
+	resp, err := http.Get(s.ts.URL + "/v2/fhir/metadata")
+	s.NoError(err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	s.NoError(err)
+	s.Contains(string(body), `"resourceType": "CapabilityStatement"`)
+}
+
+func (s *ServerTestSuite) TestInvalidRequests() {
+	tests := []struct {
+		path      string
+		expStatus int
+	}{
+		{"/v1/fhir/Claim/", http.StatusNotFound},
+		{"/v1/fhir/Coverage/", http.StatusBadRequest},
+		{"/v1/fhir/Patient/?identifier=ssn|123", http.StatusBadRequest},
+		{"/v1/fhir/ExplanationOfBenefit/?patient=1&service-date=eq2020-01-01", http.StatusBadRequest},
+		{"/v1/fhir/ExplanationOfBenefit/?patient=1&_lastUpdated=le2020", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.path, func(t *testing.T) {
+			resp, err := http.Get(s.ts.URL + tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expStatus, resp.StatusCode)
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	server, err := NewServer(Config{DataDir: dataDir, ErrorRate: 1, ErrorStatus: http.StatusTooManyRequests, RetryAfter: 5,
+		Latency: 10 * time.Millisecond}, nil)
+	assert.NoError(t, err)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/v1/fhir/metadata", nil)
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = fhir.NewClient(http.DefaultClient, 0).DoRaw(req)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(10*time.Millisecond))
+
+	var respErr *fhir.ResponseError
+	if assert.True(t, errors.As(err, &respErr)) {
+		assert.Equal(t, http.StatusTooManyRequests, respErr.StatusCode)
+		assert.Equal(t, 5*time.Second, respErr.RetryAfter)
+	}
+}
+
+func TestNewServerInvalidConfig(t *testing.T) {
+	_, err := NewServer(Config{DataDir: dataDir, ErrorRate: 2}, nil)
+	assert.EqualError(t, err, "invalid error rate 2.000000, must be between 0 and 1")
+
+	_, err = NewServer(Config{DataDir: "/does/not/exist"}, nil)
+	assert.Contains(t, err.Error(), "failed to read synthetic Patient data")
+}
+
+func TestPatientID(t *testing.T) {
+	assert.Equal(t, PatientID("1A00A00AA00"), PatientID("1A00A00AA00"))
+	assert.NotEqual(t, PatientID("1A00A00AA00"), PatientID("2B00B00BB00"))
+	assert.Regexp(t, fmt.Sprintf("^-\\d{%d}$", 14), PatientID("1A00A00AA00"))
+}
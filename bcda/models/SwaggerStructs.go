@@ -66,6 +66,36 @@ type ErrorResponse struct {
 	Body OperationOutcomeResponse
 }
 
+// The ACO's access does not permit this request. The body will contain a FHIR OperationOutcome resource in JSON format. https://www.hl7.org/fhir/operationoutcome.html
+// swagger:response forbiddenResponse
+type ForbiddenResponse struct {
+	// in: body
+	Body OperationOutcomeResponse
+}
+
+// JSON object listing the MBIs added to and removed from the ACO's attribution
+// swagger:response attributionChangesResponse
+type AttributionChangesResponse struct {
+	// in: body
+	Body struct {
+		// CMS ID of the ACO
+		CMSID string `json:"cmsId"`
+		// CCLF8 file the current attribution is compared against. Null when no earlier file exists.
+		PreviousFile *AttributionFile `json:"previousFile"`
+		// CCLF8 file containing the current attribution
+		CurrentFile  AttributionFile `json:"currentFile"`
+		AddedCount   int             `json:"addedCount"`
+		RemovedCount int             `json:"removedCount"`
+		Added        []string        `json:"added"`
+		Removed      []string        `json:"removed"`
+	}
+}
+
+type AttributionFile struct {
+	Name      string `json:"name"`
+	Timestamp string `json:"timestamp"`
+}
+
 // A bulk export job of this resource type is already in progress for the ACO.
 // swagger:response tooManyRequestsResponse
 type TooManyRequestsResponse struct {
@@ -110,6 +140,13 @@ type MetadataResponse struct {
 	Body fhirmodels.CapabilityStatement `json:"body,omitempty"`
 }
 
+// FHIR Group in JSON format
+// swagger:response GroupResponse
+type GroupResponse struct {
+	// in: body
+	Body fhirmodels.Group `json:"body,omitempty"`
+}
+
 // File of newline-delimited JSON FHIR objects
 // swagger:response FileNDJSON
 type FileNDJSON struct {
@@ -199,6 +236,14 @@ type GroupIDParam struct {
 	GroupID string `json:"groupId"`
 }
 
+// swagger:parameters bulkGroupRequest bulkGroupRequestV2
+type RunoutYearParam struct {
+	// Performance year of the runout data to export (e.g. `2020`). Only supported by the `runout` group, for performance years with a runout period. Defaults to the most recent runout data.
+	// in: query
+	// required: false
+	RunoutYear int `json:"runoutYear"`
+}
+
 // JSON with a valid JWT
 // swagger:response tokenResponse
 type TokenResponse struct {
@@ -210,6 +255,34 @@ type TokenResponse struct {
 	}
 }
 
+// Token introspection result
+// swagger:response introspectionResponse
+type IntrospectionResponse struct {
+	// in: body
+	Body struct {
+		// Required: true
+		Active    bool     `json:"active"`
+		ClientID  string   `json:"client_id"`
+		TokenType string   `json:"token_type"`
+		ExpiresAt int64    `json:"exp"`
+		IssuedAt  int64    `json:"iat"`
+		Issuer    string   `json:"iss"`
+		TokenID   string   `json:"jti"`
+		SystemID  string   `json:"system_id"`
+		CMSID     string   `json:"cms_id"`
+		CMSIDs    []string `json:"cms_ids"`
+	}
+}
+
+// A token introspection request
+// swagger:parameters IntrospectToken
+type IntrospectTokenParam struct {
+	// The access token to introspect
+	// in: formData
+	// required: true
+	Token string `json:"token"`
+}
+
 // Missing credentials
 // swagger:response missingCredentials
 type MissingCredentials struct{}
@@ -218,6 +291,13 @@ type MissingCredentials struct{}
 // swagger:response invalidCredentials
 type InvalidCredentials struct{}
 
+// Too many failed attempts. The client ID or source IP address is temporarily locked out.
+// swagger:response lockedOut
+type LockedOut struct {
+	// Number of seconds until the lockout ends
+	RetryAfter int `json:"Retry-After"`
+}
+
 // Server error
 // swagger:response serverError
 type ServerError struct{}
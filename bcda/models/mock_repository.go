@@ -72,6 +72,20 @@ func (_m *MockRepository) CreateJob(ctx context.Context, j Job) (uint, error) {
 	return r0, r1
 }
 
+// CreatePlannedQueueJobs provides a mock function with given fields: ctx, jobID, jobs, priority
+func (_m *MockRepository) CreatePlannedQueueJobs(ctx context.Context, jobID uint, jobs []*JobEnqueueArgs, priority int) error {
+	ret := _m.Called(ctx, jobID, jobs, priority)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, []*JobEnqueueArgs, int) error); ok {
+		r0 = rf(ctx, jobID, jobs, priority)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateSuppression provides a mock function with given fields: ctx, suppression
 func (_m *MockRepository) CreateSuppression(ctx context.Context, suppression Suppression) error {
 	ret := _m.Called(ctx, suppression)
@@ -107,6 +121,48 @@ func (_m *MockRepository) CreateSuppressionFile(ctx context.Context, suppression
 	return r0, r1
 }
 
+// DeleteAuthLockout provides a mock function with given fields: ctx, lockoutType, subject
+func (_m *MockRepository) DeleteAuthLockout(ctx context.Context, lockoutType LockoutType, subject string) error {
+	ret := _m.Called(ctx, lockoutType, subject)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, LockoutType, string) error); ok {
+		r0 = rf(ctx, lockoutType, subject)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteDeadLetterQueueJob provides a mock function with given fields: ctx, id
+func (_m *MockRepository) DeleteDeadLetterQueueJob(ctx context.Context, id uint) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeletePlannedQueueJobs provides a mock function with given fields: ctx, jobID
+func (_m *MockRepository) DeletePlannedQueueJobs(ctx context.Context, jobID uint) error {
+	ret := _m.Called(ctx, jobID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) error); ok {
+		r0 = rf(ctx, jobID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetACOByCMSID provides a mock function with given fields: ctx, cmsID
 func (_m *MockRepository) GetACOByCMSID(ctx context.Context, cmsID string) (*ACO, error) {
 	ret := _m.Called(ctx, cmsID)
@@ -176,13 +232,59 @@ func (_m *MockRepository) GetACOByUUID(ctx context.Context, _a1 uuid.UUID) (*ACO
 	return r0, r1
 }
 
-// GetCCLFBeneficiaries provides a mock function with given fields: ctx, cclfFileID, ignoredMBIs
-func (_m *MockRepository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, ignoredMBIs []string) ([]*CCLFBeneficiary, error) {
-	ret := _m.Called(ctx, cclfFileID, ignoredMBIs)
+// GetAuthLockout provides a mock function with given fields: ctx, lockoutType, subject
+func (_m *MockRepository) GetAuthLockout(ctx context.Context, lockoutType LockoutType, subject string) (*AuthLockout, error) {
+	ret := _m.Called(ctx, lockoutType, subject)
+
+	var r0 *AuthLockout
+	if rf, ok := ret.Get(0).(func(context.Context, LockoutType, string) *AuthLockout); ok {
+		r0 = rf(ctx, lockoutType, subject)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*AuthLockout)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, LockoutType, string) error); ok {
+		r1 = rf(ctx, lockoutType, subject)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBeneficiarySuppressions provides a mock function with given fields: ctx, cclfFileID, suppression
+func (_m *MockRepository) GetBeneficiarySuppressions(ctx context.Context, cclfFileID uint, suppression SuppressionCriteria) ([]*BeneficiarySuppression, error) {
+	ret := _m.Called(ctx, cclfFileID, suppression)
+
+	var r0 []*BeneficiarySuppression
+	if rf, ok := ret.Get(0).(func(context.Context, uint, SuppressionCriteria) []*BeneficiarySuppression); ok {
+		r0 = rf(ctx, cclfFileID, suppression)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*BeneficiarySuppression)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint, SuppressionCriteria) error); ok {
+		r1 = rf(ctx, cclfFileID, suppression)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCCLFBeneficiaries provides a mock function with given fields: ctx, cclfFileID, suppression
+func (_m *MockRepository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, suppression *SuppressionCriteria) ([]*CCLFBeneficiary, error) {
+	ret := _m.Called(ctx, cclfFileID, suppression)
 
 	var r0 []*CCLFBeneficiary
-	if rf, ok := ret.Get(0).(func(context.Context, uint, []string) []*CCLFBeneficiary); ok {
-		r0 = rf(ctx, cclfFileID, ignoredMBIs)
+	if rf, ok := ret.Get(0).(func(context.Context, uint, *SuppressionCriteria) []*CCLFBeneficiary); ok {
+		r0 = rf(ctx, cclfFileID, suppression)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*CCLFBeneficiary)
@@ -190,8 +292,8 @@ func (_m *MockRepository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID u
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, uint, []string) error); ok {
-		r1 = rf(ctx, cclfFileID, ignoredMBIs)
+	if rf, ok := ret.Get(1).(func(context.Context, uint, *SuppressionCriteria) error); ok {
+		r1 = rf(ctx, cclfFileID, suppression)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -199,6 +301,36 @@ func (_m *MockRepository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID u
 	return r0, r1
 }
 
+// GetCCLFBeneficiariesAfter provides a mock function with given fields: ctx, cclfFileID, suppression, afterID, limit
+func (_m *MockRepository) GetCCLFBeneficiariesAfter(ctx context.Context, cclfFileID uint, suppression *SuppressionCriteria, afterID uint, limit int) ([]*CCLFBeneficiary, uint, error) {
+	ret := _m.Called(ctx, cclfFileID, suppression, afterID, limit)
+
+	var r0 []*CCLFBeneficiary
+	if rf, ok := ret.Get(0).(func(context.Context, uint, *SuppressionCriteria, uint, int) []*CCLFBeneficiary); ok {
+		r0 = rf(ctx, cclfFileID, suppression, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*CCLFBeneficiary)
+		}
+	}
+
+	var r1 uint
+	if rf, ok := ret.Get(1).(func(context.Context, uint, *SuppressionCriteria, uint, int) uint); ok {
+		r1 = rf(ctx, cclfFileID, suppression, afterID, limit)
+	} else {
+		r1 = ret.Get(1).(uint)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, uint, *SuppressionCriteria, uint, int) error); ok {
+		r2 = rf(ctx, cclfFileID, suppression, afterID, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetCCLFBeneficiaryMBIs provides a mock function with given fields: ctx, cclfFileID
 func (_m *MockRepository) GetCCLFBeneficiaryMBIs(ctx context.Context, cclfFileID uint) ([]string, error) {
 	ret := _m.Called(ctx, cclfFileID)
@@ -222,6 +354,121 @@ func (_m *MockRepository) GetCCLFBeneficiaryMBIs(ctx context.Context, cclfFileID
 	return r0, r1
 }
 
+// GetCCLFBeneficiaryMBIsIn provides a mock function with given fields: ctx, cclfFileID, mbis
+func (_m *MockRepository) GetCCLFBeneficiaryMBIsIn(ctx context.Context, cclfFileID uint, mbis []string) ([]string, error) {
+	ret := _m.Called(ctx, cclfFileID, mbis)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, uint, []string) []string); ok {
+		r0 = rf(ctx, cclfFileID, mbis)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint, []string) error); ok {
+		r1 = rf(ctx, cclfFileID, mbis)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCredentialByClientID provides a mock function with given fields: ctx, clientID
+func (_m *MockRepository) GetCredentialByClientID(ctx context.Context, clientID string) (*Credential, error) {
+	ret := _m.Called(ctx, clientID)
+
+	var r0 *Credential
+	if rf, ok := ret.Get(0).(func(context.Context, string) *Credential); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Credential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCredentials provides a mock function with given fields: ctx, cmsID
+func (_m *MockRepository) GetCredentials(ctx context.Context, cmsID string) ([]*Credential, error) {
+	ret := _m.Called(ctx, cmsID)
+
+	var r0 []*Credential
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*Credential); ok {
+		r0 = rf(ctx, cmsID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*Credential)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, cmsID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeadLetterQueueJob provides a mock function with given fields: ctx, id
+func (_m *MockRepository) GetDeadLetterQueueJob(ctx context.Context, id uint) (*DeadLetterQueueJob, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *DeadLetterQueueJob
+	if rf, ok := ret.Get(0).(func(context.Context, uint) *DeadLetterQueueJob); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*DeadLetterQueueJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeadLetterQueueJobs provides a mock function with given fields: ctx, jobID
+func (_m *MockRepository) GetDeadLetterQueueJobs(ctx context.Context, jobID uint) ([]*DeadLetterQueueJob, error) {
+	ret := _m.Called(ctx, jobID)
+
+	var r0 []*DeadLetterQueueJob
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []*DeadLetterQueueJob); ok {
+		r0 = rf(ctx, jobID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*DeadLetterQueueJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, jobID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetJobByID provides a mock function with given fields: ctx, jobID
 func (_m *MockRepository) GetJobByID(ctx context.Context, jobID uint) (*Job, error) {
 	ret := _m.Called(ctx, jobID)
@@ -351,22 +598,66 @@ func (_m *MockRepository) GetLatestCCLFFile(ctx context.Context, cmsID string, c
 	return r0, r1
 }
 
-// GetSuppressedMBIs provides a mock function with given fields: ctx, lookbackDays, upperBound
-func (_m *MockRepository) GetSuppressedMBIs(ctx context.Context, lookbackDays int, upperBound time.Time) ([]string, error) {
-	ret := _m.Called(ctx, lookbackDays, upperBound)
+// GetLatestCCLFFileForPerformanceYear provides a mock function with given fields: ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, performanceYear
+func (_m *MockRepository) GetLatestCCLFFileForPerformanceYear(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound time.Time, upperBound time.Time, fileType CCLFFileType, performanceYear int) (*CCLFFile, error) {
+	ret := _m.Called(ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, performanceYear)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) []string); ok {
-		r0 = rf(ctx, lookbackDays, upperBound)
+	var r0 *CCLFFile
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, string, time.Time, time.Time, CCLFFileType, int) *CCLFFile); ok {
+		r0 = rf(ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, performanceYear)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).(*CCLFFile)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, string, time.Time, time.Time, CCLFFileType, int) error); ok {
+		r1 = rf(ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, performanceYear)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMBIXrefs provides a mock function with given fields: ctx, cmsID
+func (_m *MockRepository) GetMBIXrefs(ctx context.Context, cmsID string) ([]*CCLFBeneficiaryXref, error) {
+	ret := _m.Called(ctx, cmsID)
+
+	var r0 []*CCLFBeneficiaryXref
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*CCLFBeneficiaryXref); ok {
+		r0 = rf(ctx, cmsID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*CCLFBeneficiaryXref)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) error); ok {
-		r1 = rf(ctx, lookbackDays, upperBound)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, cmsID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IncrementAuthLockout provides a mock function with given fields: ctx, lockoutType, subject, resetBefore
+func (_m *MockRepository) IncrementAuthLockout(ctx context.Context, lockoutType LockoutType, subject string, resetBefore time.Time) (int, error) {
+	ret := _m.Called(ctx, lockoutType, subject, resetBefore)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, LockoutType, string, time.Time) int); ok {
+		r0 = rf(ctx, lockoutType, subject, resetBefore)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, LockoutType, string, time.Time) error); ok {
+		r1 = rf(ctx, lockoutType, subject, resetBefore)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -374,6 +665,90 @@ func (_m *MockRepository) GetSuppressedMBIs(ctx context.Context, lookbackDays in
 	return r0, r1
 }
 
+// LockAuthLockout provides a mock function with given fields: ctx, lockoutType, subject, until
+func (_m *MockRepository) LockAuthLockout(ctx context.Context, lockoutType LockoutType, subject string, until time.Time) error {
+	ret := _m.Called(ctx, lockoutType, subject, until)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, LockoutType, string, time.Time) error); ok {
+		r0 = rf(ctx, lockoutType, subject, until)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordTokenIssued provides a mock function with given fields: ctx, clientID, issuedAt
+func (_m *MockRepository) RecordTokenIssued(ctx context.Context, clientID string, issuedAt time.Time) error {
+	ret := _m.Called(ctx, clientID, issuedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, clientID, issuedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeCredentialByClientID provides a mock function with given fields: ctx, clientID
+func (_m *MockRepository) RevokeCredentialByClientID(ctx context.Context, clientID string) error {
+	ret := _m.Called(ctx, clientID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeCredentialBySystemID provides a mock function with given fields: ctx, systemID
+func (_m *MockRepository) RevokeCredentialBySystemID(ctx context.Context, systemID string) error {
+	ret := _m.Called(ctx, systemID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, systemID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveAuthLockout provides a mock function with given fields: ctx, lockout
+func (_m *MockRepository) SaveAuthLockout(ctx context.Context, lockout AuthLockout) error {
+	ret := _m.Called(ctx, lockout)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, AuthLockout) error); ok {
+		r0 = rf(ctx, lockout)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveCredential provides a mock function with given fields: ctx, credential
+func (_m *MockRepository) SaveCredential(ctx context.Context, credential Credential) error {
+	ret := _m.Called(ctx, credential)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, Credential) error); ok {
+		r0 = rf(ctx, credential)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateACO provides a mock function with given fields: ctx, acoUUID, fieldsAndValues
 func (_m *MockRepository) UpdateACO(ctx context.Context, acoUUID uuid.UUID, fieldsAndValues map[string]interface{}) error {
 	ret := _m.Called(ctx, acoUUID, fieldsAndValues)
@@ -416,6 +791,48 @@ func (_m *MockRepository) UpdateJob(ctx context.Context, j Job) error {
 	return r0
 }
 
+// UpdateJobCount provides a mock function with given fields: ctx, jobID, jobCount
+func (_m *MockRepository) UpdateJobCount(ctx context.Context, jobID uint, jobCount int) error {
+	ret := _m.Called(ctx, jobID, jobCount)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, int) error); ok {
+		r0 = rf(ctx, jobID, jobCount)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateJobStatus provides a mock function with given fields: ctx, jobID, status
+func (_m *MockRepository) UpdateJobStatus(ctx context.Context, jobID uint, status JobStatus) error {
+	ret := _m.Called(ctx, jobID, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, JobStatus) error); ok {
+		r0 = rf(ctx, jobID, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateJobStatusCheckStatus provides a mock function with given fields: ctx, jobID, current, new
+func (_m *MockRepository) UpdateJobStatusCheckStatus(ctx context.Context, jobID uint, current JobStatus, new JobStatus) error {
+	ret := _m.Called(ctx, jobID, current, new)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, JobStatus, JobStatus) error); ok {
+		r0 = rf(ctx, jobID, current, new)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateSuppressionFileImportStatus provides a mock function with given fields: ctx, fileID, importStatus
 func (_m *MockRepository) UpdateSuppressionFileImportStatus(ctx context.Context, fileID uint, importStatus string) error {
 	ret := _m.Called(ctx, fileID, importStatus)
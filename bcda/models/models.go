@@ -30,8 +30,10 @@ type Job struct {
 	TransactionTime   time.Time // most recent data load transaction time from BFD
 	JobCount          int
 	CompletedJobCount int
-	CreatedAt         time.Time
-	UpdatedAt         time.Time
+	// DeadLetterCount is the number of the job's queue jobs that were dead-lettered
+	DeadLetterCount int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 func (j *Job) StatusMessage() string {
@@ -51,6 +53,8 @@ type JobKey struct {
 	JobID        uint `json:"job_id"`
 	FileName     string
 	ResourceType string
+	// PlannedQueueJobID identifies the planned queue job that was completed, zero if the queue job was not planned
+	PlannedQueueJobID uint
 }
 
 // ACO represents an Accountable Care Organization.
@@ -68,6 +72,34 @@ type ACO struct {
 	TerminationDetails *Termination `json:"termination"`
 }
 
+// Credential tracks the lifecycle of a client credential issued to an ACO.
+type Credential struct {
+	ID                uint
+	ClientID          string
+	SystemID          string
+	ACOCMSID          string
+	LastTokenIssuedAt time.Time // zero value if no token has been issued
+	RevokedAt         time.Time // zero value if the credential is active
+	CreatedAt         time.Time
+}
+
+type LockoutType string
+
+const (
+	LockoutClientID  LockoutType = "client_id"
+	LockoutIPAddress LockoutType = "ip_address"
+)
+
+// AuthLockout tracks the failed token requests associated with a client ID or source IP address.
+type AuthLockout struct {
+	ID             uint
+	Type           LockoutType
+	Subject        string
+	FailedAttempts int
+	LockedUntil    time.Time // zero value if the subject is not locked out
+	UpdatedAt      time.Time
+}
+
 type CCLFFileType int16
 
 const (
@@ -98,6 +130,25 @@ type CCLFBeneficiary struct {
 	FileID       uint
 	MBI          string
 	BlueButtonID string
+
+	// SAMHSASuppressed is set when the beneficiary has opted out of sharing their substance abuse treatment (SAMHSA)
+	// claims with the ACO. It is not persisted and is only populated when beneficiaries are retrieved with suppressions applied.
+	SAMHSASuppressed bool
+}
+
+// XrefIndicatorMBI identifies CCLF9 crosswalk entries between MBIs (as opposed to HICNs)
+const XrefIndicatorMBI = "M"
+
+// CCLFBeneficiaryXref links a beneficiary's current identifier (found in CCLF9 files)
+// to an identifier that was previously issued to them.
+type CCLFBeneficiaryXref struct {
+	ID            uint
+	FileID        uint
+	XrefIndicator string
+	CurrentNum    string
+	PrevNum       string
+	PrevsEfctDt   string
+	PrevsObsltDt  string
 }
 
 type SuppressionFile struct {
@@ -121,10 +172,44 @@ type Suppression struct {
 	BeneficiaryLinkKey  int
 }
 
+// SuppressionCriteria identifies the data sharing preferences that apply to an ACO's beneficiaries.
+// Only preferences that became effective between LowerBound and UpperBound are considered.
+type SuppressionCriteria struct {
+	CMSID      string
+	LowerBound time.Time
+	UpperBound time.Time
+}
+
+// SuppressionReason identifies the data sharing preference that caused a beneficiary's data to be withheld
+type SuppressionReason string
+
+const (
+	// SuppressionReasonOptOut excludes the beneficiary from the ACO's attribution
+	SuppressionReasonOptOut SuppressionReason = "opt-out"
+	// SuppressionReasonSAMHSA omits the beneficiary's substance abuse treatment claims
+	SuppressionReasonSAMHSA SuppressionReason = "samhsa-opt-out"
+)
+
+// BeneficiarySuppression describes why data for a beneficiary attributed to an ACO is withheld.
+type BeneficiarySuppression struct {
+	BeneficiaryID uint
+	MBI           string
+	Reason        SuppressionReason
+	// SuppressionMBI is the MBI the preference was recorded against.
+	// It differs from MBI when the preference was recorded before the beneficiary was issued a new MBI.
+	SuppressionMBI    string
+	SuppressionFileID uint
+	EffectiveDt       time.Time
+	// ACOCMSID is the ACO the preference was recorded for. It is empty when the preference applies to every ACO.
+	ACOCMSID string
+}
+
 type JobEnqueueArgs struct {
 	ID              int
 	ACOID           string
 	BeneficiaryIDs  []string
+	// SAMHSASuppressedIDs contains the BeneficiaryIDs whose substance abuse treatment claims must be omitted
+	SAMHSASuppressedIDs []string
 	ResourceType    string
 	Since           string
 	TransactionTime time.Time
@@ -134,4 +219,67 @@ type JobEnqueueArgs struct {
 		LowerBound time.Time
 		UpperBound time.Time
 	}
+	// PlannedQueueJobID identifies the planned queue job that is removed once the queue job has been completed.
+	// It is zero for queue jobs enqueued before queue jobs were planned.
+	PlannedQueueJobID uint
+	// Checkpoint is set when a worker was stopped part way through the queue job, allowing the queue job
+	// to be resumed from the last completed beneficiary.
+	Checkpoint *JobCheckpoint `json:",omitempty"`
+}
+
+// JobCheckpoint records the progress of a queue job that was interrupted
+type JobCheckpoint struct {
+	// FileUUID identifies the staged file that the queue job's resources are written to
+	FileUUID string
+	// LastBeneficiaryID is the last of the BeneficiaryIDs whose resources were written to the file
+	LastBeneficiaryID string
+	// ErrorCount is the number of beneficiaries whose resources could not be retrieved
+	ErrorCount int
+	// FileOffset and ErrorFileOffset are the sizes of the staged file and error file when the checkpoint was taken.
+	// Anything written past them (e.g. by a worker that was killed after resuming) is discarded on resume.
+	FileOffset      int64
+	ErrorFileOffset int64
+}
+
+// PlannedQueueJob is a queue job planned for a job that has not been completed.
+// It allows a queue job to be re-enqueued if it is lost from the queue.
+type PlannedQueueJob struct {
+	ID       uint
+	JobID    uint
+	Args     JobEnqueueArgs
+	Priority int
+}
+
+type ReconciliationAction string
+
+const (
+	// ReconciliationCompleted indicates every queue job was completed but the job was not marked as completed
+	ReconciliationCompleted ReconciliationAction = "Completed"
+	// ReconciliationRequeued indicates the lost queue jobs were re-enqueued
+	ReconciliationRequeued ReconciliationAction = "Requeued"
+	// ReconciliationFailed indicates the job could not be recovered
+	ReconciliationFailed ReconciliationAction = "Failed"
+)
+
+// JobReconciliation records an action taken to recover a job that stopped making progress
+type JobReconciliation struct {
+	JobID  uint
+	Action ReconciliationAction
+	Reason string
+}
+
+// DeadLetterQueueJob is a queue job that was removed from the queue because it can never be worked on successfully.
+// It is kept until it is replayed or discarded.
+type DeadLetterQueueJob struct {
+	ID uint
+	// JobID is zero when the queue job's args could not be read
+	JobID    uint
+	QueJobID int64
+	// Args contains the JSON args supplied to the queue job as is, they may not be a valid JobEnqueueArgs
+	Args       []byte
+	Priority   int
+	ErrorCount int
+	LastError  string
+	Reason     string
+	CreatedAt  time.Time
 }
@@ -81,6 +81,18 @@ func CreateCCLFBeneficiary(t *testing.T, db *sql.DB, bene *models.CCLFBeneficiar
 	err := db.QueryRow(query, args...).Scan(&bene.ID)
 	assert.NoError(t, err)
 }
+
+func CreateCCLFBeneficiaryXref(t *testing.T, db *sql.DB, xref *models.CCLFBeneficiaryXref) {
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("cclf_beneficiary_xrefs")
+	ib.Cols("file_id", "xref_indicator", "current_num", "prev_num", "prevs_efct_dt", "prevs_obslt_dt").
+		Values(xref.FileID, xref.XrefIndicator, xref.CurrentNum, xref.PrevNum, xref.PrevsEfctDt, xref.PrevsObsltDt)
+	query, args := ib.Build()
+	query = fmt.Sprintf("%s RETURNING id", query)
+
+	err := db.QueryRow(query, args...).Scan(&xref.ID)
+	assert.NoError(t, err)
+}
+
 func CreateCCLFFile(t *testing.T, db *sql.DB, cclfFile *models.CCLFFile) {
 	r := postgres.NewRepository(db)
 	var err error
@@ -114,6 +126,13 @@ func DeleteCCLFFilesByCMSID(t *testing.T, db *sql.DB, cmsID string) {
 		query, args := beneDelete.Build()
 		_, err = db.Exec(query, args...)
 		assert.NoError(t, err)
+
+		xrefDelete := sqlFlavor.NewDeleteBuilder().DeleteFrom("cclf_beneficiary_xrefs")
+		xrefDelete = xrefDelete.Where(xrefDelete.In("file_id", fileIDs...))
+
+		query, args = xrefDelete.Build()
+		_, err = db.Exec(query, args...)
+		assert.NoError(t, err)
 	}
 
 	cclfFileDelete := sqlFlavor.NewDeleteBuilder().DeleteFrom("cclf_files")
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/pborman/uuid"
 
+	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/models"
 )
 
@@ -22,6 +24,11 @@ type executable interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 const (
 	sqlFlavor = sqlbuilder.PostgreSQL
 )
@@ -88,7 +95,216 @@ func (r *Repository) UpdateACO(ctx context.Context, acoUUID uuid.UUID, fieldsAnd
 	return nil
 }
 
+func (r *Repository) SaveCredential(ctx context.Context, credential models.Credential) error {
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("credentials")
+	ib.Cols("client_id", "system_id", "aco_cms_id").
+		Values(credential.ClientID, credential.SystemID, credential.ACOCMSID)
+	query, args := ib.Build()
+	// A credential that is re-issued (e.g. a secret reset) keeps its client ID, so we restart its lifecycle
+	query = fmt.Sprintf("%s ON CONFLICT (client_id) DO UPDATE SET system_id = EXCLUDED.system_id, "+
+		"aco_cms_id = EXCLUDED.aco_cms_id, created_at = NOW(), last_token_issued_at = NULL, revoked_at = NULL", query)
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) GetCredentials(ctx context.Context, cmsID string) ([]*models.Credential, error) {
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select(credentialColumns...)
+	sb.From("credentials").Where(sb.Equal("aco_cms_id", cmsID))
+	sb.OrderBy("created_at")
+
+	query, args := sb.Build()
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credentials []*models.Credential
+	for rows.Next() {
+		c, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+func (r *Repository) GetCredentialByClientID(ctx context.Context, clientID string) (*models.Credential, error) {
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select(credentialColumns...)
+	sb.From("credentials").Where(sb.Equal("client_id", clientID))
+
+	query, args := sb.Build()
+	c, err := scanCredential(r.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (r *Repository) RecordTokenIssued(ctx context.Context, clientID string, issuedAt time.Time) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("credentials")
+	// GREATEST ignores NULL values, so the first token issued is always recorded
+	ub.Set(fmt.Sprintf("last_token_issued_at = GREATEST(last_token_issued_at, %s)", ub.Var(issuedAt)))
+	ub.Where(ub.Equal("client_id", clientID))
+	return r.updateCredential(ctx, ub, clientID)
+}
+
+func (r *Repository) RevokeCredentialByClientID(ctx context.Context, clientID string) error {
+	return r.revokeCredential(ctx, "client_id", clientID)
+}
+
+func (r *Repository) RevokeCredentialBySystemID(ctx context.Context, systemID string) error {
+	return r.revokeCredential(ctx, "system_id", systemID)
+}
+
+func (r *Repository) revokeCredential(ctx context.Context, column string, value string) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("credentials")
+	ub.Set(ub.Assign("revoked_at", sqlbuilder.Raw("NOW()")))
+	ub.Where(ub.Equal(column, value), ub.IsNull("revoked_at"))
+	return r.updateCredential(ctx, ub, value)
+}
+
+func (r *Repository) updateCredential(ctx context.Context, ub *sqlbuilder.UpdateBuilder, id string) error {
+	query, args := ub.Build()
+	result, err := r.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("credential %s not updated, no row found", id)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAuthLockout(ctx context.Context, lockoutType models.LockoutType, subject string) (*models.AuthLockout, error) {
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select("id", "failed_attempts", "locked_until", "updated_at").From("auth_lockouts")
+	sb.Where(sb.Equal("lockout_type", lockoutType), sb.Equal("subject", subject))
+
+	query, args := sb.Build()
+	lockout := models.AuthLockout{Type: lockoutType, Subject: subject}
+	var lockedUntil sql.NullTime
+	err := r.QueryRowContext(ctx, query, args...).Scan(&lockout.ID, &lockout.FailedAttempts, &lockedUntil, &lockout.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	lockout.LockedUntil = lockedUntil.Time
+
+	return &lockout, nil
+}
+
+func (r *Repository) SaveAuthLockout(ctx context.Context, lockout models.AuthLockout) error {
+	var lockedUntil sql.NullTime
+	if !lockout.LockedUntil.IsZero() {
+		lockedUntil = sql.NullTime{Time: lockout.LockedUntil, Valid: true}
+	}
+
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("auth_lockouts")
+	ib.Cols("lockout_type", "subject", "failed_attempts", "locked_until").
+		Values(lockout.Type, lockout.Subject, lockout.FailedAttempts, lockedUntil)
+	query, args := ib.Build()
+	query = fmt.Sprintf("%s ON CONFLICT (lockout_type, subject) DO UPDATE SET "+
+		"failed_attempts = EXCLUDED.failed_attempts, locked_until = EXCLUDED.locked_until", query)
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) IncrementAuthLockout(ctx context.Context, lockoutType models.LockoutType, subject string,
+	resetBefore time.Time) (int, error) {
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("auth_lockouts")
+	ib.Cols("lockout_type", "subject", "failed_attempts").Values(lockoutType, subject, 1)
+	query, args := ib.Build()
+	// Increment in the database so concurrent failures for the same subject are all counted
+	query = fmt.Sprintf("%s ON CONFLICT (lockout_type, subject) DO UPDATE SET "+
+		"failed_attempts = CASE WHEN auth_lockouts.updated_at < $%[2]d THEN 1 ELSE auth_lockouts.failed_attempts + 1 END, "+
+		"locked_until = CASE WHEN auth_lockouts.updated_at < $%[2]d THEN NULL ELSE auth_lockouts.locked_until END "+
+		"RETURNING failed_attempts", query, len(args)+1)
+	args = append(args, resetBefore)
+
+	var failedAttempts int
+	if err := r.QueryRowContext(ctx, query, args...).Scan(&failedAttempts); err != nil {
+		return 0, err
+	}
+	return failedAttempts, nil
+}
+
+func (r *Repository) LockAuthLockout(ctx context.Context, lockoutType models.LockoutType, subject string, until time.Time) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("auth_lockouts")
+	ub.Set(fmt.Sprintf("locked_until = GREATEST(locked_until, %s)", ub.Var(until)))
+	ub.Where(ub.Equal("lockout_type", lockoutType), ub.Equal("subject", subject))
+
+	query, args := ub.Build()
+	result, err := r.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("no lockout found for %s %s", lockoutType, subject)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteAuthLockout(ctx context.Context, lockoutType models.LockoutType, subject string) error {
+	db := sqlFlavor.NewDeleteBuilder().DeleteFrom("auth_lockouts")
+	db.Where(db.Equal("lockout_type", lockoutType), db.Equal("subject", subject))
+
+	query, args := db.Build()
+	result, err := r.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("no lockout found for %s %s", lockoutType, subject)
+	}
+
+	return nil
+}
+
 func (r *Repository) GetLatestCCLFFile(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound, upperBound time.Time, fileType models.CCLFFileType) (*models.CCLFFile, error) {
+	return r.getLatestCCLFFile(ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, 0)
+}
+
+func (r *Repository) GetLatestCCLFFileForPerformanceYear(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound, upperBound time.Time, fileType models.CCLFFileType, performanceYear int) (*models.CCLFFile, error) {
+	if performanceYear <= 0 {
+		return nil, fmt.Errorf("invalid performance year %d", performanceYear)
+	}
+	return r.getLatestCCLFFile(ctx, cmsID, cclfNum, importStatus, lowerBound, upperBound, fileType, performanceYear)
+}
+
+// getLatestCCLFFile returns the latest CCLF file matching the search criteria. The performance year is only used in the filtering when it is set.
+func (r *Repository) getLatestCCLFFile(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound, upperBound time.Time, fileType models.CCLFFileType, performanceYear int) (*models.CCLFFile, error) {
 	sb := sqlFlavor.NewSelectBuilder()
 	sb.Select("id", "name", "timestamp", "performance_year")
 	sb.From("cclf_files")
@@ -98,6 +314,9 @@ func (r *Repository) GetLatestCCLFFile(ctx context.Context, cmsID string, cclfNu
 		sb.Equal("import_status", importStatus),
 		sb.Equal("type", fileType),
 	)
+	if performanceYear > 0 {
+		sb.Where(sb.Equal("performance_year", performanceYear))
+	}
 
 	cclfFile := models.CCLFFile{
 		ACOCMSID:     cmsID,
@@ -197,7 +416,64 @@ func (r *Repository) GetCCLFBeneficiaryMBIs(ctx context.Context, cclfFileID uint
 	return mbis, nil
 }
 
-func (r *Repository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, ignoredMBIs []string) ([]*models.CCLFBeneficiary, error) {
+// maxMBIsPerQuery keeps the number of bind parameters well below Postgres' limit of 65535
+const maxMBIsPerQuery = 10000
+
+func (r *Repository) GetCCLFBeneficiaryMBIsIn(ctx context.Context, cclfFileID uint, mbis []string) ([]string, error) {
+	found := []string{}
+	for start := 0; start < len(mbis); start += maxMBIsPerQuery {
+		end := start + maxMBIsPerQuery
+		if end > len(mbis) {
+			end = len(mbis)
+		}
+
+		args := make([]interface{}, 0, end-start)
+		for _, mbi := range mbis[start:end] {
+			args = append(args, mbi)
+		}
+
+		sb := sqlFlavor.NewSelectBuilder().Distinct().Select("mbi").From("cclf_beneficiaries")
+		sb.Where(sb.Equal("file_id", cclfFileID), sb.In("mbi", args...))
+
+		query, queryArgs := sb.Build()
+		rows, err := r.QueryContext(ctx, query, queryArgs...)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var mbi string
+			if err = rows.Scan(&mbi); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found = append(found, mbi)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return found, nil
+}
+
+func (r *Repository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, suppression *models.SuppressionCriteria) ([]*models.CCLFBeneficiary, error) {
+	benes, _, err := r.getCCLFBeneficiaries(ctx, cclfFileID, suppression, 0, 0)
+	return benes, err
+}
+
+func (r *Repository) GetCCLFBeneficiariesAfter(ctx context.Context, cclfFileID uint, suppression *models.SuppressionCriteria, afterID uint, limit int) ([]*models.CCLFBeneficiary, uint, error) {
+	if limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid limit %d, must be greater than zero", limit)
+	}
+	return r.getCCLFBeneficiaries(ctx, cclfFileID, suppression, afterID, limit)
+}
+
+// getCCLFBeneficiaries returns the beneficiaries found in the CCLF file along with the cursor used to retrieve the next page.
+// A limit of zero returns every beneficiary found in the file.
+func (r *Repository) getCCLFBeneficiaries(ctx context.Context, cclfFileID uint, suppression *models.SuppressionCriteria, afterID uint, limit int) ([]*models.CCLFBeneficiary, uint, error) {
 	var beneficiaries []*models.CCLFBeneficiary
 
 	// Subquery to deal with duplicate MBIs found within a single CCLF file.
@@ -209,42 +485,103 @@ func (r *Repository) GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint,
 		subSB.Equal("file_id", cclfFileID),
 	).GroupBy("mbi")
 
+	// Suppressed beneficiaries are filtered out after they're scanned so the cursor
+	// advances past them even when an entire page is suppressed.
 	sb := sqlFlavor.NewSelectBuilder()
-	sb.Select("id", "file_id", "mbi", "blue_button_id")
-	sb.From("cclf_beneficiaries").Where(sb.In("id", subSB))
-
-	if len(ignoredMBIs) != 0 {
-		ignored := make([]interface{}, len(ignoredMBIs))
-		for i, v := range ignoredMBIs {
-			ignored[i] = v
+	if suppression == nil {
+		sb.Select("id", "file_id", "mbi", "blue_button_id", "false", "false")
+		sb.From("cclf_beneficiaries").Where(sb.In("id", subSB))
+		if limit > 0 {
+			sb.Where(sb.GreaterThan("id", afterID))
+			sb.OrderBy("id").Limit(limit)
+		}
+	} else {
+		sb.Select("b.id", "b.file_id", "b.mbi", "b.blue_button_id", "sp.opt_out_mbi IS NOT NULL", "sp.samhsa_mbi IS NOT NULL")
+		sb.From("cclf_beneficiaries b").
+			Join(sb.BuilderAs(beneficiarySuppressions(cclfFileID, *suppression, afterID, limit), "sp"), "sp.bene_id = b.id")
+		if limit > 0 {
+			sb.OrderBy("b.id")
 		}
-		sb.Where(sb.NotIn("mbi", ignored...))
 	}
 
 	query, args := sb.Build()
 	rows, err := r.QueryContext(ctx, query, args...)
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
+	var (
+		scanned int
+		lastID  uint
+	)
 	for rows.Next() {
 		var (
-			bene models.CCLFBeneficiary
-			bbID sql.NullString
+			bene     models.CCLFBeneficiary
+			bbID     sql.NullString
+			optedOut bool
 		)
-		if err := rows.Scan(&bene.ID, &bene.FileID, &bene.MBI, &bbID); err != nil {
-			return nil, err
+		if err := rows.Scan(&bene.ID, &bene.FileID, &bene.MBI, &bbID, &optedOut, &bene.SAMHSASuppressed); err != nil {
+			return nil, 0, err
+		}
+		scanned++
+		lastID = bene.ID
+		if optedOut {
+			continue
 		}
 		bene.BlueButtonID = bbID.String
 		beneficiaries = append(beneficiaries, &bene)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// A partial page indicates we've reached the end of the file
+	if limit == 0 || scanned < limit {
+		lastID = 0
+	}
+
+	return beneficiaries, lastID, nil
+}
+
+func (r *Repository) GetMBIXrefs(ctx context.Context, cmsID string) ([]*models.CCLFBeneficiaryXref, error) {
+	var xrefs []*models.CCLFBeneficiaryXref
+
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select("x.id", "x.file_id", "x.xref_indicator", "x.current_num", "x.prev_num", "x.prevs_efct_dt", "x.prevs_obslt_dt")
+	sb.From("cclf_beneficiary_xrefs x").Join("cclf_files f", "f.id = x.file_id")
+	sb.Where(
+		sb.Equal("f.aco_cms_id", cmsID),
+		sb.Equal("f.cclf_num", 9),
+		sb.Equal("f.import_status", constants.ImportComplete),
+		sb.Equal("x.xref_indicator", models.XrefIndicatorMBI),
+	)
+
+	query, args := sb.Build()
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			xref            models.CCLFBeneficiaryXref
+			efctDt, obsltDt sql.NullString
+		)
+		if err = rows.Scan(&xref.ID, &xref.FileID, &xref.XrefIndicator, &xref.CurrentNum, &xref.PrevNum,
+			&efctDt, &obsltDt); err != nil {
+			return nil, err
+		}
+		xref.PrevsEfctDt, xref.PrevsObsltDt = efctDt.String, obsltDt.String
+		xrefs = append(xrefs, &xref)
+	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return beneficiaries, nil
+	return xrefs, nil
 }
 
 func (r *Repository) CreateSuppression(ctx context.Context, suppression models.Suppression) error {
@@ -261,22 +598,16 @@ func (r *Repository) CreateSuppression(ctx context.Context, suppression models.S
 	return err
 }
 
-func (r *Repository) GetSuppressedMBIs(ctx context.Context, lookbackDays int, upperBound time.Time) ([]string, error) {
-	var suppressedMBIs []string
-
-	lookbackDuration := time.Duration(-1*lookbackDays*24) * time.Hour
-	lowerBound := upperBound.Add(lookbackDuration)
+func (r *Repository) GetBeneficiarySuppressions(ctx context.Context, cclfFileID uint, suppression models.SuppressionCriteria) ([]*models.BeneficiarySuppression, error) {
+	var suppressions []*models.BeneficiarySuppression
 
-	subSB := sqlFlavor.NewSelectBuilder()
-	subSB.Select("mbi", "MAX(effective_date) as max_date").From("suppressions")
-	subSB.Where(
-		subSB.GreaterEqualThan("effective_date", lowerBound), subSB.LessEqualThan("effective_date", upperBound),
-		subSB.NotEqual("preference_indicator", ""),
-	).GroupBy("mbi")
-
-	sb := sqlFlavor.NewSelectBuilder().Distinct().Select("s.mbi")
-	sb.From(sb.BuilderAs(subSB, "h")).Join("suppressions s", "s.mbi = h.mbi", "s.effective_date = h.max_date")
-	sb.Where(sb.Equal("preference_indicator", "N"))
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select("bene_id", "mbi",
+		"opt_out_file_id", "opt_out_mbi", "opt_out_date", "opt_out_aco_cms_id",
+		"samhsa_file_id", "samhsa_mbi", "samhsa_date", "samhsa_aco_cms_id")
+	sb.From(sb.BuilderAs(beneficiarySuppressions(cclfFileID, suppression, 0, 0), "sp"))
+	sb.Where(sb.Or("opt_out_mbi IS NOT NULL", "samhsa_mbi IS NOT NULL"))
+	sb.OrderBy("mbi")
 
 	query, args := sb.Build()
 	rows, err := r.QueryContext(ctx, query, args...)
@@ -286,17 +617,103 @@ func (r *Repository) GetSuppressedMBIs(ctx context.Context, lookbackDays int, up
 	defer rows.Close()
 
 	for rows.Next() {
-		var mbi string
-		if err = rows.Scan(&mbi); err != nil {
+		var (
+			beneID                   uint
+			mbi                      string
+			optOutFileID, samhsaFile sql.NullInt64
+			optOutMBI, samhsaMBI     sql.NullString
+			optOutDate, samhsaDate   sql.NullTime
+			optOutACO, samhsaACO     sql.NullString
+		)
+		if err = rows.Scan(&beneID, &mbi, &optOutFileID, &optOutMBI, &optOutDate, &optOutACO,
+			&samhsaFile, &samhsaMBI, &samhsaDate, &samhsaACO); err != nil {
 			return nil, err
 		}
-		suppressedMBIs = append(suppressedMBIs, mbi)
+		if optOutMBI.Valid {
+			suppressions = append(suppressions, &models.BeneficiarySuppression{
+				BeneficiaryID: beneID, MBI: mbi, Reason: models.SuppressionReasonOptOut,
+				SuppressionMBI: optOutMBI.String, SuppressionFileID: uint(optOutFileID.Int64),
+				EffectiveDt: optOutDate.Time, ACOCMSID: optOutACO.String,
+			})
+		}
+		if samhsaMBI.Valid {
+			suppressions = append(suppressions, &models.BeneficiarySuppression{
+				BeneficiaryID: beneID, MBI: mbi, Reason: models.SuppressionReasonSAMHSA,
+				SuppressionMBI: samhsaMBI.String, SuppressionFileID: uint(samhsaFile.Int64),
+				EffectiveDt: samhsaDate.Time, ACOCMSID: samhsaACO.String,
+			})
+		}
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return suppressedMBIs, nil
+	return suppressions, nil
+}
+
+// beneficiarySuppressions builds a query that returns a row for each beneficiary found in the CCLF file
+// along with the latest data sharing and SAMHSA preferences that withhold their data from the ACO.
+// The preference columns are NULL when the beneficiary's data is shared.
+//
+// A preference applies to the ACO when it was recorded for the ACO or without an ACO.
+// Preferences recorded against any MBI issued to the beneficiary are considered. The MBIs are linked through the
+// ACO's CCLF9 crosswalk as well as the beneficiary link key found in the suppression files.
+func beneficiarySuppressions(cclfFileID uint, suppression models.SuppressionCriteria, afterID uint, limit int) sqlbuilder.Builder {
+	benes := "SELECT id, mbi FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = ${fileID} GROUP BY mbi)"
+	// Only resolve the preferences for the requested page of beneficiaries
+	if limit > 0 {
+		benes += " AND id > ${afterID} ORDER BY id LIMIT ${limit}"
+	}
+
+	query := `WITH RECURSIVE benes AS (
+		` + benes + `
+	), xrefs AS (
+		SELECT x.current_num, x.prev_num FROM cclf_beneficiary_xrefs x JOIN cclf_files f ON f.id = x.file_id
+		WHERE f.aco_cms_id = ${cmsID} AND f.cclf_num = 9 AND f.import_status = ${importStatus} AND x.xref_indicator = ${xrefIndicator}
+	), aliases (bene_id, mbi) AS (
+		SELECT id, mbi::text FROM benes
+		UNION
+		SELECT a.bene_id, (CASE WHEN x.current_num = a.mbi THEN x.prev_num ELSE x.current_num END)::text
+		FROM aliases a JOIN xrefs x ON a.mbi IN (x.current_num, x.prev_num)
+	), preferences AS (
+		SELECT a.bene_id, s.id, s.file_id, s.mbi, s.effective_date, s.preference_indicator,
+			s.samhsa_effective_date, s.samhsa_preference_indicator, COALESCE(TRIM(s.aco_cms_id), '') AS aco_cms_id
+		FROM aliases a JOIN suppressions s ON s.mbi = a.mbi
+		WHERE COALESCE(TRIM(s.aco_cms_id), '') IN ('', ${cmsID})
+		UNION
+		SELECT a.bene_id, s.id, s.file_id, s.mbi, s.effective_date, s.preference_indicator,
+			s.samhsa_effective_date, s.samhsa_preference_indicator, COALESCE(TRIM(s.aco_cms_id), '') AS aco_cms_id
+		FROM aliases a JOIN suppressions l ON l.mbi = a.mbi AND l.beneficiary_link_key <> 0
+		JOIN suppressions s ON s.beneficiary_link_key = l.beneficiary_link_key
+		WHERE COALESCE(TRIM(s.aco_cms_id), '') IN ('', ${cmsID})
+	), opt_outs AS (
+		SELECT DISTINCT ON (bene_id) bene_id, file_id, mbi, effective_date, preference_indicator, aco_cms_id
+		FROM preferences
+		WHERE effective_date >= ${lowerBound} AND effective_date <= ${upperBound} AND preference_indicator <> ''
+		ORDER BY bene_id, effective_date DESC, id DESC
+	), samhsa_opt_outs AS (
+		SELECT DISTINCT ON (bene_id) bene_id, file_id, mbi, samhsa_effective_date, samhsa_preference_indicator, aco_cms_id
+		FROM preferences
+		WHERE samhsa_effective_date >= ${lowerBound} AND samhsa_effective_date <= ${upperBound} AND samhsa_preference_indicator <> ''
+		ORDER BY bene_id, samhsa_effective_date DESC, id DESC
+	)
+	SELECT b.id AS bene_id, b.mbi,
+		o.file_id AS opt_out_file_id, o.mbi AS opt_out_mbi, o.effective_date AS opt_out_date, o.aco_cms_id AS opt_out_aco_cms_id,
+		so.file_id AS samhsa_file_id, so.mbi AS samhsa_mbi, so.samhsa_effective_date AS samhsa_date, so.aco_cms_id AS samhsa_aco_cms_id
+	FROM benes b
+	LEFT JOIN opt_outs o ON o.bene_id = b.id AND o.preference_indicator = 'N'
+	LEFT JOIN samhsa_opt_outs so ON so.bene_id = b.id AND so.samhsa_preference_indicator = 'N'`
+
+	return sqlbuilder.WithFlavor(sqlbuilder.BuildNamed(query, map[string]interface{}{
+		"afterID":       afterID,
+		"limit":         limit,
+		"fileID":        cclfFileID,
+		"cmsID":         suppression.CMSID,
+		"importStatus":  constants.ImportComplete,
+		"xrefIndicator": models.XrefIndicatorMBI,
+		"lowerBound":    suppression.LowerBound,
+		"upperBound":    suppression.UpperBound,
+	}), sqlFlavor)
 }
 
 func (r *Repository) CreateSuppressionFile(ctx context.Context, suppressionFile models.SuppressionFile) (uint, error) {
@@ -337,7 +754,7 @@ func (r *Repository) UpdateSuppressionFileImportStatus(ctx context.Context, file
 	return nil
 }
 
-var jobColumns []string = []string{"id", "aco_id", "request_url", "status", "transaction_time", "job_count", "completed_job_count", "created_at", "updated_at"}
+var jobColumns []string = []string{"id", "aco_id", "request_url", "status", "transaction_time", "job_count", "completed_job_count", "dead_letter_count", "created_at", "updated_at"}
 
 func (r *Repository) GetJobs(ctx context.Context, acoID uuid.UUID, statuses ...models.JobStatus) ([]*models.Job, error) {
 	s := make([]interface{}, len(statuses))
@@ -395,7 +812,7 @@ func (r *Repository) GetJobByID(ctx context.Context, jobID uint) (*models.Job, e
 	)
 
 	err := r.QueryRowContext(ctx, query, args...).Scan(&j.ID, &j.ACOID, &j.RequestURL, &j.Status, &transactionTime,
-		&j.JobCount, &j.CompletedJobCount, &createdAt, &updatedAt)
+		&j.JobCount, &j.CompletedJobCount, &j.DeadLetterCount, &createdAt, &updatedAt)
 	j.TransactionTime, j.CreatedAt, j.UpdatedAt = transactionTime.Time, createdAt.Time, updatedAt.Time
 
 	if err != nil {
@@ -439,8 +856,170 @@ func (r *Repository) UpdateJob(ctx context.Context, j models.Job) error {
 		ub.Assign("updated_at", sqlbuilder.Raw("NOW()")),
 	)
 	ub.Where(ub.Equal("id", j.ID))
-	query, args := ub.Build()
 
+	return r.updateJob(ctx, ub)
+}
+
+func (r *Repository) UpdateJobCount(ctx context.Context, jobID uint, jobCount int) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("jobs")
+	ub.Set(
+		ub.Assign("job_count", jobCount),
+		ub.Assign("updated_at", sqlbuilder.Raw("NOW()")),
+	)
+	ub.Where(ub.Equal("id", jobID))
+
+	return r.updateJob(ctx, ub)
+}
+
+func (r *Repository) UpdateJobStatus(ctx context.Context, jobID uint, status models.JobStatus) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("jobs")
+	ub.Set(
+		ub.Assign("status", status),
+		ub.Assign("updated_at", sqlbuilder.Raw("NOW()")),
+	)
+	ub.Where(ub.Equal("id", jobID))
+
+	return r.updateJob(ctx, ub)
+}
+
+func (r *Repository) UpdateJobStatusCheckStatus(ctx context.Context, jobID uint, current, new models.JobStatus) error {
+	ub := sqlFlavor.NewUpdateBuilder().Update("jobs")
+	ub.Set(
+		ub.Assign("status", new),
+		ub.Assign("updated_at", sqlbuilder.Raw("NOW()")),
+	)
+	ub.Where(ub.Equal("id", jobID), ub.Equal("status", current))
+
+	return r.updateJob(ctx, ub)
+}
+
+// CreatePlannedQueueJobs records the planned queue jobs in a single transaction, so that either all or none of
+// them are recorded. When the repository is already backed by a transaction, the caller's transaction is used.
+func (r *Repository) CreatePlannedQueueJobs(ctx context.Context, jobID uint, jobs []*models.JobEnqueueArgs, priority int) (err error) {
+	db, ok := r.queryable.(*sql.DB)
+	if !ok {
+		return r.createPlannedQueueJobs(ctx, jobID, jobs, priority)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			// The IDs of the rolled back planned queue jobs no longer exist
+			for _, job := range jobs {
+				job.PlannedQueueJobID = 0
+			}
+		}
+	}()
+
+	return NewRepositoryTx(tx).createPlannedQueueJobs(ctx, jobID, jobs, priority)
+}
+
+func (r *Repository) createPlannedQueueJobs(ctx context.Context, jobID uint, jobs []*models.JobEnqueueArgs, priority int) error {
+	for _, job := range jobs {
+		args, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		ib := sqlFlavor.NewInsertBuilder().InsertInto("planned_queue_jobs")
+		ib.Cols("job_id", "args", "priority").Values(jobID, args, priority)
+		query, queryArgs := ib.Build()
+		// Append the RETURNING id to retrieve the auto-generated ID value associated with the planned queue job
+		query = fmt.Sprintf("%s RETURNING id", query)
+
+		if err := r.QueryRowContext(ctx, query, queryArgs...).Scan(&job.PlannedQueueJobID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) DeletePlannedQueueJobs(ctx context.Context, jobID uint) error {
+	db := sqlFlavor.NewDeleteBuilder().DeleteFrom("planned_queue_jobs")
+	db.Where(db.Equal("job_id", jobID))
+
+	query, args := db.Build()
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetDeadLetterQueueJobs returns the dead-lettered queue jobs of the job, or all of them when jobID is zero.
+func (r *Repository) GetDeadLetterQueueJobs(ctx context.Context, jobID uint) ([]*models.DeadLetterQueueJob, error) {
+	sb := sqlFlavor.NewSelectBuilder().Select(deadLetterQueueJobColumns...).From("dead_letter_queue_jobs")
+	if jobID != 0 {
+		sb.Where(sb.Equal("job_id", jobID))
+	}
+	sb.OrderBy("id")
+
+	query, args := sb.Build()
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deadLetters []*models.DeadLetterQueueJob
+	for rows.Next() {
+		deadLetter, err := scanDeadLetterQueueJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
+
+func (r *Repository) GetDeadLetterQueueJob(ctx context.Context, id uint) (*models.DeadLetterQueueJob, error) {
+	sb := sqlFlavor.NewSelectBuilder().Select(deadLetterQueueJobColumns...).From("dead_letter_queue_jobs")
+	sb.Where(sb.Equal("id", id))
+
+	query, args := sb.Build()
+	return scanDeadLetterQueueJob(r.QueryRowContext(ctx, query, args...))
+}
+
+// DeleteDeadLetterQueueJob deletes the dead-lettered queue job, updating the dead letter count of its job.
+func (r *Repository) DeleteDeadLetterQueueJob(ctx context.Context, id uint) error {
+	db := sqlFlavor.NewDeleteBuilder().DeleteFrom("dead_letter_queue_jobs")
+	db.Where(db.Equal("id", id))
+	query, args := db.Build()
+	// Update the job in the same statement so its dead letter count always matches its dead-lettered queue jobs
+	query = fmt.Sprintf(`WITH deleted AS (%s RETURNING job_id)
+		UPDATE jobs SET dead_letter_count = GREATEST(dead_letter_count - 1, 0), updated_at = NOW()
+		WHERE id IN (SELECT job_id FROM deleted)`, query)
+
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+var deadLetterQueueJobColumns = []string{"id", "job_id", "que_job_id", "args", "priority", "error_count", "last_error", "reason", "created_at"}
+
+func scanDeadLetterQueueJob(row scanner) (*models.DeadLetterQueueJob, error) {
+	var (
+		deadLetter models.DeadLetterQueueJob
+		jobID      sql.NullInt64
+		lastError  sql.NullString
+	)
+	if err := row.Scan(&deadLetter.ID, &jobID, &deadLetter.QueJobID, &deadLetter.Args, &deadLetter.Priority,
+		&deadLetter.ErrorCount, &lastError, &deadLetter.Reason, &deadLetter.CreatedAt); err != nil {
+		return nil, err
+	}
+	deadLetter.JobID, deadLetter.LastError = uint(jobID.Int64), lastError.String
+
+	return &deadLetter, nil
+}
+
+// updateJob executes the update, ensuring that exactly one job was modified
+func (r *Repository) updateJob(ctx context.Context, ub *sqlbuilder.UpdateBuilder) error {
+	query, args := ub.Build()
 	res, err := r.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
@@ -499,7 +1078,7 @@ func (r *Repository) getJobs(ctx context.Context, query string, args ...interfac
 	for rows.Next() {
 		var j models.Job
 		if err = rows.Scan(&j.ID, &j.ACOID, &j.RequestURL, &j.Status, &transactionTime,
-			&j.JobCount, &j.CompletedJobCount, &createdAt, &updatedAt); err != nil {
+			&j.JobCount, &j.CompletedJobCount, &j.DeadLetterCount, &createdAt, &updatedAt); err != nil {
 			return nil, err
 		}
 		j.TransactionTime, j.CreatedAt, j.UpdatedAt = transactionTime.Time, createdAt.Time, updatedAt.Time
@@ -531,7 +1110,7 @@ func (r *Repository) getACO(ctx context.Context, field string, value interface{}
 		&publicKey, &aco.Blacklisted, &termination)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("no ACO record found for %s", value)
+			return nil, &models.NotFoundError{Message: fmt.Sprintf("no ACO record found for %s", value)}
 		}
 		return nil, err
 	}
@@ -541,3 +1120,26 @@ func (r *Repository) getACO(ctx context.Context, field string, value interface{}
 	aco.TerminationDetails = termination.Termination
 	return &aco, nil
 }
+
+var credentialColumns = []string{"id", "client_id", "system_id", "aco_cms_id", "last_token_issued_at", "revoked_at", "created_at"}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCredential reads a credential selected with credentialColumns.
+func scanCredential(row rowScanner) (*models.Credential, error) {
+	var (
+		c                                       models.Credential
+		systemID                                sql.NullString
+		lastTokenIssuedAt, revokedAt, createdAt sql.NullTime
+	)
+	if err := row.Scan(&c.ID, &c.ClientID, &systemID, &c.ACOCMSID, &lastTokenIssuedAt, &revokedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	c.SystemID = systemID.String
+	c.LastTokenIssuedAt, c.RevokedAt, c.CreatedAt = lastTokenIssuedAt.Time, revokedAt.Time, createdAt.Time
+
+	return &c, nil
+}
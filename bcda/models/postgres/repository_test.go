@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -52,6 +53,7 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 		lowerBound    time.Time
 		upperBound    time.Time
 		fileType      models.CCLFFileType
+		perfYear      int
 		expQueryRegex string
 		result        *models.CCLFFile
 	}{
@@ -60,6 +62,7 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			time.Time{},
 			time.Time{},
 			models.FileTypeDefault,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 ORDER BY timestamp DESC LIMIT 1`,
 			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeDefault),
 		},
@@ -68,6 +71,7 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			time.Time{},
 			time.Time{},
 			models.FileTypeRunout,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 ORDER BY timestamp DESC LIMIT 1`,
 			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeRunout),
 		},
@@ -76,6 +80,7 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			time.Now(),
 			time.Time{},
 			models.FileTypeDefault,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 AND timestamp >= $5 ORDER BY timestamp DESC LIMIT 1`,
 			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeDefault),
 		},
@@ -84,6 +89,7 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			time.Time{},
 			time.Now(),
 			models.FileTypeDefault,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 AND timestamp <= $5 ORDER BY timestamp DESC LIMIT 1`,
 			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeDefault),
 		},
@@ -92,14 +98,25 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			time.Now(),
 			time.Now(),
 			models.FileTypeDefault,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 AND timestamp >= $5 AND timestamp <= $6 ORDER BY timestamp DESC LIMIT 1`,
 			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeDefault),
 		},
+		{
+			"PerformanceYear",
+			time.Now(),
+			time.Time{},
+			models.FileTypeRunout,
+			20,
+			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 AND performance_year = $5 AND timestamp >= $6 ORDER BY timestamp DESC LIMIT 1`,
+			getCCLFFile(cclfNum, cmsID, importStatus, models.FileTypeRunout),
+		},
 		{
 			"NoResult",
 			time.Time{},
 			time.Time{},
 			models.FileTypeDefault,
+			0,
 			`SELECT id, name, timestamp, performance_year FROM cclf_files WHERE aco_cms_id = $1 AND cclf_num = $2 AND import_status = $3 AND type = $4 ORDER BY timestamp DESC LIMIT 1`,
 			nil,
 		},
@@ -117,6 +134,9 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 			repository := postgres.NewRepository(db)
 
 			args := []driver.Value{cmsID, cclfNum, importStatus, tt.fileType}
+			if tt.perfYear > 0 {
+				args = append(args, tt.perfYear)
+			}
 			if !tt.lowerBound.IsZero() {
 				args = append(args, tt.lowerBound)
 			}
@@ -133,8 +153,14 @@ func (r *RepositoryTestSuite) TestGetLatestCCLFFile() {
 					NewRows([]string{"id", "name", "timestamp", "performance_year"}).
 					AddRow(tt.result.ID, tt.result.Name, tt.result.Timestamp, tt.result.PerformanceYear))
 			}
-			cclfFile, err := repository.GetLatestCCLFFile(context.Background(), cmsID, cclfNum, importStatus, tt.lowerBound, tt.upperBound,
-				tt.fileType)
+			var cclfFile *models.CCLFFile
+			if tt.perfYear > 0 {
+				cclfFile, err = repository.GetLatestCCLFFileForPerformanceYear(context.Background(), cmsID, cclfNum, importStatus,
+					tt.lowerBound, tt.upperBound, tt.fileType, tt.perfYear)
+			} else {
+				cclfFile, err = repository.GetLatestCCLFFile(context.Background(), cmsID, cclfNum, importStatus, tt.lowerBound, tt.upperBound,
+					tt.fileType)
+			}
 			assert.NoError(t, err)
 
 			if tt.result == nil {
@@ -201,17 +227,55 @@ func (r *RepositoryTestSuite) TestGetCCLFBeneficiaryMBIs() {
 	}
 }
 
+func (r *RepositoryTestSuite) TestGetCCLFBeneficiaryMBIsIn() {
+	cclfFileID := uint(rand.Int63())
+	expQuery := `SELECT DISTINCT mbi FROM cclf_beneficiaries WHERE file_id = $1 AND mbi IN ($2, $3, $4)`
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(r.T(), err)
+	defer func() {
+		assert.NoError(r.T(), mock.ExpectationsWereMet())
+		db.Close()
+	}()
+	repository := postgres.NewRepository(db)
+
+	mock.ExpectQuery(fmt.Sprintf("^%s$", regexp.QuoteMeta(expQuery))).
+		WithArgs(cclfFileID, "0", "1", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"mbi"}).AddRow("1"))
+	result, err := repository.GetCCLFBeneficiaryMBIsIn(context.Background(), cclfFileID, []string{"0", "1", "2"})
+	assert.NoError(r.T(), err)
+	assert.Equal(r.T(), []string{"1"}, result)
+
+	// No MBIs are supplied, so there is nothing to query
+	result, err = repository.GetCCLFBeneficiaryMBIsIn(context.Background(), cclfFileID, nil)
+	assert.NoError(r.T(), err)
+	assert.Empty(r.T(), result)
+
+	mock.ExpectQuery(fmt.Sprintf("^%s$", regexp.QuoteMeta(expQuery))).
+		WithArgs(cclfFileID, "0", "1", "2").
+		WillReturnError(fmt.Errorf("Some SQL error"))
+	result, err = repository.GetCCLFBeneficiaryMBIsIn(context.Background(), cclfFileID, []string{"0", "1", "2"})
+	assert.Error(r.T(), err)
+	assert.Nil(r.T(), result)
+}
+
 func (r *RepositoryTestSuite) TestGetCCLFBeneficiaries() {
+	suppression := &models.SuppressionCriteria{CMSID: "A0000",
+		LowerBound: time.Now().Add(-24 * time.Hour).Round(time.Millisecond), UpperBound: time.Now().Round(time.Millisecond)}
+	samhsaSuppressed := getCCLFBeneficiary()
+	samhsaSuppressed.SAMHSASuppressed = true
+
 	tests := []struct {
 		name            string
 		expQueryRegex   string
-		ignoredMBIs     []string
+		suppression     *models.SuppressionCriteria
 		expectedResults []*models.CCLFBeneficiary
+		optedOut        []*models.CCLFBeneficiary
 		errToReturn     error
 	}{
 		{
-			"NoIgnoreMBIs",
-			`SELECT id, file_id, mbi, blue_button_id FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi)`,
+			"NoSuppression",
+			regexp.QuoteMeta(`SELECT id, file_id, mbi, blue_button_id, false, false FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi)`),
 			nil,
 			[]*models.CCLFBeneficiary{
 				getCCLFBeneficiary(),
@@ -220,19 +284,24 @@ func (r *RepositoryTestSuite) TestGetCCLFBeneficiaries() {
 				getCCLFBeneficiary(),
 			},
 			nil,
+			nil,
 		},
 		{
-			"IgnoredMBIs",
-			`SELECT id, file_id, mbi, blue_button_id FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi) AND mbi NOT IN ($2, $3)`,
-			[]string{"123", "456"},
+			"Suppression",
+			`(?s)` + regexp.QuoteMeta(`SELECT b.id, b.file_id, b.mbi, b.blue_button_id, sp.opt_out_mbi IS NOT NULL, sp.samhsa_mbi IS NOT NULL FROM cclf_beneficiaries b JOIN (WITH RECURSIVE benes AS (`) +
+				`.*` + regexp.QuoteMeta(`) AS sp ON sp.bene_id = b.id`),
+			suppression,
 			[]*models.CCLFBeneficiary{
 				getCCLFBeneficiary(),
+				samhsaSuppressed,
 			},
+			[]*models.CCLFBeneficiary{getCCLFBeneficiary()},
 			nil,
 		},
 		{
 			"ErrorOnQuery",
-			`SELECT id, file_id, mbi, blue_button_id FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi)`,
+			regexp.QuoteMeta(`SELECT id, file_id, mbi, blue_button_id, false, false FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi)`),
+			nil,
 			nil,
 			nil,
 			fmt.Errorf("Some SQL error"),
@@ -252,28 +321,20 @@ func (r *RepositoryTestSuite) TestGetCCLFBeneficiaries() {
 			repository := postgres.NewRepository(db)
 
 			var query *sqlmock.ExpectedQuery
-			if tt.ignoredMBIs == nil {
-				query = mock.ExpectQuery(fmt.Sprintf("^%s$", regexp.QuoteMeta(tt.expQueryRegex))).
+			if tt.suppression == nil {
+				query = mock.ExpectQuery(fmt.Sprintf("^%s$", tt.expQueryRegex)).
 					WithArgs(cclfFileID)
 			} else {
-				args := []driver.Value{cclfFileID}
-				for _, ignoredMBI := range tt.ignoredMBIs {
-					args = append(args, ignoredMBI)
-				}
-				query = mock.ExpectQuery(regexp.QuoteMeta(tt.expQueryRegex)).
-					WithArgs(args...)
+				query = mock.ExpectQuery(fmt.Sprintf("^%s$", tt.expQueryRegex)).
+					WithArgs(suppressionArgs(cclfFileID, *tt.suppression)...)
 			}
 			if tt.errToReturn == nil {
-				rows := sqlmock.NewRows([]string{"id", "file_id", "mbi", "blue_button_id"})
-				for _, bene := range tt.expectedResults {
-					rows.AddRow(bene.ID, bene.FileID, bene.MBI, bene.BlueButtonID)
-				}
-				query.WillReturnRows(rows)
+				query.WillReturnRows(beneficiaryRows(tt.expectedResults, tt.optedOut))
 			} else {
 				query.WillReturnError(tt.errToReturn)
 			}
 
-			result, err := repository.GetCCLFBeneficiaries(context.Background(), cclfFileID, tt.ignoredMBIs)
+			result, err := repository.GetCCLFBeneficiaries(context.Background(), cclfFileID, tt.suppression)
 			if tt.errToReturn == nil {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedResults, result)
@@ -285,37 +346,41 @@ func (r *RepositoryTestSuite) TestGetCCLFBeneficiaries() {
 	}
 }
 
-func (r *RepositoryTestSuite) TestGetSuppressedMBIs() {
-	lookbackDays := 10
-	upperBound := time.Now().Round(time.Millisecond).UTC()
-	lowerBound := upperBound.Add(time.Duration(-1*lookbackDays*24) * time.Hour)
+func (r *RepositoryTestSuite) TestGetCCLFBeneficiariesAfter() {
+	suppression := &models.SuppressionCriteria{CMSID: "A0000",
+		LowerBound: time.Now().Add(-24 * time.Hour).Round(time.Millisecond), UpperBound: time.Now().Round(time.Millisecond)}
+	bene1, bene2, optedOut := getCCLFBeneficiary(), getCCLFBeneficiary(), getCCLFBeneficiary()
+	bene1.ID, bene2.ID, optedOut.ID = 11, 12, 13
 
 	tests := []struct {
-		name          string
-		expQueryRegex string
-		lowerBound    time.Time
-		upperBound    time.Time
-		errToReturn   error
+		name           string
+		expQueryRegex  string
+		suppression    *models.SuppressionCriteria
+		limit          int
+		expectedCursor uint
 	}{
 		{
-			"HappyPath",
-			`SELECT DISTINCT s.mbi FROM (SELECT mbi, MAX(effective_date) as max_date FROM suppressions WHERE effective_date >= $1 AND effective_date <= $2 AND preference_indicator <> $3 GROUP BY mbi) AS h JOIN suppressions s ON s.mbi = h.mbi AND s.effective_date = h.max_date WHERE preference_indicator = $4`,
-			lowerBound,
-			upperBound,
+			"NoSuppression",
+			regexp.QuoteMeta(`SELECT id, file_id, mbi, blue_button_id, false, false FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi) AND id > $2 ORDER BY id LIMIT 3`),
 			nil,
+			3,
+			13,
 		},
 		{
-			"ErrorOnQuery",
-			`SELECT DISTINCT s.mbi FROM (SELECT mbi, MAX(effective_date) as max_date FROM suppressions WHERE effective_date >= $1 AND effective_date <= $2 AND preference_indicator <> $3 GROUP BY mbi) AS h JOIN suppressions s ON s.mbi = h.mbi AND s.effective_date = h.max_date WHERE preference_indicator = $4`,
-			time.Time{}.Add(-1 * 10 * 24 * time.Hour),
-			time.Time{},
-			fmt.Errorf("Some SQL error"),
+			"SuppressionLastPage",
+			`(?s)` + regexp.QuoteMeta(`SELECT b.id, b.file_id, b.mbi, b.blue_button_id, sp.opt_out_mbi IS NOT NULL, sp.samhsa_mbi IS NOT NULL FROM cclf_beneficiaries b JOIN (WITH RECURSIVE benes AS (`) +
+				`\s*` + regexp.QuoteMeta(`SELECT id, mbi FROM cclf_beneficiaries WHERE id IN (SELECT MAX(id) FROM cclf_beneficiaries WHERE file_id = $1 GROUP BY mbi) AND id > $2 ORDER BY id LIMIT $3`) +
+				`.*` + regexp.QuoteMeta(`) AS sp ON sp.bene_id = b.id ORDER BY b.id`),
+			suppression,
+			4,
+			0,
 		},
 	}
 
 	for _, tt := range tests {
 		r.T().Run(tt.name, func(t *testing.T) {
-			suppressedMBIs := []string{"0", "1", "2"}
+			cclfFileID, afterID := uint(rand.Int63()), uint(10)
+
 			db, mock, err := sqlmock.New()
 			assert.NoError(t, err)
 			defer func() {
@@ -324,27 +389,87 @@ func (r *RepositoryTestSuite) TestGetSuppressedMBIs() {
 			}()
 			repository := postgres.NewRepository(db)
 
-			args := []driver.Value{tt.lowerBound, tt.upperBound, "", "N"}
-			query := mock.ExpectQuery(fmt.Sprintf("^%s$", regexp.QuoteMeta(tt.expQueryRegex))).
-				WithArgs(args...)
+			args := []driver.Value{cclfFileID, afterID}
+			if tt.suppression != nil {
+				args = append(args, tt.limit)
+				args = append(args, suppressionArgs(cclfFileID, *tt.suppression)[1:]...)
+			}
+			mock.ExpectQuery(fmt.Sprintf("^%s$", tt.expQueryRegex)).WithArgs(args...).
+				WillReturnRows(beneficiaryRows([]*models.CCLFBeneficiary{bene1, bene2}, []*models.CCLFBeneficiary{optedOut}))
+
+			// The cursor advances past the opted out beneficiary found at the end of the page
+			result, cursor, err := repository.GetCCLFBeneficiariesAfter(context.Background(), cclfFileID, tt.suppression, afterID, tt.limit)
+			assert.NoError(t, err)
+			assert.Equal(t, []*models.CCLFBeneficiary{bene1, bene2}, result)
+			assert.Equal(t, tt.expectedCursor, cursor)
+		})
+	}
+
+	_, _, err := r.repository.GetCCLFBeneficiariesAfter(context.Background(), 1, nil, 0, 0)
+	r.EqualError(err, "invalid limit 0, must be greater than zero")
+}
+
+func (r *RepositoryTestSuite) TestGetBeneficiarySuppressions() {
+	expQueryRegex := `(?s)^` + regexp.QuoteMeta(`SELECT bene_id, mbi, opt_out_file_id, opt_out_mbi, opt_out_date, opt_out_aco_cms_id, `+
+		`samhsa_file_id, samhsa_mbi, samhsa_date, samhsa_aco_cms_id FROM (WITH RECURSIVE benes AS (`) +
+		`.*` + regexp.QuoteMeta(`) AS sp WHERE (opt_out_mbi IS NOT NULL OR samhsa_mbi IS NOT NULL) ORDER BY mbi`) + `$`
+	suppression := models.SuppressionCriteria{CMSID: "A0000",
+		LowerBound: time.Now().Add(-24 * time.Hour).Round(time.Millisecond), UpperBound: time.Now().Round(time.Millisecond)}
+	effectiveDt := time.Now().Add(-time.Hour).Round(time.Millisecond)
+
+	tests := []struct {
+		name        string
+		errToReturn error
+	}{
+		{"HappyPath", nil},
+		{"ErrorOnQuery", fmt.Errorf("Some SQL error")},
+	}
+
+	for _, tt := range tests {
+		r.T().Run(tt.name, func(t *testing.T) {
+			cclfFileID := uint(rand.Int63())
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer func() {
+				assert.NoError(t, mock.ExpectationsWereMet())
+				db.Close()
+			}()
+			repository := postgres.NewRepository(db)
+
+			query := mock.ExpectQuery(expQueryRegex).WithArgs(suppressionArgs(cclfFileID, suppression)...)
 			if tt.errToReturn == nil {
-				rows := sqlmock.NewRows([]string{"mbi"})
-				for _, mbi := range suppressedMBIs {
-					rows.AddRow(mbi)
-				}
+				rows := sqlmock.NewRows([]string{"bene_id", "mbi",
+					"opt_out_file_id", "opt_out_mbi", "opt_out_date", "opt_out_aco_cms_id",
+					"samhsa_file_id", "samhsa_mbi", "samhsa_date", "samhsa_aco_cms_id"})
+				// Opted out of sharing all data with the ACO using a previously issued MBI
+				rows.AddRow(1, "MBI1", 10, "MBI0", effectiveDt, "A0000", nil, nil, nil, nil)
+				// Opted out of sharing SAMHSA claims with every ACO
+				rows.AddRow(2, "MBI2", nil, nil, nil, nil, 11, "MBI2", effectiveDt, "")
+				// Opted out of both
+				rows.AddRow(3, "MBI3", 10, "MBI3", effectiveDt, "", 10, "MBI3", effectiveDt, "")
 				query.WillReturnRows(rows)
 			} else {
 				query.WillReturnError(tt.errToReturn)
 			}
 
-			result, err := repository.GetSuppressedMBIs(context.Background(), lookbackDays, tt.upperBound)
-			if tt.errToReturn == nil {
-				assert.NoError(t, err)
-				assert.Equal(t, suppressedMBIs, result)
-			} else {
+			result, err := repository.GetBeneficiarySuppressions(context.Background(), cclfFileID, suppression)
+			if tt.errToReturn != nil {
 				assert.Error(t, err)
 				assert.Nil(t, result)
+				return
 			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, []*models.BeneficiarySuppression{
+				{BeneficiaryID: 1, MBI: "MBI1", Reason: models.SuppressionReasonOptOut, SuppressionMBI: "MBI0",
+					SuppressionFileID: 10, EffectiveDt: effectiveDt, ACOCMSID: "A0000"},
+				{BeneficiaryID: 2, MBI: "MBI2", Reason: models.SuppressionReasonSAMHSA, SuppressionMBI: "MBI2",
+					SuppressionFileID: 11, EffectiveDt: effectiveDt},
+				{BeneficiaryID: 3, MBI: "MBI3", Reason: models.SuppressionReasonOptOut, SuppressionMBI: "MBI3",
+					SuppressionFileID: 10, EffectiveDt: effectiveDt},
+				{BeneficiaryID: 3, MBI: "MBI3", Reason: models.SuppressionReasonSAMHSA, SuppressionMBI: "MBI3",
+					SuppressionFileID: 10, EffectiveDt: effectiveDt},
+			}, result)
 		})
 	}
 }
@@ -484,6 +609,148 @@ func (r *RepositoryTestSuite) TestACOMethods() {
 	assert.Contains(r.repository.CreateACO(ctx, aco).Error(), "duplicate key value violates unique constraint \"acos_cms_id_key\"")
 }
 
+// TestCredentialMethods validates the CRUD operations associated with the credentials table
+func (r *RepositoryTestSuite) TestCredentialMethods() {
+	assert := r.Assert()
+	ctx := context.Background()
+
+	cmsID := testUtils.RandomHexID()[0:4]
+	credential := models.Credential{ClientID: uuid.New(), SystemID: uuid.New(), ACOCMSID: cmsID}
+	other := models.Credential{ClientID: uuid.New(), ACOCMSID: cmsID}
+	defer func() {
+		_, err := r.db.Exec("DELETE FROM credentials WHERE aco_cms_id = $1", cmsID)
+		assert.NoError(err)
+	}()
+
+	assert.NoError(r.repository.SaveCredential(ctx, credential))
+	assert.NoError(r.repository.SaveCredential(ctx, other))
+
+	issuedAt := time.Now().UTC().Round(time.Millisecond)
+	assert.NoError(r.repository.RecordTokenIssued(ctx, credential.ClientID, issuedAt))
+	// Tokens issued before the last recorded token do not move the time back
+	assert.NoError(r.repository.RecordTokenIssued(ctx, credential.ClientID, issuedAt.Add(-time.Hour)))
+	assert.NoError(r.repository.RevokeCredentialBySystemID(ctx, credential.SystemID))
+	assert.NoError(r.repository.RevokeCredentialByClientID(ctx, other.ClientID))
+
+	credentials, err := r.repository.GetCredentials(ctx, cmsID)
+	assert.NoError(err)
+	assert.Len(credentials, 2)
+	for _, c := range credentials {
+		assert.False(c.RevokedAt.IsZero())
+		assert.False(c.CreatedAt.IsZero())
+		if c.ClientID == credential.ClientID {
+			assert.Equal(credential.SystemID, c.SystemID)
+			assert.True(issuedAt.Equal(c.LastTokenIssuedAt.UTC()))
+		} else {
+			assert.True(c.LastTokenIssuedAt.IsZero())
+		}
+	}
+
+	// Re-saving a credential restarts its lifecycle
+	assert.NoError(r.repository.SaveCredential(ctx, credential))
+	credentials, err = r.repository.GetCredentials(ctx, cmsID)
+	assert.NoError(err)
+	for _, c := range credentials {
+		if c.ClientID == credential.ClientID {
+			assert.True(c.RevokedAt.IsZero())
+			assert.True(c.LastTokenIssuedAt.IsZero())
+		}
+	}
+
+	c, err := r.repository.GetCredentialByClientID(ctx, other.ClientID)
+	assert.NoError(err)
+	assert.Equal(cmsID, c.ACOCMSID)
+	assert.False(c.RevokedAt.IsZero())
+
+	// Negative cases
+	c, err = r.repository.GetCredentialByClientID(ctx, uuid.New())
+	assert.NoError(err)
+	assert.Nil(c)
+	assert.EqualError(r.repository.RevokeCredentialByClientID(ctx, other.ClientID),
+		fmt.Sprintf("credential %s not updated, no row found", other.ClientID))
+	unknown := uuid.New()
+	assert.EqualError(r.repository.RecordTokenIssued(ctx, unknown, time.Now()),
+		fmt.Sprintf("credential %s not updated, no row found", unknown))
+}
+
+// TestAuthLockoutMethods validates the CRUD operations associated with the auth_lockouts table
+func (r *RepositoryTestSuite) TestAuthLockoutMethods() {
+	assert := r.Assert()
+	ctx := context.Background()
+
+	subject := uuid.New()
+	lockout, err := r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.Nil(lockout)
+
+	assert.NoError(r.repository.SaveAuthLockout(ctx,
+		models.AuthLockout{Type: models.LockoutClientID, Subject: subject, FailedAttempts: 1}))
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.Equal(1, lockout.FailedAttempts)
+	assert.True(lockout.LockedUntil.IsZero())
+	assert.False(lockout.UpdatedAt.IsZero())
+
+	// Same subject with a different type is tracked separately
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutIPAddress, subject)
+	assert.NoError(err)
+	assert.Nil(lockout)
+
+	lockedUntil := time.Now().Add(time.Hour).UTC().Round(time.Millisecond)
+	assert.NoError(r.repository.SaveAuthLockout(ctx,
+		models.AuthLockout{Type: models.LockoutClientID, Subject: subject, FailedAttempts: 2, LockedUntil: lockedUntil}))
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.Equal(2, lockout.FailedAttempts)
+	assert.True(lockedUntil.Equal(lockout.LockedUntil))
+
+	// Failed attempts are incremented atomically, even when requests race
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.repository.IncrementAuthLockout(ctx, models.LockoutClientID, subject, time.Now().Add(-time.Hour))
+			assert.NoError(err)
+		}()
+	}
+	wg.Wait()
+	failedAttempts, err := r.repository.IncrementAuthLockout(ctx, models.LockoutClientID, subject, time.Now().Add(-time.Hour))
+	assert.NoError(err)
+	assert.Equal(8, failedAttempts)
+
+	// A shorter lockout does not replace a longer one
+	assert.NoError(r.repository.LockAuthLockout(ctx, models.LockoutClientID, subject, time.Now().Add(time.Minute)))
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.True(lockedUntil.Equal(lockout.LockedUntil))
+
+	// Stale failed attempts are forgotten, along with the lockout
+	failedAttempts, err = r.repository.IncrementAuthLockout(ctx, models.LockoutClientID, subject, time.Now().Add(time.Hour))
+	assert.NoError(err)
+	assert.Equal(1, failedAttempts)
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.True(lockout.LockedUntil.IsZero())
+
+	// A new subject starts with a single failed attempt
+	otherSubject := uuid.New()
+	failedAttempts, err = r.repository.IncrementAuthLockout(ctx, models.LockoutIPAddress, otherSubject, time.Now())
+	assert.NoError(err)
+	assert.Equal(1, failedAttempts)
+	assert.NoError(r.repository.DeleteAuthLockout(ctx, models.LockoutIPAddress, otherSubject))
+	assert.EqualError(r.repository.LockAuthLockout(ctx, models.LockoutIPAddress, otherSubject, time.Now()),
+		fmt.Sprintf("no lockout found for ip_address %s", otherSubject))
+
+	assert.NoError(r.repository.DeleteAuthLockout(ctx, models.LockoutClientID, subject))
+	lockout, err = r.repository.GetAuthLockout(ctx, models.LockoutClientID, subject)
+	assert.NoError(err)
+	assert.Nil(lockout)
+
+	assert.EqualError(r.repository.DeleteAuthLockout(ctx, models.LockoutClientID, subject),
+		fmt.Sprintf("no lockout found for client_id %s", subject))
+}
+
 // TestCCLFFilesMethods validates the CRUD operations associated with the cclf_files table
 func (r *RepositoryTestSuite) TestCCLFFilesMethods() {
 	var err error
@@ -567,62 +834,184 @@ func (r *RepositoryTestSuite) TestCCLFBeneficiariesMethods() {
 	assert.Contains(benes, bene1)
 	assert.Contains(benes, bene2)
 
-	// All benes excluded
-	benes, err = r.repository.GetCCLFBeneficiaries(ctx, cclfFile.ID, mbis)
+	// No suppressions recorded for the benes
+	suppression := &models.SuppressionCriteria{CMSID: cclfFile.ACOCMSID, LowerBound: time.Now().Add(-24 * time.Hour), UpperBound: time.Now()}
+	benes, err = r.repository.GetCCLFBeneficiaries(ctx, cclfFile.ID, suppression)
 	assert.NoError(err)
-	assert.Len(benes, 0)
+	assert.Len(benes, 2)
+	assert.Contains(benes, bene1)
+	assert.Contains(benes, bene2)
+
+	// Page through the benes one at a time
+	var paged []*models.CCLFBeneficiary
+	for cursor, pages := uint(0), 0; ; pages++ {
+		assert.True(pages <= 2, "expected to exhaust the file in three pages")
+		var page []*models.CCLFBeneficiary
+		page, cursor, err = r.repository.GetCCLFBeneficiariesAfter(ctx, cclfFile.ID, suppression, cursor, 1)
+		assert.NoError(err)
+		paged = append(paged, page...)
+		if cursor == 0 || pages > 2 {
+			break
+		}
+	}
+	assert.Len(paged, 2)
+	assert.Contains(paged, bene1)
+	assert.Contains(paged, bene2)
 
 	// Negative cases
 	mbis, err = r.repository.GetCCLFBeneficiaryMBIs(ctx, 0)
 	assert.NoError(err)
 	assert.Len(mbis, 0)
 
-	benes, err = r.repository.GetCCLFBeneficiaries(ctx, 0, mbis)
+	benes, err = r.repository.GetCCLFBeneficiaries(ctx, 0, nil)
+	assert.NoError(err)
+	assert.Len(benes, 0)
+
+	benes, err = r.repository.GetCCLFBeneficiaries(ctx, 0, suppression)
 	assert.NoError(err)
 	assert.Len(benes, 0)
 }
 
+// TestGetMBIXrefs validates that only MBI crosswalk entries from completed CCLF9 files for the ACO are returned
+func (r *RepositoryTestSuite) TestGetMBIXrefs() {
+	ctx := context.Background()
+	assert := r.Assert()
+
+	cmsID := testUtils.RandomHexID()[0:4]
+	completed := &models.CCLFFile{CCLFNum: 9, ACOCMSID: cmsID, Timestamp: time.Now(), PerformanceYear: 19, Name: uuid.New(),
+		ImportStatus: constants.ImportComplete}
+	failed := &models.CCLFFile{CCLFNum: 9, ACOCMSID: cmsID, Timestamp: time.Now(), PerformanceYear: 19, Name: uuid.New(),
+		ImportStatus: constants.ImportFail}
+	postgrestest.CreateCCLFFile(r.T(), r.db, completed)
+	postgrestest.CreateCCLFFile(r.T(), r.db, failed)
+	defer postgrestest.DeleteCCLFFilesByCMSID(r.T(), r.db, cmsID)
+
+	mbiXref := &models.CCLFBeneficiaryXref{FileID: completed.ID, XrefIndicator: models.XrefIndicatorMBI,
+		CurrentNum: testUtils.RandomMBI(r.T()), PrevNum: testUtils.RandomMBI(r.T()),
+		PrevsEfctDt: "2019-01-01", PrevsObsltDt: "2020-01-01"}
+	hicnXref := &models.CCLFBeneficiaryXref{FileID: completed.ID, XrefIndicator: "H",
+		CurrentNum: testUtils.RandomHexID()[0:11], PrevNum: testUtils.RandomHexID()[0:11]}
+	failedXref := &models.CCLFBeneficiaryXref{FileID: failed.ID, XrefIndicator: models.XrefIndicatorMBI,
+		CurrentNum: testUtils.RandomMBI(r.T()), PrevNum: testUtils.RandomMBI(r.T())}
+	for _, xref := range []*models.CCLFBeneficiaryXref{mbiXref, hicnXref, failedXref} {
+		postgrestest.CreateCCLFBeneficiaryXref(r.T(), r.db, xref)
+	}
+
+	xrefs, err := r.repository.GetMBIXrefs(ctx, cmsID)
+	assert.NoError(err)
+	assert.Equal([]*models.CCLFBeneficiaryXref{mbiXref}, xrefs)
+
+	xrefs, err = r.repository.GetMBIXrefs(ctx, "UNKNOWN")
+	assert.NoError(err)
+	assert.Len(xrefs, 0)
+}
+
 // TestSuppressionsMethods validates the CRUD operations associated with the suppressions table
+// along with how suppressions are applied to an ACO's beneficiaries
 func (r *RepositoryTestSuite) TestSuppresionsMethods() {
 	ctx := context.Background()
 	assert := r.Assert()
-	fileID := uint(rand.Int31())
-	upperBound := time.Now().Add(-30 * time.Minute)
-	// Effective date is too old
-	tooOld := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "N",
-		EffectiveDt: time.Now().Add(-365 * 24 * time.Hour)}
-	// Effective date is after the upper bound
-	tooNew := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "N",
-		EffectiveDt: time.Now()}
-	// Mismatching preference indicators
-	mismatch1 := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "Y",
-		EffectiveDt: time.Now().Add(-time.Hour)}
-	mismatch2 := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "",
-		EffectiveDt: time.Now().Add(-time.Hour)}
-	suppressed1 := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "N",
-		EffectiveDt: time.Now().Add(-time.Hour)}
-	suppressed2 := models.Suppression{FileID: fileID, MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "N",
-		EffectiveDt: time.Now().Add(-time.Hour)}
-
-	assert.NoError(r.repository.CreateSuppression(ctx, tooOld))
-	assert.NoError(r.repository.CreateSuppression(ctx, tooNew))
-	assert.NoError(r.repository.CreateSuppression(ctx, mismatch1))
-	assert.NoError(r.repository.CreateSuppression(ctx, mismatch2))
-	assert.NoError(r.repository.CreateSuppression(ctx, suppressed1))
-	assert.NoError(r.repository.CreateSuppression(ctx, suppressed2))
 
+	cmsID := testUtils.RandomHexID()[0:4]
+	cclf8 := &models.CCLFFile{CCLFNum: 8, ACOCMSID: cmsID, Timestamp: time.Now(), PerformanceYear: 19, Name: uuid.New(),
+		ImportStatus: constants.ImportComplete}
+	cclf9 := &models.CCLFFile{CCLFNum: 9, ACOCMSID: cmsID, Timestamp: time.Now(), PerformanceYear: 19, Name: uuid.New(),
+		ImportStatus: constants.ImportComplete}
+	postgrestest.CreateCCLFFile(r.T(), r.db, cclf8)
+	postgrestest.CreateCCLFFile(r.T(), r.db, cclf9)
+	defer postgrestest.DeleteCCLFFilesByCMSID(r.T(), r.db, cmsID)
+
+	newBene := func() *models.CCLFBeneficiary {
+		bene := &models.CCLFBeneficiary{FileID: cclf8.ID, MBI: testUtils.RandomMBI(r.T())}
+		postgrestest.CreateCCLFBeneficiary(r.T(), r.db, bene)
+		return bene
+	}
+	var (
+		tooOld, tooNew, mismatch, otherACO, optedIn = newBene(), newBene(), newBene(), newBene(), newBene()
+		suppressed, suppressedForACO, prevMBI        = newBene(), newBene(), newBene()
+		linkKey, samhsa                              = newBene(), newBene()
+	)
+
+	// prevMBI's beneficiary recorded their preference using an MBI they were previously issued
+	previous := testUtils.RandomMBI(r.T())
+	postgrestest.CreateCCLFBeneficiaryXref(r.T(), r.db, &models.CCLFBeneficiaryXref{FileID: cclf9.ID,
+		XrefIndicator: models.XrefIndicatorMBI, CurrentNum: prevMBI.MBI, PrevNum: previous})
+
+	fileID, beneLinkKey := uint(rand.Int31()), int(rand.Int31())
 	defer postgrestest.DeleteSuppressionFileByID(r.T(), r.db, fileID)
+	recent, older := time.Now().Add(-time.Hour).Round(time.Millisecond), time.Now().Add(-2*time.Hour).Round(time.Millisecond)
+	for _, suppression := range []models.Suppression{
+		// Effective date is too old
+		{MBI: tooOld.MBI, PrefIndicator: "N", EffectiveDt: time.Now().Add(-365 * 24 * time.Hour)},
+		// Effective date is after the upper bound
+		{MBI: tooNew.MBI, PrefIndicator: "N", EffectiveDt: time.Now()},
+		// Mismatching preference indicator
+		{MBI: mismatch.MBI, PrefIndicator: "", EffectiveDt: recent},
+		// Recorded for another ACO
+		{MBI: otherACO.MBI, PrefIndicator: "N", EffectiveDt: recent, ACOCMSID: "Z9999"},
+		// Latest preference shares the data
+		{MBI: optedIn.MBI, PrefIndicator: "N", EffectiveDt: older},
+		{MBI: optedIn.MBI, PrefIndicator: "Y", EffectiveDt: recent},
+		{MBI: suppressed.MBI, PrefIndicator: "N", EffectiveDt: recent},
+		{MBI: suppressedForACO.MBI, PrefIndicator: "N", EffectiveDt: recent, ACOCMSID: cmsID},
+		{MBI: previous, PrefIndicator: "N", EffectiveDt: recent},
+		// The latest preference is linked to linkKey's beneficiary only through the beneficiary link key
+		{MBI: linkKey.MBI, PrefIndicator: "Y", EffectiveDt: older, BeneficiaryLinkKey: beneLinkKey},
+		{MBI: testUtils.RandomMBI(r.T()), PrefIndicator: "N", EffectiveDt: recent, BeneficiaryLinkKey: beneLinkKey},
+		{MBI: samhsa.MBI, PrefIndicator: "Y", EffectiveDt: recent, SAMHSAPrefIndicator: "N", SAMHSAEffectiveDt: recent},
+	} {
+		suppression.FileID = fileID
+		assert.NoError(r.repository.CreateSuppression(ctx, suppression))
+	}
+
+	criteria := models.SuppressionCriteria{CMSID: cmsID,
+		LowerBound: time.Now().Add(-10 * 24 * time.Hour), UpperBound: time.Now().Add(-30 * time.Minute)}
 
-	mbis, err := r.repository.GetSuppressedMBIs(ctx, 10, upperBound)
+	benes, err := r.repository.GetCCLFBeneficiaries(ctx, cclf8.ID, &criteria)
 	assert.NoError(err)
+	samhsaSuppressed := make(map[string]bool)
+	for _, bene := range benes {
+		samhsaSuppressed[bene.MBI] = bene.SAMHSASuppressed
+	}
+	assert.Equal(map[string]bool{tooOld.MBI: false, tooNew.MBI: false, mismatch.MBI: false, otherACO.MBI: false,
+		optedIn.MBI: false, samhsa.MBI: true}, samhsaSuppressed)
+
+	// Paging through the file applies the same suppressions, even when every bene on a page is suppressed
+	var paged []*models.CCLFBeneficiary
+	for cursor := uint(0); ; {
+		var page []*models.CCLFBeneficiary
+		page, cursor, err = r.repository.GetCCLFBeneficiariesAfter(ctx, cclf8.ID, &criteria, cursor, 2)
+		assert.NoError(err)
+		paged = append(paged, page...)
+		if cursor == 0 || err != nil {
+			break
+		}
+	}
+	assert.ElementsMatch(benes, paged)
 
-	// Since we have other data seeded in this table, we cannot do a len check on the MBIs
-	assert.Contains(mbis, suppressed1.MBI)
-	assert.Contains(mbis, suppressed2.MBI)
-	assert.NotContains(mbis, tooOld.MBI)
-	assert.NotContains(mbis, tooNew.MBI)
-	assert.NotContains(mbis, mismatch1.MBI)
-	assert.NotContains(mbis, mismatch2.MBI)
+	suppressions, err := r.repository.GetBeneficiarySuppressions(ctx, cclf8.ID, criteria)
+	assert.NoError(err)
+	reasons := make(map[string]models.SuppressionReason)
+	for _, s := range suppressions {
+		assert.Equal(fileID, s.SuppressionFileID)
+		reasons[s.MBI] = s.Reason
+		switch s.MBI {
+		case prevMBI.MBI:
+			assert.Equal(previous, s.SuppressionMBI)
+		case suppressedForACO.MBI:
+			assert.Equal(cmsID, s.ACOCMSID)
+		case suppressed.MBI:
+			assert.Equal("", s.ACOCMSID)
+			assert.Equal(recent.UTC(), s.EffectiveDt.UTC())
+		}
+	}
+	assert.Equal(map[string]models.SuppressionReason{
+		suppressed.MBI:       models.SuppressionReasonOptOut,
+		suppressedForACO.MBI: models.SuppressionReasonOptOut,
+		prevMBI.MBI:          models.SuppressionReasonOptOut,
+		linkKey.MBI:          models.SuppressionReasonOptOut,
+		samhsa.MBI:           models.SuppressionReasonSAMHSA,
+	}, reasons)
 }
 
 // TestSuppressionFilesMethods validates the CRUD operations associated with the suppression_files table
@@ -771,9 +1160,29 @@ func (r *RepositoryTestSuite) TestJobsMethods() {
 	assert.Equal(models.JobStatusCompleted, newCompleted.Status)
 	assert.True(newFailed.UpdatedAt.After(newCompleted.UpdatedAt))
 
+	// Targeted updates only modify the requested field
+	assert.NoError(r.repository.UpdateJobCount(ctx, pending.ID, 50))
+	assert.NoError(r.repository.UpdateJobStatus(ctx, pending.ID, models.JobStatusFailed))
+	newPending, err := r.repository.GetJobByID(ctx, pending.ID)
+	assert.NoError(err)
+	assert.Equal(models.JobStatusFailed, newPending.Status)
+	assert.Equal(50, newPending.JobCount)
+	assert.Equal(pending.CompletedJobCount, newPending.CompletedJobCount)
+	assert.Equal(pending.RequestURL, newPending.RequestURL)
+
+	// Conditional updates only apply when the job still has the expected status
+	assert.EqualError(r.repository.UpdateJobStatusCheckStatus(ctx, pending.ID, models.JobStatusInProgress, models.JobStatusCompleted),
+		"expected to affect 1 row, affected 0")
+	assert.NoError(r.repository.UpdateJobStatusCheckStatus(ctx, pending.ID, models.JobStatusFailed, models.JobStatusCancelled))
+	newPending, err = r.repository.GetJobByID(ctx, pending.ID)
+	assert.NoError(err)
+	assert.Equal(models.JobStatusCancelled, newPending.Status)
+
 	// Negative cases
 	notExists := models.Job{ACOID: aco.UUID, RequestURL: reqURL, Status: models.JobStatusCompleted}
 	assert.EqualError(r.repository.UpdateJob(ctx, notExists), "expected to affect 1 row, affected 0")
+	assert.EqualError(r.repository.UpdateJobCount(ctx, 0, 1), "expected to affect 1 row, affected 0")
+	assert.EqualError(r.repository.UpdateJobStatus(ctx, 0, models.JobStatusFailed), "expected to affect 1 row, affected 0")
 }
 
 // TestJobKeysMethods validates the CRUD operations associated with the job_keys table
@@ -970,6 +1379,25 @@ func getCCLFFile(cclfNum int, cmsID, importStatus string, fileType models.CCLFFi
 	}
 }
 
+// suppressionArgs returns the arguments supplied to the query used to evaluate beneficiary suppressions
+func suppressionArgs(cclfFileID uint, suppression models.SuppressionCriteria) []driver.Value {
+	return []driver.Value{cclfFileID, suppression.CMSID, constants.ImportComplete, models.XrefIndicatorMBI,
+		suppression.CMSID, suppression.CMSID,
+		suppression.LowerBound, suppression.UpperBound, suppression.LowerBound, suppression.UpperBound}
+}
+
+// beneficiaryRows returns the beneficiaries followed by the beneficiaries that opted out of data sharing
+func beneficiaryRows(benes, optedOut []*models.CCLFBeneficiary) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "file_id", "mbi", "blue_button_id", "opted_out", "samhsa_suppressed"})
+	for _, bene := range benes {
+		rows.AddRow(bene.ID, bene.FileID, bene.MBI, bene.BlueButtonID, false, bene.SAMHSASuppressed)
+	}
+	for _, bene := range optedOut {
+		rows.AddRow(bene.ID, bene.FileID, bene.MBI, bene.BlueButtonID, true, bene.SAMHSASuppressed)
+	}
+	return rows
+}
+
 func getCCLFBeneficiary() *models.CCLFBeneficiary {
 	return &models.CCLFBeneficiary{
 		ID:           uint(rand.Int63()),
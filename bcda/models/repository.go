@@ -7,15 +7,28 @@ import (
 	"github.com/pborman/uuid"
 )
 
+// NotFoundError is returned when the requested record does not exist
+type NotFoundError struct {
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
 // Repository contains all of the CRUD methods represented in the models package from the storage layer
 type Repository interface {
 	acoRepository
+	credentialRepository
+	authLockoutRepository
 	cclfFileRepository
 	cclfBeneficiaryRepository
 	suppressionRepository
 	suppressionFileRepository
 	jobRepository
 	jobKeyRepository
+	plannedQueueJobRepository
+	deadLetterQueueJobRepository
 }
 
 type acoRepository interface {
@@ -33,12 +46,53 @@ type acoRepository interface {
 	UpdateACO(ctx context.Context, acoUUID uuid.UUID, fieldsAndValues map[string]interface{}) error
 }
 
+type credentialRepository interface {
+	// SaveCredential creates the credential identified by its client ID. If the credential already exists,
+	// it is reset to an active credential with no token history.
+	SaveCredential(ctx context.Context, credential Credential) error
+
+	// GetCredentials returns all of the credentials that were issued to the ACO identified by cmsID.
+	GetCredentials(ctx context.Context, cmsID string) ([]*Credential, error)
+
+	// GetCredentialByClientID returns the credential identified by clientID, or nil if there is no such credential.
+	GetCredentialByClientID(ctx context.Context, clientID string) (*Credential, error)
+
+	// RecordTokenIssued records the time a token was issued for the credential, ignoring tokens issued
+	// before the last one recorded.
+	RecordTokenIssued(ctx context.Context, clientID string, issuedAt time.Time) error
+
+	RevokeCredentialByClientID(ctx context.Context, clientID string) error
+
+	RevokeCredentialBySystemID(ctx context.Context, systemID string) error
+}
+
+type authLockoutRepository interface {
+	// GetAuthLockout returns the lockout associated with the subject. If no lockout exists, nil is returned.
+	GetAuthLockout(ctx context.Context, lockoutType LockoutType, subject string) (*AuthLockout, error)
+
+	// SaveAuthLockout creates or updates the lockout associated with the lockout's type and subject.
+	SaveAuthLockout(ctx context.Context, lockout AuthLockout) error
+
+	// IncrementAuthLockout atomically increments the failed attempts of the subject, creating the lockout if needed,
+	// and returns the failed attempts after the increment. A lockout last updated before resetBefore starts over.
+	IncrementAuthLockout(ctx context.Context, lockoutType LockoutType, subject string, resetBefore time.Time) (int, error)
+
+	// LockAuthLockout locks out the subject until the given time, unless it is already locked out for longer.
+	LockAuthLockout(ctx context.Context, lockoutType LockoutType, subject string, until time.Time) error
+
+	DeleteAuthLockout(ctx context.Context, lockoutType LockoutType, subject string) error
+}
+
 type cclfFileRepository interface {
 	// GetLatest returns the latest CCLF File (most recent timestamp) that matches the search criteria.
 	// The returned CCLF file will fall between the provided time window.
 	// If any of the time values equals time.Time (default value), then the time value IS NOT used in the filtering.
 	GetLatestCCLFFile(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound, upperBound time.Time, fileType CCLFFileType) (*CCLFFile, error)
 
+	// GetLatestCCLFFileForPerformanceYear behaves like GetLatestCCLFFile, but only considers CCLF files for the performance year.
+	// The performance year uses the two digit format found in the CCLF file name (e.g. 20 for 2020).
+	GetLatestCCLFFileForPerformanceYear(ctx context.Context, cmsID string, cclfNum int, importStatus string, lowerBound, upperBound time.Time, fileType CCLFFileType, performanceYear int) (*CCLFFile, error)
+
 	// CreateCCLFFile creates a CCLFFile and returns the unique ID associated with the newly created CCLF file
 	CreateCCLFFile(ctx context.Context, cclfFile CCLFFile) (uint, error)
 
@@ -49,11 +103,28 @@ type cclfFileRepository interface {
 type cclfBeneficiaryRepository interface {
 	GetCCLFBeneficiaryMBIs(ctx context.Context, cclfFileID uint) ([]string, error)
 
-	GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, ignoredMBIs []string) ([]*CCLFBeneficiary, error)
+	// GetCCLFBeneficiaryMBIsIn returns the subset of the supplied MBIs that are found in the CCLF file.
+	GetCCLFBeneficiaryMBIsIn(ctx context.Context, cclfFileID uint, mbis []string) ([]string, error)
+
+	// GetCCLFBeneficiaries returns the beneficiaries found in the CCLF file.
+	// When suppression is supplied, beneficiaries that opted out of sharing their data with the ACO are excluded
+	// and the beneficiaries that opted out of sharing their SAMHSA claims are flagged.
+	GetCCLFBeneficiaries(ctx context.Context, cclfFileID uint, suppression *SuppressionCriteria) ([]*CCLFBeneficiary, error)
+
+	// GetCCLFBeneficiariesAfter returns the next page of beneficiaries found in the CCLF file, ordered by ID.
+	// Up to limit beneficiaries whose ID is greater than afterID are examined; suppressed beneficiaries are
+	// excluded in the same manner as GetCCLFBeneficiaries so a page may contain fewer than limit beneficiaries.
+	// The returned cursor is the afterID for the next page. It is zero once the file has been exhausted.
+	GetCCLFBeneficiariesAfter(ctx context.Context, cclfFileID uint, suppression *SuppressionCriteria, afterID uint, limit int) (benes []*CCLFBeneficiary, cursor uint, err error)
+
+	// GetMBIXrefs returns the MBI crosswalk entries found in the successfully imported CCLF9 files for the ACO.
+	GetMBIXrefs(ctx context.Context, cmsID string) ([]*CCLFBeneficiaryXref, error)
 }
 
 type suppressionRepository interface {
-	GetSuppressedMBIs(ctx context.Context, lookbackDays int, upperBound time.Time) ([]string, error)
+	// GetBeneficiarySuppressions returns the reasons data is withheld for the beneficiaries found in the CCLF file.
+	// A beneficiary's latest preference applies, regardless of which of their MBIs it was recorded against.
+	GetBeneficiarySuppressions(ctx context.Context, cclfFileID uint, suppression SuppressionCriteria) ([]*BeneficiarySuppression, error)
 
 	CreateSuppression(ctx context.Context, suppression Suppression) error
 }
@@ -75,8 +146,35 @@ type jobRepository interface {
 	GetJobByID(ctx context.Context, jobID uint) (*Job, error)
 
 	UpdateJob(ctx context.Context, j Job) error
+
+	// UpdateJobCount sets the number of queue jobs needed to complete the job without modifying any other fields.
+	UpdateJobCount(ctx context.Context, jobID uint, jobCount int) error
+
+	// UpdateJobStatus sets the status of the job without modifying any other fields.
+	UpdateJobStatus(ctx context.Context, jobID uint, status JobStatus) error
+
+	// UpdateJobStatusCheckStatus sets the status of the job iff the job's status is still current.
+	UpdateJobStatusCheckStatus(ctx context.Context, jobID uint, current, new JobStatus) error
 }
 
 type jobKeyRepository interface {
 	GetJobKeys(ctx context.Context, jobID uint) ([]*JobKey, error)
 }
+
+type deadLetterQueueJobRepository interface {
+	// GetDeadLetterQueueJobs returns the dead-lettered queue jobs of the job, or all of them when jobID is zero.
+	GetDeadLetterQueueJobs(ctx context.Context, jobID uint) ([]*DeadLetterQueueJob, error)
+
+	GetDeadLetterQueueJob(ctx context.Context, id uint) (*DeadLetterQueueJob, error)
+
+	// DeleteDeadLetterQueueJob deletes the dead-lettered queue job, updating the dead letter count of its job.
+	DeleteDeadLetterQueueJob(ctx context.Context, id uint) error
+}
+
+type plannedQueueJobRepository interface {
+	// CreatePlannedQueueJobs records the queue jobs planned for the job, setting the PlannedQueueJobID of each queue job.
+	CreatePlannedQueueJobs(ctx context.Context, jobID uint, jobs []*JobEnqueueArgs, priority int) error
+
+	// DeletePlannedQueueJobs deletes all of the job's planned queue jobs, they will never need to be re-enqueued.
+	DeletePlannedQueueJobs(ctx context.Context, jobID uint) error
+}
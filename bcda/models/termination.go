@@ -45,6 +45,45 @@ type Termination struct {
 	AttributionStrategy Attribution
 	OptOutStrategy      OptOut
 	ClaimsStrategy      Claims
+
+	// Resource types and groups a caller with limited access may request.
+	// An empty list places no restriction on the caller.
+	AllowedResourceTypes []string `json:",omitempty"`
+	AllowedGroups        []string `json:",omitempty"`
+}
+
+// IsLimited returns true if the caller has limited access to the service
+func (t *Termination) IsLimited() bool {
+	return t.BlacklistType == Limited
+}
+
+// CutoffPassed returns true if the caller's cutoff date has passed,
+// meaning the caller no longer has any access to the service.
+// A zero cutoff date indicates that the caller's access has no end.
+func (t *Termination) CutoffPassed(now time.Time) bool {
+	return !t.CutoffDate.IsZero() && now.After(t.CutoffDate)
+}
+
+// AllowsResourceType returns true if the caller may request the resource type
+func (t *Termination) AllowsResourceType(resourceType string) bool {
+	return allows(t.AllowedResourceTypes, resourceType)
+}
+
+// AllowsGroup returns true if the caller may request the group
+func (t *Termination) AllowsGroup(group string) bool {
+	return allows(t.AllowedGroups, group)
+}
+
+func allows(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
 }
 
 // AttributionDate returns the date that should be used for attribution
@@ -30,3 +30,31 @@ func TestTerminationDates(t *testing.T) {
 	assert.Equal(t, time.Time{}, termination.OptOutDate())
 	assert.Equal(t, time.Time{}, termination.ClaimsDate())
 }
+
+func TestTerminationLimitedAccess(t *testing.T) {
+	termination := &Termination{
+		TerminationDate: time.Now().Add(-time.Hour),
+		BlacklistType:   Limited,
+	}
+
+	assert.True(t, termination.IsLimited())
+	// No cutoff date means access never ends
+	assert.False(t, termination.CutoffPassed(time.Now()))
+	// No allowed lists means no restrictions
+	assert.True(t, termination.AllowsResourceType("Coverage"))
+	assert.True(t, termination.AllowsGroup("runout"))
+
+	termination.CutoffDate = time.Now()
+	termination.AllowedResourceTypes = []string{"Patient", "ExplanationOfBenefit"}
+	termination.AllowedGroups = []string{"runout"}
+
+	assert.True(t, termination.CutoffPassed(termination.CutoffDate.Add(time.Second)))
+	assert.False(t, termination.CutoffPassed(termination.CutoffDate.Add(-time.Second)))
+	assert.True(t, termination.AllowsResourceType("Patient"))
+	assert.False(t, termination.AllowsResourceType("Coverage"))
+	assert.True(t, termination.AllowsGroup("runout"))
+	assert.False(t, termination.AllowsGroup("all"))
+
+	termination.BlacklistType = Involuntary
+	assert.False(t, termination.IsLimited())
+}
@@ -1,6 +1,7 @@
 package responseutils
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -12,8 +13,15 @@ import (
 	fhircodes "github.com/google/fhir/go/proto/google/fhir/proto/stu3/codes_go_proto"
 	fhirdatatypes "github.com/google/fhir/go/proto/google/fhir/proto/stu3/datatypes_go_proto"
 	fhirmodels "github.com/google/fhir/go/proto/google/fhir/proto/stu3/resources_go_proto"
+
+	fhircodesr4 "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/codes_go_proto"
+	fhirdatatypesr4 "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/datatypes_go_proto"
+	fhirgroupr4 "github.com/google/fhir/go/proto/google/fhir/proto/r4/core/resources/group_go_proto"
 )
 
+// MBISystem identifies a Medicare Beneficiary Identifier
+const MBISystem = "http://hl7.org/fhir/sid/us-mbi"
+
 var marshaller *jsonformat.Marshaller
 
 func init() {
@@ -189,3 +197,127 @@ func WriteCapabilityStatement(statement *fhirmodels.CapabilityStatement, w http.
 		return
 	}
 }
+
+// GroupRoster holds the values of a Group resource listing the beneficiaries attributed to an ACO.
+// Members are identified by MBI since BCDA does not host Patient resources.
+type GroupRoster struct {
+	CMSID string
+	MBIs  []string
+	// Start and End bound the period the beneficiaries are attributed for
+	Start, End time.Time
+	// LastUpdated is the time the attribution was received
+	LastUpdated time.Time
+	// Active is false once the ACO's attribution is no longer being updated
+	Active bool
+}
+
+// period returns the (day precision) bounds of the members' attribution. The end never comes before
+// the start, e.g. for an ACO that was terminated before the performance year began.
+func (g GroupRoster) period() (startUs, endUs int64) {
+	start, end := g.Start.UTC(), g.End.UTC()
+	if end.Before(start) {
+		end = start
+	}
+	return start.UnixNano() / int64(time.Microsecond), end.UnixNano() / int64(time.Microsecond)
+}
+
+func (g GroupRoster) name() string {
+	return fmt.Sprintf("Beneficiaries attributed to %s", g.CMSID)
+}
+
+// CreateGroup builds a FHIR STU3 Group resource for the roster
+func CreateGroup(roster GroupRoster) *fhirmodels.Group {
+	start, end := roster.period()
+	period := &fhirdatatypes.Period{
+		Start: &fhirdatatypes.DateTime{ValueUs: start, Timezone: time.UTC.String(), Precision: fhirdatatypes.DateTime_DAY},
+		End:   &fhirdatatypes.DateTime{ValueUs: end, Timezone: time.UTC.String(), Precision: fhirdatatypes.DateTime_DAY},
+	}
+
+	members := make([]*fhirmodels.Group_Member, 0, len(roster.MBIs))
+	for _, mbi := range roster.MBIs {
+		members = append(members, &fhirmodels.Group_Member{
+			Entity: &fhirdatatypes.Reference{
+				Identifier: &fhirdatatypes.Identifier{
+					System: &fhirdatatypes.Uri{Value: MBISystem},
+					Value:  &fhirdatatypes.String{Value: mbi},
+				},
+			},
+			Period: period,
+		})
+	}
+
+	return &fhirmodels.Group{
+		Id: &fhirdatatypes.Id{Value: "all"},
+		Meta: &fhirdatatypes.Meta{
+			LastUpdated: &fhirdatatypes.Instant{
+				ValueUs:   roster.LastUpdated.UTC().UnixNano() / int64(time.Microsecond),
+				Timezone:  time.UTC.String(),
+				Precision: fhirdatatypes.Instant_MICROSECOND,
+			},
+		},
+		Active:   &fhirdatatypes.Boolean{Value: roster.Active},
+		Type:     &fhircodes.GroupTypeCode{Value: fhircodes.GroupTypeCode_PERSON},
+		Actual:   &fhirdatatypes.Boolean{Value: true},
+		Name:     &fhirdatatypes.String{Value: roster.name()},
+		Quantity: &fhirdatatypes.UnsignedInt{Value: uint32(len(roster.MBIs))},
+		Member:   members,
+	}
+}
+
+// CreateGroupR4 builds a FHIR R4 Group resource for the roster
+func CreateGroupR4(roster GroupRoster) *fhirgroupr4.Group {
+	start, end := roster.period()
+	period := &fhirdatatypesr4.Period{
+		Start: &fhirdatatypesr4.DateTime{ValueUs: start, Timezone: time.UTC.String(), Precision: fhirdatatypesr4.DateTime_DAY},
+		End:   &fhirdatatypesr4.DateTime{ValueUs: end, Timezone: time.UTC.String(), Precision: fhirdatatypesr4.DateTime_DAY},
+	}
+
+	members := make([]*fhirgroupr4.Group_Member, 0, len(roster.MBIs))
+	for _, mbi := range roster.MBIs {
+		members = append(members, &fhirgroupr4.Group_Member{
+			Entity: &fhirdatatypesr4.Reference{
+				Identifier: &fhirdatatypesr4.Identifier{
+					System: &fhirdatatypesr4.Uri{Value: MBISystem},
+					Value:  &fhirdatatypesr4.String{Value: mbi},
+				},
+			},
+			Period: period,
+		})
+	}
+
+	return &fhirgroupr4.Group{
+		Id: &fhirdatatypesr4.Id{Value: "all"},
+		Meta: &fhirdatatypesr4.Meta{
+			LastUpdated: &fhirdatatypesr4.Instant{
+				ValueUs:   roster.LastUpdated.UTC().UnixNano() / int64(time.Microsecond),
+				Timezone:  time.UTC.String(),
+				Precision: fhirdatatypesr4.Instant_MICROSECOND,
+			},
+		},
+		Active:   &fhirdatatypesr4.Boolean{Value: roster.Active},
+		Type:     &fhirgroupr4.Group_TypeCode{Value: fhircodesr4.GroupTypeCode_PERSON},
+		Actual:   &fhirdatatypesr4.Boolean{Value: true},
+		Name:     &fhirdatatypesr4.String{Value: roster.name()},
+		Quantity: &fhirdatatypesr4.UnsignedInt{Value: uint32(len(roster.MBIs))},
+		Member:   members,
+	}
+}
+
+func WriteGroup(group *fhirmodels.Group, w http.ResponseWriter) {
+	resource := &fhirmodels.ContainedResource{
+		OneofResource: &fhirmodels.ContainedResource_Group{Group: group},
+	}
+	groupJSON, err := marshaller.Marshal(resource)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(groupJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
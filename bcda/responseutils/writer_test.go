@@ -94,3 +94,53 @@ func (s *ResponseUtilsWriterTestSuite) TestWriteCapabilityStatement() {
 	assert.Equal(s.T(), "3.0.1", respCS.FhirVersion.Value)
 	assert.Equal(s.T(), cs.FhirVersion, respCS.FhirVersion)
 }
+
+func (s *ResponseUtilsWriterTestSuite) TestWriteGroup() {
+	timestamp := time.Date(2020, time.December, 1, 10, 0, 0, 0, time.UTC)
+	terminated := time.Date(2020, time.June, 30, 0, 0, 0, 0, time.UTC)
+	roster := GroupRoster{
+		CMSID:       "A0000",
+		MBIs:        []string{"MBI1", "MBI2"},
+		Start:       time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		End:         terminated,
+		LastUpdated: timestamp,
+	}
+	WriteGroup(CreateGroup(roster), s.rr)
+
+	res, err := s.unmarshaller.Unmarshal(s.rr.Body.Bytes())
+	assert.NoError(s.T(), err)
+	group := res.(*fhirmodels.ContainedResource).GetGroup()
+
+	assert.Equal(s.T(), http.StatusOK, s.rr.Code)
+	assert.Equal(s.T(), "all", group.Id.Value)
+	assert.Equal(s.T(), timestamp.UnixNano()/int64(time.Microsecond), group.Meta.LastUpdated.ValueUs)
+	assert.False(s.T(), group.Active.Value)
+	assert.Equal(s.T(), fhircodes.GroupTypeCode_PERSON, group.Type.Value)
+	assert.True(s.T(), group.Actual.Value)
+	assert.Equal(s.T(), uint32(2), group.Quantity.Value)
+	assert.Len(s.T(), group.Member, 2)
+	for i, mbi := range roster.MBIs {
+		member := group.Member[i]
+		assert.Equal(s.T(), MBISystem, member.Entity.Identifier.System.Value)
+		assert.Equal(s.T(), mbi, member.Entity.Identifier.Value.Value)
+		assert.Equal(s.T(), time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano()/int64(time.Microsecond),
+			member.Period.Start.ValueUs)
+		assert.Equal(s.T(), terminated.UnixNano()/int64(time.Microsecond), member.Period.End.ValueUs)
+	}
+}
+
+func (s *ResponseUtilsWriterTestSuite) TestCreateGroupPeriod() {
+	// An ACO terminated before the performance year began has no attribution period
+	start := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	roster := GroupRoster{CMSID: "A0000", MBIs: []string{"MBI1"}, Start: start, End: start.AddDate(0, -6, 0)}
+	startUs := start.UnixNano() / int64(time.Microsecond)
+
+	group := CreateGroup(roster)
+	assert.Equal(s.T(), startUs, group.Member[0].Period.Start.ValueUs)
+	assert.Equal(s.T(), startUs, group.Member[0].Period.End.ValueUs)
+
+	groupR4 := CreateGroupR4(roster)
+	assert.Equal(s.T(), startUs, groupR4.Member[0].Period.Start.ValueUs)
+	assert.Equal(s.T(), startUs, groupR4.Member[0].Period.End.ValueUs)
+	assert.Equal(s.T(), "Beneficiaries attributed to A0000", groupR4.Name.Value)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"time"
 
@@ -27,6 +28,8 @@ func LoadConfig() (cfg *Config, err error) {
 type Config struct {
 	SuppressionLookbackDays int `conf:"BCDA_SUPPRESSION_LOOKBACK_DAYS" conf_default:"60"`
 	CutoffDurationDays      int `conf:"CCLF_CUTOFF_DATE_DAYS" conf_default:"45"`
+	// Number of beneficiaries retrieved at a time when creating the queue jobs for a request
+	BeneficiaryBatchSize int `conf:"BCDA_BENEFICIARY_BATCH_SIZE" conf_default:"5000"`
 
 	// Use the squash tag to allow the RunoutConfigs to avoid requiring the parameters
 	// to be defined as a child of RunoutConfig.
@@ -36,6 +39,11 @@ type Config struct {
 
 	ACOConfigs []ACOConfig `conf:"aco_config"`
 
+	// Rules evaluated in order to determine the priority of the queue jobs created for a request.
+	// When no rules are configured, the default rules are used.
+	PriorityRules []PriorityRule `conf:"priority_rules"`
+	PriorityAging PriorityAging  `conf:",squash"`
+
 	// Un-exported fields that are computed using the exported ones above
 	cutoffDuration time.Duration
 }
@@ -59,6 +67,24 @@ func (cfg *Config) computeFields() (err error) {
 		return fmt.Errorf("failed to parse runout claim thru date: %w", err)
 	}
 
+	// Replace the runout periods inline with computed columns
+	perfYears := make(map[int]struct{}, len(cfg.RunoutConfig.Periods))
+	for idx := range cfg.RunoutConfig.Periods {
+		period := &cfg.RunoutConfig.Periods[idx]
+		if period.PerformanceYear < 2000 {
+			return fmt.Errorf("invalid runout performance year %d, must be a four digit year", period.PerformanceYear)
+		}
+		if _, ok := perfYears[period.PerformanceYear]; ok {
+			return fmt.Errorf("duplicate runout period for performance year %d", period.PerformanceYear)
+		}
+		perfYears[period.PerformanceYear] = struct{}{}
+
+		period.cutoffDuration = 24 * time.Hour * time.Duration(period.CutoffDurationDays)
+		if period.claimThru, err = time.Parse(claimThruLayout, period.ClaimThruDate); err != nil {
+			return fmt.Errorf("failed to parse runout claim thru date for performance year %d: %w", period.PerformanceYear, err)
+		}
+	}
+
 	// Replace the ACO configs inline with computed columns
 	for idx := range cfg.ACOConfigs {
 		if cfg.ACOConfigs[idx].patternExp, err = regexp.Compile(cfg.ACOConfigs[idx].Pattern); err != nil {
@@ -71,12 +97,29 @@ func (cfg *Config) computeFields() (err error) {
 		}
 	}
 
+	for idx := range cfg.PriorityRules {
+		rule := &cfg.PriorityRules[idx]
+		if rule.Priority < 0 || rule.Priority > math.MaxInt16 {
+			return fmt.Errorf("invalid priority %d for priority rule %s, must be between 0 and %d", rule.Priority, rule.Name, math.MaxInt16)
+		}
+		if rule.CMSIDPattern != "" {
+			if rule.cmsIDExp, err = regexp.Compile(rule.CMSIDPattern); err != nil {
+				return fmt.Errorf("failed to parse priority rule %s CMS ID pattern: %w", rule.Name, err)
+			}
+		}
+		if rule.MinAgeMinutes < 0 {
+			return fmt.Errorf("invalid minimum age %d for priority rule %s, must not be negative", rule.MinAgeMinutes, rule.Name)
+		}
+	}
+
 	return nil
 }
 
 type RunoutConfig struct {
 	CutoffDurationDays int    `conf:"RUNOUT_CUTOFF_DATE_DAYS" conf_default:"180"`
 	ClaimThruDate      string `conf:"RUNOUT_CLAIM_THRU_DATE" conf_default:"2020-12-31"`
+	// Runout files belonging to a performance year without a period use the cutoff and claim thru date above
+	Periods []RunoutPeriod `conf:"runout_periods"`
 	// Un-exported fields that are computed using the exported ones above
 	cutoffDuration time.Duration
 	claimThru      time.Time
@@ -86,6 +129,17 @@ func (config RunoutConfig) String() string {
 	return toJSON(config)
 }
 
+// RunoutPeriod contains the runout configuration for the runout files of a single performance year
+type RunoutPeriod struct {
+	// Four digit year (e.g. 2020)
+	PerformanceYear    int    `conf:"performance_year"`
+	CutoffDurationDays int    `conf:"cutoff_date_days"`
+	ClaimThruDate      string `conf:"claim_thru_date"`
+	// Un-exported fields that are computed using the exported ones above
+	cutoffDuration time.Duration
+	claimThru      time.Time
+}
+
 type ACOConfig struct {
 	Model              string
 	Pattern            string `conf:"name_pattern"`
@@ -61,6 +61,42 @@ func TestLookbackTime(t *testing.T) {
 	}
 }
 
+func TestComputeRunoutPeriods(t *testing.T) {
+	base := RunoutConfig{CutoffDurationDays: 180, ClaimThruDate: "2020-12-31"}
+	tests := []struct {
+		name    string
+		periods []RunoutPeriod
+		errMsg  string
+	}{
+		{"Valid", []RunoutPeriod{{PerformanceYear: 2020, CutoffDurationDays: 120, ClaimThruDate: "2020-12-31"},
+			{PerformanceYear: 2021, CutoffDurationDays: 90, ClaimThruDate: "2021-12-31"}}, ""},
+		{"Two digit year", []RunoutPeriod{{PerformanceYear: 21, ClaimThruDate: "2021-12-31"}},
+			"invalid runout performance year 21, must be a four digit year"},
+		{"Duplicate year", []RunoutPeriod{{PerformanceYear: 2021, ClaimThruDate: "2021-12-31"},
+			{PerformanceYear: 2021, ClaimThruDate: "2021-12-31"}}, "duplicate runout period for performance year 2021"},
+		{"Invalid claim thru date", []RunoutPeriod{{PerformanceYear: 2021, ClaimThruDate: "12/31/2021"}},
+			"failed to parse runout claim thru date for performance year 2021"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runoutCfg := base
+			runoutCfg.Periods = tt.periods
+			cfg := &Config{RunoutConfig: runoutCfg}
+			err := cfg.computeFields()
+			if tt.errMsg != "" {
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			for _, period := range cfg.RunoutConfig.Periods {
+				assert.Equal(t, time.Duration(period.CutoffDurationDays)*24*time.Hour, period.cutoffDuration)
+				assert.Equal(t, time.Date(period.PerformanceYear, time.December, 31, 0, 0, 0, 0, time.UTC), period.claimThru)
+			}
+		})
+	}
+}
+
 func expectedPerfYear(base time.Time, minusYears int) time.Time {
 	return time.Date(base.Year()-minusYears, base.Month(), base.Day(), 0, 0, 0, 0, time.UTC)
 }
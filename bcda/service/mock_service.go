@@ -35,6 +35,52 @@ func (_m *MockService) CancelJob(ctx context.Context, jobID uint) (uint, error)
 	return r0, r1
 }
 
+// GetAttributionChanges provides a mock function with given fields: ctx, conditions
+func (_m *MockService) GetAttributionChanges(ctx context.Context, conditions RequestConditions) (*AttributionChanges, error) {
+	ret := _m.Called(ctx, conditions)
+
+	var r0 *AttributionChanges
+	if rf, ok := ret.Get(0).(func(context.Context, RequestConditions) *AttributionChanges); ok {
+		r0 = rf(ctx, conditions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*AttributionChanges)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, RequestConditions) error); ok {
+		r1 = rf(ctx, conditions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAttributionRoster provides a mock function with given fields: ctx, conditions
+func (_m *MockService) GetAttributionRoster(ctx context.Context, conditions RequestConditions) (*AttributionRoster, error) {
+	ret := _m.Called(ctx, conditions)
+
+	var r0 *AttributionRoster
+	if rf, ok := ret.Get(0).(func(context.Context, RequestConditions) *AttributionRoster); ok {
+		r0 = rf(ctx, conditions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*AttributionRoster)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, RequestConditions) error); ok {
+		r1 = rf(ctx, conditions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetJobAndKeys provides a mock function with given fields: ctx, jobID
 func (_m *MockService) GetJobAndKeys(ctx context.Context, jobID uint) (*models.Job, []*models.JobKey, error) {
 	ret := _m.Called(ctx, jobID)
@@ -67,13 +113,13 @@ func (_m *MockService) GetJobAndKeys(ctx context.Context, jobID uint) (*models.J
 	return r0, r1, r2
 }
 
-// GetJobPriority provides a mock function with given fields: acoID, resourceType, sinceParam
-func (_m *MockService) GetJobPriority(acoID string, resourceType string, sinceParam bool) int16 {
-	ret := _m.Called(acoID, resourceType, sinceParam)
+// GetJobPriority provides a mock function with given fields: req
+func (_m *MockService) GetJobPriority(req PriorityRequest) int16 {
+	ret := _m.Called(req)
 
 	var r0 int16
-	if rf, ok := ret.Get(0).(func(string, string, bool) int16); ok {
-		r0 = rf(acoID, resourceType, sinceParam)
+	if rf, ok := ret.Get(0).(func(PriorityRequest) int16); ok {
+		r0 = rf(req)
 	} else {
 		r0 = ret.Get(0).(int16)
 	}
@@ -81,16 +127,39 @@ func (_m *MockService) GetJobPriority(acoID string, resourceType string, sincePa
 	return r0
 }
 
-// GetQueJobs provides a mock function with given fields: ctx, conditions
-func (_m *MockService) GetQueJobs(ctx context.Context, conditions RequestConditions) ([]*models.JobEnqueueArgs, error) {
+// GetSuppressionReport provides a mock function with given fields: ctx, conditions
+func (_m *MockService) GetSuppressionReport(ctx context.Context, conditions RequestConditions) ([]*models.BeneficiarySuppression, error) {
+	ret := _m.Called(ctx, conditions)
+
+	var r0 []*models.BeneficiarySuppression
+	if rf, ok := ret.Get(0).(func(context.Context, RequestConditions) []*models.BeneficiarySuppression); ok {
+		r0 = rf(ctx, conditions)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.BeneficiarySuppression)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, RequestConditions) error); ok {
+		r1 = rf(ctx, conditions)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PlanQueJobs provides a mock function with given fields: ctx, conditions
+func (_m *MockService) PlanQueJobs(ctx context.Context, conditions RequestConditions) (QueJobPlan, error) {
 	ret := _m.Called(ctx, conditions)
 
-	var r0 []*models.JobEnqueueArgs
-	if rf, ok := ret.Get(0).(func(context.Context, RequestConditions) []*models.JobEnqueueArgs); ok {
+	var r0 QueJobPlan
+	if rf, ok := ret.Get(0).(func(context.Context, RequestConditions) QueJobPlan); ok {
 		r0 = rf(ctx, conditions)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*models.JobEnqueueArgs)
+			r0 = ret.Get(0).(QueJobPlan)
 		}
 	}
 
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+)
+
+const defaultBeneficiaryBatchSize = 5000
+
+// QueJobPlan enqueues the queue jobs needed to fulfill an export request.
+type QueJobPlan interface {
+	// Execute streams the beneficiaries associated with the request, supplying enqueue with the queue jobs
+	// created from each batch of beneficiaries. Every queue job in a batch shares the same resource type.
+	// It returns the number of queue jobs supplied to enqueue.
+	Execute(ctx context.Context, enqueue func(jobs []*models.JobEnqueueArgs) error) (int, error)
+}
+
+// queJobPlan pages through the beneficiaries found in a CCLF file, creating queue jobs as each page is retrieved.
+// This avoids holding every beneficiary (or queue job) for the largest ACOs in memory.
+type queJobPlan struct {
+	s          *service
+	conditions RequestConditions
+
+	cclfFileID  uint
+	suppression *models.SuppressionCriteria
+
+	// The first page of beneficiaries is retrieved while planning to ensure the CCLF file contains beneficiaries.
+	benes  []*models.CCLFBeneficiary
+	cursor uint
+
+	// isNew reports, for each beneficiary on a page, whether the beneficiary's full history should be retrieved,
+	// ignoring the since parameter. When nil, the since parameter applies to every beneficiary.
+	isNew func(ctx context.Context, benes []*models.CCLFBeneficiary) ([]bool, error)
+}
+
+// newQueJobPlan returns a plan for the beneficiaries found in the CCLF file with the first non-empty page of beneficiaries populated.
+func (s *service) newQueJobPlan(ctx context.Context, cclfFileID uint, conditions RequestConditions) (*queJobPlan, error) {
+	plan := &queJobPlan{s: s, conditions: conditions, cclfFileID: cclfFileID}
+	if !s.sp.includeSuppressedBeneficiaries {
+		plan.suppression = s.suppressionCriteria(conditions)
+	}
+
+	// Suppressions may exclude every beneficiary on a page, so continue until we find a beneficiary or exhaust the file.
+	for {
+		if err := plan.next(ctx); err != nil {
+			return nil, err
+		}
+		if len(plan.benes) > 0 || plan.cursor == 0 {
+			return plan, nil
+		}
+	}
+}
+
+// next retrieves the page of beneficiaries found after the plan's cursor.
+func (p *queJobPlan) next(ctx context.Context) (err error) {
+	p.benes, p.cursor, err = p.s.repository.GetCCLFBeneficiariesAfter(ctx, p.cclfFileID, p.suppression, p.cursor, p.s.batchSize())
+	if err != nil {
+		return fmt.Errorf("failed to get beneficiaries %s", err.Error())
+	}
+	return nil
+}
+
+func (p *queJobPlan) Execute(ctx context.Context, enqueue func(jobs []*models.JobEnqueueArgs) error) (int, error) {
+	// New beneficiaries retrieve their full history so they use a default since value
+	newBuilders, err := p.s.newQueJobBuilders(p.conditions, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	existingBuilders, err := p.s.newQueJobBuilders(p.conditions, p.conditions.Since)
+	if err != nil {
+		return 0, err
+	}
+	builders := append(append([]*queJobBuilder{}, newBuilders...), existingBuilders...)
+
+	var count int
+	enqueueJobs := func() error {
+		for _, b := range builders {
+			if jobs := b.take(); len(jobs) > 0 {
+				if err := enqueue(jobs); err != nil {
+					return err
+				}
+				count += len(jobs)
+			}
+		}
+		return nil
+	}
+
+	for {
+		var isNew []bool
+		if p.isNew != nil {
+			if isNew, err = p.isNew(ctx, p.benes); err != nil {
+				return count, err
+			}
+		}
+
+		for i, bene := range p.benes {
+			group := existingBuilders
+			if isNew != nil && isNew[i] {
+				group = newBuilders
+			}
+			for _, b := range group {
+				b.add(bene)
+			}
+		}
+
+		if err := enqueueJobs(); err != nil {
+			return count, err
+		}
+
+		if p.cursor == 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if err := p.next(ctx); err != nil {
+			return count, err
+		}
+	}
+
+	// Create queue jobs for the remaining beneficiaries that did not fill an entire queue job
+	for _, b := range builders {
+		b.flush()
+	}
+	if err := enqueueJobs(); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+// queJobBuilder groups beneficiaries into queue jobs for a single resource type.
+// A queue job is created each time the resource type's beneficiary limit is reached.
+type queJobBuilder struct {
+	args             models.JobEnqueueArgs
+	maxBeneficiaries int
+
+	beneIDs             []string
+	samhsaSuppressedIDs []string
+
+	jobs []*models.JobEnqueueArgs
+}
+
+// newQueJobBuilders returns a builder for each of the requested resource types.
+func (s *service) newQueJobBuilders(conditions RequestConditions, since time.Time) ([]*queJobBuilder, error) {
+	// persist in format ready for usage with _lastUpdated -- i.e., prepended with 'gt'
+	var sinceArg string
+	if !since.IsZero() {
+		sinceArg = "gt" + since.Format(time.RFC3339Nano)
+	}
+
+	builders := make([]*queJobBuilder, 0, len(conditions.Resources))
+	for _, rt := range conditions.Resources {
+		maxBeneficiaries, err := getMaxBeneCount(rt)
+		if err != nil {
+			return nil, err
+		}
+
+		args := models.JobEnqueueArgs{
+			ID:              int(conditions.JobID),
+			ACOID:           conditions.ACOID.String(),
+			ResourceType:    rt,
+			Since:           sinceArg,
+			TransactionTime: conditions.TransactionTime,
+			BBBasePath:      s.bbBasePath,
+		}
+		s.setClaimsDate(&args, conditions)
+
+		builders = append(builders, &queJobBuilder{args: args, maxBeneficiaries: maxBeneficiaries})
+	}
+
+	return builders, nil
+}
+
+func (b *queJobBuilder) add(bene *models.CCLFBeneficiary) {
+	id := fmt.Sprint(bene.ID)
+	b.beneIDs = append(b.beneIDs, id)
+	// Claims for these beneficiaries are still exported, but without any SAMHSA data
+	if bene.SAMHSASuppressed {
+		b.samhsaSuppressedIDs = append(b.samhsaSuppressedIDs, id)
+	}
+	if len(b.beneIDs) >= b.maxBeneficiaries {
+		b.flush()
+	}
+}
+
+// flush creates a queue job for the beneficiaries that have not been assigned to a queue job.
+func (b *queJobBuilder) flush() {
+	if len(b.beneIDs) == 0 {
+		return
+	}
+
+	args := b.args
+	args.BeneficiaryIDs, args.SAMHSASuppressedIDs = b.beneIDs, b.samhsaSuppressedIDs
+	b.jobs = append(b.jobs, &args)
+
+	b.beneIDs, b.samhsaSuppressedIDs = make([]string, 0, b.maxBeneficiaries), nil
+}
+
+// take returns the queue jobs created since the last call to take.
+func (b *queJobBuilder) take() []*models.JobEnqueueArgs {
+	jobs := b.jobs
+	b.jobs = nil
+	return jobs
+}
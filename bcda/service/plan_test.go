@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/testUtils"
+	"github.com/CMSgov/bcda-app/conf"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestQueJobPlanPaging verifies queue jobs span the pages of beneficiaries and that each batch holds a single resource type
+func TestQueJobPlanPaging(t *testing.T) {
+	conf.SetEnv(t, "BCDA_FHIR_MAX_RECORDS_EOB", "3")
+	defer conf.UnsetEnv(t, "BCDA_FHIR_MAX_RECORDS_EOB")
+	conf.SetEnv(t, "BCDA_FHIR_MAX_RECORDS_PATIENT", "5")
+	defer conf.UnsetEnv(t, "BCDA_FHIR_MAX_RECORDS_PATIENT")
+
+	cclfFileID := uint(1)
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(t)
+	// The second page only contains suppressed beneficiaries
+	repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileID, (*models.SuppressionCriteria)(nil), uint(0), 2).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1"), getCCLFBeneficiary(2, "MBI2")}, uint(2), nil)
+	repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileID, (*models.SuppressionCriteria)(nil), uint(2), 2).
+		Return(nil, uint(4), nil)
+	repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileID, (*models.SuppressionCriteria)(nil), uint(4), 2).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(5, "MBI5"), getCCLFBeneficiary(6, "MBI6")}, uint(6), nil)
+	repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileID, (*models.SuppressionCriteria)(nil), uint(6), 2).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(7, "MBI7")}, uint(0), nil)
+
+	serviceInstance := &service{repository: repository, beneBatchSize: 2,
+		sp: suppressionParameters{includeSuppressedBeneficiaries: true}}
+	conditions := RequestConditions{ACOID: uuid.NewRandom(), Resources: []string{"ExplanationOfBenefit", "Patient"}}
+	plan, err := serviceInstance.newQueJobPlan(context.Background(), cclfFileID, conditions)
+	assert.NoError(t, err)
+
+	var batches [][]*models.JobEnqueueArgs
+	count, err := plan.Execute(context.Background(), func(jobs []*models.JobEnqueueArgs) error {
+		batches = append(batches, jobs)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	if assert.Len(t, batches, 3) {
+		assert.Len(t, batches[0], 1)
+		assert.Equal(t, "ExplanationOfBenefit", batches[0][0].ResourceType)
+		assert.Equal(t, []string{"1", "2", "5"}, batches[0][0].BeneficiaryIDs)
+
+		assert.Len(t, batches[1], 1)
+		assert.Equal(t, "Patient", batches[1][0].ResourceType)
+		assert.Equal(t, []string{"1", "2", "5", "6", "7"}, batches[1][0].BeneficiaryIDs)
+
+		// Remaining beneficiaries are flushed once the file is exhausted
+		assert.Len(t, batches[2], 1)
+		assert.Equal(t, "ExplanationOfBenefit", batches[2][0].ResourceType)
+		assert.Equal(t, []string{"6", "7"}, batches[2][0].BeneficiaryIDs)
+	}
+}
+
+// TestQueJobPlanSAMHSASuppressed verifies each job only carries the SAMHSA suppressions for its own beneficiaries
+func TestQueJobPlanSAMHSASuppressed(t *testing.T) {
+	conf.SetEnv(t, "BCDA_FHIR_MAX_RECORDS_EOB", "2")
+	defer conf.UnsetEnv(t, "BCDA_FHIR_MAX_RECORDS_EOB")
+
+	benes := []*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1"), getCCLFBeneficiary(2, "MBI2"), getCCLFBeneficiary(3, "MBI3")}
+	benes[1].SAMHSASuppressed = true
+
+	plan := &queJobPlan{s: &service{}, benes: benes,
+		conditions: RequestConditions{ACOID: uuid.NewRandom(), Resources: []string{"ExplanationOfBenefit"}}}
+	jobs, err := executePlan(plan)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, []string{"1", "2"}, jobs[0].BeneficiaryIDs)
+	assert.Equal(t, []string{"2"}, jobs[0].SAMHSASuppressedIDs)
+	assert.Equal(t, []string{"3"}, jobs[1].BeneficiaryIDs)
+	assert.Empty(t, jobs[1].SAMHSASuppressedIDs)
+}
+
+func TestQueJobPlanErrors(t *testing.T) {
+	cclfFileID := uint(1)
+	benes := []*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1")}
+	conditions := RequestConditions{ACOID: uuid.NewRandom(), Resources: []string{"Patient"}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		enqueueErr error
+		pageErr    error
+		expCount   int
+		expErr     string
+	}{
+		{"Enqueue failed", context.Background(), errors.New("enqueue error"), nil, 0, "enqueue error"},
+		// The first page is still enqueued
+		{"Next page failed", context.Background(), nil, errors.New("page error"), 1, "failed to get beneficiaries page error"},
+		{"Cancelled", cancelled, nil, nil, 1, context.Canceled.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &models.MockRepository{}
+			repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileID, (*models.SuppressionCriteria)(nil), uint(1), defaultBeneficiaryBatchSize).
+				Return(nil, uint(0), tt.pageErr)
+
+			// Beneficiary limit is reached on the first page to ensure the queue job is enqueued before retrieving the next page
+			conf.SetEnv(t, "BCDA_FHIR_MAX_RECORDS_PATIENT", "1")
+			defer conf.UnsetEnv(t, "BCDA_FHIR_MAX_RECORDS_PATIENT")
+
+			plan := &queJobPlan{s: &service{repository: repository}, conditions: conditions, cclfFileID: cclfFileID,
+				benes: benes, cursor: 1}
+			count, err := plan.Execute(tt.ctx, func(jobs []*models.JobEnqueueArgs) error {
+				return tt.enqueueErr
+			})
+			assert.EqualError(t, err, tt.expErr)
+			assert.Equal(t, tt.expCount, count)
+		})
+	}
+}
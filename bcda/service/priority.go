@@ -0,0 +1,155 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/CMSgov/bcda-app/conf"
+)
+
+// Priority levels used when no priority rules are configured.
+// The lower the number, the higher the priority in the queue.
+const (
+	priorityACOPriority      = 10  // priority level for jobs for synthetic ACOs that are used for smoke testing
+	smallResourcesPriority   = 20  // priority level for jobs that only request smaller resources
+	limitedTimeframePriority = 30  // priority level for jobs that only request data for a limited timeframe
+	defaultPriority          = 100 // default priority level for jobs
+)
+
+// PriorityRequest describes the queue jobs whose priority is being computed
+type PriorityRequest struct {
+	CMSID        string
+	ResourceType string
+	// Since reports whether the request only retrieves data for a limited timeframe
+	Since   bool
+	ReqType RequestType
+	// CreatedAt is when the job was created. Rules with a job age condition never match a request without it.
+	CreatedAt time.Time
+}
+
+// PriorityRule assigns its priority to the queue jobs of a request that satisfies all of the rule's conditions.
+// Conditions that are not set match every request.
+type PriorityRule struct {
+	Name     string `conf:"name"`
+	Priority int    `conf:"priority"`
+
+	// Model of the ACO as defined by the ACO configs (e.g. SSP)
+	ACOModel      string   `conf:"aco_model"`
+	CMSIDPattern  string   `conf:"cms_id_pattern"`
+	ResourceTypes []string `conf:"resource_types"`
+	Since         *bool    `conf:"since"`
+	Runout        *bool    `conf:"runout"`
+	// MinAgeMinutes matches jobs that were created at least this many minutes ago
+	MinAgeMinutes int `conf:"min_age_minutes"`
+
+	// Un-exported fields that are computed using the exported ones above
+	cmsIDExp *regexp.Regexp
+}
+
+func (rule *PriorityRule) String() string {
+	return toJSON(rule)
+}
+
+// PriorityAging improves the priority of queue jobs that have been waiting to be worked on.
+// Every Threshold minutes that a queue job waits, its priority is reduced by Step.
+// Aged queue jobs never move ahead of the queue jobs of a higher priority tier (see PriorityTiers).
+type PriorityAging struct {
+	ThresholdMinutes int `conf:"JOB_PRIORITY_AGING_THRESHOLD_MINUTES" conf_default:"60"`
+	Step             int `conf:"JOB_PRIORITY_AGING_STEP" conf_default:"10"`
+}
+
+func (config PriorityAging) String() string {
+	return toJSON(config)
+}
+
+// Enabled reports whether queue jobs should be aged
+func (config PriorityAging) Enabled() bool {
+	return config.ThresholdMinutes > 0 && config.Step > 0
+}
+
+// defaultPriorityRules returns the rules used when none are configured.
+// Priority ACOs are identified by the PRIORITY_ACO_REG_EX pattern.
+func defaultPriorityRules() []PriorityRule {
+	since := true
+	var rules []PriorityRule
+	if pattern := conf.GetEnv("PRIORITY_ACO_REG_EX"); pattern != "" {
+		rules = append(rules, PriorityRule{Name: "priority ACO", Priority: priorityACOPriority, CMSIDPattern: pattern,
+			cmsIDExp: regexp.MustCompile(pattern)})
+	}
+	return append(rules,
+		PriorityRule{Name: "small resources", Priority: smallResourcesPriority, ResourceTypes: []string{"Patient", "Coverage"}},
+		PriorityRule{Name: "limited timeframe", Priority: limitedTimeframePriority, Since: &since},
+	)
+}
+
+// PriorityTiers returns the distinct priorities that queue jobs are enqueued with in ascending order,
+// i.e. the priorities of the priority rules along with the default priority.
+func (cfg *Config) PriorityTiers() []int {
+	rules := cfg.PriorityRules
+	if rules == nil {
+		rules = defaultPriorityRules()
+	}
+
+	seen := map[int]bool{defaultPriority: true}
+	tiers := []int{defaultPriority}
+	for _, rule := range rules {
+		if !seen[rule.Priority] {
+			seen[rule.Priority] = true
+			tiers = append(tiers, rule.Priority)
+		}
+	}
+	sort.Ints(tiers)
+	return tiers
+}
+
+// matches reports whether the request satisfies all of the rule's conditions.
+// acoModel is the model of the ACO making the request.
+func (rule *PriorityRule) matches(req PriorityRequest, acoModel string) bool {
+	if rule.ACOModel != "" && !strings.EqualFold(rule.ACOModel, acoModel) {
+		return false
+	}
+	if rule.cmsIDExp != nil && !rule.cmsIDExp.MatchString(req.CMSID) {
+		return false
+	}
+	if len(rule.ResourceTypes) > 0 && !utils.ContainsString(rule.ResourceTypes, req.ResourceType) {
+		return false
+	}
+	if rule.Since != nil && *rule.Since != req.Since {
+		return false
+	}
+	if rule.Runout != nil && *rule.Runout != (req.ReqType == Runout) {
+		return false
+	}
+	if rule.MinAgeMinutes > 0 &&
+		(req.CreatedAt.IsZero() || time.Since(req.CreatedAt) < time.Duration(rule.MinAgeMinutes)*time.Minute) {
+		return false
+	}
+	return true
+}
+
+// GetJobPriority gets the priority for the queue jobs of the request where the lower the number the higher the priority in the queue.
+// The priority rules are evaluated in order, with the first matching rule determining the priority.
+func (s *service) GetJobPriority(req PriorityRequest) int16 {
+	rules := s.priorityRules
+	if rules == nil {
+		rules = defaultPriorityRules()
+	}
+
+	var acoModel string
+	for pattern, cfg := range s.acoConfig {
+		if pattern.MatchString(req.CMSID) {
+			acoModel = cfg.Model
+			break
+		}
+	}
+
+	for idx := range rules {
+		if rules[idx].matches(req, acoModel) {
+			return int16(rules[idx].Priority)
+		}
+	}
+	return defaultPriority
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetJobPriorityRules(t *testing.T) {
+	yes, no := true, false
+	cfg := &Config{
+		RunoutConfig: RunoutConfig{ClaimThruDate: "2020-12-31"},
+		ACOConfigs:   []ACOConfig{{Model: "SSP", Pattern: `^A\d{4}$`}, {Model: "NGACO", Pattern: `^V\d{3}$`}},
+		PriorityRules: []PriorityRule{
+			{Name: "smoke test", Priority: 1, CMSIDPattern: `^A999\d$`},
+			{Name: "waiting", Priority: 5, ResourceTypes: []string{"ExplanationOfBenefit"}, MinAgeMinutes: 30},
+			{Name: "runout", Priority: 500, Runout: &yes},
+			{Name: "NGACO claims", Priority: 50, ACOModel: "ngaco", ResourceTypes: []string{"ExplanationOfBenefit"}, Since: &no},
+			{Name: "small resources", Priority: 20, ResourceTypes: []string{"Patient", "Coverage"}},
+		},
+	}
+	assert.NoError(t, cfg.computeFields())
+	svc := NewService(nil, cfg, "")
+
+	tests := []struct {
+		name        string
+		req         PriorityRequest
+		expPriority int16
+	}{
+		{"Smoke test ACO", PriorityRequest{CMSID: "A9990", ResourceType: "ExplanationOfBenefit", ReqType: Runout}, 1},
+		{"Runout", PriorityRequest{CMSID: "A0000", ResourceType: "Patient", ReqType: Runout}, 500},
+		{"ACO model", PriorityRequest{CMSID: "V001", ResourceType: "ExplanationOfBenefit"}, 50},
+		{"ACO model with since", PriorityRequest{CMSID: "V001", ResourceType: "ExplanationOfBenefit", Since: true}, 100},
+		{"Different ACO model", PriorityRequest{CMSID: "A0000", ResourceType: "ExplanationOfBenefit"}, 100},
+		{"Resource type", PriorityRequest{CMSID: "A0000", ResourceType: "Coverage"}, 20},
+		{"Unknown ACO", PriorityRequest{CMSID: "Z0000", ResourceType: "Patient"}, 20},
+		{"Old job", PriorityRequest{CMSID: "A0000", ResourceType: "ExplanationOfBenefit", CreatedAt: time.Now().Add(-time.Hour)}, 5},
+		{"New job", PriorityRequest{CMSID: "A0000", ResourceType: "ExplanationOfBenefit", CreatedAt: time.Now()}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expPriority, svc.GetJobPriority(tt.req))
+		})
+	}
+}
+
+func TestComputePriorityRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   PriorityRule
+		errMsg string
+	}{
+		{"Valid", PriorityRule{Name: "valid", Priority: 10, CMSIDPattern: "^A"}, ""},
+		{"Negative priority", PriorityRule{Name: "negative", Priority: -1},
+			"invalid priority -1 for priority rule negative, must be between 0 and 32767"},
+		{"Priority too large", PriorityRule{Name: "large", Priority: 40000},
+			"invalid priority 40000 for priority rule large, must be between 0 and 32767"},
+		{"Invalid pattern", PriorityRule{Name: "pattern", CMSIDPattern: "(A"},
+			"failed to parse priority rule pattern CMS ID pattern"},
+		{"Negative age", PriorityRule{Name: "age", CMSIDPattern: "^A", MinAgeMinutes: -1},
+			"invalid minimum age -1 for priority rule age, must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RunoutConfig: RunoutConfig{ClaimThruDate: "2020-12-31"}, PriorityRules: []PriorityRule{tt.rule}}
+			err := cfg.computeFields()
+			if tt.errMsg != "" {
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, cfg.PriorityRules[0].cmsIDExp)
+		})
+	}
+}
+
+func TestPriorityTiers(t *testing.T) {
+	cfg := &Config{PriorityRules: []PriorityRule{{Priority: 50}, {Priority: 1}, {Priority: 50}, {Priority: 200}}}
+	assert.Equal(t, []int{1, 50, 100, 200}, cfg.PriorityTiers())
+
+	// The default rules are used when none are configured
+	defer conf.SetEnv(t, "PRIORITY_ACO_REG_EX", conf.GetEnv("PRIORITY_ACO_REG_EX"))
+	conf.SetEnv(t, "PRIORITY_ACO_REG_EX", "^A999\\d$")
+	cfg = &Config{}
+	assert.Equal(t, []int{10, 20, 30, 100}, cfg.PriorityTiers())
+}
+
+func TestPriorityAgingEnabled(t *testing.T) {
+	assert.True(t, PriorityAging{ThresholdMinutes: 60, Step: 10}.Enabled())
+	assert.False(t, PriorityAging{Step: 10}.Enabled())
+	assert.False(t, PriorityAging{ThresholdMinutes: 60}.Enabled())
+}
@@ -5,6 +5,7 @@ import (
 	goerrors "errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,7 +15,6 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcda/utils"
-	"github.com/CMSgov/bcda-app/conf"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -29,12 +29,19 @@ type RequestConditions struct {
 	Since           time.Time
 	TransactionTime time.Time
 
+	// RunoutYear is the four digit performance year of the runout data requested by the caller.
+	// When it is not set, the most recent runout file is used.
+	RunoutYear int
+
 	// Fields set in the service
 	fileType models.CCLFFileType
+	// Four digit performance year of the CCLF file used to fulfill the request
+	perfYear int
 
 	attributionDate time.Time
 	optOutDate      time.Time
 	claimsDate      time.Time
+	termination     *models.Termination
 }
 
 type RequestType uint8
@@ -50,13 +57,21 @@ var _ Service = &service{}
 
 // Service contains all of the methods needed to interact with the data represented in the models package
 type Service interface {
-	GetQueJobs(ctx context.Context, conditions RequestConditions) (queJobs []*models.JobEnqueueArgs, err error)
+	// PlanQueJobs validates the request and returns the plan used to enqueue the queue jobs needed to fulfill it.
+	// Errors that prevent the request from being fulfilled are returned here rather than when the plan is executed.
+	PlanQueJobs(ctx context.Context, conditions RequestConditions) (QueJobPlan, error)
 
 	GetJobAndKeys(ctx context.Context, jobID uint) (*models.Job, []*models.JobKey, error)
 
 	CancelJob(ctx context.Context, jobID uint) (uint, error)
 
-	GetJobPriority(acoID string, resourceType string, sinceParam bool) int16
+	GetJobPriority(req PriorityRequest) int16
+
+	GetAttributionChanges(ctx context.Context, conditions RequestConditions) (*AttributionChanges, error)
+
+	GetAttributionRoster(ctx context.Context, conditions RequestConditions) (*AttributionRoster, error)
+
+	GetSuppressionReport(ctx context.Context, conditions RequestConditions) ([]*models.BeneficiarySuppression, error)
 }
 
 const (
@@ -70,10 +85,24 @@ func NewService(r models.Repository, cfg *Config, basePath string) Service {
 		acoMap[acoCfg.patternExp] = &acoCfg
 	}
 
+	runoutPeriods := make(map[int]runoutParameters, len(cfg.RunoutConfig.Periods))
+	for _, period := range cfg.RunoutConfig.Periods {
+		runoutPeriods[period.PerformanceYear] = runoutParameters{
+			claimThruDate:  period.claimThru,
+			cutoffDuration: period.cutoffDuration,
+		}
+	}
+
+	priorityRules := cfg.PriorityRules
+	if len(priorityRules) == 0 {
+		priorityRules = defaultPriorityRules()
+	}
+
 	return &service{
 		repository:        r,
 		logger:            log.StandardLogger(),
 		stdCutoffDuration: cfg.cutoffDuration,
+		beneBatchSize:     cfg.BeneficiaryBatchSize,
 		sp: suppressionParameters{
 			includeSuppressedBeneficiaries: false,
 			lookbackDays:                   cfg.SuppressionLookbackDays,
@@ -82,9 +111,11 @@ func NewService(r models.Repository, cfg *Config, basePath string) Service {
 			// Runouts apply to claims data for the previous year.
 			claimThruDate:  cfg.RunoutConfig.claimThru,
 			cutoffDuration: cfg.RunoutConfig.cutoffDuration,
+			periods:        runoutPeriods,
 		},
-		bbBasePath: basePath,
-		acoConfig:  acoMap,
+		bbBasePath:    basePath,
+		acoConfig:     acoMap,
+		priorityRules: priorityRules,
 	}
 }
 
@@ -94,12 +125,23 @@ type service struct {
 	logger *log.Logger
 
 	stdCutoffDuration time.Duration
+	beneBatchSize     int
 	sp                suppressionParameters
 	rp                runoutParameters
 	bbBasePath        string
 
 	// Links pattern match to the associated ACO config
 	acoConfig map[*regexp.Regexp]*ACOConfig
+	// Evaluated in order to determine the priority of queue jobs
+	priorityRules []PriorityRule
+}
+
+// batchSize returns the number of beneficiaries to retrieve at a time when planning queue jobs
+func (s *service) batchSize() int {
+	if s.beneBatchSize <= 0 {
+		return defaultBeneficiaryBatchSize
+	}
+	return s.beneBatchSize
 }
 
 type suppressionParameters struct {
@@ -112,61 +154,62 @@ type runoutParameters struct {
 	claimThruDate time.Time
 	// Amount of time the callers can retrieve runout data (relative to when runout data was ingested)
 	cutoffDuration time.Duration
+	// Parameters for the runout data of specific performance years, keyed by the four digit year
+	periods map[int]runoutParameters
+}
+
+// forYear returns the runout parameters that apply to the performance year.
+// Performance years without a configured period use the default parameters, in which case ok is false.
+func (rp runoutParameters) forYear(perfYear int) (params runoutParameters, ok bool) {
+	if period, ok := rp.periods[perfYear]; ok {
+		return period, true
+	}
+	return rp, false
 }
 
-func (s *service) GetQueJobs(ctx context.Context, conditions RequestConditions) (queJobs []*models.JobEnqueueArgs, err error) {
+func (s *service) PlanQueJobs(ctx context.Context, conditions RequestConditions) (QueJobPlan, error) {
 	if err := s.setTimeConstraints(ctx, conditions.ACOID, &conditions); err != nil {
 		return nil, fmt.Errorf("failed to set time constraints for caller: %w", err)
 	}
 
-	var (
-		beneficiaries, newBeneficiaries []*models.CCLFBeneficiary
-		jobs                            []*models.JobEnqueueArgs
-	)
+	// Limited access only restricts exports; attribution and suppression reports remain available
+	if err := checkLimitedAccess(conditions.termination, conditions); err != nil {
+		return nil, err
+	}
 
 	if conditions.ReqType == Runout {
 		conditions.fileType = models.FileTypeRunout
+		// The default runout parameters may not apply to the requested performance year
+		if _, ok := s.rp.forYear(conditions.RunoutYear); conditions.RunoutYear > 0 && !ok {
+			return nil, RunoutYearNotConfiguredError{conditions.RunoutYear}
+		}
 	} else {
 		conditions.fileType = models.FileTypeDefault
 	}
 
 	hasAttributionDate := !conditions.attributionDate.IsZero()
 
+	var (
+		plan *queJobPlan
+		err  error
+	)
+
 	// for default requests, runouts, or any requests where the Since parameter is
 	// after a terminated ACO's attribution date, we should only retrieve exisiting benes
 	if conditions.ReqType == DefaultRequest ||
 		conditions.ReqType == Runout ||
 		hasAttributionDate && conditions.Since.After(conditions.attributionDate) {
-
-		beneficiaries, err = s.getBeneficiaries(ctx, conditions)
-		if err != nil {
-			return nil, err
-		}
+		plan, err = s.planBeneficiaries(ctx, conditions)
 	} else if conditions.ReqType == RetrieveNewBeneHistData {
-		newBeneficiaries, beneficiaries, err = s.getNewAndExistingBeneficiaries(ctx, conditions)
-		if err != nil {
-			return nil, err
-		}
-		// add new beneficiaries to the job queue; use a default time value to ensure
-		// that we retrieve the full history for these beneficiaries
-		jobs, err = s.createQueueJobs(conditions, time.Time{}, newBeneficiaries)
-		if err != nil {
-			return nil, err
-		}
-		queJobs = append(queJobs, jobs...)
+		plan, err = s.planNewAndExistingBeneficiaries(ctx, conditions)
 	} else {
 		return nil, fmt.Errorf("Unsupported RequestType %d", conditions.ReqType)
 	}
 
-	// add existiing beneficiaries to the job queue
-	jobs, err = s.createQueueJobs(conditions, conditions.Since, beneficiaries)
 	if err != nil {
 		return nil, err
 	}
-
-	queJobs = append(queJobs, jobs...)
-
-	return queJobs, nil
+	return plan, nil
 }
 
 func (s *service) GetJobAndKeys(ctx context.Context, jobID uint) (*models.Job, []*models.JobKey, error) {
@@ -217,48 +260,9 @@ func (s *service) CancelJob(ctx context.Context, jobID uint) (uint, error) {
 	return 0, ErrJobNotCancellable
 }
 
-func (s *service) createQueueJobs(conditions RequestConditions, since time.Time, beneficiaries []*models.CCLFBeneficiary) (jobs []*models.JobEnqueueArgs, err error) {
-
-	// persist in format ready for usage with _lastUpdated -- i.e., prepended with 'gt'
-	var sinceArg string
-	if !since.IsZero() {
-		sinceArg = "gt" + since.Format(time.RFC3339Nano)
-	}
-
-	for _, rt := range conditions.Resources {
-		maxBeneficiaries, err := getMaxBeneCount(rt)
-		if err != nil {
-			return nil, err
-		}
-
-		var rowCount = 0
-		jobIDs := make([]string, 0, maxBeneficiaries)
-		for _, b := range beneficiaries {
-			rowCount++
-			jobIDs = append(jobIDs, fmt.Sprint(b.ID))
-			if len(jobIDs) >= maxBeneficiaries || rowCount >= len(beneficiaries) {
-				enqueueArgs := models.JobEnqueueArgs{
-					ID:              int(conditions.JobID),
-					ACOID:           conditions.ACOID.String(),
-					BeneficiaryIDs:  jobIDs,
-					ResourceType:    rt,
-					Since:           sinceArg,
-					TransactionTime: conditions.TransactionTime,
-					BBBasePath:      s.bbBasePath,
-				}
-
-				s.setClaimsDate(&enqueueArgs, conditions)
-
-				jobs = append(jobs, &enqueueArgs)
-				jobIDs = make([]string, 0, maxBeneficiaries)
-			}
-		}
-	}
-
-	return jobs, nil
-}
-
-func (s *service) getNewAndExistingBeneficiaries(ctx context.Context, conditions RequestConditions) (newBeneficiaries, beneficiaries []*models.CCLFBeneficiary, err error) {
+// planNewAndExistingBeneficiaries plans the queue jobs for a request that retrieves the full history of newly attributed beneficiaries.
+// Beneficiaries found in the CCLF file in effect at the time of the since parameter are considered existing beneficiaries.
+func (s *service) planNewAndExistingBeneficiaries(ctx context.Context, conditions RequestConditions) (*queJobPlan, error) {
 
 	var (
 		cutoffTime time.Time
@@ -273,65 +277,198 @@ func (s *service) getNewAndExistingBeneficiaries(ctx context.Context, conditions
 	cclfFileNew, err := s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum, constants.ImportComplete,
 		cutoffTime, conditions.attributionDate, conditions.fileType)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get new CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
+		return nil, fmt.Errorf("failed to get new CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
 	}
 	if cclfFileNew == nil {
-		return nil, nil, CCLFNotFoundError{8, conditions.CMSID, conditions.fileType, cutoffTime}
+		return nil, CCLFNotFoundError{8, conditions.CMSID, conditions.fileType, cutoffTime}
 	}
 
 	cclfFileOld, err := s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum, constants.ImportComplete,
 		time.Time{}, conditions.Since, models.FileTypeDefault)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get old CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
+		return nil, fmt.Errorf("failed to get old CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
 	}
 
 	if cclfFileOld == nil {
 		s.logger.Infof("Unable to find CCLF8 File for cmsID %s prior to date: %s; all beneficiaries will be considered NEW",
 			conditions.CMSID, conditions.Since)
-		newBeneficiaries, err = s.getBenesByFileID(ctx, cclfFileNew.ID, conditions)
+		plan, err := s.newQueJobPlan(ctx, cclfFileNew.ID, conditions)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		if len(newBeneficiaries) == 0 {
-			return nil, nil, fmt.Errorf("Found 0 new beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
+		if len(plan.benes) == 0 {
+			return nil, fmt.Errorf("Found 0 new beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
 				conditions.CMSID, cclfFileNew.ID)
 		}
-		return newBeneficiaries, nil, nil
+		plan.isNew = func(_ context.Context, benes []*models.CCLFBeneficiary) ([]bool, error) {
+			isNew := make([]bool, len(benes))
+			for i := range isNew {
+				isNew[i] = true
+			}
+			return isNew, nil
+		}
+		return plan, nil
 	}
 
-	oldMBIs, err := s.repository.GetCCLFBeneficiaryMBIs(ctx, cclfFileOld.ID)
+	// Retrieve the first page of benes associated with this CCLF file.
+	plan, err := s.newQueJobPlan(ctx, cclfFileNew.ID, conditions)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to retrieve MBIs for cmsID %s cclfFileID %d %s",
-			conditions.CMSID, cclfFileOld.ID, err.Error())
+		return nil, err
+	}
+	if len(plan.benes) == 0 {
+		return nil, fmt.Errorf("Found 0 new or existing beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
+			conditions.CMSID, cclfFileNew.ID)
 	}
 
-	// Retrieve all of the benes associated with this CCLF file.
-	benes, err := s.getBenesByFileID(ctx, cclfFileNew.ID, conditions)
+	xrefs, err := s.getMBIXrefs(ctx, conditions.CMSID)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	if len(benes) == 0 {
-		return nil, nil, fmt.Errorf("Found 0 new or existing beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
-			conditions.CMSID, cclfFileNew.ID)
+
+	// Split each page between new and old benes based on the existence of the bene in the old file.
+	// Benes whose MBI changed since the old file are considered existing benes.
+	// Only the MBIs on the page are looked up so the old file is never loaded in full.
+	plan.isNew = func(ctx context.Context, benes []*models.CCLFBeneficiary) ([]bool, error) {
+		var mbis []string
+		for _, bene := range benes {
+			mbis = append(mbis, xrefs.linked(bene.MBI)...)
+		}
+		oldMBIs, err := s.repository.GetCCLFBeneficiaryMBIsIn(ctx, cclfFileOld.ID, mbis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve MBIs for cmsID %s cclfFileID %d %s",
+				conditions.CMSID, cclfFileOld.ID, err.Error())
+		}
+
+		oldMBIMap := make(map[string]struct{}, len(oldMBIs))
+		for _, oldMBI := range oldMBIs {
+			oldMBIMap[oldMBI] = struct{}{}
+		}
+		isNew := make([]bool, len(benes))
+		for i, bene := range benes {
+			isNew[i] = !xrefs.linkedToAny(bene.MBI, oldMBIMap)
+		}
+		return isNew, nil
 	}
 
-	// Split the results beteween new and old benes based on the existence of the bene in the old map
-	oldMBIMap := make(map[string]struct{}, len(oldMBIs))
-	for _, oldMBI := range oldMBIs {
-		oldMBIMap[oldMBI] = struct{}{}
+	return plan, nil
+}
+
+// AttributionChanges contains the beneficiaries added to and removed from an ACO's attribution
+// between two CCLF8 files.
+type AttributionChanges struct {
+	CMSID string `json:"cmsId"`
+	// PreviousFile is nil when no earlier CCLF8 file exists; every beneficiary is then considered added.
+	PreviousFile *AttributionFile `json:"previousFile"`
+	CurrentFile  AttributionFile  `json:"currentFile"`
+
+	AddedCount   int      `json:"addedCount"`
+	RemovedCount int      `json:"removedCount"`
+	Added        []string `json:"added"`
+	Removed      []string `json:"removed"`
+}
+
+// AttributionFile identifies the CCLF8 file used as one side of an attribution comparison
+type AttributionFile struct {
+	Name      string    `json:"name"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetAttributionChanges compares the MBIs in the ACO's latest CCLF8 file with the MBIs in an earlier CCLF8 file.
+// When conditions.Since is set, the earlier file is the latest file delivered at or before that time
+// (the same file used to determine new beneficiaries for _since requests); otherwise it is the file delivered
+// immediately before the latest one.
+// Beneficiaries that were issued a new MBI between the two files are neither added nor removed.
+// Suppressed beneficiaries are omitted from both files, as they are from exports.
+func (s *service) GetAttributionChanges(ctx context.Context, conditions RequestConditions) (*AttributionChanges, error) {
+	if err := s.setTimeConstraints(ctx, conditions.ACOID, &conditions); err != nil {
+		return nil, fmt.Errorf("failed to set time constraints for caller: %w", err)
 	}
-	for _, bene := range benes {
-		if _, ok := oldMBIMap[bene.MBI]; ok {
-			beneficiaries = append(beneficiaries, bene)
-		} else {
-			newBeneficiaries = append(newBeneficiaries, bene)
+
+	var cutoffTime time.Time
+	if s.stdCutoffDuration > 0 && conditions.attributionDate.IsZero() {
+		cutoffTime = time.Now().Add(-1 * s.stdCutoffDuration)
+	}
+
+	cclfFileNew, err := s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum, constants.ImportComplete,
+		cutoffTime, conditions.attributionDate, models.FileTypeDefault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
+	}
+	if cclfFileNew == nil {
+		return nil, CCLFNotFoundError{8, conditions.CMSID, models.FileTypeDefault, cutoffTime}
+	}
+
+	// Timestamps are stored with microsecond precision so this excludes only the latest file
+	upperBound := cclfFileNew.Timestamp.Add(-1 * time.Microsecond)
+	if !conditions.Since.IsZero() {
+		upperBound = conditions.Since
+	}
+	cclfFileOld, err := s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, upperBound, models.FileTypeDefault)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old CCLF file for cmsID %s %s", conditions.CMSID, err.Error())
+	}
+
+	changes := &AttributionChanges{
+		CMSID:       conditions.CMSID,
+		CurrentFile: AttributionFile{cclfFileNew.Name, cclfFileNew.Timestamp},
+		Added:       []string{},
+		Removed:     []string{},
+	}
+
+	newMBIs, err := s.getMBIsByFileID(ctx, cclfFileNew.ID, conditions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve MBIs for cmsID %s cclfFileID %d: %w",
+			conditions.CMSID, cclfFileNew.ID, err)
+	}
+
+	var oldMBIs []string
+	if cclfFileOld != nil {
+		changes.PreviousFile = &AttributionFile{cclfFileOld.Name, cclfFileOld.Timestamp}
+		if oldMBIs, err = s.getMBIsByFileID(ctx, cclfFileOld.ID, conditions); err != nil {
+			return nil, fmt.Errorf("failed to retrieve MBIs for cmsID %s cclfFileID %d: %w",
+				conditions.CMSID, cclfFileOld.ID, err)
 		}
 	}
 
-	return newBeneficiaries, beneficiaries, nil
+	xrefs, err := s.getMBIXrefs(ctx, conditions.CMSID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Added = xrefs.difference(newMBIs, oldMBIs)
+	changes.Removed = xrefs.difference(oldMBIs, newMBIs)
+	changes.AddedCount, changes.RemovedCount = len(changes.Added), len(changes.Removed)
+
+	return changes, nil
+}
+
+// planBeneficiaries plans the queue jobs for a request that retrieves the data for every beneficiary attributed to the ACO.
+func (s *service) planBeneficiaries(ctx context.Context, conditions RequestConditions) (*queJobPlan, error) {
+	cclfFile, err := s.getLatestCCLFFile(ctx, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions.perfYear = 2000 + cclfFile.PerformanceYear
+	if _, ok := s.rp.forYear(conditions.perfYear); conditions.fileType == models.FileTypeRunout && !ok && len(s.rp.periods) > 0 {
+		s.logger.Warnf("No runout period configured for performance year %d of CCLF file %s; using the default runout claim thru date %s",
+			conditions.perfYear, cclfFile.Name, s.rp.claimThruDate.Format("2006-01-02"))
+	}
+	plan, err := s.newQueJobPlan(ctx, cclfFile.ID, conditions)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.benes) == 0 {
+		return nil, fmt.Errorf("Found 0 beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
+			conditions.CMSID, cclfFile.ID)
+	}
+
+	return plan, nil
 }
 
-func (s *service) getBeneficiaries(ctx context.Context, conditions RequestConditions) ([]*models.CCLFBeneficiary, error) {
+// getLatestCCLFFile returns the CCLF8 file that holds the ACO's current attribution for the requested file type.
+func (s *service) getLatestCCLFFile(ctx context.Context, conditions RequestConditions) (*models.CCLFFile, error) {
 	var (
 		cutoffTime time.Time
 	)
@@ -340,12 +477,26 @@ func (s *service) getBeneficiaries(ctx context.Context, conditions RequestCondit
 	if conditions.attributionDate.IsZero() {
 		if conditions.fileType == models.FileTypeDefault && s.stdCutoffDuration > 0 {
 			cutoffTime = time.Now().Add(-1 * s.stdCutoffDuration)
-		} else if conditions.fileType == models.FileTypeRunout && s.rp.cutoffDuration > 0 {
-			cutoffTime = time.Now().Add(-1 * s.rp.cutoffDuration)
+		} else if conditions.fileType == models.FileTypeRunout {
+			// The runout year has already been validated, and the latest runout file is found using the default cutoff
+			if rp, _ := s.rp.forYear(conditions.RunoutYear); rp.cutoffDuration > 0 {
+				cutoffTime = time.Now().Add(-1 * rp.cutoffDuration)
+			}
 		}
 	}
-	cclfFile, err := s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum,
-		constants.ImportComplete, cutoffTime, conditions.attributionDate, conditions.fileType)
+
+	var (
+		cclfFile *models.CCLFFile
+		err      error
+	)
+	if conditions.fileType == models.FileTypeRunout && conditions.RunoutYear > 0 {
+		// CCLF files store the performance year using two digits
+		cclfFile, err = s.repository.GetLatestCCLFFileForPerformanceYear(ctx, conditions.CMSID, cclf8FileNum,
+			constants.ImportComplete, cutoffTime, conditions.attributionDate, conditions.fileType, conditions.RunoutYear%100)
+	} else {
+		cclfFile, err = s.repository.GetLatestCCLFFile(ctx, conditions.CMSID, cclf8FileNum,
+			constants.ImportComplete, cutoffTime, conditions.attributionDate, conditions.fileType)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get CCLF file for cmsID %s fileType %d %s",
 			conditions.CMSID, conditions.fileType, err.Error())
@@ -354,36 +505,105 @@ func (s *service) getBeneficiaries(ctx context.Context, conditions RequestCondit
 		return nil, CCLFNotFoundError{8, conditions.CMSID, conditions.fileType, cutoffTime}
 	}
 
+	// The most recent runout file was found using the default cutoff, but its performance year may have a runout period
+	// that has already ended.
+	if conditions.fileType == models.FileTypeRunout && conditions.RunoutYear == 0 && conditions.attributionDate.IsZero() {
+		if rp, ok := s.rp.periods[2000+cclfFile.PerformanceYear]; ok && rp.cutoffDuration > 0 {
+			if cutoffTime = time.Now().Add(-1 * rp.cutoffDuration); cclfFile.Timestamp.Before(cutoffTime) {
+				return nil, CCLFNotFoundError{8, conditions.CMSID, conditions.fileType, cutoffTime}
+			}
+		}
+	}
+
+	return cclfFile, nil
+}
+
+// AttributionRoster contains the beneficiaries attributed to an ACO by its latest CCLF8 file.
+type AttributionRoster struct {
+	CMSID           string
+	File            AttributionFile
+	PerformanceYear int
+	// AttributionDate is set for terminated ACOs; the roster reflects the attribution in effect on this date.
+	AttributionDate time.Time
+
+	// MBIs of the attributed beneficiaries, excluding any beneficiaries that have opted out of data sharing
+	MBIs []string
+}
+
+// Period returns the performance year covered by the roster. CCLF files identify the performance year
+// by its last two digits. For terminated ACOs, the period ends on the attribution date.
+func (r *AttributionRoster) Period() (start, end time.Time) {
+	start = time.Date(2000+r.PerformanceYear, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end = time.Date(2000+r.PerformanceYear, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if !r.AttributionDate.IsZero() && r.AttributionDate.Before(end) {
+		end = r.AttributionDate.UTC()
+	}
+	return start, end
+}
+
+// GetAttributionRoster returns the beneficiaries attributed to the ACO with suppressions applied.
+// Unlike an export request, an ACO with no attributed beneficiaries results in an empty roster.
+func (s *service) GetAttributionRoster(ctx context.Context, conditions RequestConditions) (*AttributionRoster, error) {
+	if err := s.setTimeConstraints(ctx, conditions.ACOID, &conditions); err != nil {
+		return nil, fmt.Errorf("failed to set time constraints for caller: %w", err)
+	}
+	conditions.fileType = models.FileTypeDefault
+
+	cclfFile, err := s.getLatestCCLFFile(ctx, conditions)
+	if err != nil {
+		return nil, err
+	}
+
 	benes, err := s.getBenesByFileID(ctx, cclfFile.ID, conditions)
 	if err != nil {
 		return nil, err
 	}
-	if len(benes) == 0 {
-		return nil, fmt.Errorf("Found 0 beneficiaries from CCLF8 file for cmsID %s cclfFiledID %d",
-			conditions.CMSID, cclfFile.ID)
+
+	roster := &AttributionRoster{
+		CMSID:           conditions.CMSID,
+		File:            AttributionFile{cclfFile.Name, cclfFile.Timestamp},
+		PerformanceYear: cclfFile.PerformanceYear,
+		AttributionDate: conditions.attributionDate,
+		MBIs:            make([]string, 0, len(benes)),
+	}
+	for _, bene := range benes {
+		roster.MBIs = append(roster.MBIs, bene.MBI)
 	}
+	sort.Strings(roster.MBIs)
 
-	return benes, nil
+	return roster, nil
+}
+
+// GetSuppressionReport returns the reasons each beneficiary attributed to the ACO is excluded from,
+// or has SAMHSA data removed from, its exports. Beneficiaries without an applicable suppression are omitted.
+func (s *service) GetSuppressionReport(ctx context.Context, conditions RequestConditions) ([]*models.BeneficiarySuppression, error) {
+	if err := s.setTimeConstraints(ctx, conditions.ACOID, &conditions); err != nil {
+		return nil, fmt.Errorf("failed to set time constraints for caller: %w", err)
+	}
+	conditions.fileType = models.FileTypeDefault
+
+	cclfFile, err := s.getLatestCCLFFile(ctx, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressions, err := s.repository.GetBeneficiarySuppressions(ctx, cclfFile.ID, *s.suppressionCriteria(conditions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get beneficiary suppressions %s", err.Error())
+	}
+
+	return suppressions, nil
 }
 
 func (s *service) getBenesByFileID(ctx context.Context, cclfFileID uint, conditions RequestConditions) ([]*models.CCLFBeneficiary, error) {
-	var (
-		ignoredMBIs []string
-		err         error
-	)
+	// Suppressions are resolved by the repository so that only the preferences that apply
+	// to the caller's ACO, and to any of the beneficiary's MBIs, are considered.
+	var suppression *models.SuppressionCriteria
 	if !s.sp.includeSuppressedBeneficiaries {
-		upperBound := conditions.optOutDate
-		if conditions.optOutDate.IsZero() {
-			upperBound = time.Now()
-		}
-
-		ignoredMBIs, err = s.repository.GetSuppressedMBIs(ctx, s.sp.lookbackDays, upperBound)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retreive suppressedMBIs %s", err.Error())
-		}
+		suppression = s.suppressionCriteria(conditions)
 	}
 
-	benes, err := s.repository.GetCCLFBeneficiaries(ctx, cclfFileID, ignoredMBIs)
+	benes, err := s.repository.GetCCLFBeneficiaries(ctx, cclfFileID, suppression)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get beneficiaries %s", err.Error())
 	}
@@ -391,7 +611,99 @@ func (s *service) getBenesByFileID(ctx context.Context, cclfFileID uint, conditi
 	return benes, nil
 }
 
-// setTimeConstraints searches for any time bounds that we should apply on the associated ACO
+// getMBIsByFileID returns the MBIs of the beneficiaries found in the CCLF file with suppressions applied.
+func (s *service) getMBIsByFileID(ctx context.Context, cclfFileID uint, conditions RequestConditions) ([]string, error) {
+	benes, err := s.getBenesByFileID(ctx, cclfFileID, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	mbis := make([]string, 0, len(benes))
+	for _, bene := range benes {
+		mbis = append(mbis, bene.MBI)
+	}
+	return mbis, nil
+}
+
+// suppressionCriteria returns the window of suppression records that apply to the caller.
+func (s *service) suppressionCriteria(conditions RequestConditions) *models.SuppressionCriteria {
+	upperBound := conditions.optOutDate
+	if conditions.optOutDate.IsZero() {
+		upperBound = time.Now()
+	}
+
+	lookbackDuration := time.Duration(-1*s.sp.lookbackDays*24) * time.Hour
+	return &models.SuppressionCriteria{
+		CMSID:      conditions.CMSID,
+		LowerBound: upperBound.Add(lookbackDuration),
+		UpperBound: upperBound,
+	}
+}
+
+func (s *service) getMBIXrefs(ctx context.Context, cmsID string) (mbiXrefs, error) {
+	xrefs, err := s.repository.GetMBIXrefs(ctx, cmsID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve MBI crosswalk for cmsID %s %s", cmsID, err.Error())
+	}
+	return newMBIXrefs(xrefs), nil
+}
+
+// mbiXrefs links each MBI to the other MBIs that were issued to the same beneficiary
+type mbiXrefs map[string][]string
+
+func newMBIXrefs(xrefs []*models.CCLFBeneficiaryXref) mbiXrefs {
+	x := make(mbiXrefs)
+	for _, xref := range xrefs {
+		x[xref.CurrentNum] = append(x[xref.CurrentNum], xref.PrevNum)
+		x[xref.PrevNum] = append(x[xref.PrevNum], xref.CurrentNum)
+	}
+	return x
+}
+
+// linked returns the supplied MBI followed by every other MBI issued to the same beneficiary.
+// Beneficiaries that were issued more than two MBIs are linked through each crosswalk entry.
+func (x mbiXrefs) linked(mbi string) []string {
+	linked := []string{mbi}
+	seen := map[string]struct{}{mbi: {}}
+	for i := 0; i < len(linked); i++ {
+		for _, other := range x[linked[i]] {
+			if _, ok := seen[other]; !ok {
+				seen[other] = struct{}{}
+				linked = append(linked, other)
+			}
+		}
+	}
+	return linked
+}
+
+// linkedToAny reports whether the supplied MBI, or any other MBI issued to the same beneficiary, is found in mbis.
+func (x mbiXrefs) linkedToAny(mbi string, mbis map[string]struct{}) bool {
+	for _, linked := range x.linked(mbi) {
+		if _, ok := mbis[linked]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// difference returns the sorted MBIs in a whose beneficiaries are not found in b.
+func (x mbiXrefs) difference(a, b []string) []string {
+	bMap := make(map[string]struct{}, len(b))
+	for _, mbi := range b {
+		bMap[mbi] = struct{}{}
+	}
+
+	diff := []string{}
+	for _, mbi := range a {
+		if !x.linkedToAny(mbi, bMap) {
+			diff = append(diff, mbi)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+// setTimeConstraints searches for any time bounds that we should apply on the associated ACO.
 func (s *service) setTimeConstraints(ctx context.Context, acoID uuid.UUID, conditions *RequestConditions) error {
 	aco, err := s.repository.GetACOByUUID(ctx, acoID)
 	if err != nil {
@@ -403,15 +715,50 @@ func (s *service) setTimeConstraints(ctx context.Context, acoID uuid.UUID, condi
 		conditions.attributionDate = time.Time{}
 		conditions.claimsDate = time.Time{}
 		conditions.optOutDate = time.Time{}
+		conditions.termination = nil
 		return nil
 	}
 
+	conditions.termination = aco.TerminationDetails
 	conditions.attributionDate = aco.TerminationDetails.AttributionDate()
 	conditions.claimsDate = aco.TerminationDetails.ClaimsDate()
 	conditions.optOutDate = aco.TerminationDetails.OptOutDate()
 	return nil
 }
 
+// checkLimitedAccess verifies that an ACO with limited access is permitted to make the request.
+// Patient level requests are treated as requests for the "all" group since they return the same beneficiaries.
+func checkLimitedAccess(termination *models.Termination, conditions RequestConditions) error {
+	if termination == nil || !termination.IsLimited() {
+		return nil
+	}
+
+	if termination.CutoffPassed(time.Now()) {
+		return LimitedAccessError{conditions.CMSID,
+			fmt.Sprintf("access ended on %s", termination.CutoffDate.Format(time.RFC3339))}
+	}
+
+	group := "all"
+	if conditions.ReqType == Runout {
+		group = "runout"
+	}
+	if !termination.AllowsGroup(group) {
+		return LimitedAccessError{conditions.CMSID,
+			fmt.Sprintf("group %s is not available since access was limited on %s",
+				group, termination.TerminationDate.Format(time.RFC3339))}
+	}
+
+	for _, rt := range conditions.Resources {
+		if !termination.AllowsResourceType(rt) {
+			return LimitedAccessError{conditions.CMSID,
+				fmt.Sprintf("resource type %s is not available since access was limited on %s",
+					rt, termination.TerminationDate.Format(time.RFC3339))}
+		}
+	}
+
+	return nil
+}
+
 // setClaimsDate computes the claims window to apply on the args
 func (s *service) setClaimsDate(args *models.JobEnqueueArgs, conditions RequestConditions) {
 
@@ -419,7 +766,8 @@ func (s *service) setClaimsDate(args *models.JobEnqueueArgs, conditions RequestC
 	// it takes precedence over any other claims date
 	// that may be applied
 	if conditions.ReqType == Runout {
-		args.ClaimsWindow.UpperBound = s.rp.claimThruDate
+		rp, _ := s.rp.forYear(conditions.perfYear)
+		args.ClaimsWindow.UpperBound = rp.claimThruDate
 	} else if !conditions.claimsDate.IsZero() {
 		args.ClaimsWindow.UpperBound = conditions.claimsDate
 	}
@@ -437,35 +785,6 @@ func (s *service) setClaimsDate(args *models.JobEnqueueArgs, conditions RequestC
 	args.ServiceDate = args.ClaimsWindow.UpperBound
 }
 
-// Gets the priority for the job where the lower the number the higher the priority in the queue.
-// Priority is based on the request parameters that the job is executing on.
-func (s *service) GetJobPriority(acoID string, resourceType string, sinceParam bool) int16 {
-	var priority int16
-	if isPriorityACO(acoID) {
-		priority = int16(10) // priority level for jobs for synthetic ACOs that are used for smoke testing
-	} else if resourceType == "Patient" || resourceType == "Coverage" {
-		priority = int16(20) // priority level for jobs that only request smaller resources
-	} else if sinceParam {
-		priority = int16(30) // priority level for jobs that only request data for a limited timeframe
-	} else {
-		priority = int16(100) // default priority level for jobs
-	}
-	return priority
-}
-
-// Checks to see if an ACO is priority ACO based on a regex pattern provided by an
-// environment variable.
-func isPriorityACO(acoID string) bool {
-	if priorityACOPattern := conf.GetEnv("PRIORITY_ACO_REG_EX"); priorityACOPattern != "" {
-		var priorityACORegex = regexp.MustCompile(priorityACOPattern)
-		if priorityACORegex.MatchString(acoID) {
-			return true
-		}
-	}
-	return false
-
-}
-
 func getMaxBeneCount(requestType string) (int, error) {
 	const (
 		BCDA_FHIR_MAX_RECORDS_EOB_DEFAULT      = 200
@@ -522,6 +841,26 @@ func (e CCLFNotFoundError) Error() string {
 		e.FileNumber, e.CMSID, e.FileType, e.CutoffTime.String())
 }
 
+// LimitedAccessError indicates that an ACO with limited access is not permitted to make the request
+type LimitedAccessError struct {
+	CMSID  string
+	Reason string
+}
+
+func (e LimitedAccessError) Error() string {
+	return fmt.Sprintf("ACO (CMS_ID: %s) has limited access; %s", e.CMSID, e.Reason)
+}
+
+// RunoutYearNotConfiguredError indicates that the caller requested the runout data of a performance year
+// without a configured runout period
+type RunoutYearNotConfiguredError struct {
+	PerformanceYear int
+}
+
+func (e RunoutYearNotConfiguredError) Error() string {
+	return fmt.Sprintf("no runout period is configured for performance year %d", e.PerformanceYear)
+}
+
 var (
 	ErrJobNotCancelled   = goerrors.New("Job was not cancelled due to internal server error.")
 	ErrJobNotCancellable = goerrors.New("Job was not cancelled because it is not Pending or In Progress")
@@ -15,6 +15,7 @@ import (
 	"github.com/CMSgov/bcda-app/bcda/testUtils"
 	"github.com/CMSgov/bcda-app/conf"
 	"github.com/pborman/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -166,7 +167,7 @@ func (s *ServiceTestSuite) TestIncludeSuppressedBeneficiaries() {
 			getCCLFFile(1),
 			getCCLFFile(2),
 			func(serv *service) error {
-				_, _, err := serv.getNewAndExistingBeneficiaries(context.Background(), conditions)
+				_, err := serv.planNewAndExistingBeneficiaries(context.Background(), conditions)
 				return err
 			},
 		},
@@ -175,7 +176,7 @@ func (s *ServiceTestSuite) TestIncludeSuppressedBeneficiaries() {
 			getCCLFFile(3),
 			nil,
 			func(serv *service) error {
-				_, err := serv.getBeneficiaries(context.Background(), conditions)
+				_, err := serv.planBeneficiaries(context.Background(), conditions)
 				return err
 			},
 		},
@@ -189,17 +190,20 @@ func (s *ServiceTestSuite) TestIncludeSuppressedBeneficiaries() {
 			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(timeIsSetMatcher), time.Time{}, models.FileTypeDefault).Return(tt.cclfFileNew, nil)
 			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, mock.Anything, mock.Anything, mock.Anything, time.Time{}, mock.MatchedBy(timeIsSetMatcher), models.FileTypeDefault).Return(tt.cclfFileOld, nil)
 			if tt.cclfFileOld != nil {
-				repository.On("GetCCLFBeneficiaryMBIs", testUtils.CtxMatcher, tt.cclfFileOld.ID).Return([]string{"1", "2", "3"}, nil)
+				repository.On("GetCCLFBeneficiaryMBIsIn", testUtils.CtxMatcher, tt.cclfFileOld.ID, mock.Anything).Return(mbisInFile([]string{"1", "2", "3"}), nil)
+				repository.On("GetMBIXrefs", testUtils.CtxMatcher, conditions.CMSID).Return(nil, nil)
 			}
 
-			var suppressedMBIs []string
-			repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, tt.cclfFileNew.ID, suppressedMBIs).Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "1")}, nil)
+			var suppression *models.SuppressionCriteria
+			repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, tt.cclfFileNew.ID, suppression, uint(0), defaultBeneficiaryBatchSize).
+				Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "1")}, uint(0), nil)
 			serviceInstance := &service{repository: repository, sp: sp, stdCutoffDuration: 1 * time.Hour}
 
 			err := tt.funcUnderTest(serviceInstance)
 			assert.NoError(t, err)
 
-			repository.AssertNotCalled(t, "GetSuppressedMBIs", testUtils.CtxMatcher, lookbackDays, time.Time{})
+			// No suppression criteria should be applied
+			repository.AssertCalled(t, "GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, tt.cclfFileNew.ID, suppression, uint(0), defaultBeneficiaryBatchSize)
 		})
 	}
 }
@@ -270,9 +274,9 @@ func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiaries() {
 			now := time.Now().Round(time.Millisecond)
 			// Since we're using time.Now() within the service call, we can't compare directly.
 			// Make sure we're close enough.
-			mockUpperBound := mock.MatchedBy(func(t time.Time) bool {
+			isNow := func(t time.Time) bool {
 				return now.Sub(t) < time.Second
-			})
+			}
 
 			var benes []*models.CCLFBeneficiary
 			oldMBIs := make(map[string]bool)
@@ -306,13 +310,13 @@ func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiaries() {
 			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, fileNum, constants.ImportComplete, time.Time{}, since, models.FileTypeDefault).Return(tt.cclfFileOld, nil)
 
 			if tt.cclfFileOld != nil {
-				repository.On("GetCCLFBeneficiaryMBIs", testUtils.CtxMatcher, tt.cclfFileOld.ID).Return(tt.oldMBIs, nil)
+				repository.On("GetCCLFBeneficiaryMBIsIn", testUtils.CtxMatcher, tt.cclfFileOld.ID, mock.Anything).Return(mbisInFile(tt.oldMBIs), nil)
 			}
-			suppressedMBI := "suppressedMBI"
 			if tt.cclfFileNew != nil {
-				repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, tt.cclfFileNew.ID, []string{suppressedMBI}).Return(benes, nil)
+				repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, tt.cclfFileNew.ID,
+					suppressionCriteriaMatcher(cmsID, lookbackDays, isNow), uint(0), defaultBeneficiaryBatchSize).Return(benes, uint(0), nil)
 			}
-			repository.On("GetSuppressedMBIs", testUtils.CtxMatcher, lookbackDays, mockUpperBound).Return([]string{suppressedMBI}, nil)
+			repository.On("GetMBIXrefs", testUtils.CtxMatcher, cmsID).Return(nil, nil)
 
 			cfg := &Config{
 				cutoffDuration:          time.Hour,
@@ -323,7 +327,7 @@ func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiaries() {
 				},
 			}
 			serviceInstance := NewService(repository, cfg, "").(*service)
-			newBenes, oldBenes, err := serviceInstance.getNewAndExistingBeneficiaries(context.Background(),
+			plan, err := serviceInstance.planNewAndExistingBeneficiaries(context.Background(),
 				RequestConditions{CMSID: "cmsID", Since: since, fileType: models.FileTypeDefault})
 
 			if tt.expectedErr != nil {
@@ -334,6 +338,8 @@ func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiaries() {
 			}
 			assert.NoError(t, err)
 
+			newBenes, oldBenes := splitBeneficiaries(plan)
+
 			for _, bene := range oldBenes {
 				assert.True(t, oldMBIs[bene.MBI], "MBI %s should be found in old MBI map %v", bene.MBI, oldMBIs)
 			}
@@ -345,6 +351,45 @@ func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiaries() {
 	}
 }
 
+// TestGetNewAndExistingBeneficiariesWithMBIXrefs verifies that beneficiaries that were issued a new MBI
+// are treated as the same beneficiary when determining attribution changes.
+func (s *ServiceTestSuite) TestGetNewAndExistingBeneficiariesWithMBIXrefs() {
+	cmsID := "cmsID"
+	since := time.Now().Add(-1 * time.Hour)
+	cclfFileNew, cclfFileOld := getCCLFFile(1), getCCLFFile(2)
+
+	xrefs := []*models.CCLFBeneficiaryXref{
+		// Beneficiary was issued MBI2 then MBI3, replacing MBI1
+		{CurrentNum: "MBI2", PrevNum: "MBI1"},
+		{CurrentNum: "MBI3", PrevNum: "MBI2"},
+	}
+
+	repository := &models.MockRepository{}
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		mock.MatchedBy(timeIsSetMatcher), time.Time{}, models.FileTypeDefault).Return(cclfFileNew, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, since, models.FileTypeDefault).Return(cclfFileOld, nil)
+	repository.On("GetCCLFBeneficiaryMBIsIn", testUtils.CtxMatcher, cclfFileOld.ID, mock.Anything).Return(mbisInFile([]string{"MBI1"}), nil)
+	repository.On("GetMBIXrefs", testUtils.CtxMatcher, cmsID).Return(xrefs, nil)
+	repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, cclfFileNew.ID, suppressionCriteriaMatcher(cmsID, 30, timeIsSetMatcher),
+		uint(0), defaultBeneficiaryBatchSize).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI3"), getCCLFBeneficiary(2, "MBI4")}, uint(0), nil)
+
+	cfg := &Config{cutoffDuration: time.Hour, SuppressionLookbackDays: 30}
+	serviceInstance := NewService(repository, cfg, "").(*service)
+	plan, err := serviceInstance.planNewAndExistingBeneficiaries(context.Background(),
+		RequestConditions{CMSID: cmsID, Since: since, fileType: models.FileTypeDefault})
+	assert.NoError(s.T(), err)
+
+	newBenes, oldBenes := splitBeneficiaries(plan)
+
+	assert.Len(s.T(), oldBenes, 1)
+	assert.Equal(s.T(), "MBI3", oldBenes[0].MBI)
+	assert.Len(s.T(), newBenes, 1)
+	assert.Equal(s.T(), "MBI4", newBenes[0].MBI)
+	repository.AssertExpectations(s.T())
+}
+
 func (s *ServiceTestSuite) TestGetBeneficiaries() {
 	tests := []struct {
 		name        string
@@ -388,9 +433,9 @@ func (s *ServiceTestSuite) TestGetBeneficiaries() {
 			now := time.Now().Round(time.Millisecond)
 			// Since we're using time.Now() within the service call, we can't compare directly.
 			// Make sure we're close enough.
-			mockUpperBound := mock.MatchedBy(func(t time.Time) bool {
+			isNow := func(t time.Time) bool {
 				return now.Sub(t) < time.Second
-			})
+			}
 
 			var benes []*models.CCLFBeneficiary
 			mbis := make(map[string]bool)
@@ -420,10 +465,9 @@ func (s *ServiceTestSuite) TestGetBeneficiaries() {
 				}),
 				time.Time{}, tt.fileType).Return(tt.cclfFile, nil)
 
-			suppressedMBI := "suppressedMBI"
-			repository.On("GetSuppressedMBIs", testUtils.CtxMatcher, lookbackDays, mockUpperBound).Return([]string{suppressedMBI}, nil)
 			if tt.cclfFile != nil {
-				repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, tt.cclfFile.ID, []string{suppressedMBI}).Return(benes, nil)
+				repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, tt.cclfFile.ID,
+					suppressionCriteriaMatcher(cmsID, lookbackDays, isNow), uint(0), defaultBeneficiaryBatchSize).Return(benes, uint(0), nil)
 			}
 
 			cfg := &Config{
@@ -435,7 +479,7 @@ func (s *ServiceTestSuite) TestGetBeneficiaries() {
 				},
 			}
 			serviceInstance := NewService(repository, cfg, "").(*service)
-			benes, err := serviceInstance.getBeneficiaries(context.Background(),
+			plan, err := serviceInstance.planBeneficiaries(context.Background(),
 				RequestConditions{CMSID: "cmsID", fileType: tt.fileType})
 
 			if tt.expectedErr != nil {
@@ -446,14 +490,15 @@ func (s *ServiceTestSuite) TestGetBeneficiaries() {
 			}
 			assert.NoError(t, err)
 
-			for _, bene := range benes {
+			assert.Len(t, plan.benes, len(benes))
+			for _, bene := range plan.benes {
 				assert.True(t, mbis[bene.MBI], "MBI %s should be found in MBI map %v", bene.MBI, mbis)
 			}
 		})
 	}
 }
 
-func (s *ServiceTestSuite) TestGetQueJobs() {
+func (s *ServiceTestSuite) TestPlanQueJobs() {
 	defaultACOID, lookbackACOID := "SOME_ACO_ID", "LOOKBACK_ACO"
 
 	acoCfg := ACOConfig{
@@ -565,10 +610,10 @@ func (s *ServiceTestSuite) TestGetQueJobs() {
 			repository.On("GetACOByUUID", testUtils.CtxMatcher, conditions.ACOID).
 				Return(&models.ACO{UUID: conditions.ACOID, TerminationDetails: tt.terminationDetails}, nil)
 			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(getCCLFFile(1), nil)
-			repository.On("GetSuppressedMBIs", testUtils.CtxMatcher, mock.Anything, mock.Anything).Return(nil, nil)
-			repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, mock.Anything, mock.Anything).Return(tt.expBenes, nil)
+			repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, mock.Anything, mock.Anything, uint(0), mock.Anything).Return(tt.expBenes, uint(0), nil)
 			// use benes1 as the "old" benes. Allows us to verify the since parameter is populated as expected
-			repository.On("GetCCLFBeneficiaryMBIs", testUtils.CtxMatcher, mock.Anything).Return(benes1MBI, nil)
+			repository.On("GetCCLFBeneficiaryMBIsIn", testUtils.CtxMatcher, mock.Anything, mock.Anything).Return(mbisInFile(benes1MBI), nil)
+			repository.On("GetMBIXrefs", testUtils.CtxMatcher, mock.Anything).Return(nil, nil)
 
 			cfg := &Config{
 				cutoffDuration:          time.Hour,
@@ -581,7 +626,9 @@ func (s *ServiceTestSuite) TestGetQueJobs() {
 			serviceInstance := NewService(repository, cfg, basePath)
 			serviceInstance.(*service).acoConfig = acoCfgs
 
-			queJobs, err := serviceInstance.GetQueJobs(context.Background(), conditions)
+			plan, err := serviceInstance.PlanQueJobs(context.Background(), conditions)
+			assert.NoError(t, err)
+			queJobs, err := executePlan(plan)
 			assert.NoError(t, err)
 			// map tuple of resourceType:beneID
 			benesInJob := make(map[string]map[string]struct{})
@@ -636,18 +683,412 @@ func (s *ServiceTestSuite) TestGetQueJobs() {
 	}
 }
 
-func (s *ServiceTestSuite) TestGetQueJobsFailedACOLookup() {
+// TestPlanQueJobsRunoutPeriods verifies runout requests use the runout period of the CCLF file's performance year
+func (s *ServiceTestSuite) TestPlanQueJobsRunoutPeriods() {
+	cmsID := "A0000"
+	claimThru2021 := time.Date(2021, time.December, 31, 0, 0, 0, 0, time.UTC)
+	cutoff2021 := 90 * 24 * time.Hour
+	isCutoff := func(cutoff time.Duration) interface{} {
+		return mock.MatchedBy(func(t time.Time) bool {
+			return time.Now().Add(-1*cutoff).Sub(t) < time.Second
+		})
+	}
+
+	tests := []struct {
+		name         string
+		runoutYear   int
+		file         *models.CCLFFile
+		expClaimThru time.Time
+		expNotFound  bool
+	}{
+		{"Requested year", 2021, &models.CCLFFile{ID: 1, PerformanceYear: 21, Timestamp: time.Now()}, claimThru2021, false},
+		{"Latest file", 0, &models.CCLFFile{ID: 2, PerformanceYear: 21, Timestamp: time.Now().Add(-10 * 24 * time.Hour)}, claimThru2021, false},
+		{"Latest file without period", 0, &models.CCLFFile{ID: 3, PerformanceYear: 20, Timestamp: time.Now()}, defaultRunoutClaimThru, false},
+		{"Latest file period ended", 0, &models.CCLFFile{ID: 4, PerformanceYear: 21, Timestamp: time.Now().Add(-100 * 24 * time.Hour)}, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			conditions := RequestConditions{CMSID: cmsID, ACOID: uuid.NewRandom(), ReqType: Runout,
+				Resources: []string{"ExplanationOfBenefit"}, RunoutYear: tt.runoutYear}
+
+			repository := &models.MockRepository{}
+			defer repository.AssertExpectations(t)
+			repository.On("GetACOByUUID", testUtils.CtxMatcher, conditions.ACOID).Return(&models.ACO{UUID: conditions.ACOID}, nil)
+			if tt.runoutYear > 0 {
+				repository.On("GetLatestCCLFFileForPerformanceYear", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+					isCutoff(cutoff2021), time.Time{}, models.FileTypeRunout, tt.runoutYear%100).Return(tt.file, nil)
+			} else {
+				repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+					isCutoff(defaultRunoutCutoff), time.Time{}, models.FileTypeRunout).Return(tt.file, nil)
+			}
+			if !tt.expNotFound {
+				repository.On("GetCCLFBeneficiariesAfter", testUtils.CtxMatcher, tt.file.ID, mock.Anything, uint(0), mock.Anything).
+					Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1")}, uint(0), nil)
+			}
+
+			cfg := &Config{
+				RunoutConfig: RunoutConfig{
+					cutoffDuration: defaultRunoutCutoff,
+					claimThru:      defaultRunoutClaimThru,
+					Periods:        []RunoutPeriod{{PerformanceYear: 2021, cutoffDuration: cutoff2021, claimThru: claimThru2021}},
+				},
+			}
+			serviceInstance := NewService(repository, cfg, "")
+			logger, hook := test.NewNullLogger()
+			serviceInstance.(*service).logger = logger
+			plan, err := serviceInstance.PlanQueJobs(context.Background(), conditions)
+			if tt.expNotFound {
+				assert.IsType(t, CCLFNotFoundError{}, err)
+				return
+			}
+			assert.NoError(t, err)
+			// Files of a performance year without a runout period are planned with a warning
+			if tt.file.PerformanceYear != 21 {
+				assert.Contains(t, hook.LastEntry().Message, "No runout period configured for performance year 2020")
+			} else {
+				assert.Nil(t, hook.LastEntry())
+			}
+
+			queJobs, err := executePlan(plan)
+			assert.NoError(t, err)
+			assert.Len(t, queJobs, 1)
+			assert.True(t, tt.expClaimThru.Equal(queJobs[0].ClaimsWindow.UpperBound),
+				"Upper bounds should equal. Have %s. Want %s", queJobs[0].ClaimsWindow.UpperBound, tt.expClaimThru)
+		})
+	}
+}
+
+// TestPlanQueJobsUnconfiguredRunoutYear verifies that runout data cannot be requested for a performance year
+// without a runout period, since the default runout parameters may not apply to it
+func (s *ServiceTestSuite) TestPlanQueJobsUnconfiguredRunoutYear() {
+	conditions := RequestConditions{CMSID: "A0000", ACOID: uuid.NewRandom(), ReqType: Runout,
+		Resources: []string{"ExplanationOfBenefit"}, RunoutYear: 2020}
+
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, conditions.ACOID).Return(&models.ACO{UUID: conditions.ACOID}, nil)
+
+	cfg := &Config{
+		RunoutConfig: RunoutConfig{
+			cutoffDuration: defaultRunoutCutoff,
+			claimThru:      defaultRunoutClaimThru,
+			Periods:        []RunoutPeriod{{PerformanceYear: 2021, cutoffDuration: defaultRunoutCutoff, claimThru: defaultRunoutClaimThru}},
+		},
+	}
+	plan, err := NewService(repository, cfg, "").PlanQueJobs(context.Background(), conditions)
+	assert.Nil(s.T(), plan)
+	assert.Equal(s.T(), RunoutYearNotConfiguredError{2020}, err)
+	repository.AssertNotCalled(s.T(), "GetLatestCCLFFileForPerformanceYear", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ServiceTestSuite) TestPlanQueJobsLimitedAccess() {
+	terminationDate := time.Now().Add(-30 * 24 * time.Hour).Round(time.Second)
+	cutoffDate := time.Now().Add(-24 * time.Hour).Round(time.Second)
+	active := &models.Termination{
+		TerminationDate:      terminationDate,
+		CutoffDate:           time.Now().Add(24 * time.Hour),
+		BlacklistType:        models.Limited,
+		AllowedResourceTypes: []string{"ExplanationOfBenefit"},
+		AllowedGroups:        []string{"runout"},
+	}
+	ended := &models.Termination{TerminationDate: terminationDate, CutoffDate: cutoffDate, BlacklistType: models.Limited}
+
+	tests := []struct {
+		name        string
+		termination *models.Termination
+		reqType     RequestType
+		resources   []string
+		errMsg      string
+	}{
+		{"After cutoff", ended, Runout, []string{"ExplanationOfBenefit"},
+			fmt.Sprintf("access ended on %s", cutoffDate.Format(time.RFC3339))},
+		{"Group not allowed", active, DefaultRequest, []string{"ExplanationOfBenefit"},
+			fmt.Sprintf("group all is not available since access was limited on %s", terminationDate.Format(time.RFC3339))},
+		{"New benes group not allowed", active, RetrieveNewBeneHistData, []string{"ExplanationOfBenefit"},
+			"group all is not available"},
+		{"Resource type not allowed", active, Runout, []string{"ExplanationOfBenefit", "Coverage"},
+			fmt.Sprintf("resource type Coverage is not available since access was limited on %s", terminationDate.Format(time.RFC3339))},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			conditions := RequestConditions{CMSID: "A0000", ACOID: uuid.NewRandom(), ReqType: tt.reqType, Resources: tt.resources}
+			repository := &models.MockRepository{}
+			repository.On("GetACOByUUID", testUtils.CtxMatcher, conditions.ACOID).
+				Return(&models.ACO{UUID: conditions.ACOID, TerminationDetails: tt.termination}, nil)
+			defer repository.AssertExpectations(t)
+
+			service := &service{repository: repository}
+			plan, err := service.PlanQueJobs(context.Background(), conditions)
+			assert.Nil(t, plan)
+
+			var limitedErr LimitedAccessError
+			assert.True(t, errors.As(err, &limitedErr), "Error should be a LimitedAccessError")
+			assert.Equal(t, conditions.CMSID, limitedErr.CMSID)
+			assert.Contains(t, limitedErr.Reason, tt.errMsg)
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestPlanQueJobsFailedACOLookup() {
 	conditions := RequestConditions{ACOID: uuid.NewRandom()}
 	repository := &models.MockRepository{}
 	repository.On("GetACOByUUID", testUtils.CtxMatcher, conditions.ACOID).
 		Return(nil, context.DeadlineExceeded)
 	defer repository.AssertExpectations(s.T())
 	service := &service{repository: repository}
-	queJobs, err := service.GetQueJobs(context.Background(), conditions)
-	assert.Nil(s.T(), queJobs)
+	plan, err := service.PlanQueJobs(context.Background(), conditions)
+	assert.Nil(s.T(), plan)
 	assert.True(s.T(), errors.Is(err, context.DeadlineExceeded), "Root cause should be deadline exceeded")
 }
 
+func (s *ServiceTestSuite) TestGetAttributionChanges() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	since := time.Now().Add(-1 * time.Hour)
+	newFile := &models.CCLFFile{ID: 1, Name: "T.BCD.A0000.ZC8Y20.D201201.T1000000", Timestamp: time.Now().Add(-30 * time.Minute)}
+	oldFile := &models.CCLFFile{ID: 2, Name: "T.BCD.A0000.ZC8Y20.D201101.T1000000", Timestamp: time.Now().Add(-2 * time.Hour)}
+
+	tests := []struct {
+		name       string
+		since      time.Time
+		upperBound time.Time
+		oldFile    *models.CCLFFile
+		added      []string
+		removed    []string
+	}{
+		{"Previous delivery", time.Time{}, newFile.Timestamp.Add(-1 * time.Microsecond), oldFile,
+			[]string{"MBI4"}, []string{"MBI2"}},
+		{"Since", since, since, oldFile, []string{"MBI4"}, []string{"MBI2"}},
+		{"No previous delivery", time.Time{}, newFile.Timestamp.Add(-1 * time.Microsecond), nil,
+			[]string{"MBI3", "MBI4", "MBI5"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			repository := &models.MockRepository{}
+			defer repository.AssertExpectations(t)
+			repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).Return(&models.ACO{UUID: acoID}, nil)
+			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+				mock.MatchedBy(timeIsSetMatcher), time.Time{}, models.FileTypeDefault).Return(newFile, nil)
+			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+				time.Time{}, tt.upperBound, models.FileTypeDefault).Return(tt.oldFile, nil)
+			// Suppressed beneficiaries are filtered by the repository so they never appear in the changes
+			repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, newFile.ID,
+				suppressionCriteriaMatcher(cmsID, 30, timeIsSetMatcher)).
+				Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(5, "MBI5"), getCCLFBeneficiary(4, "MBI4"),
+					getCCLFBeneficiary(3, "MBI3")}, nil)
+			if tt.oldFile != nil {
+				repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, tt.oldFile.ID,
+					suppressionCriteriaMatcher(cmsID, 30, timeIsSetMatcher)).
+					Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1"), getCCLFBeneficiary(2, "MBI2"),
+						getCCLFBeneficiary(6, "MBI5")}, nil)
+			}
+			// MBI3 replaced MBI1 so the beneficiary is neither added nor removed
+			repository.On("GetMBIXrefs", testUtils.CtxMatcher, cmsID).
+				Return([]*models.CCLFBeneficiaryXref{{CurrentNum: "MBI3", PrevNum: "MBI1"}}, nil)
+
+			cfg := &Config{cutoffDuration: time.Hour, SuppressionLookbackDays: 30}
+			serviceInstance := NewService(repository, cfg, "")
+			changes, err := serviceInstance.GetAttributionChanges(context.Background(),
+				RequestConditions{CMSID: cmsID, ACOID: acoID, Since: tt.since})
+			assert.NoError(t, err)
+
+			assert.Equal(t, cmsID, changes.CMSID)
+			assert.Equal(t, AttributionFile{newFile.Name, newFile.Timestamp}, changes.CurrentFile)
+			if tt.oldFile != nil {
+				assert.Equal(t, &AttributionFile{oldFile.Name, oldFile.Timestamp}, changes.PreviousFile)
+			} else {
+				assert.Nil(t, changes.PreviousFile)
+			}
+			assert.Equal(t, tt.added, changes.Added)
+			assert.Equal(t, tt.removed, changes.Removed)
+			assert.Equal(t, len(tt.added), changes.AddedCount)
+			assert.Equal(t, len(tt.removed), changes.RemovedCount)
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestGetAttributionChangesNoCCLFFile() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).Return(&models.ACO{UUID: acoID}, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, time.Time{}, models.FileTypeDefault).Return(nil, nil)
+
+	serviceInstance := NewService(repository, &Config{}, "")
+	changes, err := serviceInstance.GetAttributionChanges(context.Background(), RequestConditions{CMSID: cmsID, ACOID: acoID})
+	assert.Nil(s.T(), changes)
+	assert.IsType(s.T(), CCLFNotFoundError{}, err)
+}
+
+func (s *ServiceTestSuite) TestGetAttributionRoster() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	termination := &models.Termination{
+		AttributionStrategy: models.AttributionHistorical,
+		OptOutStrategy:      models.OptOutHistorical,
+		TerminationDate:     time.Now().Add(-30 * 24 * time.Hour).Round(time.Millisecond).UTC(),
+	}
+	// Performance year the ACO was terminated in so the roster period ends on the termination date
+	perfYear := termination.TerminationDate.Year()
+	cclfFile := &models.CCLFFile{ID: 1, Name: "T.BCD.A0000.ZC8Y20.D201201.T1000000", PerformanceYear: perfYear % 100,
+		Timestamp: time.Now().Add(-30 * time.Minute)}
+
+	tests := []struct {
+		name            string
+		termination     *models.Termination
+		cutoffMatcher   interface{}
+		attributionDate time.Time
+		optOutMatcher   func(time.Time) bool
+		benes           []*models.CCLFBeneficiary
+		expectedMBIs    []string
+	}{
+		{"Active ACO", nil, mock.MatchedBy(timeIsSetMatcher), time.Time{}, timeIsSetMatcher,
+			[]*models.CCLFBeneficiary{getCCLFBeneficiary(2, "MBI2"), getCCLFBeneficiary(1, "MBI1")}, []string{"MBI1", "MBI2"}},
+		{"Terminated ACO", termination, time.Time{}, termination.TerminationDate, termination.TerminationDate.Equal,
+			[]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1")}, []string{"MBI1"}},
+		{"No beneficiaries", nil, mock.MatchedBy(timeIsSetMatcher), time.Time{}, timeIsSetMatcher,
+			nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			repository := &models.MockRepository{}
+			defer repository.AssertExpectations(t)
+			repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).
+				Return(&models.ACO{UUID: acoID, TerminationDetails: tt.termination}, nil)
+			repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+				tt.cutoffMatcher, tt.attributionDate, models.FileTypeDefault).Return(cclfFile, nil)
+			repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, cclfFile.ID,
+				suppressionCriteriaMatcher(cmsID, 30, tt.optOutMatcher)).Return(tt.benes, nil)
+
+			cfg := &Config{cutoffDuration: time.Hour, SuppressionLookbackDays: 30}
+			serviceInstance := NewService(repository, cfg, "")
+			roster, err := serviceInstance.GetAttributionRoster(context.Background(),
+				RequestConditions{CMSID: cmsID, ACOID: acoID})
+			assert.NoError(t, err)
+
+			assert.Equal(t, cmsID, roster.CMSID)
+			assert.Equal(t, AttributionFile{cclfFile.Name, cclfFile.Timestamp}, roster.File)
+			assert.Equal(t, cclfFile.PerformanceYear, roster.PerformanceYear)
+			assert.Equal(t, tt.attributionDate, roster.AttributionDate)
+			assert.Equal(t, tt.expectedMBIs, roster.MBIs)
+
+			start, end := roster.Period()
+			assert.Equal(t, time.Date(perfYear, time.January, 1, 0, 0, 0, 0, time.UTC), start)
+			if tt.termination != nil {
+				assert.Equal(t, tt.termination.TerminationDate, end)
+			} else {
+				assert.Equal(t, time.Date(perfYear, time.December, 31, 0, 0, 0, 0, time.UTC), end)
+			}
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestGetAttributionRosterNoCCLFFile() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).Return(&models.ACO{UUID: acoID}, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, time.Time{}, models.FileTypeDefault).Return(nil, nil)
+
+	serviceInstance := NewService(repository, &Config{}, "")
+	roster, err := serviceInstance.GetAttributionRoster(context.Background(), RequestConditions{CMSID: cmsID, ACOID: acoID})
+	assert.Nil(s.T(), roster)
+	assert.IsType(s.T(), CCLFNotFoundError{}, err)
+}
+
+func (s *ServiceTestSuite) TestGetSuppressionReport() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	cclfFile := getCCLFFile(1)
+	suppressions := []*models.BeneficiarySuppression{
+		{BeneficiaryID: 1, MBI: "MBI1", Reason: models.SuppressionReasonOptOut, SuppressionMBI: "MBI0", ACOCMSID: cmsID},
+		{BeneficiaryID: 2, MBI: "MBI2", Reason: models.SuppressionReasonSAMHSA, SuppressionMBI: "MBI2"},
+	}
+
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).Return(&models.ACO{UUID: acoID}, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		mock.MatchedBy(timeIsSetMatcher), time.Time{}, models.FileTypeDefault).Return(cclfFile, nil)
+	repository.On("GetBeneficiarySuppressions", testUtils.CtxMatcher, cclfFile.ID,
+		mock.MatchedBy(func(c models.SuppressionCriteria) bool {
+			return c.CMSID == cmsID && c.UpperBound.Sub(c.LowerBound) == 30*24*time.Hour
+		})).Return(suppressions, nil)
+
+	cfg := &Config{cutoffDuration: time.Hour, SuppressionLookbackDays: 30}
+	serviceInstance := NewService(repository, cfg, "")
+	report, err := serviceInstance.GetSuppressionReport(context.Background(), RequestConditions{CMSID: cmsID, ACOID: acoID})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), suppressions, report)
+}
+
+func (s *ServiceTestSuite) TestGetSuppressionReportNoCCLFFile() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).Return(&models.ACO{UUID: acoID}, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, time.Time{}, models.FileTypeDefault).Return(nil, nil)
+
+	serviceInstance := NewService(repository, &Config{}, "")
+	report, err := serviceInstance.GetSuppressionReport(context.Background(), RequestConditions{CMSID: cmsID, ACOID: acoID})
+	assert.Nil(s.T(), report)
+	assert.IsType(s.T(), CCLFNotFoundError{}, err)
+}
+
+// TestAttributionLimitedAccess verifies that the attribution reports remain available to an ACO
+// whose limited access has ended, since limited access only restricts exports.
+func (s *ServiceTestSuite) TestAttributionLimitedAccess() {
+	cmsID, acoID := "A0000", uuid.NewRandom()
+	termination := &models.Termination{
+		TerminationDate: time.Now().Add(-30 * 24 * time.Hour).Round(time.Millisecond).UTC(),
+		CutoffDate:      time.Now().Add(-24 * time.Hour),
+		BlacklistType:   models.Limited,
+		AllowedGroups:   []string{"runout"},
+	}
+	newFile := &models.CCLFFile{ID: 1, Name: "T.BCD.A0000.ZC8Y20.D201201.T1000000", Timestamp: time.Now().Add(-31 * 24 * time.Hour)}
+	oldFile := &models.CCLFFile{ID: 2, Name: "T.BCD.A0000.ZC8Y20.D201101.T1000000", Timestamp: time.Now().Add(-60 * 24 * time.Hour)}
+
+	repository := &models.MockRepository{}
+	defer repository.AssertExpectations(s.T())
+	repository.On("GetACOByUUID", testUtils.CtxMatcher, acoID).
+		Return(&models.ACO{UUID: acoID, TerminationDetails: termination}, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, termination.TerminationDate, models.FileTypeDefault).Return(newFile, nil)
+	repository.On("GetLatestCCLFFile", testUtils.CtxMatcher, cmsID, cclf8FileNum, constants.ImportComplete,
+		time.Time{}, newFile.Timestamp.Add(-1*time.Microsecond), models.FileTypeDefault).Return(oldFile, nil)
+	repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, newFile.ID,
+		suppressionCriteriaMatcher(cmsID, 30, termination.TerminationDate.Equal)).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(1, "MBI1"), getCCLFBeneficiary(2, "MBI2")}, nil)
+	repository.On("GetCCLFBeneficiaries", testUtils.CtxMatcher, oldFile.ID,
+		suppressionCriteriaMatcher(cmsID, 30, termination.TerminationDate.Equal)).
+		Return([]*models.CCLFBeneficiary{getCCLFBeneficiary(3, "MBI1")}, nil)
+	repository.On("GetMBIXrefs", testUtils.CtxMatcher, cmsID).Return(nil, nil)
+
+	cfg := &Config{cutoffDuration: time.Hour, SuppressionLookbackDays: 30}
+	serviceInstance := NewService(repository, cfg, "")
+	conditions := RequestConditions{CMSID: cmsID, ACOID: acoID}
+
+	roster, err := serviceInstance.GetAttributionRoster(context.Background(), conditions)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"MBI1", "MBI2"}, roster.MBIs)
+	assert.Equal(s.T(), termination.TerminationDate, roster.AttributionDate)
+
+	changes, err := serviceInstance.GetAttributionChanges(context.Background(), conditions)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"MBI2"}, changes.Added)
+	assert.Equal(s.T(), []string{}, changes.Removed)
+
+	// Export requests are still rejected
+	plan, err := serviceInstance.PlanQueJobs(context.Background(), conditions)
+	assert.Nil(s.T(), plan)
+	assert.True(s.T(), errors.As(err, &LimitedAccessError{}), "Error should be a LimitedAccessError")
+}
+
 func (s *ServiceTestSuite) TestCancelJob() {
 	ctx := context.Background()
 	synthErr := fmt.Errorf("Synthetic error for testing.")
@@ -713,14 +1154,15 @@ func (s *ServiceTestSuite) TestGetJobPriority() {
 		{"EOB with Historic Benes", defaultACOID, "ExplanationOfBenefit", "", RetrieveNewBeneHistData},
 	}
 
-	svc := &service{}
 	conf.SetEnv(s.T(), "PRIORITY_ACO_REG_EX", priorityACOID)
+	// Without any configured rules, the default rules are used
+	svc := NewService(nil, &Config{}, "")
 
 	for _, tt := range tests {
 		expectedPriority := int16(100)
 
 		s.T().Run(string(tt.name), func(t *testing.T) {
-			if isPriorityACO(tt.acoID) {
+			if tt.acoID == priorityACOID {
 				expectedPriority = 10
 			} else if tt.resourceType == "Patient" || tt.resourceType == "Coverage" {
 				expectedPriority = 20
@@ -729,7 +1171,8 @@ func (s *ServiceTestSuite) TestGetJobPriority() {
 			}
 
 			sinceParam := (len(tt.expSince) > 0) || tt.reqType == RetrieveNewBeneHistData
-			jobPriority := svc.GetJobPriority(tt.acoID, tt.resourceType, sinceParam)
+			jobPriority := svc.GetJobPriority(PriorityRequest{CMSID: tt.acoID, ResourceType: tt.resourceType,
+				Since: sinceParam, ReqType: tt.reqType})
 
 			assert.Equal(t, expectedPriority, jobPriority)
 		})
@@ -752,3 +1195,57 @@ func getCCLFBeneficiary(id uint, mbi string) *models.CCLFBeneficiary {
 func timeIsSetMatcher(t time.Time) bool {
 	return !t.IsZero()
 }
+
+// suppressionCriteriaMatcher verifies the suppression window is scoped to the ACO and spans the lookback period
+func suppressionCriteriaMatcher(cmsID string, lookbackDays int, upperBoundMatcher func(time.Time) bool) interface{} {
+	return mock.MatchedBy(func(c *models.SuppressionCriteria) bool {
+		return c != nil && c.CMSID == cmsID && upperBoundMatcher(c.UpperBound) &&
+			c.UpperBound.Sub(c.LowerBound) == time.Duration(lookbackDays*24)*time.Hour
+	})
+}
+
+// splitBeneficiaries separates the beneficiaries retrieved while planning into new and existing beneficiaries
+func splitBeneficiaries(plan *queJobPlan) (newBenes, oldBenes []*models.CCLFBeneficiary) {
+	var isNew []bool
+	if plan.isNew != nil {
+		var err error
+		if isNew, err = plan.isNew(context.Background(), plan.benes); err != nil {
+			panic(err)
+		}
+	}
+	for i, bene := range plan.benes {
+		if isNew != nil && isNew[i] {
+			newBenes = append(newBenes, bene)
+		} else {
+			oldBenes = append(oldBenes, bene)
+		}
+	}
+	return newBenes, oldBenes
+}
+
+// mbisInFile mocks GetCCLFBeneficiaryMBIsIn for a CCLF file containing the supplied MBIs
+func mbisInFile(fileMBIs []string) func(context.Context, uint, []string) []string {
+	return func(_ context.Context, _ uint, mbis []string) []string {
+		inFile := make(map[string]struct{}, len(fileMBIs))
+		for _, mbi := range fileMBIs {
+			inFile[mbi] = struct{}{}
+		}
+		var found []string
+		for _, mbi := range mbis {
+			if _, ok := inFile[mbi]; ok {
+				found = append(found, mbi)
+			}
+		}
+		return found
+	}
+}
+
+// executePlan returns every queue job created by the plan
+func executePlan(plan QueJobPlan) ([]*models.JobEnqueueArgs, error) {
+	var queJobs []*models.JobEnqueueArgs
+	_, err := plan.Execute(context.Background(), func(jobs []*models.JobEnqueueArgs) error {
+		queJobs = append(queJobs, jobs...)
+		return nil
+	})
+	return queJobs, err
+}
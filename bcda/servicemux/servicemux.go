@@ -2,6 +2,7 @@ package servicemux
 
 import (
 	"bufio"
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CMSgov/bcda-app/conf"
@@ -53,6 +56,19 @@ func (ln tcpKeepAliveListener) Accept() (net.Conn, error) {
 	return tc, nil
 }
 
+// onceCloseListener wraps the listener shared by every server so that it is only closed once,
+// no matter how many of the servers are shut down.
+type onceCloseListener struct {
+	net.Listener
+	once     sync.Once
+	closeErr error
+}
+
+func (ln *onceCloseListener) Close() error {
+	ln.once.Do(func() { ln.closeErr = ln.Listener.Close() })
+	return ln.closeErr
+}
+
 func URLPrefixMatcher(prefix string) cmux.Matcher {
 	return func(r io.Reader) bool {
 		req, err := http.ReadRequest(bufio.NewReader(r))
@@ -68,6 +84,9 @@ type ServiceMux struct {
 	Listener  net.Listener
 	Servers   []map[*http.Server]string
 	TLSConfig tls.Config
+
+	// Set once Shutdown has been called, since the listener is expected to close
+	shuttingDown int32
 }
 
 func New(addr string) *ServiceMux {
@@ -127,7 +146,7 @@ func (sm *ServiceMux) serveHTTPS(tlsCertPath, tlsKeyPath string) {
 }
 
 func (sm *ServiceMux) serveHTTP() {
-	m := cmux.New(sm.Listener)
+	m := cmux.New(&onceCloseListener{Listener: sm.Listener})
 
 	for _, server := range sm.Servers {
 		for srv, path := range server {
@@ -147,11 +166,28 @@ func (sm *ServiceMux) serveHTTP() {
 	}
 
 	err := m.Serve()
-	if err != nil {
+	if err != nil && atomic.LoadInt32(&sm.shuttingDown) == 0 {
 		panic(err)
 	}
 }
 
+// Shutdown gracefully shuts down every server. The servers share a listener, so no new connections are accepted
+// by any of them once Shutdown is called, and Serve returns. Shutdown waits for the active connections to finish
+// until the context is done.
+func (sm *ServiceMux) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&sm.shuttingDown, 1)
+
+	var result error
+	for _, server := range sm.Servers {
+		for srv := range server {
+			if err := srv.Shutdown(ctx); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
 func (sm *ServiceMux) Close() {
 	err := sm.Listener.Close()
 	if err != nil {
@@ -1,6 +1,7 @@
 package servicemux
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/CMSgov/bcda-app/conf"
 
@@ -228,6 +230,42 @@ func (s *ServiceMuxTestSuite) TestServeHTTPEmptyPath() {
 	assert.Equal(s.T(), "Test", string(b))
 }
 
+func (s *ServiceMuxTestSuite) TestShutdown() {
+	origTLSCert, origTLSKey, origHTTPOnly := getOrigVars()
+	defer resetOrigVars(origTLSCert, origTLSKey, origHTTPOnly)
+	conf.SetEnv(s.T(), "BCDA_TLS_CERT", "")
+	conf.SetEnv(s.T(), "BCDA_TLS_KEY", "")
+	conf.SetEnv(s.T(), "HTTP_ONLY", "true")
+
+	sm := New(getConfig().TestAddress)
+	addr := sm.Listener.Addr().String()
+	sm.AddServer(&http.Server{Handler: testHandler}, "/test")
+	sm.AddServer(&http.Server{Handler: testHandler}, "")
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		sm.Serve()
+	}()
+
+	resp, err := http.Get("http://" + addr + "/test")
+	if err != nil {
+		s.T().Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Every server is shut down and Serve returns without panicking
+	assert.NoError(s.T(), sm.Shutdown(context.Background()))
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("Serve did not return after Shutdown")
+	}
+
+	_, err = http.Get("http://" + addr + "/foo")
+	assert.Error(s.T(), err)
+}
+
 func (s *ServiceMuxTestSuite) TestIsHTTPSFalse() {
 	req := httptest.NewRequest("GET", "/", nil)
 	assert.False(s.T(), IsHTTPS(req))
@@ -0,0 +1,71 @@
+package suppression
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/CMSgov/bcda-app/bcda/dryrun"
+	"github.com/CMSgov/bcda-app/bcda/utils"
+)
+
+// ValidateSuppressionDirectory runs the same filename, header/trailer and record checks as ImportSuppressionDirectory
+// without writing to the database or moving any files. Every problem found is captured in the returned report.
+func ValidateSuppressionDirectory(filePath string) (*dryrun.Report, error) {
+	report := dryrun.NewReport(filePath, "suppression")
+	err := filepath.Walk(filePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories are not Suppression files
+		if info.IsDir() {
+			return nil
+		}
+
+		f := report.AddFile(path, "")
+		metadata, err := parseMetadata(info.Name())
+		if err != nil {
+			f.AddProblem(err.Error())
+			return nil
+		}
+		metadata.filePath = path
+		validateSuppressionRecords(&metadata, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func validateSuppressionRecords(metadata *suppressionFileMetadata, f *dryrun.FileReport) {
+	file, err := os.Open(metadata.filePath)
+	if err != nil {
+		f.AddProblem(fmt.Sprintf("could not read file: %s", err.Error()))
+		return
+	}
+	defer utils.CloseFileAndLogError(file)
+
+	sc := bufio.NewScanner(file)
+	var checker lineChecker
+	for sc.Scan() {
+		b := sc.Bytes()
+		isRecord, err := checker.check(b)
+		if err != nil {
+			f.AddLineProblem(checker.line, err.Error())
+		}
+		if !isRecord {
+			continue
+		}
+
+		f.Records++
+		if _, err := parseSuppressionRecord(metadata, b); err != nil {
+			f.AddLineProblem(checker.line, err.Error())
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.AddProblem(fmt.Sprintf("failed to read file: %s", err.Error()))
+	}
+}
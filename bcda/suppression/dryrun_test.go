@@ -0,0 +1,78 @@
+package suppression
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *SuppressionTestSuite) TestValidateSuppressionDirectory() {
+	tests := []struct {
+		name     string
+		dir      string
+		problems map[string][]string
+	}{
+		{"Valid files", "synthetic1800MedicareFiles/test2", map[string][]string{
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000010": nil,
+			"T#EFT.ON.ACO.NGD1800.DPRF.D190816.T0241391": nil,
+		}},
+		{"Bad header", "suppressionfile_BadHeader", map[string][]string{
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000009": {"invalid file header"},
+		}},
+		{"Bad file names", "suppressionfile_BadFileNames", map[string][]string{
+			"T#EFT.ON.ACO.NGD1800.DPRF.D190117.T9909420": {"failed to parse date 'D190117.T990942'"},
+			"T#EFT.ON.ACO.NGD1800.FRPD.D191220.T1000009": {"invalid filename for file"},
+		}},
+		{"Missing data", "suppressionfile_MissingData", map[string][]string{
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000009": {"failed to parse record count from file trailer"},
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000010": {"incorrect number of records found (expected: 5, actual: 4)"},
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000011": {"failed to parse the effective date '20191301'",
+				"incorrect number of records found (expected: 5, actual: 4)"},
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000012": {"failed to parse the samhsa effective date '20191301'",
+				"incorrect number of records found (expected: 5, actual: 4)"},
+			"T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000013": {"failed to parse beneficiary link key"},
+		}},
+	}
+
+	for _, tt := range tests {
+		s.T().Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(s.basePath, tt.dir)
+			report, err := ValidateSuppressionDirectory(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, "suppression", report.FileType)
+			assert.Len(t, report.Files, len(tt.problems))
+
+			for _, f := range report.Files {
+				expected, ok := tt.problems[filepath.Base(f.Path)]
+				assert.True(t, ok, "unexpected file %s", f.Path)
+				assert.Len(t, f.Problems, len(expected))
+				for i, problem := range f.Problems {
+					if i < len(expected) {
+						assert.Contains(t, problem.Message, expected[i])
+					}
+				}
+			}
+		})
+	}
+
+	// Files are left in place
+	infos, err := ioutil.ReadDir(filepath.Join(s.basePath, "suppressionfile_MissingData"))
+	s.NoError(err)
+	s.Len(infos, 5)
+}
+
+func (s *SuppressionTestSuite) TestValidateSuppressionDirectory_LineNumbers() {
+	report, err := ValidateSuppressionDirectory(filepath.Join(s.basePath, "suppressionfile_MissingData"))
+	s.NoError(err)
+
+	for _, f := range report.Files {
+		if filepath.Base(f.Path) != "T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000013" {
+			continue
+		}
+		s.Equal(4, f.Records)
+		s.Len(f.Problems, 1)
+		s.Equal(2, f.Problems[0].Line)
+	}
+}
@@ -0,0 +1,112 @@
+package suppression
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/CMSgov/bcda-app/bcda/cclf/metrics"
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+)
+
+// A suppressionImporter is not safe for concurrent use by multiple goroutines.
+// It should be scoped to a single *pgx.Tx
+type suppressionImporter struct {
+	ctx context.Context
+
+	metadata       *suppressionFileMetadata
+	scanner        *bufio.Scanner
+	reportInterval int
+
+	importCount int
+}
+
+// Next advances the scanner to the next suppression record, skipping blank lines and the file's header and trailer.
+func (importer *suppressionImporter) Next() bool {
+	for importer.scanner.Scan() {
+		b := importer.scanner.Bytes()
+		if len(bytes.TrimSpace(b)) == 0 || isHeaderOrTrailer(b) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (importer *suppressionImporter) Values() ([]interface{}, error) {
+	close := metrics.NewChild(importer.ctx, "importSuppressionData-suppressioncreate")
+	defer close()
+
+	suppression, err := parseSuppressionRecord(importer.metadata, importer.scanner.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Use Int4 because we store file_id and beneficiary_link_key as integers
+	fileID, linkKey := &pgtype.Int4{}, &pgtype.Int4{}
+	effectiveDt, samhsaEffectiveDt := &pgtype.Timestamptz{}, &pgtype.Timestamptz{}
+	mbi, sourceCode, samhsaSourceCode := &pgtype.Varchar{}, &pgtype.Varchar{}, &pgtype.Varchar{}
+	prefIndicator, samhsaPrefIndicator, acoCMSID := &pgtype.BPChar{}, &pgtype.BPChar{}, &pgtype.BPChar{}
+
+	for _, field := range []struct {
+		value pgtype.Value
+		src   interface{}
+	}{
+		{fileID, importer.metadata.fileID},
+		{mbi, suppression.MBI},
+		{sourceCode, suppression.SourceCode},
+		{effectiveDt, suppression.EffectiveDt},
+		{prefIndicator, suppression.PrefIndicator},
+		{samhsaSourceCode, suppression.SAMHSASourceCode},
+		{samhsaEffectiveDt, suppression.SAMHSAEffectiveDt},
+		{samhsaPrefIndicator, suppression.SAMHSAPrefIndicator},
+		{linkKey, suppression.BeneficiaryLinkKey},
+		{acoCMSID, suppression.ACOCMSID},
+	} {
+		if err := field.value.Set(field.src); err != nil {
+			return nil, err
+		}
+	}
+
+	importer.importCount++
+	if importer.importCount%importer.reportInterval == 0 {
+		fmt.Printf("Suppression records imported: %d\n", importer.importCount)
+	}
+
+	return []interface{}{fileID, mbi, sourceCode, effectiveDt, prefIndicator,
+		samhsaSourceCode, samhsaEffectiveDt, samhsaPrefIndicator, linkKey, acoCMSID}, nil
+}
+
+// Err allows us to report back the the CopyFrom if and when
+// the underlying context has been stopped or the file could not be read.
+func (importer *suppressionImporter) Err() error {
+	if err := importer.scanner.Err(); err != nil {
+		return err
+	}
+	return importer.ctx.Err()
+}
+
+// CopyFrom writes all of the suppression records captured in the scanner to the suppressions table.
+// It returns the number of rows written along with any error that occurred.
+// Since the copy is performed within the supplied transaction, callers must roll it back
+// if an error is returned to ensure no records from the file remain.
+func CopyFrom(ctx context.Context, tx *pgx.Tx, metadata *suppressionFileMetadata, scanner *bufio.Scanner, reportInterval int) (int, error) {
+	importer := &suppressionImporter{
+		ctx:      ctx,
+		metadata: metadata,
+		scanner:  scanner,
+
+		reportInterval: reportInterval,
+	}
+	tableName := pgx.Identifier([]string{"suppressions"})
+	return tx.CopyFrom(tableName, []string{"file_id", "mbi", "source_code", "effective_date", "preference_indicator",
+		"samhsa_source_code", "samhsa_effective_date", "samhsa_preference_indicator",
+		"beneficiary_link_key", "aco_cms_id"}, importer)
+}
+
+func isHeaderOrTrailer(b []byte) bool {
+	code := headerOrTrailerCode(b)
+	return code == headerCode || code == trailerCode
+}
@@ -11,11 +11,13 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/CMSgov/bcda-app/bcda/cclf/metrics"
 	"github.com/CMSgov/bcda-app/bcda/constants"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres"
 
 	"github.com/CMSgov/bcda-app/bcda/utils"
 
+	"github.com/jackc/pgx/stdlib"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
@@ -39,9 +41,15 @@ const (
 )
 
 func ImportSuppressionDirectory(filePath string) (success, failure, skipped int, err error) {
+	t := metrics.GetTimer()
+	defer t.Close()
+	ctx := metrics.NewContext(context.Background(), t)
+
 	var suppresslist []*suppressionFileMetadata
 
+	_, c := metrics.NewParent(ctx, "ImportSuppressionDirectory#getSuppressionFileMetadata")
 	err = filepath.Walk(filePath, getSuppressionFileMetadata(&suppresslist, &skipped))
+	c()
 	if err != nil {
 		return 0, 0, 0, err
 	}
@@ -52,23 +60,30 @@ func ImportSuppressionDirectory(filePath string) (success, failure, skipped int,
 	}
 
 	for _, metadata := range suppresslist {
-		err = validate(metadata)
-		if err != nil {
-			fmt.Printf("Failed to validate suppression file: %s.\n", metadata)
-			log.Errorf("Failed to validate suppression file: %s", metadata)
-			failure++
-		} else {
-			if err = importSuppressionData(metadata); err != nil {
-				fmt.Printf("Failed to import suppression file: %s.\n", metadata)
-				log.Errorf("Failed to import suppression file: %s ", metadata)
+		func() {
+			ctx, c := metrics.NewParent(ctx, "ImportSuppressionDirectory#importSuppressionFiles")
+			defer c()
+			err = validate(metadata)
+			if err != nil {
+				fmt.Printf("Failed to validate suppression file: %s.\n", metadata)
+				log.Errorf("Failed to validate suppression file: %s", metadata)
 				failure++
 			} else {
-				metadata.imported = true
-				success++
+				if err = importSuppressionData(ctx, metadata); err != nil {
+					fmt.Printf("Failed to import suppression file: %s.\n", metadata)
+					log.Errorf("Failed to import suppression file: %s ", metadata)
+					failure++
+				} else {
+					metadata.imported = true
+					success++
+				}
 			}
-		}
+		}()
 	}
+
+	_, c = metrics.NewParent(ctx, "ImportSuppressionDirectory#cleanupSuppression")
 	err = cleanupSuppression(suppresslist)
+	c()
 	if err != nil {
 		log.Error(err)
 	}
@@ -167,182 +182,201 @@ func validate(metadata *suppressionFileMetadata) error {
 	}
 	defer utils.CloseFileAndLogError(f)
 
-	var (
-		headTrailStart, headTrailEnd = 0, 15
-		recCountStart, recCountEnd   = 23, 33
-	)
-
 	sc := bufio.NewScanner(f)
-	count := 0
+	var checker lineChecker
 	for sc.Scan() {
-		b := sc.Bytes()
-		metaInfo := string(bytes.TrimSpace(b[headTrailStart:headTrailEnd]))
-		if count == 0 {
-			if metaInfo != headerCode {
-				// invalid file header found
-				fmt.Printf("Invalid file header for file: %s.\n", metadata.filePath)
-				err := fmt.Errorf("invalid file header for file: %s", metadata.filePath)
-				log.Error(err)
-				return err
-			}
-			count++
-			continue
-		}
-
-		if metaInfo != trailerCode {
-			count++
-		} else {
-			// trailer info
-			expectedCount, err := strconv.Atoi(string(bytes.TrimSpace(b[recCountStart:recCountEnd])))
-			if err != nil {
-				fmt.Printf("Failed to parse record count from file: %s.\n", metadata.filePath)
+		if _, err := checker.check(sc.Bytes()); err != nil {
+			countErr, isCountErr := err.(recordCountError)
+			switch {
+			case err == errInvalidHeader:
+				err = fmt.Errorf("invalid file header for file: %s", metadata.filePath)
+			case err == errRecordCount:
 				err = fmt.Errorf("failed to parse record count from file: %s", metadata.filePath)
-				log.Error(err)
-				return err
-			}
-			// subtract the single count from the header
-			count--
-			if count != expectedCount {
-				fmt.Printf("Incorrect number of records found from file: '%s'. Expected record count: %d, Actual record count: %d.\n", metadata.filePath, expectedCount, count)
-				err = fmt.Errorf("incorrect number of records found from file: '%s'. Expected record count: %d, Actual record count: %d", metadata.filePath, expectedCount, count)
-				log.Error(err)
-				return err
+			case isCountErr:
+				err = fmt.Errorf("incorrect number of records found from file: '%s'. Expected record count: %d, Actual record count: %d",
+					metadata.filePath, countErr.expected, countErr.actual)
+			default:
+				err = fmt.Errorf("%s on line %d of file: %s", err.Error(), checker.line, metadata.filePath)
 			}
+			fmt.Printf("%s.\n", err.Error())
+			log.Error(err)
+			return err
 		}
 	}
+	if err := sc.Err(); err != nil {
+		err = errors.Wrapf(err, "failed to read file %s", metadata)
+		log.Error(err)
+		return err
+	}
 	fmt.Printf("Successfully validated suppression file %s.\n", metadata)
 	log.Infof("Successfully validated suppression file %s.", metadata)
 	return nil
 }
 
-func importSuppressionData(metadata *suppressionFileMetadata) error {
-	err := importSuppressionMetadata(metadata, func(fileID uint, b []byte, r models.Repository) error {
-		var (
-			mbiStart, mbiEnd                             = 0, 11
-			lKeyStart, lKeyEnd                           = 11, 21
-			effectiveDtStart, effectiveDtEnd             = 354, 362
-			sourceCdeStart, sourceCdeEnd                 = 362, 367
-			prefIndtorStart, prefIndtorEnd               = 368, 369
-			samhsaEffectiveDtStart, samhsaEffectiveDtEnd = 369, 377
-			samhsaSourceCdeStart, samhsaSourceCdeEnd     = 377, 382
-			samhsaPrefIndtorStart, samhsaPrefIndtorEnd   = 383, 384
-			acoIdStart, acoIdEnd                         = 384, 389
-		)
-		ds := string(bytes.TrimSpace(b[effectiveDtStart:effectiveDtEnd]))
-		dt, err := convertDt(ds)
-		if err != nil {
-			fmt.Printf("Failed to parse the effective date '%s' from file: %s.\n", ds, metadata.filePath)
-			err = errors.Wrapf(err, "failed to parse the effective date '%s' from file: %s", ds, metadata.filePath)
-			log.Error(err)
-			return err
-		}
-		ds = string(bytes.TrimSpace(b[samhsaEffectiveDtStart:samhsaEffectiveDtEnd]))
-		samhsaDt, err := convertDt(ds)
-		if err != nil {
-			fmt.Printf("Failed to parse the samhsa effective date '%s' from file: %s.\n", ds, metadata.filePath)
-			err = errors.Wrapf(err, "failed to parse the samhsa effective date '%s' from file: %s", ds, metadata.filePath)
-			log.Error(err)
-			return err
+var (
+	errInvalidHeader      = errors.New("invalid file header")
+	errUnexpectedHeader   = errors.New("unexpected file header")
+	errRecordCount        = errors.New("failed to parse record count from file trailer")
+	errRecordAfterTrailer = errors.New("record found after file trailer")
+)
+
+// recordCountError reports a trailer whose record count does not match the records found in the file
+type recordCountError struct {
+	expected, actual int
+}
+
+func (e recordCountError) Error() string {
+	return fmt.Sprintf("incorrect number of records found (expected: %d, actual: %d)", e.expected, e.actual)
+}
+
+// lineChecker applies the header and trailer checks to each line of a suppression file.
+// It is shared by validate and the dry run so both accept the same files.
+type lineChecker struct {
+	// line is the number of the line last checked
+	line    int
+	records int
+
+	headerFound, trailerFound bool
+}
+
+// check examines the next line of the file, reporting whether the line is a record.
+// Blank lines are skipped, matching the importer. Records are reported even if they are found after the trailer.
+func (c *lineChecker) check(b []byte) (bool, error) {
+	const recCountStart, recCountEnd = 23, 33
+
+	c.line++
+	if len(bytes.TrimSpace(b)) == 0 {
+		return false, nil
+	}
+
+	code := headerOrTrailerCode(b)
+	first := !c.headerFound
+	c.headerFound = true
+	switch {
+	case code == headerCode:
+		if !first {
+			return false, errUnexpectedHeader
 		}
-		keyval := string(bytes.TrimSpace(b[lKeyStart:lKeyEnd]))
-		if keyval == "" {
-			keyval = "0"
+		return false, nil
+	case first:
+		return false, errInvalidHeader
+	case code == trailerCode:
+		c.trailerFound = true
+		if len(b) < recCountEnd {
+			return false, errRecordCount
 		}
-		lk, err := strconv.Atoi(keyval)
+		expected, err := strconv.Atoi(string(bytes.TrimSpace(b[recCountStart:recCountEnd])))
 		if err != nil {
-			fmt.Printf("Failed to parse beneficiary link key from file: %s.\n", metadata.filePath)
-			err = errors.Wrapf(err, "failed to parse beneficiary link key from file: %s", metadata.filePath)
-			log.Error(err)
-			return err
+			return false, errRecordCount
 		}
-
-		suppression := models.Suppression{
-			FileID:              fileID,
-			MBI:                 string(bytes.TrimSpace(b[mbiStart:mbiEnd])),
-			SourceCode:          string(bytes.TrimSpace(b[sourceCdeStart:sourceCdeEnd])),
-			EffectiveDt:         dt,
-			PrefIndicator:       string(bytes.TrimSpace(b[prefIndtorStart:prefIndtorEnd])),
-			SAMHSASourceCode:    string(bytes.TrimSpace(b[samhsaSourceCdeStart:samhsaSourceCdeEnd])),
-			SAMHSAEffectiveDt:   samhsaDt,
-			SAMHSAPrefIndicator: string(bytes.TrimSpace(b[samhsaPrefIndtorStart:samhsaPrefIndtorEnd])),
-			BeneficiaryLinkKey:  lk,
-			ACOCMSID:            string(bytes.TrimSpace(b[acoIdStart:acoIdEnd])),
+		if expected != c.records {
+			return false, recordCountError{expected, c.records}
 		}
+		return false, nil
+	}
 
-		if err = r.CreateSuppression(context.Background(), suppression); err != nil {
-			fmt.Println("Could not create suppression record.")
-			err = errors.Wrap(err, "could not create suppression record")
-			log.Error(err)
-			return err
-		}
-		return nil
-	})
+	c.records++
+	if c.trailerFound {
+		return true, errRecordAfterTrailer
+	}
+	return true, nil
+}
 
-	if err != nil {
-		updateImportStatus(metadata.fileID, constants.ImportFail)
-		return err
+// headerOrTrailerCode returns the code identifying a header or trailer line.
+// Lines too short to hold the code cannot be a header or trailer.
+func headerOrTrailerCode(b []byte) string {
+	const headTrailStart, headTrailEnd = 0, 15
+	if len(b) < headTrailEnd {
+		return ""
 	}
-	updateImportStatus(metadata.fileID, constants.ImportComplete)
-	return nil
+	return string(bytes.TrimSpace(b[headTrailStart:headTrailEnd]))
 }
 
-func importSuppressionMetadata(metadata *suppressionFileMetadata, importFunc func(uint, []byte, models.Repository) error) error {
+// importSuppressionData copies all of the records in the suppression file to the suppressions table
+// within a single transaction. If any record is rejected, none of the file's records are kept.
+func importSuppressionData(ctx context.Context, metadata *suppressionFileMetadata) (err error) {
 	fmt.Printf("Importing suppression file %s...\n", metadata)
 	log.Infof("Importing suppression file %s...", metadata)
 
-	var (
-		headTrailStart, headTrailEnd = 0, 15
-		err                          error
-	)
+	close := metrics.NewChild(ctx, "importSuppressionData")
+	defer close()
 
-	suppressionMetaFile := models.SuppressionFile{
-		Name:         metadata.name,
-		Timestamp:    metadata.timestamp,
-		ImportStatus: constants.ImportInprog,
+	f, err := os.Open(metadata.filePath)
+	if err != nil {
+		fmt.Printf("Could not read file %s.\n", metadata)
+		err = errors.Wrapf(err, "could not read file %s", metadata)
+		log.Error(err)
+		return err
 	}
+	defer utils.CloseFileAndLogError(f)
 
 	db := database.Connection
-
 	r := postgres.NewRepository(db)
 
-	if suppressionMetaFile.ID, err = r.CreateSuppressionFile(context.Background(), suppressionMetaFile); err != nil {
+	suppressionMetaFile := models.SuppressionFile{
+		Name:         metadata.name,
+		Timestamp:    metadata.timestamp,
+		ImportStatus: constants.ImportInprog,
+	}
+	if suppressionMetaFile.ID, err = r.CreateSuppressionFile(ctx, suppressionMetaFile); err != nil {
 		fmt.Printf("Could not create suppression file record for file: %s. \n", metadata)
 		err = errors.Wrapf(err, "could not create suppression file record for file: %s.", metadata)
 		log.Error(err)
 		return err
 	}
-
 	metadata.fileID = suppressionMetaFile.ID
 
-	importStatusInterval := utils.GetEnvInt("SUPPRESS_IMPORT_STATUS_RECORDS_INTERVAL", 1000)
-	importedCount := 0
-	f, err := os.Open(metadata.filePath)
+	// The file record is kept outside of the transaction so a rejected file is still reported as failed
+	defer func() {
+		if err != nil {
+			updateImportStatus(metadata.fileID, constants.ImportFail)
+		}
+	}()
+
+	// Open transaction to encompass entire suppression file ingest.
+	conn, err := stdlib.AcquireConn(db)
 	if err != nil {
-		fmt.Printf("Could not read file %s.\n", metadata)
-		err = errors.Wrapf(err, "could not read file %s", metadata)
-		log.Error(err)
-		return err
+		return errors.Wrap(err, "failed to acquire connection")
 	}
-	defer utils.CloseFileAndLogError(f)
+	defer utils.CloseAndLog(log.WarnLevel, func() error { return stdlib.ReleaseConn(db, conn) })
 
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		b := sc.Bytes()
-		if len(bytes.TrimSpace(b)) > 0 {
-			metaInfo := string(bytes.TrimSpace(b[headTrailStart:headTrailEnd]))
-			if metaInfo == headerCode || metaInfo == trailerCode {
-				continue
-			}
-			err = importFunc(suppressionMetaFile.ID, b, r)
-			if err != nil {
-				log.Error(err)
-				return err
-			}
-			importedCount++
-			if importedCount%importStatusInterval == 0 {
-				fmt.Printf("Suppression records imported: %d\n", importedCount)
+	tx, err := conn.BeginEx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to start transaction")
+	}
+	defer func() {
+		if err != nil {
+			if rErr := tx.RollbackEx(ctx); rErr != nil {
+				log.Warnf("Failed to rollback transaction %s", rErr.Error())
 			}
 		}
+	}()
+
+	start := time.Now()
+	importedCount, err := CopyFrom(ctx, tx, metadata, bufio.NewScanner(f), utils.GetEnvInt("SUPPRESS_IMPORT_STATUS_RECORDS_INTERVAL", 1000))
+	if err != nil {
+		fmt.Printf("%s.\n", err.Error())
+		log.Error(err)
+		return err
+	}
+
+	if _, err = tx.ExecEx(ctx, "UPDATE suppression_files SET import_status = $1 WHERE id = $2", nil,
+		constants.ImportComplete, metadata.fileID); err != nil {
+		err = errors.Wrapf(err, "could not update suppression file record for file_id: %d", metadata.fileID)
+		log.Error(err)
+		return err
+	}
+
+	if err = tx.CommitEx(ctx); err != nil {
+		err = errors.Wrapf(err, "failed to commit suppression records from file: %s", metadata)
+		log.Error(err)
+		return err
+	}
+
+	elapsed := time.Since(start)
+	metrics.RecordValue(ctx, "ImportSuppression/records", float64(importedCount))
+	if elapsed > 0 {
+		metrics.RecordValue(ctx, "ImportSuppression/recordsPerSecond", float64(importedCount)/elapsed.Seconds())
 	}
 
 	successMsg := fmt.Sprintf("Successfully imported %d records from suppression file %s.", importedCount, metadata)
@@ -352,6 +386,56 @@ func importSuppressionMetadata(metadata *suppressionFileMetadata, importFunc fun
 	return nil
 }
 
+// parseSuppressionRecord parses a single record from a suppression file.
+// The FileID of the returned suppression is not set.
+func parseSuppressionRecord(metadata *suppressionFileMetadata, b []byte) (models.Suppression, error) {
+	var (
+		mbiStart, mbiEnd                             = 0, 11
+		lKeyStart, lKeyEnd                           = 11, 21
+		effectiveDtStart, effectiveDtEnd             = 354, 362
+		sourceCdeStart, sourceCdeEnd                 = 362, 367
+		prefIndtorStart, prefIndtorEnd               = 368, 369
+		samhsaEffectiveDtStart, samhsaEffectiveDtEnd = 369, 377
+		samhsaSourceCdeStart, samhsaSourceCdeEnd     = 377, 382
+		samhsaPrefIndtorStart, samhsaPrefIndtorEnd   = 383, 384
+		acoIdStart, acoIdEnd                         = 384, 389
+	)
+	if len(b) < acoIdEnd {
+		return models.Suppression{}, fmt.Errorf("invalid record length %d from file: %s, expected at least %d", len(b), metadata.filePath, acoIdEnd)
+	}
+
+	ds := string(bytes.TrimSpace(b[effectiveDtStart:effectiveDtEnd]))
+	dt, err := convertDt(ds)
+	if err != nil {
+		return models.Suppression{}, errors.Wrapf(err, "failed to parse the effective date '%s' from file: %s", ds, metadata.filePath)
+	}
+	ds = string(bytes.TrimSpace(b[samhsaEffectiveDtStart:samhsaEffectiveDtEnd]))
+	samhsaDt, err := convertDt(ds)
+	if err != nil {
+		return models.Suppression{}, errors.Wrapf(err, "failed to parse the samhsa effective date '%s' from file: %s", ds, metadata.filePath)
+	}
+	keyval := string(bytes.TrimSpace(b[lKeyStart:lKeyEnd]))
+	if keyval == "" {
+		keyval = "0"
+	}
+	lk, err := strconv.Atoi(keyval)
+	if err != nil {
+		return models.Suppression{}, errors.Wrapf(err, "failed to parse beneficiary link key from file: %s", metadata.filePath)
+	}
+
+	return models.Suppression{
+		MBI:                 string(bytes.TrimSpace(b[mbiStart:mbiEnd])),
+		SourceCode:          string(bytes.TrimSpace(b[sourceCdeStart:sourceCdeEnd])),
+		EffectiveDt:         dt,
+		PrefIndicator:       string(bytes.TrimSpace(b[prefIndtorStart:prefIndtorEnd])),
+		SAMHSASourceCode:    string(bytes.TrimSpace(b[samhsaSourceCdeStart:samhsaSourceCdeEnd])),
+		SAMHSAEffectiveDt:   samhsaDt,
+		SAMHSAPrefIndicator: string(bytes.TrimSpace(b[samhsaPrefIndtorStart:samhsaPrefIndtorEnd])),
+		BeneficiaryLinkKey:  lk,
+		ACOCMSID:            string(bytes.TrimSpace(b[acoIdStart:acoIdEnd])),
+	}, nil
+}
+
 func cleanupSuppression(suppresslist []*suppressionFileMetadata) error {
 	errCount := 0
 	for _, suppressionFile := range suppresslist {
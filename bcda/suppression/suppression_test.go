@@ -1,6 +1,7 @@
 package suppression
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -64,7 +65,7 @@ func (s *SuppressionTestSuite) TestImportSuppression() {
 		name:         "T#EFT.ON.ACO.NGD1800.DPRF.D181120.T1000009",
 		deliveryDate: time.Now(),
 	}
-	err := importSuppressionData(metadata)
+	err := importSuppressionData(context.Background(), metadata)
 	assert.Nil(err)
 
 	suppressionFile := postgrestest.GetSuppressionFileByName(s.T(), db, metadata.name)[0]
@@ -93,7 +94,7 @@ func (s *SuppressionTestSuite) TestImportSuppression() {
 		name:         "T#EFT.ON.ACO.NGD1800.DPRF.D190816.T0241390",
 		deliveryDate: time.Now(),
 	}
-	err = importSuppressionData(metadata)
+	err = importSuppressionData(context.Background(), metadata)
 	assert.Nil(err)
 
 	suppressionFile = postgrestest.GetSuppressionFileByName(s.T(), db, metadata.name)[0]
@@ -122,7 +123,7 @@ func (s *SuppressionTestSuite) TestImportSuppression_MissingData() {
 
 	// Verify empty file is rejected
 	metadata := &suppressionFileMetadata{}
-	err := importSuppressionData(metadata)
+	err := importSuppressionData(context.Background(), metadata)
 	assert.NotNil(err)
 	assert.Contains(err.Error(), "could not read file")
 
@@ -144,12 +145,14 @@ func (s *SuppressionTestSuite) TestImportSuppression_MissingData() {
 				name:         tt.name,
 				deliveryDate: time.Now(),
 			}
-			err = importSuppressionData(metadata)
+			err = importSuppressionData(context.Background(), metadata)
 			assert.NotNil(err)
 			assert.Contains(err.Error(), fmt.Sprintf("%s: %s", tt.expErr, fp))
 
 			suppressionFile := postgrestest.GetSuppressionFileByName(s.T(), db, metadata.name)[0]
 			assert.Equal(constants.ImportFail, suppressionFile.ImportStatus)
+			// Records parsed before the failure must not be left behind
+			assert.Empty(postgrestest.GetSuppressionsByFileID(s.T(), db, suppressionFile.ID))
 			postgrestest.DeleteSuppressionFileByID(s.T(), db, suppressionFile.ID)
 		})
 	}
@@ -186,6 +189,45 @@ func (s *SuppressionTestSuite) TestValidate() {
 	assert.EqualError(err, "incorrect number of records found from file: '"+metadata.filePath+"'. Expected record count: 5, Actual record count: 4")
 }
 
+func TestLineChecker(t *testing.T) {
+	const (
+		header  = "HDR_BENEDATASHR20190729"
+		record  = "5SJ0A00AA001847800005John                          Mitchell"
+		trailer = "TRL_BENEDATASHR201907290000000002"
+	)
+
+	tests := []struct {
+		name   string
+		lines  []string
+		errs   map[int]error
+		expect int
+	}{
+		{"Valid", []string{header, record, record, trailer}, nil, 2},
+		{"Blank and short lines", []string{header, "", record, "short", "  ", trailer}, nil, 2},
+		{"Blank line before header", []string{"", header, record, record, trailer}, nil, 2},
+		{"Missing header", []string{record, record, trailer},
+			map[int]error{1: errInvalidHeader, 3: recordCountError{2, 1}}, 1},
+		{"Short first line", []string{"short", record, trailer},
+			map[int]error{1: errInvalidHeader, 3: recordCountError{2, 1}}, 1},
+		{"Repeated header", []string{header, record, header, record, trailer}, map[int]error{3: errUnexpectedHeader}, 2},
+		{"Short trailer", []string{header, record, record, "TRL_BENEDATASHR2019"}, map[int]error{4: errRecordCount}, 2},
+		{"Incorrect count", []string{header, record, trailer}, map[int]error{3: recordCountError{2, 1}}, 1},
+		{"Record after trailer", []string{header, record, record, trailer, record},
+			map[int]error{5: errRecordAfterTrailer}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checker lineChecker
+			for i, line := range tt.lines {
+				_, err := checker.check([]byte(line))
+				assert.Equal(t, tt.errs[i+1], err, "line %d", i+1)
+			}
+			assert.Equal(t, tt.expect, checker.records)
+		})
+	}
+}
+
 func (s *SuppressionTestSuite) TestParseMetadata() {
 	assert := assert.New(s.T())
 
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/CMSgov/bcda-app/bcda/admin"
 	v1 "github.com/CMSgov/bcda-app/bcda/api/v1"
 	v2 "github.com/CMSgov/bcda-app/bcda/api/v2"
 	"github.com/CMSgov/bcda-app/bcda/auth"
@@ -20,6 +21,27 @@ var commonAuth = []func(http.Handler) http.Handler{
 	auth.RequireTokenAuth,
 	auth.CheckBlacklist}
 
+// Auth middleware for requests made on behalf of the ACO selected in the path.
+// The ACO is selected before the blacklist check so the check applies to the selected ACO.
+var acoAuth = []func(http.Handler) http.Handler{
+	auth.RequireTokenAuth,
+	auth.SelectACO,
+	auth.CheckBlacklist}
+
+// Auth middleware for export requests (kickoffs). ACOs with limited access cannot start exports
+// once their cutoff date passes, but can still check on and download their existing jobs.
+var kickoffAuth = []func(http.Handler) http.Handler{
+	auth.RequireTokenAuth,
+	auth.CheckBlacklist,
+	auth.CheckLimitedAccess}
+
+// Auth middleware for export requests made on behalf of the ACO selected in the path.
+var acoKickoffAuth = []func(http.Handler) http.Handler{
+	auth.RequireTokenAuth,
+	auth.SelectACO,
+	auth.CheckBlacklist,
+	auth.CheckLimitedAccess}
+
 func NewAPIRouter() http.Handler {
 	r := chi.NewRouter()
 	m := monitoring.GetMonitor()
@@ -33,8 +55,14 @@ func NewAPIRouter() http.Handler {
 		r.Get(`/{:(user_guide|encryption|decryption_walkthrough).html}`, userGuideRedirect)
 	}
 	r.Route("/api/v1", func(r chi.Router) {
-		r.With(append(commonAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Patient/$export", v1.BulkPatientRequest))
-		r.With(append(commonAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Group/{groupId}/$export", v1.BulkGroupRequest))
+		r.With(append(kickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Patient/$export", v1.BulkPatientRequest))
+		r.With(append(kickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Group/{groupId}/$export", v1.BulkGroupRequest))
+		r.With(append(acoKickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/ACO/{cmsId}/Patient/$export", v1.BulkPatientRequest))
+		r.With(append(acoKickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/ACO/{cmsId}/Group/{groupId}/$export", v1.BulkGroupRequest))
+		r.With(commonAuth...).Get(m.WrapHandler("/Group/{groupId}/$attribution-changes", v1.AttributionChanges))
+		r.With(acoAuth...).Get(m.WrapHandler("/ACO/{cmsId}/Group/{groupId}/$attribution-changes", v1.AttributionChanges))
+		r.With(commonAuth...).Get(m.WrapHandler("/Group/{groupId}", v1.GroupRoster))
+		r.With(acoAuth...).Get(m.WrapHandler("/ACO/{cmsId}/Group/{groupId}", v1.GroupRoster))
 		r.With(append(commonAuth, auth.RequireTokenJobMatch)...).Get(m.WrapHandler("/jobs/{jobID}", v1.JobStatus))
 		r.With(append(commonAuth, auth.RequireTokenJobMatch)...).Delete(m.WrapHandler("/jobs/{jobID}", v1.DeleteJob))
 		r.Get(m.WrapHandler("/metadata", v1.Metadata))
@@ -43,8 +71,12 @@ func NewAPIRouter() http.Handler {
 	if utils.GetEnvBool("VERSION_2_ENDPOINT_ACTIVE", true) {
 		FileServer(r, "/api/v2/swagger", http.Dir("./swaggerui/v2"))
 		r.Route("/api/v2", func(r chi.Router) {
-			r.With(append(commonAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Patient/$export", v2.BulkPatientRequest))
-			r.With(append(commonAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Group/{groupId}/$export", v2.BulkGroupRequest))
+			r.With(append(kickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Patient/$export", v2.BulkPatientRequest))
+			r.With(append(kickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/Group/{groupId}/$export", v2.BulkGroupRequest))
+			r.With(append(acoKickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/ACO/{cmsId}/Patient/$export", v2.BulkPatientRequest))
+			r.With(append(acoKickoffAuth, ValidateBulkRequestHeaders)...).Get(m.WrapHandler("/ACO/{cmsId}/Group/{groupId}/$export", v2.BulkGroupRequest))
+			r.With(commonAuth...).Get(m.WrapHandler("/Group/{groupId}", v2.GroupRoster))
+			r.With(acoAuth...).Get(m.WrapHandler("/ACO/{cmsId}/Group/{groupId}", v2.GroupRoster))
 			r.Get(m.WrapHandler("/metadata", v2.Metadata))
 		})
 	}
@@ -59,6 +91,10 @@ func NewAuthRouter() http.Handler {
 	return auth.NewAuthRouter(logging.NewStructuredLogger(), SecurityHeader, ConnectionClose)
 }
 
+func NewAdminRouter() http.Handler {
+	return admin.NewAdminRouter(logging.NewStructuredLogger(), SecurityHeader, ConnectionClose)
+}
+
 func NewDataRouter() http.Handler {
 	r := chi.NewRouter()
 	m := monitoring.GetMonitor()
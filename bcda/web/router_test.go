@@ -182,6 +182,17 @@ func (s *RouterTestSuite) TestCoverageExportRoute() {
 	assert.Equal(s.T(), http.StatusNotFound, res.StatusCode)
 }
 
+func (s *RouterTestSuite) TestACOExportRoutes() {
+	res := s.getAPIRoute("/api/v1/ACO/A0000/Patient/$export")
+	assert.Equal(s.T(), http.StatusUnauthorized, res.StatusCode)
+
+	res = s.getAPIRoute("/api/v1/ACO/A0000/Group/all/$export")
+	assert.Equal(s.T(), http.StatusUnauthorized, res.StatusCode)
+
+	res = s.getAPIRoute("/api/v1/ACO/Group/all/$export")
+	assert.Equal(s.T(), http.StatusNotFound, res.StatusCode)
+}
+
 func (s *RouterTestSuite) TestV2EndpointsDisabled() {
 	// Set the V2 endpoints to be off and restart the router so the test router has the correct configuration
 	v2Active := conf.GetEnv("VERSION_2_ENDPOINT_ACTIVE")
@@ -283,6 +294,7 @@ func (s *RouterTestSuite) TestBlacklistedACO() {
 	}{
 		{apiRouter, []string{"/api/v1/Patient/$export", "/api/v1/Group/all/$export",
 			"/api/v2/Patient/$export", "/api/v2/Group/all/$export",
+			fmt.Sprintf("/api/v1/ACO/%s/Patient/$export", cmsID), fmt.Sprintf("/api/v2/ACO/%s/Group/all/$export", cmsID),
 			"/api/v1/jobs/1"}},
 		{s.dataRouter, []string{"/data/test/test.ndjson"}},
 		{NewAuthRouter(), []string{"/auth/welcome"}},
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/health"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/conf"
 
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
@@ -57,3 +67,114 @@ func (l *HealthLogger) Log() {
 type HealthLogEntry struct {
 	Logger logrus.FieldLogger
 }
+
+var queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "bcda_worker_queue_depth",
+	Help: "Number of queue jobs waiting in the queue",
+}, []string{"type", "lane", "priority"})
+
+// bbCheckTTL is how long the result of the BFD readiness check is reused.
+// Readiness is polled frequently and each check makes a request to BFD.
+const bbCheckTTL = 30 * time.Second
+
+type healthCheck struct {
+	name string
+	ok   func() bool
+}
+
+// healthHandler serves the worker's health (/_health), readiness (/_ready) and metrics (/_metrics) endpoints.
+// The worker is healthy when it can reach its databases, and ready when it can also write export files and reach BFD.
+type healthHandler struct {
+	liveness  []healthCheck
+	readiness []healthCheck
+
+	queueDepths func() ([]queueing.QueueDepth, error)
+	// Serializes scrapes so the queue depth gauge is not refreshed concurrently
+	mu sync.Mutex
+}
+
+func newHealthHandler() *healthHandler {
+	liveness := []healthCheck{
+		{"database", health.IsDatabaseOK},
+		{"queue_database", health.IsQueueDatabaseOK},
+	}
+	readiness := append(liveness,
+		healthCheck{"staging_dir", func() bool { return health.IsDirectoryWritable(conf.GetEnv("FHIR_STAGING_DIR")) }},
+		healthCheck{"payload_dir", func() bool { return health.IsDirectoryWritable(conf.GetEnv("FHIR_PAYLOAD_DIR")) }},
+		healthCheck{"bb", cachedCheck(health.IsBlueButtonOK, bbCheckTTL)},
+	)
+	return &healthHandler{
+		liveness:    liveness,
+		readiness:   readiness,
+		queueDepths: func() ([]queueing.QueueDepth, error) { return queueing.GetQueueDepths(database.QueueConnection) },
+	}
+}
+
+func (h *healthHandler) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_health", func(w http.ResponseWriter, r *http.Request) { writeChecks(w, h.liveness) })
+	mux.HandleFunc("/_ready", func(w http.ResponseWriter, r *http.Request) { writeChecks(w, h.readiness) })
+	mux.HandleFunc("/_metrics", h.serveMetrics)
+	return mux
+}
+
+func (h *healthHandler) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	depths, err := h.queueDepths()
+	if err != nil {
+		// Serve the remaining metrics, leaving the queue depth unreported
+		logrus.Warnf("Failed to retrieve queue depths: %s", err.Error())
+	}
+	queueDepth.Reset()
+	for _, depth := range depths {
+		queueDepth.WithLabelValues(depth.Type, depth.Lane, strconv.Itoa(int(depth.Priority))).Set(float64(depth.Count))
+	}
+
+	promhttp.Handler().ServeHTTP(w, r)
+}
+
+// cachedCheck returns a check that reuses the result of ok until it is older than the ttl.
+// Concurrent callers wait for a single check to complete rather than each running the check.
+func cachedCheck(ok func() bool, ttl time.Duration) func() bool {
+	var (
+		mu      sync.Mutex
+		result  bool
+		checked time.Time
+	)
+	return func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if checked.IsZero() || time.Since(checked) >= ttl {
+			result, checked = ok(), time.Now()
+		}
+		return result
+	}
+}
+
+// writeChecks runs the checks, responding with the result of each check
+func writeChecks(w http.ResponseWriter, checks []healthCheck) {
+	m := make(map[string]string)
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.ok() {
+			m[check.name] = "ok"
+		} else {
+			m[check.name] = "error"
+			status = http.StatusBadGateway
+		}
+	}
+
+	respJSON, err := json.Marshal(m)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(respJSON); err != nil {
+		logrus.Warnf("Failed to write health check response: %s", err.Error())
+	}
+}
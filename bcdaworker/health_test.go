@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ok := func() bool { return true }
+	failed := func() bool { return false }
+
+	h := &healthHandler{
+		liveness:  []healthCheck{{"database", ok}, {"queue_database", ok}},
+		readiness: []healthCheck{{"database", ok}, {"queue_database", ok}, {"staging_dir", ok}, {"bb", failed}},
+		queueDepths: func() ([]queueing.QueueDepth, error) {
+			return []queueing.QueueDepth{{Type: queueing.QUE_PROCESS_JOB, Lane: queueing.LanePatient, Priority: 20, Count: 3}}, nil
+		},
+	}
+	mux := h.mux()
+
+	tests := []struct {
+		path      string
+		expStatus int
+		expChecks map[string]string
+	}{
+		{"/_health", http.StatusOK, map[string]string{"database": "ok", "queue_database": "ok"}},
+		{"/_ready", http.StatusBadGateway,
+			map[string]string{"database": "ok", "queue_database": "ok", "staging_dir": "ok", "bb": "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.expStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var checks map[string]string
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &checks))
+			assert.Equal(t, tt.expChecks, checks)
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/_metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `bcda_worker_queue_depth{lane="Patient",priority="20",type="ProcessJob"} 3`)
+
+	// Queue depths that can no longer be retrieved are not reported
+	h.queueDepths = func() ([]queueing.QueueDepth, error) { return nil, errors.New("connection refused") }
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/_metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), `bcda_worker_queue_depth{`)
+	assert.Contains(t, rr.Body.String(), "go_goroutines")
+}
+
+func TestCachedCheck(t *testing.T) {
+	var calls int
+	ok := func() bool {
+		calls++
+		return calls == 1
+	}
+
+	// The first result is reused until it expires
+	check := cachedCheck(ok, time.Hour)
+	assert.True(t, check())
+	assert.True(t, check())
+	assert.Equal(t, 1, calls)
+
+	// An expired result is refreshed
+	calls = 0
+	check = cachedCheck(ok, 0)
+	assert.True(t, check())
+	assert.False(t, check())
+	assert.Equal(t, 2, calls)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/utils"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/bcdaworker/queueing/manager"
 	"github.com/CMSgov/bcda-app/conf"
 	log "github.com/sirupsen/logrus"
@@ -37,43 +40,43 @@ func createWorkerDirs() {
 	}
 }
 
+// waitForSig blocks until the worker is signalled to stop
 func waitForSig() {
 	signalChan := make(chan os.Signal, 1)
-	defer close(signalChan)
+	defer signal.Stop(signalChan)
 
 	signal.Notify(signalChan,
 		syscall.SIGINT,
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
-	exitChan := make(chan int)
-	defer close(exitChan)
-
-	go func() {
-		for {
-			s := <-signalChan
-			switch s {
-			case syscall.SIGINT:
-				fmt.Println("interrupt")
-				exitChan <- 0
-			case syscall.SIGTERM:
-				fmt.Println("force stop")
-				exitChan <- 0
-			case syscall.SIGQUIT:
-				fmt.Println("stop and core dump")
-				exitChan <- 0
-			}
-		}
-	}()
-
-	code := <-exitChan
-	os.Exit(code)
+	switch <-signalChan {
+	case syscall.SIGINT:
+		fmt.Println("interrupt")
+	case syscall.SIGTERM:
+		fmt.Println("force stop")
+	case syscall.SIGQUIT:
+		fmt.Println("stop and core dump")
+	}
 }
 
 func main() {
 	fmt.Println("Starting bcdaworker...")
-	queue := manager.StartQue(log.StandardLogger(), utils.GetEnvInt("WORKER_POOL_SIZE", 2))
-	defer queue.StopQue()
+	// By default every lane is worked on by a single worker
+	queue := manager.StartQue(log.StandardLogger(), utils.GetEnvInt("WORKER_POOL_SIZE", len(queueing.Lanes())))
+
+	// The readiness check waits on BFD, which may take multiple attempts to respond
+	srv := &http.Server{
+		Handler:      newHealthHandler().mux(),
+		Addr:         fmt.Sprintf(":%d", utils.GetEnvInt("WORKER_HEALTH_PORT", 3007)),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: time.Minute,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
 
 	if hInt, err := strconv.Atoi(conf.GetEnv("WORKER_HEALTH_INT_SEC")); err == nil {
 		healthLogger := NewHealthLogger()
@@ -93,4 +96,14 @@ func main() {
 	}
 
 	waitForSig()
+
+	// Workers checkpoint the queue jobs they are working on, releasing them to be resumed by another worker
+	log.Info("Stopping bcdaworker...")
+	start := time.Now()
+	queue.StopQue()
+	log.Infof("Stopped workers in %s", time.Since(start))
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		log.Warnf("Failed to shut down health server: %s", err.Error())
+	}
 }
@@ -0,0 +1,57 @@
+package queueing
+
+import (
+	"math"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// AgeJobs improves the priority of queue jobs that have waited longer than threshold to be worked on.
+// The priority of each job is reduced by step. It returns the number of queue jobs aged.
+//
+// tiers are the priorities that queue jobs are enqueued with in ascending order. A queue job is aged within
+// its tier: it may move ahead of the newer queue jobs of its own tier, but never ahead of the queue jobs of
+// the tier above it, including their fair-share offsets (see fairSharePriority). With tiers of 30 and 100,
+// queue jobs enqueued at 100-109 are aged down to 40 at most, behind the 30-39 of the higher tier.
+// Queue jobs of the highest tier are aged down to the tier's priority. Tiers should be at least
+// fairShareBand apart, otherwise the fair-share offsets of neighbouring tiers overlap.
+//
+// Aged queue jobs have their run_at reset, so a queue job is aged at most once per threshold
+// even when multiple workers age the queue concurrently.
+// Queue jobs held by a worker are never aged: que-go finds the queue job by its priority and run_at
+// when it is completed or fails, so changing either would leave the queue job to be worked on again.
+func AgeJobs(db *pgx.ConnPool, threshold time.Duration, step int, tiers []int) (int64, error) {
+	var aged int64
+	floors := agingFloors(tiers)
+	for i, floor := range floors {
+		// Each tier's queue jobs range from its floor to the next tier's floor. Aging keeps a queue job within
+		// this range, so the queue job is aged at most once even though every tier is aged in turn.
+		ceiling := math.MaxInt16
+		if i+1 < len(floors) {
+			ceiling = floors[i+1] - 1
+		}
+		tag, err := db.Exec(`UPDATE que_jobs SET priority = GREATEST(priority - $1, $2), run_at = now()
+			WHERE run_at < now() - $3 * interval '1 second' AND priority > $2 AND priority <= $4
+			AND job_id NOT IN (`+lockedQueueJobs+`)`,
+			step, floor, int(threshold.Seconds()), ceiling)
+		if err != nil {
+			return aged, err
+		}
+		aged += tag.RowsAffected()
+	}
+	return aged, nil
+}
+
+// agingFloors returns the lowest priority that the queue jobs of each tier may be aged to,
+// which is just behind the fair-share band of the tier above it.
+func agingFloors(tiers []int) []int {
+	floors := make([]int, len(tiers))
+	for i, tier := range tiers {
+		floors[i] = tier
+		if i > 0 {
+			floors[i] = tiers[i-1] + fairShareBand
+		}
+	}
+	return floors
+}
@@ -0,0 +1,108 @@
+package queueing
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/huandu/go-sqlbuilder"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgeJobs(t *testing.T) {
+	db := database.QueueConnection
+
+	enqueuer := NewEnqueuer()
+	jobID, acoID := int(rand.Int31()), uuid.New()
+	jobs := []*models.JobEnqueueArgs{
+		{ID: jobID, ACOID: acoID, ResourceType: "ExplanationOfBenefit"},
+		{ID: jobID, ACOID: acoID, ResourceType: "ExplanationOfBenefit"},
+		{ID: jobID, ACOID: acoID, ResourceType: "ExplanationOfBenefit"},
+	}
+	assert.NoError(t, enqueuer.AddJobs(jobs, 100))
+	assert.NoError(t, enqueuer.AddJob(models.JobEnqueueArgs{ID: jobID, ACOID: acoID, ResourceType: "Patient"}, 25))
+
+	// Only the queue jobs that have been waiting longer than the threshold are aged
+	update := sqlbuilder.PostgreSQL.NewUpdateBuilder().Update("que_jobs")
+	update.Set("run_at = now() - interval '2 hours'")
+	update.Where(update.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), update.Equal("args ->> 'ACOID'", acoID),
+		update.In("priority", 100, 101, 102, 25))
+	query, args := update.Build()
+	_, err := db.Exec(query, args...)
+	assert.NoError(t, err)
+
+	// A queue job that has not waited long enough is left untouched
+	assert.NoError(t, enqueuer.AddJob(models.JobEnqueueArgs{ID: jobID, ACOID: acoID, ResourceType: "Patient"}, 105))
+
+	// A queue job held by a worker is left untouched even though it has been waiting longer than the threshold
+	var lockedJobID int64
+	assert.NoError(t, db.QueryRow(`SELECT job_id FROM que_jobs WHERE CAST (args ->> 'ID' AS INTEGER) = $1
+		AND args ->> 'ACOID' = $2 AND priority = 102`, jobID, acoID).Scan(&lockedJobID))
+	conn, err := db.Acquire()
+	assert.NoError(t, err)
+	_, err = conn.Exec("SELECT pg_advisory_lock($1)", lockedJobID)
+	assert.NoError(t, err)
+	defer func() {
+		_, err := conn.Exec("SELECT pg_advisory_unlock($1)", lockedJobID)
+		assert.NoError(t, err)
+		db.Release(conn)
+	}()
+
+	getPriorities := func() []int16 {
+		sb := sqlbuilder.PostgreSQL.NewSelectBuilder().Select("priority").From("que_jobs")
+		sb.Where(sb.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), sb.Equal("args ->> 'ACOID'", acoID))
+		sb.OrderBy("job_id")
+		query, args := sb.Build()
+		rows, err := db.Query(query, args...)
+		assert.NoError(t, err)
+		defer rows.Close()
+		var priorities []int16
+		for rows.Next() {
+			var priority int16
+			assert.NoError(t, rows.Scan(&priority))
+			priorities = append(priorities, priority)
+		}
+		return priorities
+	}
+
+	tiers := []int{20, 100}
+
+	// Queue jobs keep their fair-share offsets as they are aged
+	_, err = AgeJobs(db, time.Hour, 10, tiers)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{90, 91, 102, 20, 105}, getPriorities())
+
+	// Aged queue jobs are not aged again until they have waited for another threshold
+	_, err = AgeJobs(db, time.Hour, 10, tiers)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{90, 91, 102, 20, 105}, getPriorities())
+
+	// Queue jobs are never aged ahead of the fair-share band of the tier above them
+	update = sqlbuilder.PostgreSQL.NewUpdateBuilder().Update("que_jobs")
+	update.Set("run_at = now() - interval '2 hours'")
+	update.Where(update.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), update.Equal("args ->> 'ACOID'", acoID),
+		update.In("priority", 90, 91, 20))
+	query, args = update.Build()
+	_, err = db.Exec(query, args...)
+	assert.NoError(t, err)
+	_, err = AgeJobs(db, time.Hour, 95, tiers)
+	assert.NoError(t, err)
+	assert.Equal(t, []int16{30, 30, 102, 20, 105}, getPriorities())
+
+	// Cleanup the que data
+	delete := sqlbuilder.PostgreSQL.NewDeleteBuilder().DeleteFrom("que_jobs")
+	delete.Where(delete.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), delete.Equal("args ->> 'ACOID'", acoID))
+	query, args = delete.Build()
+
+	_, err = db.Exec(query, args...)
+	assert.NoError(t, err)
+}
+
+func TestAgingFloors(t *testing.T) {
+	assert.Equal(t, []int{10, 20, 30, 40}, agingFloors([]int{10, 20, 30, 100}))
+	assert.Equal(t, []int{1, 11, 30}, agingFloors([]int{1, 20, 50}))
+	assert.Equal(t, []int{100}, agingFloors([]int{100}))
+}
@@ -2,10 +2,14 @@ package queueing
 
 import (
 	"encoding/json"
+	"math"
+	"math/bits"
 
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/bgentry/que-go"
+	"github.com/jackc/pgx"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -13,17 +17,49 @@ const (
 	ALR_JOB         = "AlrJob"
 )
 
+// Queue jobs are separated into lanes (que queues) so a large export of one resource type
+// cannot starve the others. Each lane is worked on by its own worker pool.
+const (
+	// LaneDefault holds queue jobs that were enqueued before lanes were introduced
+	// or whose resource type does not have a dedicated lane.
+	LaneDefault              = ""
+	LanePatient              = "Patient"
+	LaneCoverage             = "Coverage"
+	LaneExplanationOfBenefit = "ExplanationOfBenefit"
+	LaneAlr                  = "alr"
+)
+
+// Lanes returns every lane that queue jobs may be enqueued in.
+func Lanes() []string {
+	return []string{LaneDefault, LanePatient, LaneCoverage, LaneExplanationOfBenefit, LaneAlr}
+}
+
+// Lane returns the lane that queue jobs for the resource type are enqueued in.
+func Lane(resourceType string) string {
+	switch resourceType {
+	case LanePatient, LaneCoverage, LaneExplanationOfBenefit:
+		return resourceType
+	default:
+		return LaneDefault
+	}
+}
+
 type Enqueuer interface {
 	AddJob(job models.JobEnqueueArgs, priority int) error
+	// AddJobs enqueues all of the jobs of an export job for a single resource type in a single transaction.
+	// The priority increases (i.e. gets worse) within the priority's tier with the number of the ACO's queue jobs
+	// already in the lane, so that an ACO with a large backlog cannot starve the other ACOs in the same lane.
+	AddJobs(jobs []*models.JobEnqueueArgs, priority int) error
 	AddAlrJob(job models.JobAlrEnqueueArgs, priority int) error
 }
 
 func NewEnqueuer() Enqueuer {
-	return queEnqueuer{que.NewClient(database.QueueConnection)}
+	return queEnqueuer{que.NewClient(database.QueueConnection), database.QueueConnection}
 }
 
 type queEnqueuer struct {
 	*que.Client
+	pool *pgx.ConnPool
 }
 
 func (q queEnqueuer) AddJob(job models.JobEnqueueArgs, priority int) error {
@@ -36,11 +72,56 @@ func (q queEnqueuer) AddJob(job models.JobEnqueueArgs, priority int) error {
 		Type:     QUE_PROCESS_JOB,
 		Args:     args,
 		Priority: int16(priority),
+		Queue:    Lane(job.ResourceType),
 	}
 
 	return q.Enqueue(j)
 }
 
+func (q queEnqueuer) AddJobs(jobs []*models.JobEnqueueArgs, priority int) (err error) {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	tx, err := q.pool.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				log.Warnf("Failed to rollback transaction %s", err1.Error())
+			}
+		}
+	}()
+
+	// Queue jobs remain in que_jobs until they are completed, so this counts the ACO's waiting and in-flight queue jobs
+	var backlog int
+	if err = tx.QueryRow(`SELECT COUNT(1) FROM que_jobs WHERE queue = $1 AND job_class = $2 AND args ->> 'ACOID' = $3`,
+		Lane(jobs[0].ResourceType), QUE_PROCESS_JOB, jobs[0].ACOID).Scan(&backlog); err != nil {
+		return err
+	}
+
+	for i, job := range jobs {
+		args, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		j := &que.Job{
+			Type:     QUE_PROCESS_JOB,
+			Args:     args,
+			Priority: fairSharePriority(priority, backlog+i),
+			Queue:    Lane(job.ResourceType),
+		}
+		if err = q.EnqueueInTx(j, tx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 // ALR ENQ...
 func (q queEnqueuer) AddAlrJob(job models.JobAlrEnqueueArgs, priority int) error {
 	args, err := json.Marshal(job)
@@ -52,7 +133,30 @@ func (q queEnqueuer) AddAlrJob(job models.JobAlrEnqueueArgs, priority int) error
 		Type:     ALR_JOB,
 		Args:     args,
 		Priority: int16(priority),
+		Queue:    LaneAlr,
 	}
 
 	return q.Enqueue(j)
 }
+
+// fairShareBand is the number of priorities a tier's queue jobs are spread across. The offsets (0-9) are
+// smaller than the gap between the default priority tiers, so fair sharing never moves a queue job into the next tier.
+const fairShareBand = 10
+
+// fairSharePriority offsets the priority by the size of the ACO's backlog in the lane, i.e. the number of the ACO's
+// queue jobs ahead of this one. The offset grows with the logarithm of the backlog (0, 1, 2-3, 4-7, ... queue jobs)
+// and is capped at the top of the tier's band. que-go works on the lowest priority first, so the first queue jobs
+// of an ACO with little in the lane run ahead of the bulk of a large export from another ACO in the same tier.
+func fairSharePriority(priority, backlog int) int16 {
+	offset := 0
+	if backlog > 0 {
+		offset = bits.Len(uint(backlog))
+	}
+	if offset > fairShareBand-1 {
+		offset = fairShareBand - 1
+	}
+	if priority+offset > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	return int16(priority + offset)
+}
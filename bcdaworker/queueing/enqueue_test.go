@@ -51,3 +51,163 @@ func TestQueEnqueuer(t *testing.T) {
 	_, err := db.Exec(query, args...)
 	assert.NoError(t, err)
 }
+
+func TestQueEnqueuerAddJobs(t *testing.T) {
+	db := database.QueueConnection
+
+	priority := math.MaxInt16
+	enqueuer := NewEnqueuer()
+	jobID, acoID := int(rand.Int31()), uuid.New()
+	jobs := []*models.JobEnqueueArgs{
+		{ID: jobID, ACOID: acoID, ResourceType: "Patient", BeneficiaryIDs: []string{"1", "2"}},
+		{ID: jobID, ACOID: acoID, ResourceType: "Patient", BeneficiaryIDs: []string{"3"}},
+	}
+	assert.NoError(t, enqueuer.AddJobs(jobs, priority))
+
+	// Verify that we've inserted every que_job
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder().Select("COUNT(1)").From("que_jobs")
+	sb.Where(sb.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), sb.Equal("args ->> 'ACOID'", acoID),
+		sb.Equal("priority", priority))
+
+	var count int
+	query, args := sb.Build()
+	row := db.QueryRow(query, args...)
+	assert.NoError(t, row.Scan(&count))
+	assert.Equal(t, len(jobs), count)
+
+	// Cleanup the que data
+	delete := sqlbuilder.PostgreSQL.NewDeleteBuilder().DeleteFrom("que_jobs")
+	delete.Where(delete.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), delete.Equal("args ->> 'ACOID'", acoID))
+	query, args = delete.Build()
+
+	_, err := db.Exec(query, args...)
+	assert.NoError(t, err)
+}
+
+func TestQueEnqueuerLanes(t *testing.T) {
+	db := database.QueueConnection
+
+	enqueuer := NewEnqueuer()
+	jobID, acoID := int(rand.Int31()), uuid.New()
+	jobs := []*models.JobEnqueueArgs{
+		{ID: jobID, ACOID: acoID, ResourceType: "ExplanationOfBenefit", BeneficiaryIDs: []string{"1"}},
+		{ID: jobID, ACOID: acoID, ResourceType: "ExplanationOfBenefit", BeneficiaryIDs: []string{"2"}},
+	}
+	assert.NoError(t, enqueuer.AddJobs(jobs, 100))
+	assert.NoError(t, enqueuer.AddJob(models.JobEnqueueArgs{ID: jobID, ACOID: acoID, ResourceType: "Patient"}, 20))
+
+	// Verify the queue jobs are placed in the resource type's lane with increasing priority within the tier
+	sb := sqlbuilder.PostgreSQL.NewSelectBuilder().Select("queue", "priority").From("que_jobs")
+	sb.Where(sb.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), sb.Equal("args ->> 'ACOID'", acoID))
+	sb.OrderBy("job_id")
+
+	query, args := sb.Build()
+	rows, err := db.Query(query, args...)
+	assert.NoError(t, err)
+	type queJob struct {
+		queue    string
+		priority int16
+	}
+	var queJobs []queJob
+	for rows.Next() {
+		var j queJob
+		assert.NoError(t, rows.Scan(&j.queue, &j.priority))
+		queJobs = append(queJobs, j)
+	}
+	rows.Close()
+	assert.Equal(t, []queJob{{LaneExplanationOfBenefit, 100}, {LaneExplanationOfBenefit, 101}, {LanePatient, 20}}, queJobs)
+
+	// Cleanup the que data
+	delete := sqlbuilder.PostgreSQL.NewDeleteBuilder().DeleteFrom("que_jobs")
+	delete.Where(delete.Equal("CAST (args ->> 'ID' AS INTEGER)", jobID), delete.Equal("args ->> 'ACOID'", acoID))
+	query, args = delete.Build()
+
+	_, err = db.Exec(query, args...)
+	assert.NoError(t, err)
+}
+
+// TestQueEnqueuerFairShare verifies that a small export is interleaved with a large export from another ACO
+// that is already in the same lane and tier rather than waiting behind it.
+func TestQueEnqueuerFairShare(t *testing.T) {
+	db := database.QueueConnection
+
+	enqueuer := NewEnqueuer()
+	largeJobID, largeACOID := int(rand.Int31()), uuid.New()
+	smallJobID, smallACOID := int(rand.Int31()), uuid.New()
+	defer func() {
+		_, err := db.Exec(`DELETE FROM que_jobs WHERE args ->> 'ACOID' IN ($1, $2)`, largeACOID, smallACOID)
+		assert.NoError(t, err)
+	}()
+
+	// The large export is enqueued in several batches, as it would be while its beneficiaries are paged through
+	for batch := 0; batch < 3; batch++ {
+		var jobs []*models.JobEnqueueArgs
+		for i := 0; i < 100; i++ {
+			jobs = append(jobs, &models.JobEnqueueArgs{ID: largeJobID, ACOID: largeACOID, ResourceType: "ExplanationOfBenefit"})
+		}
+		assert.NoError(t, enqueuer.AddJobs(jobs, 100))
+	}
+	smallJobs := []*models.JobEnqueueArgs{
+		{ID: smallJobID, ACOID: smallACOID, ResourceType: "ExplanationOfBenefit"},
+		{ID: smallJobID, ACOID: smallACOID, ResourceType: "ExplanationOfBenefit"},
+		{ID: smallJobID, ACOID: smallACOID, ResourceType: "ExplanationOfBenefit"},
+	}
+	assert.NoError(t, enqueuer.AddJobs(smallJobs, 100))
+
+	// List the queue jobs of both exports in the order que-go works on them
+	rows, err := db.Query(`SELECT args ->> 'ACOID', priority FROM que_jobs WHERE queue = $1 AND args ->> 'ACOID' IN ($2, $3)
+		ORDER BY priority, run_at, job_id`, LaneExplanationOfBenefit, largeACOID, smallACOID)
+	assert.NoError(t, err)
+	var (
+		order           []string
+		largePriorities = make(map[int16]int)
+	)
+	for rows.Next() {
+		var (
+			acoID    string
+			priority int16
+		)
+		assert.NoError(t, rows.Scan(&acoID, &priority))
+		order = append(order, acoID)
+		if acoID == largeACOID {
+			largePriorities[priority]++
+		}
+	}
+	rows.Close()
+	assert.Len(t, order, 303)
+
+	// Each of the small export's queue jobs is worked on after at most a few of the large export's queue jobs
+	var smallPositions []int
+	for i, acoID := range order {
+		if acoID == smallACOID {
+			smallPositions = append(smallPositions, i)
+		}
+	}
+	assert.Equal(t, []int{1, 3, 6}, smallPositions)
+
+	// The bulk of the large export remains within its tier
+	assert.Equal(t, 300-256, largePriorities[109])
+	for priority := range largePriorities {
+		assert.True(t, priority >= 100 && priority < 110, "priority %d is outside of the tier", priority)
+	}
+}
+
+func TestLane(t *testing.T) {
+	assert.Equal(t, LanePatient, Lane("Patient"))
+	assert.Equal(t, LaneCoverage, Lane("Coverage"))
+	assert.Equal(t, LaneExplanationOfBenefit, Lane("ExplanationOfBenefit"))
+	assert.Equal(t, LaneDefault, Lane("Claim"))
+	assert.Contains(t, Lanes(), LaneDefault, "Queue jobs enqueued without a lane must still be worked on")
+}
+
+func TestFairSharePriority(t *testing.T) {
+	assert.Equal(t, int16(100), fairSharePriority(100, 0))
+	assert.Equal(t, int16(101), fairSharePriority(100, 1))
+	assert.Equal(t, int16(102), fairSharePriority(100, 3))
+	assert.Equal(t, int16(103), fairSharePriority(100, 4))
+	assert.Equal(t, int16(109), fairSharePriority(100, 256))
+	// Queue jobs never leave their tier, however large the ACO's backlog is
+	assert.Equal(t, int16(109), fairSharePriority(100, 50005))
+	assert.Equal(t, int16(19), fairSharePriority(10, math.MaxInt32))
+	assert.Equal(t, int16(math.MaxInt16), fairSharePriority(math.MaxInt16, 5))
+}
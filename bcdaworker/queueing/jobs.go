@@ -0,0 +1,138 @@
+package queueing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/jackc/pgx"
+)
+
+// QueueJob is a queue job of an export job that remains in the queue
+type QueueJob struct {
+	ID         int64
+	Args       models.JobEnqueueArgs
+	Priority   int16
+	Lane       string
+	RunAt      time.Time
+	ErrorCount int32
+	LastError  string
+	// Working reports whether a worker currently holds the queue job
+	Working bool
+}
+
+// Workers hold a Postgres advisory lock on the queue jobs they are working on.
+// The lock key is split across the classid (high 32 bits) and objid (low 32 bits) columns of pg_locks.
+const lockedQueueJobs = `SELECT (classid::bigint << 32) + objid::bigint FROM pg_locks WHERE locktype = 'advisory'`
+
+// GetQueueJobs returns the queue jobs of the export job that remain in the queue
+func GetQueueJobs(db *pgx.ConnPool, jobID uint) ([]*QueueJob, error) {
+	rows, err := db.Query(`SELECT job_id, args, priority, queue, run_at, error_count, COALESCE(last_error, ''),
+		job_id IN (`+lockedQueueJobs+`) FROM que_jobs
+		WHERE job_class = $1 AND CAST(args ->> 'ID' AS INTEGER) = $2 ORDER BY job_id`,
+		QUE_PROCESS_JOB, int(jobID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queueJobs []*QueueJob
+	for rows.Next() {
+		var (
+			queueJob QueueJob
+			args     []byte
+		)
+		if err := rows.Scan(&queueJob.ID, &args, &queueJob.Priority, &queueJob.Lane, &queueJob.RunAt,
+			&queueJob.ErrorCount, &queueJob.LastError, &queueJob.Working); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(args, &queueJob.Args); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal args of queue job %d: %w", queueJob.ID, err)
+		}
+		queueJobs = append(queueJobs, &queueJob)
+	}
+
+	return queueJobs, rows.Err()
+}
+
+// RetryFailedQueueJobs schedules the export job's failed queue jobs (queue jobs that errored and are waiting
+// to be retried) to be retried immediately. Queue jobs held by a worker are left untouched.
+// It returns the number of queue jobs scheduled.
+func RetryFailedQueueJobs(db *pgx.ConnPool, jobID uint) (int64, error) {
+	tag, err := db.Exec(`UPDATE que_jobs SET run_at = now()
+		WHERE job_class = $1 AND CAST(args ->> 'ID' AS INTEGER) = $2 AND error_count > 0
+		AND job_id NOT IN (`+lockedQueueJobs+`)`,
+		QUE_PROCESS_JOB, int(jobID))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteQueueJobs removes the export job's queue jobs from the queue. Queue jobs held by a worker are left untouched.
+// It returns the number of queue jobs removed.
+func DeleteQueueJobs(db *pgx.ConnPool, jobID uint) (int64, error) {
+	tag, err := db.Exec(`DELETE FROM que_jobs
+		WHERE job_class = $1 AND CAST(args ->> 'ID' AS INTEGER) = $2
+		AND job_id NOT IN (`+lockedQueueJobs+`)`,
+		QUE_PROCESS_JOB, int(jobID))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// QueueDepth is the number of queue jobs of a type waiting in a lane at a priority
+type QueueDepth struct {
+	Type     string
+	Lane     string
+	Priority int16
+	Count    int64
+}
+
+// GetQueueDepths returns the number of queue jobs that remain in the queue, grouped by type, lane and priority
+func GetQueueDepths(db *pgx.ConnPool) ([]QueueDepth, error) {
+	rows, err := db.Query(`SELECT job_class, queue, priority, COUNT(*) FROM que_jobs
+		GROUP BY job_class, queue, priority ORDER BY job_class, queue, priority`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var depths []QueueDepth
+	for rows.Next() {
+		var depth QueueDepth
+		if err := rows.Scan(&depth.Type, &depth.Lane, &depth.Priority, &depth.Count); err != nil {
+			return nil, err
+		}
+		depths = append(depths, depth)
+	}
+
+	return depths, rows.Err()
+}
+
+// ReleaseQueueJob replaces a queue job held by this worker with a new queue job with the given args, e.g. to record
+// the progress of a queue job that was interrupted. The new queue job keeps the lane, priority and error count of the
+// queue job it replaces, and is available to other workers immediately since it is not locked.
+//
+// The replaced queue job is deleted, so que-go deleting the queue job once the work function returns nil is a no-op.
+func ReleaseQueueJob(db *pgx.ConnPool, queJobID int64, args models.JobEnqueueArgs) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	tag, err := db.Exec(`WITH released AS (
+			DELETE FROM que_jobs WHERE job_id = $2 RETURNING queue, priority, job_class, error_count, last_error
+		)
+		INSERT INTO que_jobs (queue, priority, run_at, job_class, args, error_count, last_error)
+		SELECT queue, priority, now(), job_class, $1::json, error_count, last_error FROM released`, data, queJobID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("queue job %d not found", queJobID)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package queueing
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/database"
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/bgentry/que-go"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueJobs(t *testing.T) {
+	db := database.QueueConnection
+
+	enqueuer := NewEnqueuer()
+	jobID, otherJobID, acoID := uint(rand.Int31()), uint(rand.Int31()), uuid.New()
+	jobs := []*models.JobEnqueueArgs{
+		{ID: int(jobID), ACOID: acoID, ResourceType: "Patient", BeneficiaryIDs: []string{"1"}},
+		{ID: int(jobID), ACOID: acoID, ResourceType: "Coverage", BeneficiaryIDs: []string{"2"}},
+	}
+	assert.NoError(t, enqueuer.AddJobs(jobs, 20))
+	assert.NoError(t, enqueuer.AddJob(models.JobEnqueueArgs{ID: int(otherJobID), ACOID: acoID, ResourceType: "Patient"}, 20))
+	defer func() {
+		_, err := DeleteQueueJobs(db, otherJobID)
+		assert.NoError(t, err)
+	}()
+
+	queueJobs, err := GetQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Len(t, queueJobs, 2)
+	for i, queueJob := range queueJobs {
+		assert.Equal(t, *jobs[i], queueJob.Args)
+		assert.Equal(t, Lane(jobs[i].ResourceType), queueJob.Lane)
+		assert.Equal(t, int32(0), queueJob.ErrorCount)
+		assert.Empty(t, queueJob.LastError)
+		assert.False(t, queueJob.Working)
+	}
+
+	depths, err := GetQueueDepths(db)
+	assert.NoError(t, err)
+	var patientDepth int64
+	for _, depth := range depths {
+		if depth.Type == QUE_PROCESS_JOB && depth.Lane == LanePatient {
+			patientDepth += depth.Count
+		}
+	}
+	// Both export jobs have a Patient queue job in the queue
+	assert.GreaterOrEqual(t, patientDepth, int64(2))
+
+	// Only the queue job that failed is retried
+	_, err = db.Exec(`UPDATE que_jobs SET error_count = 1, last_error = 'connection refused', run_at = now() + interval '1 hour'
+		WHERE job_id = $1`, queueJobs[0].ID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`UPDATE que_jobs SET run_at = now() + interval '1 hour' WHERE job_id = $1`, queueJobs[1].ID)
+	assert.NoError(t, err)
+
+	// The progress of an interrupted queue job is recorded in the args of the queue job that replaces it
+	checkpointArgs := *jobs[0]
+	checkpointArgs.Checkpoint = &models.JobCheckpoint{FileUUID: uuid.New(), LastBeneficiaryID: "1"}
+	assert.NoError(t, ReleaseQueueJob(db, queueJobs[0].ID, checkpointArgs))
+	assert.EqualError(t, ReleaseQueueJob(db, 0, checkpointArgs), "queue job 0 not found")
+
+	count, err := RetryFailedQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// The replacement keeps the lane, priority and errors of the released queue job, but is queued after it
+	released := queueJobs[0]
+	queueJobs, err = GetQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Len(t, queueJobs, 2)
+	assert.True(t, queueJobs[1].ID > released.ID)
+	assert.Equal(t, checkpointArgs, queueJobs[1].Args)
+	assert.Equal(t, released.Lane, queueJobs[1].Lane)
+	assert.Equal(t, released.Priority, queueJobs[1].Priority)
+	assert.Equal(t, int32(1), queueJobs[1].ErrorCount)
+	assert.Equal(t, "connection refused", queueJobs[1].LastError)
+	assert.True(t, queueJobs[1].RunAt.Before(time.Now()))
+	assert.True(t, queueJobs[0].RunAt.After(time.Now()))
+
+	count, err = DeleteQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	queueJobs, err = GetQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Empty(t, queueJobs)
+
+	// Queue jobs of other jobs remain in the queue
+	queueJobs, err = GetQueueJobs(db, otherJobID)
+	assert.NoError(t, err)
+	assert.Len(t, queueJobs, 1)
+}
+
+// TestReleaseQueueJobLocked verifies that a released queue job remains in the queue once que-go deletes and
+// unlocks the queue job it locked, as it does when the work function returns nil
+func TestReleaseQueueJobLocked(t *testing.T) {
+	db := database.QueueConnection
+	qc := que.NewClient(db)
+
+	// Use a lane of our own so that the queue job we enqueue is the one locked
+	jobID, lane := uint(rand.Int31()), "release-"+uuid.New()
+	args := models.JobEnqueueArgs{ID: int(jobID), ACOID: uuid.New(), ResourceType: "Patient", BeneficiaryIDs: []string{"1"}}
+	data, err := json.Marshal(args)
+	assert.NoError(t, err)
+	assert.NoError(t, qc.Enqueue(&que.Job{Type: QUE_PROCESS_JOB, Queue: lane, Priority: 20, Args: data}))
+	defer func() {
+		_, err := DeleteQueueJobs(db, jobID)
+		assert.NoError(t, err)
+	}()
+
+	job, err := qc.LockJob(lane)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, job) {
+		return
+	}
+
+	checkpointArgs := args
+	checkpointArgs.Checkpoint = &models.JobCheckpoint{FileUUID: uuid.New(), LastBeneficiaryID: "1"}
+	assert.NoError(t, ReleaseQueueJob(db, job.ID, checkpointArgs))
+	assert.NoError(t, job.Delete())
+	job.Done()
+
+	queueJobs, err := GetQueueJobs(db, jobID)
+	assert.NoError(t, err)
+	assert.Len(t, queueJobs, 1)
+	assert.NotEqual(t, job.ID, queueJobs[0].ID)
+	assert.Equal(t, checkpointArgs, queueJobs[0].Args)
+	assert.Equal(t, lane, queueJobs[0].Lane)
+	assert.Equal(t, int16(20), queueJobs[0].Priority)
+	assert.False(t, queueJobs[0].Working)
+}
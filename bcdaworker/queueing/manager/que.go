@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	goerrors "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/metrics"
 	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcda/service"
 	"github.com/CMSgov/bcda-app/bcda/utils"
 	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
 	"github.com/CMSgov/bcda-app/bcdaworker/repository"
@@ -19,14 +21,39 @@ import (
 	"github.com/bgentry/que-go"
 	"github.com/jackc/pgx"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	queueJobsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bcda_worker_queue_jobs_in_flight",
+		Help: "Number of queue jobs being worked on",
+	}, []string{"resource_type"})
+	queueJobsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bcda_worker_queue_jobs_processed_total",
+		Help: "Number of queue jobs worked on",
+	}, []string{"resource_type", "result"})
+	beneficiariesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bcda_worker_beneficiaries_processed_total",
+		Help: "Number of beneficiaries whose data was exported",
+	}, []string{"resource_type"})
+	queueJobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bcda_worker_queue_job_duration_seconds",
+		Help:    "Time taken to work on a queue job",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"resource_type"})
+)
+
 // queue is responsible for retrieving jobs using the que client and
 // transforming and delegating that work to the underlying worker
 type queue struct {
-	// Resources associated with the underlying que client
-	quePool *que.WorkerPool
+	// Resources associated with the underlying que client.
+	// There is a worker pool for each lane.
+	quePools []*que.WorkerPool
+	// Closed to stop the background tasks (aging and reconciling jobs)
+	stop chan struct{}
 
 	worker     worker.Worker
 	repository repository.Repository
@@ -47,6 +74,8 @@ type masterQueue struct {
 // StartQue creates a que-go client and begins listening for items
 // It returns immediately since all of the associated workers are started
 // in separate goroutines.
+// Each lane is worked on by its own pool of workers, with the numWorkers workers split across the lanes.
+// The number of workers for a lane can be overridden by setting WORKER_POOL_SIZE_<LANE> (e.g. WORKER_POOL_SIZE_PATIENT).
 func StartQue(log *logrus.Logger, numWorkers int) *masterQueue {
 	// Allocate the queue in advance to supply the correct
 	// in the workmap
@@ -71,19 +100,95 @@ func StartQue(log *logrus.Logger, numWorkers int) *masterQueue {
 	qc := que.NewClient(q.queDB)
 	wm := que.WorkMap{
 		queueing.QUE_PROCESS_JOB: q.processJob,
-		queueing.ALR_JOB:         master.startAlrJob, // ALR currently shares pool
+		queueing.ALR_JOB:         master.startAlrJob,
 	}
 
-	q.quePool = que.NewWorkerPool(qc, wm, numWorkers)
+	for lane, size := range workerPoolSizes(numWorkers) {
+		if size <= 0 {
+			log.Warnf("No workers configured for lane %q. Queue jobs in this lane will not be worked on. "+
+				"Increase WORKER_POOL_SIZE or set WORKER_POOL_SIZE_<LANE>.", lane)
+			continue
+		}
+		pool := que.NewWorkerPool(qc, wm, size)
+		pool.Queue = lane
+		pool.Start()
+		q.quePools = append(q.quePools, pool)
+		log.Infof("Started %d worker(s) for lane %q", size, lane)
+	}
 
-	q.quePool.Start()
+	q.stop = make(chan struct{})
+	if cfg, err := service.LoadConfig(); err != nil {
+		log.Errorf("Failed to load service config. Queue jobs will not be aged: %s", err.Error())
+	} else if cfg.PriorityAging.Enabled() {
+		go q.agePriorities(cfg.PriorityAging, cfg.PriorityTiers(), time.Minute)
+	}
+
+	// Jobs that have not been updated within the threshold are checked for lost queue jobs
+	if interval := utils.GetEnvInt("BCDA_WORKER_RECONCILE_INTERVAL_MINUTES", 10); interval > 0 {
+		rc := newReconciler(q, time.Duration(utils.GetEnvInt("BCDA_WORKER_STUCK_JOB_THRESHOLD_MINUTES", 60))*time.Minute,
+			time.Duration(utils.GetEnvInt("BCDA_WORKER_PLANNING_GRACE_MINUTES", 240))*time.Minute,
+			utils.GetEnvInt("BCDA_WORKER_MAX_JOB_REQUEUES", 3))
+		go rc.run(time.Duration(interval)*time.Minute, q.stop)
+	}
 
 	return master
 }
 
 // StopQue cleans up any resources created
 func (q *masterQueue) StopQue() {
-	q.quePool.Shutdown()
+	close(q.stop)
+	for _, pool := range q.quePools {
+		pool.Shutdown()
+	}
+}
+
+// agePriorities improves the priority of queue jobs that have been waiting to be worked on within their priority tier.
+// It runs until the queue is stopped.
+func (q *queue) agePriorities(cfg service.PriorityAging, tiers []int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			count, err := queueing.AgeJobs(q.queDB, time.Duration(cfg.ThresholdMinutes)*time.Minute, cfg.Step, tiers)
+			if err != nil {
+				q.log.Warnf("Failed to age queue jobs: %s", err.Error())
+			} else if count > 0 {
+				q.log.Infof("Improved priority of %d queue job(s) waiting longer than %d minute(s)", count, cfg.ThresholdMinutes)
+			}
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// workerPoolSizes returns the number of workers for each lane. The numWorkers workers are split across the
+// lanes whose size has not been overridden, with earlier lanes receiving any remainder. These lanes never
+// receive more than numWorkers workers in total, so when there are fewer workers than lanes the later lanes
+// receive none and their queue jobs are not worked on.
+func workerPoolSizes(numWorkers int) map[string]int {
+	sizes := make(map[string]int)
+	var shared []string
+	for _, lane := range queueing.Lanes() {
+		name := "DEFAULT"
+		if lane != queueing.LaneDefault {
+			name = strings.ToUpper(lane)
+		}
+		if size := utils.GetEnvInt("WORKER_POOL_SIZE_"+name, -1); size >= 0 {
+			sizes[lane] = size
+			continue
+		}
+		shared = append(shared, lane)
+	}
+
+	for i, lane := range shared {
+		size := numWorkers / len(shared)
+		if i < numWorkers%len(shared) {
+			size++
+		}
+		sizes[lane] = size
+	}
+	return sizes
 }
 
 func (q *queue) processJob(job *que.Job) error {
@@ -97,7 +202,7 @@ func (q *queue) processJob(job *que.Job) error {
 	if err != nil {
 		// ACK the job because retrying it won't help us be able to deserialize the data
 		q.log.Warnf("Failed to deserialize job.Args '%s' %s. Removing queuejob from que.", job.Args, err)
-		return nil
+		return q.deadLetter(job, jobArgs, fmt.Sprintf("failed to deserialize args: %s", err.Error()))
 	}
 
 	// start a goroutine that will periodically check the status of the parent job
@@ -126,11 +231,16 @@ func (q *queue) processJob(job *que.Job) error {
 	if goerrors.Is(err, worker.ErrParentJobCancelled) {
 		// ACK the job because we do not need to work on queue jobs associated with a cancelled parent job
 		q.log.Warnf("queJob %d associated with a cancelled parent Job %d. Removing queuejob from que.", job.ID, jobArgs.ID)
+		if jobArgs.PlannedQueueJobID != 0 {
+			if err := q.repository.DeletePlannedQueueJob(ctx, jobArgs.PlannedQueueJobID); err != nil {
+				q.log.Warnf("Failed to delete planned queue job %d. %s", jobArgs.PlannedQueueJobID, err.Error())
+			}
+		}
 		return nil
 	} else if goerrors.Is(err, worker.ErrNoBasePathSet) {
 		// Data is corrupted, we cannot work on this job.
 		q.log.Warnf("Job %d does not contain valid base path. Removing queuejob from que.", jobArgs.ID)
-		return nil
+		return q.deadLetter(job, jobArgs, "args do not contain a base path")
 	} else if goerrors.Is(err, worker.ErrParentJobNotFound) {
 		// Based on the current backoff delay (j.ErrorCount^4 + 3 seconds), this should've given
 		// us plenty of headroom to ensure that the parent job will never be found.
@@ -139,7 +249,7 @@ func (q *queue) processJob(job *que.Job) error {
 			q.log.Errorf("No job found for ID: %d acoID: %s. Retries exhausted. Removing job from queue.", jobArgs.ID,
 				jobArgs.ACOID)
 			// By returning a nil error response, we're singaling to que-go to remove this job from the jobqueue.
-			return nil
+			return q.deadLetter(job, jobArgs, fmt.Sprintf("job not found after %d attempt(s)", job.ErrorCount+1))
 		}
 
 		q.log.Warnf("No job found for ID: %d acoID: %s. Will retry.", jobArgs.ID, jobArgs.ACOID)
@@ -148,13 +258,88 @@ func (q *queue) processJob(job *que.Job) error {
 		return errors.Wrap(err, "failed to validate job")
 	}
 
-	if err := q.worker.ProcessJob(ctx, *exportJob, jobArgs); err != nil {
+	queueJobsInFlight.WithLabelValues(jobArgs.ResourceType).Inc()
+	start := time.Now()
+	// Stopping the queue signals the worker to checkpoint its progress rather than finishing the queue job
+	err = q.worker.ProcessJob(worker.WithStop(ctx, q.stop), *exportJob, jobArgs)
+	queueJobsInFlight.WithLabelValues(jobArgs.ResourceType).Dec()
+	queueJobDuration.WithLabelValues(jobArgs.ResourceType).Observe(time.Since(start).Seconds())
+
+	var stopped *worker.StoppedError
+	switch {
+	case goerrors.As(err, &stopped):
+		queueJobsProcessed.WithLabelValues(jobArgs.ResourceType, "stopped").Inc()
+	case err != nil:
+		queueJobsProcessed.WithLabelValues(jobArgs.ResourceType, "error").Inc()
+	default:
+		queueJobsProcessed.WithLabelValues(jobArgs.ResourceType, "completed").Inc()
+		beneficiariesProcessed.WithLabelValues(jobArgs.ResourceType).Add(float64(len(jobArgs.BeneficiaryIDs)))
+	}
+
+	if stopped != nil {
+		return q.checkpoint(job, jobArgs, stopped)
+	} else if goerrors.Is(err, worker.ErrBFDUnavailable) {
+		// By returning an error, que-go will retry the queue job using its backoff delay (j.ErrorCount^4 + 3 seconds).
+		// This gives BFD time to recover without failing the entire export.
+		q.log.Warnf("Blue Button unavailable for queJob %d (job %d). Will retry.", job.ID, jobArgs.ID)
+		return err
+	} else if err != nil {
 		return errors.Wrap(err, "failed to process job")
 	}
 
 	return nil
 }
 
+// checkpoint records the progress of a queue job interrupted by the worker stopping in the args of the queue job
+// that replaces it. No error is counted against the queue job, so the next worker resumes it from the checkpoint.
+func (q *queue) checkpoint(job *que.Job, jobArgs models.JobEnqueueArgs, stopped *worker.StoppedError) error {
+	if stopped.Checkpoint != nil {
+		jobArgs.Checkpoint = stopped.Checkpoint
+	}
+	if err := queueing.ReleaseQueueJob(q.queDB, job.ID, jobArgs); err != nil {
+		if stopped.Checkpoint != nil {
+			// Discard the staged files so the queue job is started over rather than resumed from an earlier checkpoint
+			worker.DiscardCheckpoint(jobArgs.ID, stopped.Checkpoint)
+		}
+		q.log.Errorf("Failed to release queJob %d (job %d). Will retry from the start. %s", job.ID, jobArgs.ID, err.Error())
+		return errors.Wrap(err, "failed to checkpoint queue job")
+	}
+
+	if stopped.Checkpoint == nil {
+		q.log.Warnf("Worker stopped before queJob %d (job %d) was started. Will retry.", job.ID, jobArgs.ID)
+	} else {
+		q.log.Infof("Checkpointed queJob %d (job %d) after beneficiary %s. Will resume.", job.ID, jobArgs.ID,
+			stopped.Checkpoint.LastBeneficiaryID)
+	}
+	// Returning nil lets que-go release the queue job. The queue job was already replaced, so it has nothing to delete.
+	return nil
+}
+
+// deadLetter moves a queue job that can never be worked on successfully to the dead letter table, flagging its job.
+// The queue job remains in the queue (by returning an error) if it could not be dead-lettered, so it is never lost.
+func (q *queue) deadLetter(job *que.Job, jobArgs models.JobEnqueueArgs, reason string) error {
+	ctx := context.Background()
+
+	args := job.Args
+	if !json.Valid(args) {
+		// Keep the original args as a JSON string so they can still be inspected
+		args, _ = json.Marshal(string(job.Args))
+	}
+	deadLetter := models.DeadLetterQueueJob{JobID: uint(jobArgs.ID), QueJobID: job.ID, Args: args,
+		Priority: int(job.Priority), ErrorCount: int(job.ErrorCount), LastError: job.LastError.String, Reason: reason}
+	if err := q.repository.CreateDeadLetterQueueJob(ctx, deadLetter); err != nil {
+		return errors.Wrap(err, "failed to dead-letter queue job")
+	}
+
+	// The dead-lettered queue job replaces the planned queue job
+	if jobArgs.PlannedQueueJobID != 0 {
+		if err := q.repository.DeletePlannedQueueJob(ctx, jobArgs.PlannedQueueJobID); err != nil {
+			q.log.Warnf("Failed to delete planned queue job %d. %s", jobArgs.PlannedQueueJobID, err.Error())
+		}
+	}
+	return nil
+}
+
 func (q *queue) isParentJobCancelled(jobID int) (bool, error) {
 	ctx := context.Background()
 
@@ -184,6 +369,18 @@ func (q *queue) updateJobQueueCountCloudwatchMetric() {
 	}
 }
 
+// getLiveQueueJobCount returns the number of the job's queue jobs that remain in the queue
+func (q *queue) getLiveQueueJobCount(jobID uint) (int, error) {
+	row := q.queDB.QueryRow(`SELECT COUNT(1) FROM que_jobs WHERE job_class = $1 AND CAST(args ->> 'ID' AS INTEGER) = $2`,
+		queueing.QUE_PROCESS_JOB, int(jobID))
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (q *queue) getQueueJobCount() float64 {
 	row := q.queDB.QueryRow(`select count(*) from que_jobs;`)
 
@@ -2,9 +2,13 @@ package manager
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"regexp"
 	"testing"
 	"time"
@@ -19,9 +23,11 @@ import (
 	"github.com/CMSgov/bcda-app/conf"
 	"github.com/bgentry/que-go"
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // logHook allows us to retrieve the messages emitted by the logging instance
@@ -103,8 +109,15 @@ func isTerminalStatus(status models.JobStatus) bool {
 }
 
 func TestProcessJobInvalidArgs(t *testing.T) {
-	job := &que.Job{Args: []byte("{invalid_json")}
-	queue := &queue{log: log}
+	job := &que.Job{ID: rand.Int63(), Args: []byte("{invalid_json"), Priority: 20}
+	repo := &repository.MockRepository{}
+	defer repo.AssertExpectations(t)
+	// Invalid JSON args are kept as a JSON string
+	repo.On("CreateDeadLetterQueueJob", testUtils.CtxMatcher, models.DeadLetterQueueJob{QueJobID: job.ID,
+		Args: []byte(`"{invalid_json"`), Priority: 20, Reason: "failed to deserialize args: invalid character 'i' looking for beginning of object key string"}).
+		Return(nil)
+
+	queue := &queue{repository: repo, log: log}
 	assert.NoError(t, queue.processJob(job),
 		"No error since invalid job data should not be retried")
 	entry := logHook.LastEntry()
@@ -113,18 +126,31 @@ func TestProcessJobInvalidArgs(t *testing.T) {
 		fmt.Sprintf("Failed to deserialize job.Args '%s'", job.Args))
 }
 
+func TestProcessJobDeadLetterFailed(t *testing.T) {
+	job := &que.Job{ID: rand.Int63(), Args: []byte(`{"ID": "not a number"}`)}
+	repo := &repository.MockRepository{}
+	repo.On("CreateDeadLetterQueueJob", testUtils.CtxMatcher, mock.Anything).Return(errors.New("connection refused"))
+
+	// The queue job must remain in the queue so it is not lost
+	queue := &queue{repository: repo, log: log}
+	assert.EqualError(t, queue.processJob(job), "failed to dead-letter queue job: connection refused")
+}
+
 func TestProcessJobFailedValidation(t *testing.T) {
 	tests := []struct {
 		name        string
 		validateErr error
 		expectedErr error
 		expLogMsg   string
+		// Reason the queue job is dead-lettered, empty if the queue job should not be dead-lettered
+		expDeadLetterReason string
 	}{
-		{"ParentJobCancelled", worker.ErrParentJobCancelled, nil, `^queJob \d+ associated with a cancelled parent Job`},
-		{"NoBasePath", worker.ErrNoBasePathSet, nil, `^Job \d+ does not contain valid base path`},
-		{"NoParentJob", worker.ErrParentJobNotFound, repository.ErrJobNotFound, `^No job found for ID: \d+ acoID.*Will retry`},
-		{"NoParentJobRetriesExceeded", worker.ErrParentJobNotFound, nil, `No job found for ID: \d+ acoID.*Retries exhausted`},
-		{"OtherError", fmt.Errorf("some other error"), fmt.Errorf("some other error"), ""},
+		{"ParentJobCancelled", worker.ErrParentJobCancelled, nil, `^queJob \d+ associated with a cancelled parent Job`, ""},
+		{"NoBasePath", worker.ErrNoBasePathSet, nil, `^Job \d+ does not contain valid base path`, "args do not contain a base path"},
+		{"NoParentJob", worker.ErrParentJobNotFound, repository.ErrJobNotFound, `^No job found for ID: \d+ acoID.*Will retry`, ""},
+		{"NoParentJobRetriesExceeded", worker.ErrParentJobNotFound, nil, `No job found for ID: \d+ acoID.*Retries exhausted`,
+			"job not found after 4 attempt(s)"},
+		{"OtherError", fmt.Errorf("some other error"), fmt.Errorf("some other error"), "", ""},
 	}
 
 	for _, tt := range tests {
@@ -133,18 +159,28 @@ func TestProcessJobFailedValidation(t *testing.T) {
 			worker := &worker.MockWorker{}
 			defer worker.AssertExpectations(t)
 
-			queue := &queue{worker: worker, log: log}
+			repo := &repository.MockRepository{}
+			defer repo.AssertExpectations(t)
+
+			queue := &queue{worker: worker, repository: repo, log: log}
 
 			job := models.Job{ID: uint(rand.Int31())}
 			jobArgs := models.JobEnqueueArgs{ID: int(job.ID), ACOID: uuid.New()}
 
-			var queJob que.Job
+			queJob := que.Job{ID: rand.Int63()}
 			queJob.Args, err = json.Marshal(jobArgs)
 			assert.NoError(t, err)
 
 			// Set the error count to max to ensure that we've exceeded the retries
 			if tt.name == "NoParentJobRetriesExceeded" {
-				queJob.ErrorCount = rand.Int31()
+				queJob.ErrorCount = 3
+				queJob.LastError.String = "could not retrieve job from database"
+			}
+
+			if tt.expDeadLetterReason != "" {
+				repo.On("CreateDeadLetterQueueJob", testUtils.CtxMatcher, models.DeadLetterQueueJob{JobID: job.ID,
+					QueJobID: queJob.ID, Args: queJob.Args, ErrorCount: int(queJob.ErrorCount),
+					LastError: queJob.LastError.String, Reason: tt.expDeadLetterReason}).Return(nil)
 			}
 
 			worker.On("ValidateJob", testUtils.CtxMatcher, jobArgs).Return(nil, tt.validateErr)
@@ -164,4 +200,172 @@ func TestProcessJobFailedValidation(t *testing.T) {
 
 }
 
+func TestProcessJobBFDUnavailable(t *testing.T) {
+	mockWorker := &worker.MockWorker{}
+	defer mockWorker.AssertExpectations(t)
+
+	queue := &queue{worker: mockWorker, log: log}
+
+	job := models.Job{ID: uint(rand.Int31())}
+	jobArgs := models.JobEnqueueArgs{ID: int(job.ID), ACOID: uuid.New(), ResourceType: "Claim"}
+	queJob := que.Job{ID: rand.Int63()}
+	var err error
+	queJob.Args, err = json.Marshal(jobArgs)
+	assert.NoError(t, err)
+
+	mockWorker.On("ValidateJob", testUtils.CtxMatcher, jobArgs).Return(&job, nil)
+	mockWorker.On("ProcessJob", testUtils.CtxMatcher, job, jobArgs).Return(worker.ErrBFDUnavailable)
+
+	// Error is returned so que-go retries the queue job later
+	assert.Equal(t, worker.ErrBFDUnavailable, queue.processJob(&queJob))
+	assert.Regexp(t, `^Blue Button unavailable for queJob \d+ \(job \d+\). Will retry.`, logHook.LastEntry().Message)
+
+	rr := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/_metrics", nil))
+	assert.Contains(t, rr.Body.String(), `bcda_worker_queue_jobs_processed_total{resource_type="Claim",result="error"} 1`)
+	assert.Contains(t, rr.Body.String(), `bcda_worker_queue_jobs_in_flight{resource_type="Claim"} 0`)
+	assert.Contains(t, rr.Body.String(), `bcda_worker_queue_job_duration_seconds_count{resource_type="Claim"} 1`)
+}
+
+// TestProcessJobStopped verifies that a queue job interrupted by the worker stopping is released to be resumed
+// without counting an error against it
+func TestProcessJobStopped(t *testing.T) {
+	db := database.QueueConnection
+
+	tests := []struct {
+		name       string
+		checkpoint *models.JobCheckpoint
+		message    string
+	}{
+		{"NotStarted", nil, `^Worker stopped before queJob \d+ \(job \d+\) was started. Will retry.`},
+		{"Checkpointed", &models.JobCheckpoint{FileUUID: uuid.New(), LastBeneficiaryID: "1", FileOffset: 10},
+			`^Checkpointed queJob \d+ \(job \d+\) after beneficiary 1. Will resume.`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWorker := &worker.MockWorker{}
+			defer mockWorker.AssertExpectations(t)
+
+			stop := make(chan struct{})
+			close(stop)
+			queue := &queue{worker: mockWorker, log: log, stop: stop, queDB: db}
+
+			job := models.Job{ID: uint(rand.Int31())}
+			jobArgs := models.JobEnqueueArgs{ID: int(job.ID), ACOID: uuid.New(), ResourceType: "Patient", BeneficiaryIDs: []string{"1", "2"}}
+			assert.NoError(t, queueing.NewEnqueuer().AddJob(jobArgs, 10))
+			defer func() {
+				_, err := queueing.DeleteQueueJobs(db, job.ID)
+				assert.NoError(t, err)
+			}()
+
+			queJob := que.Job{Queue: queueing.LanePatient}
+			assert.NoError(t, db.QueryRow(`SELECT job_id, priority, run_at FROM que_jobs WHERE CAST(args ->> 'ID' AS INTEGER) = $1`,
+				job.ID).Scan(&queJob.ID, &queJob.Priority, &queJob.RunAt))
+			var err error
+			queJob.Args, err = json.Marshal(jobArgs)
+			assert.NoError(t, err)
+
+			mockWorker.On("ValidateJob", testUtils.CtxMatcher, jobArgs).Return(&job, nil)
+			mockWorker.On("ProcessJob", testUtils.CtxMatcher, job, jobArgs).Return(&worker.StoppedError{Checkpoint: tt.checkpoint})
+
+			// No error is returned, so que-go does not count an error against the queue job
+			assert.NoError(t, queue.processJob(&queJob))
+			assert.Regexp(t, tt.message, logHook.LastEntry().Message)
+
+			queueJobs, err := queueing.GetQueueJobs(db, job.ID)
+			assert.NoError(t, err)
+			assert.Len(t, queueJobs, 1)
+			assert.Equal(t, tt.checkpoint, queueJobs[0].Args.Checkpoint)
+			assert.Equal(t, int32(0), queueJobs[0].ErrorCount)
+			// The queue job was replaced, so que-go has nothing to delete once it releases the queue job it locked
+			assert.NotEqual(t, queJob.ID, queueJobs[0].ID)
+			assert.Equal(t, queJob.Priority, queueJobs[0].Priority)
+		})
+	}
+}
+
+// TestProcessJobStoppedReleaseFailed verifies that the checkpoint's staged files are discarded when the queue job
+// could not be released, so the queue job is started over
+func TestProcessJobStoppedReleaseFailed(t *testing.T) {
+	defer conf.SetEnv(t, "FHIR_STAGING_DIR", conf.GetEnv("FHIR_STAGING_DIR"))
+	tempDir, err := ioutil.TempDir("", "*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+	conf.SetEnv(t, "FHIR_STAGING_DIR", tempDir)
+
+	mockWorker := &worker.MockWorker{}
+	defer mockWorker.AssertExpectations(t)
+
+	stop := make(chan struct{})
+	close(stop)
+	queue := &queue{worker: mockWorker, log: log, stop: stop, queDB: database.QueueConnection}
+
+	job := models.Job{ID: uint(rand.Int31())}
+	jobArgs := models.JobEnqueueArgs{ID: int(job.ID), ACOID: uuid.New(), ResourceType: "Patient"}
+	// The queue job is not in the queue, so it cannot be released
+	queJob := que.Job{ID: 0}
+	queJob.Args, err = json.Marshal(jobArgs)
+	assert.NoError(t, err)
+
+	checkpoint := &models.JobCheckpoint{FileUUID: uuid.New(), LastBeneficiaryID: "1"}
+	assert.NoError(t, os.MkdirAll(fmt.Sprintf("%s/%d", tempDir, job.ID), os.ModePerm))
+	stagedFile := fmt.Sprintf("%s/%d/%s.ndjson", tempDir, job.ID, checkpoint.FileUUID)
+	assert.NoError(t, ioutil.WriteFile(stagedFile, []byte("{}\n"), 0600))
+
+	mockWorker.On("ValidateJob", testUtils.CtxMatcher, jobArgs).Return(&job, nil)
+	mockWorker.On("ProcessJob", testUtils.CtxMatcher, job, jobArgs).Return(&worker.StoppedError{Checkpoint: checkpoint})
+
+	assert.EqualError(t, queue.processJob(&queJob), "failed to checkpoint queue job: queue job 0 not found")
+	_, err = os.Stat(stagedFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
 // Test ALR startAlrjob
+
+func TestWorkerPoolSizes(t *testing.T) {
+	defer conf.UnsetEnv(t, "WORKER_POOL_SIZE_EXPLANATIONOFBENEFIT")
+	defer conf.UnsetEnv(t, "WORKER_POOL_SIZE_ALR")
+	conf.SetEnv(t, "WORKER_POOL_SIZE_EXPLANATIONOFBENEFIT", "5")
+	conf.SetEnv(t, "WORKER_POOL_SIZE_ALR", "0")
+
+	// The shared lanes never receive more than WORKER_POOL_SIZE workers, so later lanes may receive none
+	assert.Equal(t, map[string]int{
+		queueing.LaneDefault:              1,
+		queueing.LanePatient:              1,
+		queueing.LaneCoverage:             0,
+		queueing.LaneExplanationOfBenefit: 5,
+		queueing.LaneAlr:                  0,
+	}, workerPoolSizes(2))
+	assert.Equal(t, map[string]int{
+		queueing.LaneDefault:              4,
+		queueing.LanePatient:              3,
+		queueing.LaneCoverage:             3,
+		queueing.LaneExplanationOfBenefit: 5,
+		queueing.LaneAlr:                  0,
+	}, workerPoolSizes(10))
+
+	conf.UnsetEnv(t, "WORKER_POOL_SIZE_EXPLANATIONOFBENEFIT")
+	conf.UnsetEnv(t, "WORKER_POOL_SIZE_ALR")
+	total := func(sizes map[string]int) (total int) {
+		for _, size := range sizes {
+			total += size
+		}
+		return total
+	}
+	sizes := workerPoolSizes(12)
+	assert.Equal(t, 12, total(sizes))
+	assert.Equal(t, 3, sizes[queueing.LaneDefault])
+	assert.Equal(t, 2, sizes[queueing.LaneAlr])
+
+	for numWorkers := 0; numWorkers <= len(queueing.Lanes()); numWorkers++ {
+		assert.Equal(t, numWorkers, total(workerPoolSizes(numWorkers)), "workers for WORKER_POOL_SIZE %d", numWorkers)
+	}
+	assert.Equal(t, map[string]int{
+		queueing.LaneDefault:              1,
+		queueing.LanePatient:              1,
+		queueing.LaneCoverage:             0,
+		queueing.LaneExplanationOfBenefit: 0,
+		queueing.LaneAlr:                  0,
+	}, workerPoolSizes(2))
+}
@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
+	"github.com/CMSgov/bcda-app/bcdaworker/repository"
+	"github.com/CMSgov/bcda-app/bcdaworker/worker"
+	"github.com/sirupsen/logrus"
+)
+
+// reconciler recovers jobs that have stopped making progress because their queue jobs were lost.
+// A job is stuck when it has not been updated within the threshold and none of its queue jobs remain in the queue.
+type reconciler struct {
+	r        repository.Repository
+	enqueuer queueing.Enqueuer
+	log      *logrus.Logger
+
+	// liveQueueJobs returns the number of the job's queue jobs that remain in the queue
+	liveQueueJobs func(jobID uint) (int, error)
+	// completeJob marks the job as completed once all of its queue jobs have been completed
+	completeJob func(ctx context.Context, r repository.Repository, jobID uint) (bool, error)
+
+	threshold time.Duration
+	// Amount of time (since the job was created) the API is given to plan all of the job's queue jobs
+	planningGracePeriod time.Duration
+	// Number of times lost queue jobs are re-enqueued before the job is failed
+	maxRequeues int
+}
+
+func newReconciler(q *queue, threshold, planningGracePeriod time.Duration, maxRequeues int) *reconciler {
+	return &reconciler{
+		r:                   q.repository,
+		enqueuer:            queueing.NewEnqueuer(),
+		log:                 q.log,
+		liveQueueJobs:       q.getLiveQueueJobCount,
+		completeJob:         worker.CompleteJob,
+		threshold:           threshold,
+		maxRequeues:         maxRequeues,
+		planningGracePeriod: planningGracePeriod,
+	}
+}
+
+// reconcile recovers all of the stuck jobs
+func (rc *reconciler) reconcile(ctx context.Context) {
+	jobs, err := rc.r.GetStaleJobs(ctx, time.Now().Add(-rc.threshold), models.JobStatusPending, models.JobStatusInProgress)
+	if err != nil {
+		rc.log.Warnf("Failed to retrieve stale jobs: %s", err.Error())
+		return
+	}
+
+	for _, job := range jobs {
+		if err := rc.reconcileJob(ctx, job); err != nil {
+			rc.log.Warnf("Failed to reconcile job %d: %s", job.ID, err.Error())
+		}
+	}
+}
+
+// reconcileJob compares the job's count against its completed (job keys) and live queue jobs.
+// Jobs whose queue jobs were all completed are marked as completed, lost queue jobs are re-enqueued
+// from the planned queue jobs, and jobs that cannot be recovered are failed.
+func (rc *reconciler) reconcileJob(ctx context.Context, job *models.Job) error {
+	live, err := rc.liveQueueJobs(job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count queue jobs: %w", err)
+	}
+	if live > 0 {
+		// The job's queue jobs are still waiting to be worked on
+		return nil
+	}
+	if job.DeadLetterCount > 0 {
+		// The job's dead-lettered queue jobs must be replayed or discarded
+		return nil
+	}
+	if job.JobCount == 0 && time.Since(job.CreatedAt) < rc.planningGracePeriod {
+		// The job count is set once all of the queue jobs are planned, which can take longer than the threshold
+		// for the largest ACOs. The job may still be planned, so it is not considered interrupted yet.
+		return nil
+	}
+
+	// Claim the job so that it is reconciled by one worker, and not again until the threshold has passed
+	if err := rc.r.ClaimJob(ctx, job.ID, job.UpdatedAt); goerrors.Is(err, repository.ErrJobNotUpdated) {
+		// The job was updated (or claimed by another worker) after it was found to be stuck
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	if job.JobCount == 0 {
+		return rc.fail(ctx, job, "the request was interrupted before all of its queue jobs were enqueued")
+	}
+
+	completed, err := rc.r.GetJobKeyCount(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count job keys: %w", err)
+	}
+	if completed >= job.JobCount {
+		if _, err := rc.completeJob(ctx, rc.r, job.ID); err != nil {
+			return fmt.Errorf("failed to complete job: %w", err)
+		}
+		return rc.record(ctx, job.ID, models.ReconciliationCompleted,
+			fmt.Sprintf("all %d queue job(s) were completed but the job was not marked as completed", job.JobCount))
+	}
+
+	// Planned queue jobs are reconciled individually: those that were completed (have a job key) are not
+	// returned, even if the worker failed to delete them, so each one remaining was lost
+	lost := job.JobCount - completed
+	planned, err := rc.r.GetPlannedQueueJobs(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve planned queue jobs: %w", err)
+	}
+	if len(planned) != lost {
+		return rc.fail(ctx, job, fmt.Sprintf("%d queue job(s) were lost but %d planned queue job(s) were found", lost, len(planned)))
+	}
+
+	requeues, err := rc.r.GetJobReconciliationCount(ctx, job.ID, models.ReconciliationRequeued)
+	if err != nil {
+		return fmt.Errorf("failed to count previous reconciliations: %w", err)
+	}
+	if requeues >= rc.maxRequeues {
+		return rc.fail(ctx, job, fmt.Sprintf("%d queue job(s) were lost after being re-enqueued %d time(s)", lost, requeues))
+	}
+
+	for _, plannedJob := range planned {
+		args := plannedJob.Args
+		args.PlannedQueueJobID = plannedJob.ID
+		if err := rc.enqueuer.AddJob(args, plannedJob.Priority); err != nil {
+			return fmt.Errorf("failed to re-enqueue planned queue job %d: %w", plannedJob.ID, err)
+		}
+	}
+
+	return rc.record(ctx, job.ID, models.ReconciliationRequeued, fmt.Sprintf("re-enqueued %d lost queue job(s)", lost))
+}
+
+// fail marks the job as failed, recording the reason the job could not be recovered
+func (rc *reconciler) fail(ctx context.Context, job *models.Job, reason string) error {
+	err := rc.r.UpdateJobStatusCheckStatus(ctx, job.ID, job.Status, models.JobStatusFailed)
+	if goerrors.Is(err, repository.ErrJobNotUpdated) {
+		// The job was updated after it was found to be stuck
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := rc.r.DeletePlannedQueueJobs(ctx, job.ID); err != nil {
+		rc.log.Warnf("Failed to delete planned queue jobs for failed job %d. Will continue. %s", job.ID, err.Error())
+	}
+	return rc.record(ctx, job.ID, models.ReconciliationFailed, reason)
+}
+
+func (rc *reconciler) record(ctx context.Context, jobID uint, action models.ReconciliationAction, reason string) error {
+	rc.log.Warnf("Reconciled stuck job %d (%s): %s", jobID, action, reason)
+	return rc.r.CreateJobReconciliation(ctx, models.JobReconciliation{JobID: jobID, Action: action, Reason: reason})
+}
+
+// run reconciles the stuck jobs every interval until the queue is stopped
+func (rc *reconciler) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rc.reconcile(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
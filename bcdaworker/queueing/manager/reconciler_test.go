@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CMSgov/bcda-app/bcda/models"
+	"github.com/CMSgov/bcda-app/bcdaworker/queueing"
+	"github.com/CMSgov/bcda-app/bcdaworker/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReconcileJob(t *testing.T) {
+	const jobID = uint(1234)
+	planned := []*models.PlannedQueueJob{
+		{ID: 1, JobID: jobID, Args: models.JobEnqueueArgs{ID: int(jobID), ResourceType: "Patient"}, Priority: 20},
+		{ID: 2, JobID: jobID, Args: models.JobEnqueueArgs{ID: int(jobID), ResourceType: "Coverage"}, Priority: 21},
+	}
+
+	tests := []struct {
+		name       string
+		jobCount   int
+		live       int
+		completed  int
+		planned    []*models.PlannedQueueJob
+		requeues   int
+		expAction  models.ReconciliationAction
+		expReason  string
+		expRequeue bool
+	}{
+		{"Queue jobs still queued", 4, 1, 2, planned, 0, "", "", false},
+		{"Never planned", 0, 0, 0, nil, 0, models.ReconciliationFailed,
+			"the request was interrupted before all of its queue jobs were enqueued", false},
+		{"All queue jobs completed", 4, 0, 4, nil, 0, models.ReconciliationCompleted,
+			"all 4 queue job(s) were completed but the job was not marked as completed", false},
+		{"Lost queue jobs", 4, 0, 2, planned, 0, models.ReconciliationRequeued, "re-enqueued 2 lost queue job(s)", true},
+		{"Missing planned queue jobs", 4, 0, 1, planned, 0, models.ReconciliationFailed,
+			"3 queue job(s) were lost but 2 planned queue job(s) were found", false},
+		{"Requeues exhausted", 4, 0, 2, planned, 3, models.ReconciliationFailed,
+			"2 queue job(s) were lost after being re-enqueued 3 time(s)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			job := &models.Job{ID: jobID, Status: models.JobStatusInProgress, JobCount: tt.jobCount,
+				UpdatedAt: time.Now().Add(-time.Hour)}
+
+			repo := &repository.MockRepository{}
+			repo.On("ClaimJob", ctx, jobID, job.UpdatedAt).Return(nil)
+			repo.On("GetJobKeyCount", ctx, jobID).Return(tt.completed, nil)
+			repo.On("GetPlannedQueueJobs", ctx, jobID).Return(tt.planned, nil)
+			repo.On("GetJobReconciliationCount", ctx, jobID, models.ReconciliationRequeued).Return(tt.requeues, nil)
+			repo.On("UpdateJobStatusCheckStatus", ctx, jobID, models.JobStatusInProgress, mock.Anything).Return(nil)
+			repo.On("DeletePlannedQueueJobs", ctx, jobID).Return(nil)
+			repo.On("CreateJobReconciliation", ctx, mock.Anything).Return(nil)
+
+			enqueuer := &queueing.MockEnqueuer{}
+			for _, plannedJob := range planned {
+				args := plannedJob.Args
+				args.PlannedQueueJobID = plannedJob.ID
+				enqueuer.On("AddJob", args, plannedJob.Priority).Return(nil)
+			}
+
+			var completedJob bool
+			rc := &reconciler{r: repo, enqueuer: enqueuer, log: log, maxRequeues: 3,
+				liveQueueJobs: func(id uint) (int, error) {
+					assert.Equal(t, jobID, id)
+					return tt.live, nil
+				},
+				completeJob: func(ctx context.Context, r repository.Repository, id uint) (bool, error) {
+					completedJob = true
+					return true, nil
+				},
+			}
+
+			assert.NoError(t, rc.reconcileJob(ctx, job))
+			if tt.expAction != "" {
+				repo.AssertCalled(t, "CreateJobReconciliation", ctx,
+					models.JobReconciliation{JobID: jobID, Action: tt.expAction, Reason: tt.expReason})
+			} else {
+				repo.AssertNotCalled(t, "CreateJobReconciliation", ctx, mock.Anything)
+			}
+			assert.Equal(t, tt.expAction == models.ReconciliationCompleted, completedJob)
+			if tt.expRequeue {
+				enqueuer.AssertNumberOfCalls(t, "AddJob", len(planned))
+			} else {
+				enqueuer.AssertNotCalled(t, "AddJob", mock.Anything, mock.Anything)
+			}
+			if tt.live == 0 {
+				repo.AssertCalled(t, "ClaimJob", ctx, jobID, job.UpdatedAt)
+			} else {
+				repo.AssertNotCalled(t, "ClaimJob", ctx, jobID, job.UpdatedAt)
+			}
+			if tt.expAction == models.ReconciliationFailed {
+				repo.AssertCalled(t, "UpdateJobStatusCheckStatus", ctx, jobID, models.JobStatusInProgress, models.JobStatusFailed)
+				repo.AssertCalled(t, "DeletePlannedQueueJobs", ctx, jobID)
+			} else {
+				repo.AssertNotCalled(t, "UpdateJobStatusCheckStatus", ctx, jobID, models.JobStatusInProgress, models.JobStatusFailed)
+			}
+		})
+	}
+}
+
+// TestReconcileJobPlanning verifies that a job whose queue jobs may still be planned is not failed
+func TestReconcileJobPlanning(t *testing.T) {
+	ctx := context.Background()
+	job := &models.Job{ID: 1, Status: models.JobStatusPending, CreatedAt: time.Now().Add(-2 * time.Hour),
+		UpdatedAt: time.Now().Add(-2 * time.Hour)}
+
+	repo := &repository.MockRepository{}
+	rc := &reconciler{r: repo, log: log, planningGracePeriod: 4 * time.Hour,
+		liveQueueJobs: func(uint) (int, error) { return 0, nil }}
+	assert.NoError(t, rc.reconcileJob(ctx, job))
+	repo.AssertNotCalled(t, "ClaimJob", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "UpdateJobStatusCheckStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// Once the grace period has passed, the request is considered interrupted
+	job.CreatedAt = time.Now().Add(-5 * time.Hour)
+	repo.On("ClaimJob", ctx, job.ID, job.UpdatedAt).Return(nil)
+	repo.On("UpdateJobStatusCheckStatus", ctx, job.ID, models.JobStatusPending, models.JobStatusFailed).Return(nil)
+	repo.On("DeletePlannedQueueJobs", ctx, job.ID).Return(nil)
+	repo.On("CreateJobReconciliation", ctx, models.JobReconciliation{JobID: job.ID, Action: models.ReconciliationFailed,
+		Reason: "the request was interrupted before all of its queue jobs were enqueued"}).Return(nil)
+	assert.NoError(t, rc.reconcileJob(ctx, job))
+	repo.AssertExpectations(t)
+}
+
+// TestReconcileJobUpdated verifies that a job updated after it was found to be stuck is not failed
+func TestReconcileJobUpdated(t *testing.T) {
+	ctx := context.Background()
+	job := &models.Job{ID: 1, Status: models.JobStatusPending}
+
+	repo := &repository.MockRepository{}
+	repo.On("ClaimJob", ctx, job.ID, job.UpdatedAt).Return(nil)
+	repo.On("UpdateJobStatusCheckStatus", ctx, job.ID, models.JobStatusPending, models.JobStatusFailed).
+		Return(repository.ErrJobNotUpdated)
+
+	rc := &reconciler{r: repo, log: log, liveQueueJobs: func(uint) (int, error) { return 0, nil }}
+	assert.NoError(t, rc.reconcileJob(ctx, job))
+	repo.AssertNotCalled(t, "CreateJobReconciliation", mock.Anything, mock.Anything)
+}
+
+// TestReconcileJobClaimed verifies that a job claimed by another worker (or updated) after it was found to be stuck
+// is not reconciled again
+func TestReconcileJobClaimed(t *testing.T) {
+	ctx := context.Background()
+	job := &models.Job{ID: 1, Status: models.JobStatusInProgress, JobCount: 2, UpdatedAt: time.Now().Add(-time.Hour)}
+
+	repo := &repository.MockRepository{}
+	repo.On("ClaimJob", ctx, job.ID, job.UpdatedAt).Return(repository.ErrJobNotUpdated)
+	enqueuer := &queueing.MockEnqueuer{}
+
+	rc := &reconciler{r: repo, enqueuer: enqueuer, log: log, liveQueueJobs: func(uint) (int, error) { return 0, nil }}
+	assert.NoError(t, rc.reconcileJob(ctx, job))
+	// Any other call to the repository or enqueuer would fail since no calls are expected
+	repo.AssertExpectations(t)
+	enqueuer.AssertExpectations(t)
+}
+
+// TestReconcileJobDeadLettered verifies that a job waiting on its dead-lettered queue jobs to be replayed or discarded is left alone
+func TestReconcileJobDeadLettered(t *testing.T) {
+	repo := &repository.MockRepository{}
+	rc := &reconciler{r: repo, log: log, liveQueueJobs: func(uint) (int, error) { return 0, nil }}
+	assert.NoError(t, rc.reconcileJob(context.Background(),
+		&models.Job{ID: 1, Status: models.JobStatusInProgress, JobCount: 2, DeadLetterCount: 1}))
+	// Any call to the repository would fail since no calls are expected
+	repo.AssertExpectations(t)
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	jobs := []*models.Job{{ID: 1, Status: models.JobStatusPending, JobCount: 1}, {ID: 2, Status: models.JobStatusInProgress, JobCount: 1}}
+
+	repo := &repository.MockRepository{}
+	repo.On("GetStaleJobs", ctx, mock.MatchedBy(func(updatedBefore time.Time) bool {
+		return time.Since(updatedBefore) >= time.Hour
+	}), models.JobStatusPending, models.JobStatusInProgress).Return(jobs, nil)
+
+	var checked []uint
+	rc := &reconciler{r: repo, log: log, threshold: time.Hour,
+		liveQueueJobs: func(jobID uint) (int, error) {
+			checked = append(checked, jobID)
+			// Failing to reconcile one job should not prevent the others from being reconciled
+			if jobID == 1 {
+				return 0, errors.New("connection refused")
+			}
+			return 1, nil
+		},
+	}
+	rc.reconcile(ctx)
+	assert.Equal(t, []uint{1, 2}, checked)
+	assert.Contains(t, logHook.LastEntry().Message, "Failed to reconcile job 1: failed to count queue jobs: connection refused")
+}
@@ -39,3 +39,17 @@ func (_m *MockEnqueuer) AddJob(job models.JobEnqueueArgs, priority int) error {
 
 	return r0
 }
+
+// AddJobs provides a mock function with given fields: jobs, priority
+func (_m *MockEnqueuer) AddJobs(jobs []*models.JobEnqueueArgs, priority int) error {
+	ret := _m.Called(jobs, priority)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]*models.JobEnqueueArgs, int) error); ok {
+		r0 = rf(jobs, priority)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
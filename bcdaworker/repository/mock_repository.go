@@ -8,6 +8,8 @@ import (
 	models "github.com/CMSgov/bcda-app/bcda/models"
 	mock "github.com/stretchr/testify/mock"
 
+	time "time"
+
 	uuid "github.com/pborman/uuid"
 )
 
@@ -16,6 +18,34 @@ type MockRepository struct {
 	mock.Mock
 }
 
+// ClaimJob provides a mock function with given fields: ctx, jobID, updatedAt
+func (_m *MockRepository) ClaimJob(ctx context.Context, jobID uint, updatedAt time.Time) error {
+	ret := _m.Called(ctx, jobID, updatedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint, time.Time) error); ok {
+		r0 = rf(ctx, jobID, updatedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateDeadLetterQueueJob provides a mock function with given fields: ctx, deadLetter
+func (_m *MockRepository) CreateDeadLetterQueueJob(ctx context.Context, deadLetter models.DeadLetterQueueJob) error {
+	ret := _m.Called(ctx, deadLetter)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.DeadLetterQueueJob) error); ok {
+		r0 = rf(ctx, deadLetter)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateJobKey provides a mock function with given fields: ctx, jobKey
 func (_m *MockRepository) CreateJobKey(ctx context.Context, jobKey models.JobKey) error {
 	ret := _m.Called(ctx, jobKey)
@@ -30,6 +60,48 @@ func (_m *MockRepository) CreateJobKey(ctx context.Context, jobKey models.JobKey
 	return r0
 }
 
+// CreateJobReconciliation provides a mock function with given fields: ctx, reconciliation
+func (_m *MockRepository) CreateJobReconciliation(ctx context.Context, reconciliation models.JobReconciliation) error {
+	ret := _m.Called(ctx, reconciliation)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.JobReconciliation) error); ok {
+		r0 = rf(ctx, reconciliation)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeletePlannedQueueJob provides a mock function with given fields: ctx, id
+func (_m *MockRepository) DeletePlannedQueueJob(ctx context.Context, id uint) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeletePlannedQueueJobs provides a mock function with given fields: ctx, jobID
+func (_m *MockRepository) DeletePlannedQueueJobs(ctx context.Context, jobID uint) error {
+	ret := _m.Called(ctx, jobID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint) error); ok {
+		r0 = rf(ctx, jobID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetACOByUUID provides a mock function with given fields: ctx, _a1
 func (_m *MockRepository) GetACOByUUID(ctx context.Context, _a1 uuid.UUID) (*models.ACO, error) {
 	ret := _m.Called(ctx, _a1)
@@ -120,6 +192,80 @@ func (_m *MockRepository) GetJobKeyCount(ctx context.Context, jobID uint) (int,
 	return r0, r1
 }
 
+// GetJobReconciliationCount provides a mock function with given fields: ctx, jobID, action
+func (_m *MockRepository) GetJobReconciliationCount(ctx context.Context, jobID uint, action models.ReconciliationAction) (int, error) {
+	ret := _m.Called(ctx, jobID, action)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, uint, models.ReconciliationAction) int); ok {
+		r0 = rf(ctx, jobID, action)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint, models.ReconciliationAction) error); ok {
+		r1 = rf(ctx, jobID, action)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPlannedQueueJobs provides a mock function with given fields: ctx, jobID
+func (_m *MockRepository) GetPlannedQueueJobs(ctx context.Context, jobID uint) ([]*models.PlannedQueueJob, error) {
+	ret := _m.Called(ctx, jobID)
+
+	var r0 []*models.PlannedQueueJob
+	if rf, ok := ret.Get(0).(func(context.Context, uint) []*models.PlannedQueueJob); ok {
+		r0 = rf(ctx, jobID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.PlannedQueueJob)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint) error); ok {
+		r1 = rf(ctx, jobID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetStaleJobs provides a mock function with given fields: ctx, updatedBefore, statuses
+func (_m *MockRepository) GetStaleJobs(ctx context.Context, updatedBefore time.Time, statuses ...models.JobStatus) ([]*models.Job, error) {
+	_va := make([]interface{}, len(statuses))
+	for _i := range statuses {
+		_va[_i] = statuses[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, updatedBefore)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 []*models.Job
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, ...models.JobStatus) []*models.Job); ok {
+		r0 = rf(ctx, updatedBefore, statuses...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Job)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, ...models.JobStatus) error); ok {
+		r1 = rf(ctx, updatedBefore, statuses...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IncrementCompletedJobCount provides a mock function with given fields: ctx, jobID
 func (_m *MockRepository) IncrementCompletedJobCount(ctx context.Context, jobID uint) error {
 	ret := _m.Called(ctx, jobID)
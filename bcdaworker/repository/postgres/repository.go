@@ -3,8 +3,10 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/CMSgov/bcda-app/bcdaworker/repository"
@@ -21,6 +23,11 @@ type executable interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 const (
 	sqlFlavor = sqlbuilder.PostgreSQL
 )
@@ -83,28 +90,71 @@ func (r *Repository) GetCCLFBeneficiaryByID(ctx context.Context, id uint) (*mode
 	return &bene, nil
 }
 
+var jobColumns = []string{"id", "aco_id", "request_url", "status", "transaction_time", "job_count", "completed_job_count", "dead_letter_count", "created_at", "updated_at"}
+
 func (r *Repository) GetJobByID(ctx context.Context, jobID uint) (*models.Job, error) {
 	sb := sqlFlavor.NewSelectBuilder()
-	sb.Select("id", "aco_id", "request_url", "status", "transaction_time", "job_count", "completed_job_count", "created_at", "updated_at")
+	sb.Select(jobColumns...)
 	sb.From("jobs").Where(sb.Equal("id", jobID))
 
 	query, args := sb.Build()
 
+	j, err := scanJob(r.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (r *Repository) GetStaleJobs(ctx context.Context, updatedBefore time.Time, statuses ...models.JobStatus) ([]*models.Job, error) {
+	sb := sqlFlavor.NewSelectBuilder()
+	sb.Select(jobColumns...)
+	sb.From("jobs").Where(sb.LessThan("updated_at", updatedBefore))
+	if len(statuses) > 0 {
+		s := make([]interface{}, len(statuses))
+		for i, status := range statuses {
+			s[i] = status
+		}
+		sb.Where(sb.In("status", s...))
+	}
+	sb.OrderBy("id")
+
+	query, args := sb.Build()
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// scanJob scans the jobColumns into a job
+func scanJob(row scanner) (*models.Job, error) {
 	var (
 		j                                     models.Job
 		transactionTime, createdAt, updatedAt sql.NullTime
 	)
 
-	err := r.QueryRowContext(ctx, query, args...).Scan(&j.ID, &j.ACOID, &j.RequestURL, &j.Status, &transactionTime,
-		&j.JobCount, &j.CompletedJobCount, &createdAt, &updatedAt)
-	j.TransactionTime, j.CreatedAt, j.UpdatedAt = transactionTime.Time, createdAt.Time, updatedAt.Time
-
+	err := row.Scan(&j.ID, &j.ACOID, &j.RequestURL, &j.Status, &transactionTime,
+		&j.JobCount, &j.CompletedJobCount, &j.DeadLetterCount, &createdAt, &updatedAt)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, repository.ErrJobNotFound
-		}
 		return nil, err
 	}
+	j.TransactionTime, j.CreatedAt, j.UpdatedAt = transactionTime.Time, createdAt.Time, updatedAt.Time
 
 	return &j, nil
 }
@@ -121,6 +171,12 @@ func (r *Repository) UpdateJobStatusCheckStatus(ctx context.Context, jobID uint,
 		map[string]interface{}{"status": new})
 }
 
+func (r *Repository) ClaimJob(ctx context.Context, jobID uint, updatedAt time.Time) error {
+	return r.updateJob(ctx,
+		map[string]interface{}{"id": jobID, "updated_at": updatedAt},
+		map[string]interface{}{})
+}
+
 func (r *Repository) IncrementCompletedJobCount(ctx context.Context, jobID uint) error {
 	ub := sqlFlavor.NewUpdateBuilder().Update("jobs")
 	ub.Set(ub.Incr("completed_job_count"), ub.Assign("updated_at", sqlbuilder.Raw("NOW()"))).
@@ -143,9 +199,14 @@ func (r *Repository) IncrementCompletedJobCount(ctx context.Context, jobID uint)
 }
 
 func (r *Repository) CreateJobKey(ctx context.Context, jobKey models.JobKey) error {
+	var plannedQueueJobID sql.NullInt64
+	if jobKey.PlannedQueueJobID != 0 {
+		plannedQueueJobID = sql.NullInt64{Int64: int64(jobKey.PlannedQueueJobID), Valid: true}
+	}
+
 	ib := sqlFlavor.NewInsertBuilder().InsertInto("job_keys")
-	ib.Cols("job_id", "file_name", "resource_type").
-		Values(jobKey.JobID, jobKey.FileName, jobKey.ResourceType)
+	ib.Cols("job_id", "file_name", "resource_type", "planned_queue_job_id").
+		Values(jobKey.JobID, jobKey.FileName, jobKey.ResourceType, plannedQueueJobID)
 
 	query, args := ib.Build()
 	_, err := r.ExecContext(ctx, query, args...)
@@ -164,6 +225,103 @@ func (r *Repository) GetJobKeyCount(ctx context.Context, jobID uint) (int, error
 	return count, nil
 }
 
+func (r *Repository) GetPlannedQueueJobs(ctx context.Context, jobID uint) ([]*models.PlannedQueueJob, error) {
+	// Planned queue jobs that were completed can remain when the worker failed to delete them
+	completed := sqlFlavor.NewSelectBuilder().Select("planned_queue_job_id").From("job_keys")
+	completed.Where(completed.Equal("job_id", jobID), completed.IsNotNull("planned_queue_job_id"))
+
+	sb := sqlFlavor.NewSelectBuilder().Select("id", "job_id", "args", "priority").From("planned_queue_jobs")
+	sb.Where(sb.Equal("job_id", jobID), sb.NotIn("id", completed)).OrderBy("id")
+
+	query, args := sb.Build()
+	rows, err := r.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plannedJobs []*models.PlannedQueueJob
+	for rows.Next() {
+		var (
+			plannedJob models.PlannedQueueJob
+			jobArgs    []byte
+		)
+		if err := rows.Scan(&plannedJob.ID, &plannedJob.JobID, &jobArgs, &plannedJob.Priority); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(jobArgs, &plannedJob.Args); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal args of planned queue job %d: %w", plannedJob.ID, err)
+		}
+		plannedJobs = append(plannedJobs, &plannedJob)
+	}
+
+	return plannedJobs, rows.Err()
+}
+
+func (r *Repository) DeletePlannedQueueJob(ctx context.Context, id uint) error {
+	db := sqlFlavor.NewDeleteBuilder().DeleteFrom("planned_queue_jobs")
+	db.Where(db.Equal("id", id))
+
+	query, args := db.Build()
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) DeletePlannedQueueJobs(ctx context.Context, jobID uint) error {
+	db := sqlFlavor.NewDeleteBuilder().DeleteFrom("planned_queue_jobs")
+	db.Where(db.Equal("job_id", jobID))
+
+	query, args := db.Build()
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) CreateJobReconciliation(ctx context.Context, reconciliation models.JobReconciliation) error {
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("job_reconciliations")
+	ib.Cols("job_id", "action", "reason").
+		Values(reconciliation.JobID, reconciliation.Action, reconciliation.Reason)
+
+	query, args := ib.Build()
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *Repository) GetJobReconciliationCount(ctx context.Context, jobID uint, action models.ReconciliationAction) (int, error) {
+	sb := sqlFlavor.NewSelectBuilder().Select("COUNT(1)").From("job_reconciliations")
+	sb.Where(sb.Equal("job_id", jobID), sb.Equal("action", action))
+
+	query, args := sb.Build()
+	var count int
+	if err := r.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+// CreateDeadLetterQueueJob records the dead-lettered queue job, flagging its job by updating the job's dead letter count.
+func (r *Repository) CreateDeadLetterQueueJob(ctx context.Context, deadLetter models.DeadLetterQueueJob) error {
+	var jobID sql.NullInt64
+	if deadLetter.JobID != 0 {
+		jobID = sql.NullInt64{Int64: int64(deadLetter.JobID), Valid: true}
+	}
+	var lastError sql.NullString
+	if deadLetter.LastError != "" {
+		lastError = sql.NullString{String: deadLetter.LastError, Valid: true}
+	}
+
+	ib := sqlFlavor.NewInsertBuilder().InsertInto("dead_letter_queue_jobs")
+	ib.Cols("job_id", "que_job_id", "args", "priority", "error_count", "last_error", "reason").
+		Values(jobID, deadLetter.QueJobID, deadLetter.Args, deadLetter.Priority, deadLetter.ErrorCount, lastError, deadLetter.Reason)
+	query, args := ib.Build()
+	// Update the job in the same statement so its dead letter count always matches its dead-lettered queue jobs
+	query = fmt.Sprintf(`WITH created AS (%s RETURNING job_id)
+		UPDATE jobs SET dead_letter_count = dead_letter_count + 1, updated_at = NOW()
+		WHERE id IN (SELECT job_id FROM created)`, query)
+
+	_, err := r.ExecContext(ctx, query, args...)
+	return err
+}
+
 func (r *Repository) updateJob(ctx context.Context, clauses map[string]interface{}, fieldAndValues map[string]interface{}) error {
 	ub := sqlFlavor.NewUpdateBuilder().Update("jobs")
 	ub.Set(ub.Assign("updated_at", sqlbuilder.Raw("NOW()")))
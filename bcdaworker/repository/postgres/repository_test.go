@@ -9,6 +9,7 @@ import (
 
 	"github.com/CMSgov/bcda-app/bcda/database"
 	"github.com/CMSgov/bcda-app/bcda/models"
+	apipostgres "github.com/CMSgov/bcda-app/bcda/models/postgres"
 	"github.com/CMSgov/bcda-app/bcda/models/postgres/postgrestest"
 	"github.com/CMSgov/bcda-app/bcda/testUtils"
 	"github.com/CMSgov/bcda-app/bcdaworker/repository/postgres"
@@ -122,11 +123,34 @@ func (r *RepositoryTestSuite) TestJobsMethods() {
 	assert.True(afterUpdate.UpdatedAt.After(failed.UpdatedAt))
 	assert.Equal(afterUpdate.CompletedJobCount, failed.CompletedJobCount+1)
 
+	// Only the first claim made with the job's last update time succeeds
+	assert.NoError(r.repository.ClaimJob(ctx, failed.ID, afterUpdate.UpdatedAt))
+	assert.EqualError(r.repository.ClaimJob(ctx, failed.ID, afterUpdate.UpdatedAt), "job was not updated, no match found")
+	claimed, err := r.repository.GetJobByID(ctx, failed.ID)
+	assert.NoError(err)
+	assert.True(claimed.UpdatedAt.After(afterUpdate.UpdatedAt))
+
 	// After all of these updates, the completed job should remain untouched
 	completed1, err := r.repository.GetJobByID(ctx, completed.ID)
 	assert.NoError(err)
 	assertJobsEqual(assert, completed, *completed1)
 
+	stale, err := r.repository.GetStaleJobs(ctx, time.Now().Add(time.Hour), models.JobStatusCompleted)
+	assert.NoError(err)
+	var foundCompleted bool
+	for _, job := range stale {
+		assert.Equal(models.JobStatusCompleted, job.Status)
+		assert.NotEqual(failed.ID, job.ID)
+		foundCompleted = foundCompleted || job.ID == completed.ID
+	}
+	assert.True(foundCompleted)
+
+	stale, err = r.repository.GetStaleJobs(ctx, completed1.UpdatedAt.Add(-time.Hour), models.JobStatusCompleted)
+	assert.NoError(err)
+	for _, job := range stale {
+		assert.NotEqual(completed.ID, job.ID)
+	}
+
 	// Negative cases
 	_, err = r.repository.GetJobByID(ctx, 0)
 	assert.EqualError(err, "no job found for given id")
@@ -177,6 +201,146 @@ func (r *RepositoryTestSuite) TestJobKeyMethods() {
 	assert.Equal(0, count)
 }
 
+// TestPlannedQueueJobMethods validates the CRUD operations associated with the planned_queue_jobs table
+func (r *RepositoryTestSuite) TestPlannedQueueJobMethods() {
+	assert := r.Assert()
+	ctx := context.Background()
+
+	jobID, otherJobID := uint(rand.Int31()), uint(rand.Int31())
+	defer func() {
+		assert.NoError(r.repository.DeletePlannedQueueJobs(ctx, otherJobID))
+	}()
+
+	jobs := []*models.JobEnqueueArgs{{ID: int(jobID), ResourceType: "Patient", BeneficiaryIDs: []string{"1", "2"}},
+		{ID: int(jobID), ResourceType: "Coverage", BeneficiaryIDs: []string{"3"}}}
+	other := []*models.JobEnqueueArgs{{ID: int(otherJobID), ResourceType: "Patient"}}
+	apiRepository := apipostgres.NewRepository(r.db)
+	assert.NoError(apiRepository.CreatePlannedQueueJobs(ctx, jobID, jobs, 20))
+	assert.NoError(apiRepository.CreatePlannedQueueJobs(ctx, otherJobID, other, 100))
+
+	planned, err := r.repository.GetPlannedQueueJobs(ctx, jobID)
+	assert.NoError(err)
+	assert.Len(planned, 2)
+	for i, plannedJob := range planned {
+		assert.NotZero(plannedJob.ID)
+		assert.Equal(jobs[i].PlannedQueueJobID, plannedJob.ID)
+		assert.Equal(jobID, plannedJob.JobID)
+		assert.Equal(20, plannedJob.Priority)
+		assert.Equal(*jobs[i], plannedJob.Args)
+	}
+
+	assert.NoError(r.repository.DeletePlannedQueueJob(ctx, planned[0].ID))
+	planned, err = r.repository.GetPlannedQueueJobs(ctx, jobID)
+	assert.NoError(err)
+	assert.Len(planned, 1)
+	assert.Equal("Coverage", planned[0].Args.ResourceType)
+
+	// A planned queue job whose job key exists was completed, even if it was not deleted
+	defer postgrestest.DeleteJobKeysByJobIDs(r.T(), r.db, jobID)
+	assert.NoError(r.repository.CreateJobKey(ctx, models.JobKey{JobID: jobID, PlannedQueueJobID: planned[0].ID}))
+	assert.NoError(r.repository.CreateJobKey(ctx, models.JobKey{JobID: jobID}))
+	planned, err = r.repository.GetPlannedQueueJobs(ctx, jobID)
+	assert.NoError(err)
+	assert.Empty(planned)
+
+	assert.NoError(r.repository.DeletePlannedQueueJobs(ctx, jobID))
+	planned, err = r.repository.GetPlannedQueueJobs(ctx, jobID)
+	assert.NoError(err)
+	assert.Empty(planned)
+
+	// Planned queue jobs for other jobs should remain untouched
+	planned, err = r.repository.GetPlannedQueueJobs(ctx, otherJobID)
+	assert.NoError(err)
+	assert.Len(planned, 1)
+}
+
+// TestJobReconciliationMethods validates the CRUD operations associated with the job_reconciliations table
+func (r *RepositoryTestSuite) TestJobReconciliationMethods() {
+	assert := r.Assert()
+	ctx := context.Background()
+
+	jobID := uint(rand.Int31())
+	defer func() {
+		_, err := r.db.Exec("DELETE FROM job_reconciliations WHERE job_id = $1", jobID)
+		assert.NoError(err)
+	}()
+
+	assert.NoError(r.repository.CreateJobReconciliation(ctx,
+		models.JobReconciliation{JobID: jobID, Action: models.ReconciliationRequeued, Reason: "re-enqueued 1 lost queue job(s)"}))
+	assert.NoError(r.repository.CreateJobReconciliation(ctx,
+		models.JobReconciliation{JobID: jobID, Action: models.ReconciliationRequeued, Reason: "re-enqueued 2 lost queue job(s)"}))
+	assert.NoError(r.repository.CreateJobReconciliation(ctx,
+		models.JobReconciliation{JobID: jobID, Action: models.ReconciliationFailed, Reason: "failed"}))
+
+	count, err := r.repository.GetJobReconciliationCount(ctx, jobID, models.ReconciliationRequeued)
+	assert.NoError(err)
+	assert.Equal(2, count)
+
+	count, err = r.repository.GetJobReconciliationCount(ctx, jobID, models.ReconciliationCompleted)
+	assert.NoError(err)
+	assert.Equal(0, count)
+}
+
+// TestDeadLetterQueueJobMethods validates the CRUD operations associated with the dead_letter_queue_jobs table
+func (r *RepositoryTestSuite) TestDeadLetterQueueJobMethods() {
+	assert := r.Assert()
+	ctx := context.Background()
+
+	cmsID := testUtils.RandomHexID()[0:4]
+	aco := models.ACO{UUID: uuid.NewRandom(), Name: uuid.New(), CMSID: &cmsID}
+	postgrestest.CreateACO(r.T(), r.db, aco)
+	defer postgrestest.DeleteACO(r.T(), r.db, aco.UUID)
+
+	job := models.Job{ACOID: aco.UUID, Status: models.JobStatusInProgress, JobCount: 2}
+	postgrestest.CreateJobs(r.T(), r.db, &job)
+	defer postgrestest.DeleteJobsByACOID(r.T(), r.db, aco.UUID)
+
+	apiRepository := apipostgres.NewRepository(r.db)
+	deadLetter := models.DeadLetterQueueJob{JobID: job.ID, QueJobID: rand.Int63(), Args: []byte(`{"ID": 1}`),
+		Priority: 20, ErrorCount: 3, LastError: "could not retrieve job from database", Reason: "job not found after 4 attempt(s)"}
+	// Dead-lettered queue jobs whose args could not be read do not have a job
+	unknown := models.DeadLetterQueueJob{QueJobID: rand.Int63(), Args: []byte(`"{invalid_json"`), Reason: "failed to deserialize args"}
+	assert.NoError(r.repository.CreateDeadLetterQueueJob(ctx, deadLetter))
+	assert.NoError(r.repository.CreateDeadLetterQueueJob(ctx, unknown))
+
+	afterUpdate, err := r.repository.GetJobByID(ctx, job.ID)
+	assert.NoError(err)
+	assert.Equal(1, afterUpdate.DeadLetterCount)
+
+	deadLetters, err := apiRepository.GetDeadLetterQueueJobs(ctx, job.ID)
+	assert.NoError(err)
+	assert.Len(deadLetters, 1)
+	assert.NotZero(deadLetters[0].ID)
+	assert.False(deadLetters[0].CreatedAt.IsZero())
+	deadLetter.ID, deadLetter.CreatedAt = deadLetters[0].ID, deadLetters[0].CreatedAt
+	assert.Equal(deadLetter, *deadLetters[0])
+
+	all, err := apiRepository.GetDeadLetterQueueJobs(ctx, 0)
+	assert.NoError(err)
+	var unknownID uint
+	for _, dl := range all {
+		if dl.QueJobID == unknown.QueJobID {
+			unknownID = dl.ID
+			assert.Zero(dl.JobID)
+			assert.Empty(dl.LastError)
+		}
+	}
+	assert.NotZero(unknownID)
+
+	found, err := apiRepository.GetDeadLetterQueueJob(ctx, deadLetter.ID)
+	assert.NoError(err)
+	assert.Equal(deadLetter, *found)
+
+	assert.NoError(apiRepository.DeleteDeadLetterQueueJob(ctx, deadLetter.ID))
+	assert.NoError(apiRepository.DeleteDeadLetterQueueJob(ctx, unknownID))
+	afterUpdate, err = r.repository.GetJobByID(ctx, job.ID)
+	assert.NoError(err)
+	assert.Equal(0, afterUpdate.DeadLetterCount)
+
+	_, err = apiRepository.GetDeadLetterQueueJob(ctx, deadLetter.ID)
+	assert.Error(err)
+}
+
 func assertJobsEqual(assert *assert.Assertions, expected, actual models.Job) {
 	expected.TransactionTime, actual.TransactionTime = expected.TransactionTime.UTC(), actual.TransactionTime.UTC()
 	assert.Equal(expected, actual)
@@ -4,6 +4,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/CMSgov/bcda-app/bcda/models"
 	"github.com/pborman/uuid"
@@ -14,6 +15,9 @@ type Repository interface {
 	cclfBeneficiaryRepository
 	jobRepository
 	jobKeyRepository
+	plannedQueueJobRepository
+	jobReconciliationRepository
+	deadLetterQueueJobRepository
 }
 
 type acoRepository interface {
@@ -33,6 +37,13 @@ type jobRepository interface {
 	UpdateJobStatusCheckStatus(ctx context.Context, jobID uint, current, new models.JobStatus) error
 
 	IncrementCompletedJobCount(ctx context.Context, jobID uint) error
+
+	// ClaimJob touches the job iff it has not been updated since updatedAt, returning ErrJobNotUpdated otherwise.
+	// It ensures that only one worker acts on a job found to be stale.
+	ClaimJob(ctx context.Context, jobID uint, updatedAt time.Time) error
+
+	// GetStaleJobs returns the jobs with one of the statuses that have not been updated since updatedBefore.
+	GetStaleJobs(ctx context.Context, updatedBefore time.Time, statuses ...models.JobStatus) ([]*models.Job, error)
 }
 
 type jobKeyRepository interface {
@@ -41,6 +52,28 @@ type jobKeyRepository interface {
 	GetJobKeyCount(ctx context.Context, jobID uint) (int, error)
 }
 
+type plannedQueueJobRepository interface {
+	// GetPlannedQueueJobs returns the planned queue jobs of the job that have not been completed.
+	GetPlannedQueueJobs(ctx context.Context, jobID uint) ([]*models.PlannedQueueJob, error)
+
+	DeletePlannedQueueJob(ctx context.Context, id uint) error
+
+	// DeletePlannedQueueJobs deletes all of the planned queue jobs of the job.
+	DeletePlannedQueueJobs(ctx context.Context, jobID uint) error
+}
+
+type deadLetterQueueJobRepository interface {
+	// CreateDeadLetterQueueJob records the dead-lettered queue job, flagging its job by updating the job's dead letter count.
+	CreateDeadLetterQueueJob(ctx context.Context, deadLetter models.DeadLetterQueueJob) error
+}
+
+type jobReconciliationRepository interface {
+	CreateJobReconciliation(ctx context.Context, reconciliation models.JobReconciliation) error
+
+	// GetJobReconciliationCount returns the number of times the action was taken for the job.
+	GetJobReconciliationCount(ctx context.Context, jobID uint, action models.ReconciliationAction) (int, error)
+}
+
 var (
 	ErrJobNotUpdated = errors.New("job was not updated, no match found")
 	ErrJobNotFound   = errors.New("no job found for given id")
@@ -34,10 +34,22 @@ type Worker interface {
 
 type worker struct {
 	r repository.Repository
+	// Determines the FHIR server each resource type is retrieved from
+	sources *client.Sources
 }
 
 func NewWorker(db *sql.DB) Worker {
-	return &worker{postgres.NewRepository(db)}
+	cfg, err := client.LoadSourceConfig()
+	if err != nil {
+		log.Fatalf("Failed to load FHIR source config. Err: %v", err)
+	}
+
+	sources, err := client.NewSources(*cfg)
+	if err != nil {
+		log.Fatalf("Invalid FHIR source config. Err: %v", err)
+	}
+
+	return &worker{postgres.NewRepository(db), sources}
 }
 
 func (w *worker) ValidateJob(ctx context.Context, jobArgs models.JobEnqueueArgs) (*models.Job, error) {
@@ -73,9 +85,9 @@ func (w *worker) ProcessJob(ctx context.Context, job models.Job, jobArgs models.
 		return errors.Wrap(err, "could not update job status in database")
 	}
 
-	bb, err := client.NewBlueButtonClient(client.NewConfig(jobArgs.BBBasePath))
+	bb, err := w.sources.Client(jobArgs.ResourceType, jobArgs.BBBasePath)
 	if err != nil {
-		err = errors.Wrap(err, "could not create Blue Button client")
+		err = errors.Wrap(err, "could not create FHIR client")
 		log.Error(err)
 		return err
 	}
@@ -99,6 +111,19 @@ func (w *worker) ProcessJob(ctx context.Context, job models.Job, jobArgs models.
 	fileUUID, fileSize, err := writeBBDataToFile(ctx, w.r, bb, *aco.CMSID, jobArgs)
 	fileName := fileUUID + ".ndjson"
 
+	// BFD is degraded. Leave the job in progress so the queue job can be retried once BFD recovers.
+	if goerrors.Is(err, ErrBFDUnavailable) {
+		log.Warnf("Blue Button unavailable while processing job %d. Queue job will be retried.", job.ID)
+		return err
+	}
+
+	// The worker is stopping. Leave the job in progress so the queue job can be resumed from its checkpoint.
+	var stopped *StoppedError
+	if goerrors.As(err, &stopped) {
+		log.Warnf("Worker stopped while processing job %d. %s", job.ID, err.Error())
+		return err
+	}
+
 	// This is only run AFTER completion of all the collection
 	if err != nil {
 		// only inProgress jobs should move to a failed status (i.e. don't move a cancelled job to failed)
@@ -109,17 +134,29 @@ func (w *worker) ProcessJob(ctx context.Context, job models.Job, jobArgs models.
 		} else if err != nil {
 			return err
 		}
+		// The failed job's remaining queue jobs will never need to be re-enqueued
+		if err := w.r.DeletePlannedQueueJobs(ctx, job.ID); err != nil {
+			log.Warnf("Failed to delete planned queue jobs for failed job %d. Will continue. %s", job.ID, err.Error())
+		}
 	} else {
 		if fileSize == 0 {
 			log.Warn("Empty file found in request: ", fileName)
 			fileName = models.BlankFileName
 		}
 
-		jk := models.JobKey{JobID: job.ID, FileName: fileName, ResourceType: jobArgs.ResourceType}
+		jk := models.JobKey{JobID: job.ID, FileName: fileName, ResourceType: jobArgs.ResourceType,
+			PlannedQueueJobID: jobArgs.PlannedQueueJobID}
 		if err := w.r.CreateJobKey(ctx, jk); err != nil {
 			log.Error(err)
 			return err
 		}
+
+		if jobArgs.PlannedQueueJobID != 0 {
+			if err := w.r.DeletePlannedQueueJob(ctx, jobArgs.PlannedQueueJobID); err != nil {
+				log.Warnf("Failed to delete planned queue job %d for job %d. Will continue. %s",
+					jobArgs.PlannedQueueJobID, job.ID, err.Error())
+			}
+		}
 	}
 
 	_, err = checkJobCompleteAndCleanup(ctx, w.r, job.ID)
@@ -137,19 +174,33 @@ func (w *worker) ProcessJob(ctx context.Context, job models.Job, jobArgs models.
 	return nil
 }
 
+// CompleteJob marks the job as completed once all of its queue jobs have been completed,
+// moving the job's files from the staging directory to the payload directory.
+// It returns true if the job has a terminal status.
+func CompleteJob(ctx context.Context, r repository.Repository, jobID uint) (bool, error) {
+	return checkJobCompleteAndCleanup(ctx, r, jobID)
+}
+
 func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.APIClient,
 	cmsID string, jobArgs models.JobEnqueueArgs) (fileUUID string, size int64, err error) {
 	segment := getSegment(ctx, "writeBBDataToFile")
 	defer segment.End()
 
-	var bundleFunc func(bbID string) (*fhirmodels.Bundle, error)
+	var bundleFunc func(beneID, bbID string) (*fhirmodels.Bundle, error)
 	switch jobArgs.ResourceType {
 	case "Coverage":
-		bundleFunc = func(bbID string) (*fhirmodels.Bundle, error) {
+		bundleFunc = func(beneID, bbID string) (*fhirmodels.Bundle, error) {
 			return bb.GetCoverage(bbID, strconv.Itoa(jobArgs.ID), cmsID, jobArgs.Since, jobArgs.TransactionTime)
 		}
 	case "ExplanationOfBenefit":
-		bundleFunc = func(bbID string) (*fhirmodels.Bundle, error) {
+		// SAMHSA claims are excluded for every beneficiary unless disabled. Beneficiaries that opted out
+		// of SAMHSA data sharing always have their SAMHSA claims excluded.
+		excludeAllSAMHSA := utils.GetEnvBool("BB_EXCLUDE_SAMHSA", true)
+		samhsaSuppressed := make(map[string]struct{}, len(jobArgs.SAMHSASuppressedIDs))
+		for _, id := range jobArgs.SAMHSASuppressedIDs {
+			samhsaSuppressed[id] = struct{}{}
+		}
+		bundleFunc = func(beneID, bbID string) (*fhirmodels.Bundle, error) {
 			var claimsWindow client.ClaimsWindow
 			// TODO: (BCDA-4339) Remove this conditional check once we've completed a release with the new ClaimsWindow code.
 			// We should be able to use the jobArgs.ClaimsWindow directly
@@ -164,20 +215,50 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 				// Backwards compatibility - old API would set the service date as the upperbound
 				claimsWindow.UpperBound = jobArgs.ServiceDate
 			}
+			_, excludeSAMHSA := samhsaSuppressed[beneID]
 			return bb.GetExplanationOfBenefit(bbID, strconv.Itoa(jobArgs.ID), cmsID, jobArgs.Since, jobArgs.TransactionTime,
-				claimsWindow)
+				claimsWindow, excludeAllSAMHSA || excludeSAMHSA)
 		}
 	case "Patient":
-		bundleFunc = func(bbID string) (*fhirmodels.Bundle, error) {
+		bundleFunc = func(beneID, bbID string) (*fhirmodels.Bundle, error) {
 			return bb.GetPatient(bbID, strconv.Itoa(jobArgs.ID), cmsID, jobArgs.Since, jobArgs.TransactionTime)
 		}
 	default:
 		return "", 0, fmt.Errorf("unsupported resource type %s", jobArgs.ResourceType)
 	}
 
+	// Requests to BFD are cancelled when the worker is stopping, independent of the parent job's cancellation,
+	// so a stopping worker is not held up by in-flight requests, retries or backoff.
+	reqCtx, cancelRequests := withStopCancel(ctx)
+	defer cancelRequests()
+	bb = bb.WithContext(reqCtx)
+
 	dataDir := conf.GetEnv("FHIR_STAGING_DIR")
-	fileUUID = uuid.New()
-	f, err := os.Create(fmt.Sprintf("%s/%d/%s.ndjson", dataDir, jobArgs.ID, fileUUID))
+	beneIDs := jobArgs.BeneficiaryIDs
+	errorCount := 0
+	lastBeneID := ""
+	var f *os.File
+	// Resume writing to the staged file of an interrupted queue job
+	if cp := resumableCheckpoint(dataDir, jobArgs); cp != nil {
+		fileUUID, errorCount, lastBeneID = cp.FileUUID, cp.ErrorCount, cp.LastBeneficiaryID
+		for i, beneID := range beneIDs {
+			if beneID == cp.LastBeneficiaryID {
+				beneIDs = beneIDs[i+1:]
+				break
+			}
+		}
+		/* #nosec -- opening file defined by variable */
+		f, err = os.OpenFile(fmt.Sprintf("%s/%d/%s.ndjson", dataDir, jobArgs.ID, fileUUID), os.O_APPEND|os.O_WRONLY, 0)
+		if err == nil {
+			// Discard anything written after the checkpoint was taken so resources are not duplicated
+			err = truncateStagedFiles(f, fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobArgs.ID, fileUUID), cp)
+		}
+		log.Infof("Resuming queue job for job %d after beneficiary %s (%d beneficiaries remaining)",
+			jobArgs.ID, cp.LastBeneficiaryID, len(beneIDs))
+	} else {
+		fileUUID = uuid.New()
+		f, err = os.Create(fmt.Sprintf("%s/%d/%s.ndjson", dataDir, jobArgs.ID, fileUUID))
+	}
 	if err != nil {
 		log.Error(err)
 		return "", 0, err
@@ -186,12 +267,19 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 	defer utils.CloseFileAndLogError(f)
 
 	w := bufio.NewWriter(f)
-	errorCount := 0
 	totalBeneIDs := float64(len(jobArgs.BeneficiaryIDs))
 	failThreshold := getFailureThreshold()
 	failed := false
+	bfdUnavailable := false
+	workerStopped := false
+
+	for _, beneID := range beneIDs {
+		// if the worker is stopping, checkpoint the beneficiaries written so far so another worker can resume the queue job
+		if isStopping(ctx) {
+			workerStopped = true
+			break
+		}
 
-	for _, beneID := range jobArgs.BeneficiaryIDs {
 		// if the parent job was cancelled, stop processing beneIDs and fail the job
 		if ctx.Err() == context.Canceled {
 			failed = true
@@ -209,7 +297,7 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 				return fmt.Sprintf("Error retrieving BlueButton ID for cclfBeneficiary MBI %s", bene.MBI), err
 			}
 
-			b, err := bundleFunc(bene.BlueButtonID)
+			b, err := bundleFunc(beneID, bene.BlueButtonID)
 			if err != nil {
 				return fmt.Sprintf("Error retrieving %s for beneficiary MBI %s in ACO %s", jobArgs.ResourceType, bene.MBI, jobArgs.ACOID), err
 			}
@@ -217,12 +305,26 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 			return "", nil
 		}()
 
-		if err != nil {
+		if err != nil && isStopping(ctx) {
+			// The worker stopped during the beneficiary's requests. The beneficiary is not counted as an error and
+			// will be retrieved again when the queue job is resumed.
+			workerStopped = true
+			break
+		}
+
+		if goerrors.Is(err, client.ErrBFDUnavailable) {
+			// Errors caused by BFD being degraded should not count towards the failure threshold
+			bfdUnavailable = true
+			break
+		} else if err != nil {
 			log.Error(err)
 			errorCount++
 			appendErrorToFile(ctx, fileUUID, fhircodes.IssueTypeCode_EXCEPTION, responseutils.BbErr, errMsg, jobArgs.ID)
 		}
 
+		// The beneficiary's resources (or its error) have been written
+		lastBeneID = beneID
+
 		failPct := (float64(errorCount) / totalBeneIDs) * 100
 		if failPct >= failThreshold {
 			failed = true
@@ -234,6 +336,26 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 		return "", 0, err
 	}
 
+	if bfdUnavailable {
+		// Discard the partial results since the entire queue job will be retried
+		removeStagedFiles(f.Name(), fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobArgs.ID, fileUUID))
+		return "", 0, ErrBFDUnavailable
+	}
+
+	if workerStopped {
+		if lastBeneID == "" {
+			// Nothing was written, the queue job will be retried from the start
+			removeStagedFiles(f.Name(), fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobArgs.ID, fileUUID))
+			return "", 0, &StoppedError{}
+		}
+		cp, err := newCheckpoint(f, fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobArgs.ID, fileUUID))
+		if err != nil {
+			return "", 0, err
+		}
+		cp.FileUUID, cp.LastBeneficiaryID, cp.ErrorCount = fileUUID, lastBeneID, errorCount
+		return "", 0, &StoppedError{cp}
+	}
+
 	if failed {
 		if ctx.Err() == context.Canceled {
 			return "", 0, errors.New(fmt.Sprintf("Parent job %d was cancelled", jobArgs.ID))
@@ -249,6 +371,81 @@ func writeBBDataToFile(ctx context.Context, r repository.Repository, bb client.A
 	return fileUUID, fstat.Size(), nil
 }
 
+// resumableCheckpoint returns the queue job's checkpoint when the queue job can be resumed from it.
+// The queue job is started over when the checkpoint's staged file or last beneficiary can no longer be found.
+func resumableCheckpoint(dataDir string, jobArgs models.JobEnqueueArgs) *models.JobCheckpoint {
+	cp := jobArgs.Checkpoint
+	if cp == nil {
+		return nil
+	}
+
+	fi, err := os.Stat(fmt.Sprintf("%s/%d/%s.ndjson", dataDir, jobArgs.ID, cp.FileUUID))
+	if err != nil {
+		log.Warnf("Cannot resume queue job for job %d from staged file %s. Starting over. %s", jobArgs.ID, cp.FileUUID, err.Error())
+		return nil
+	}
+	errorSize, err := fileSize(fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobArgs.ID, cp.FileUUID))
+	if err != nil {
+		log.Warnf("Cannot resume queue job for job %d from staged file %s. Starting over. %s", jobArgs.ID, cp.FileUUID, err.Error())
+		return nil
+	}
+	if fi.Size() < cp.FileOffset || errorSize < cp.ErrorFileOffset {
+		log.Warnf("Cannot resume queue job for job %d from staged file %s that is smaller than its checkpoint. Starting over.",
+			jobArgs.ID, cp.FileUUID)
+		return nil
+	}
+	for _, beneID := range jobArgs.BeneficiaryIDs {
+		if beneID == cp.LastBeneficiaryID {
+			return cp
+		}
+	}
+	log.Warnf("Cannot resume queue job for job %d after beneficiary %s that is not part of the queue job. Starting over.",
+		jobArgs.ID, cp.LastBeneficiaryID)
+	return nil
+}
+
+// newCheckpoint records the sizes of the (flushed) staged file and its error file
+func newCheckpoint(f *os.File, errorFile string) (*models.JobCheckpoint, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	errorSize, err := fileSize(errorFile)
+	if err != nil {
+		return nil, err
+	}
+	return &models.JobCheckpoint{FileOffset: fi.Size(), ErrorFileOffset: errorSize}, nil
+}
+
+// truncateStagedFiles truncates the staged file and its error file to their sizes at the checkpoint
+func truncateStagedFiles(f *os.File, errorFile string, cp *models.JobCheckpoint) error {
+	if err := f.Truncate(cp.FileOffset); err != nil {
+		return err
+	}
+	if _, err := os.Stat(errorFile); os.IsNotExist(err) {
+		return nil
+	}
+	return os.Truncate(errorFile, cp.ErrorFileOffset)
+}
+
+// fileSize returns the size of the file, or zero when it does not exist
+func fileSize(name string) (int64, error) {
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// DiscardCheckpoint removes the staged files written for the checkpoint, so the queue job can be started over
+func DiscardCheckpoint(jobID int, cp *models.JobCheckpoint) {
+	dataDir := conf.GetEnv("FHIR_STAGING_DIR")
+	removeStagedFiles(fmt.Sprintf("%s/%d/%s.ndjson", dataDir, jobID, cp.FileUUID),
+		fmt.Sprintf("%s/%d/%s-error.ndjson", dataDir, jobID, cp.FileUUID))
+}
+
 // getBeneficiary returns the beneficiary. The bb ID value is retrieved and set in the model.
 func getBeneficiary(ctx context.Context, r repository.Repository, beneID uint, bb client.APIClient) (models.CCLFBeneficiary, error) {
 
@@ -351,6 +548,11 @@ func checkJobCompleteAndCleanup(ctx context.Context, r repository.Repository, jo
 		return true, nil
 	}
 
+	// The job count is only set once all of the queue jobs have been planned, until then the job cannot be complete
+	if j.JobCount == 0 {
+		return false, nil
+	}
+
 	completedCount, err := r.GetJobKeyCount(ctx, jobID)
 	if err != nil {
 		return false, err
@@ -398,6 +600,14 @@ func getSegment(ctx context.Context, name string) newrelic.Segment {
 	return segment
 }
 
+func removeStagedFiles(paths ...string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warnf("Failed to remove staged file %s: %s", path, err)
+		}
+	}
+}
+
 func createDir(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		if err = os.MkdirAll(path, os.ModePerm); err != nil {
@@ -407,6 +617,57 @@ func createDir(path string) error {
 	return nil
 }
 
+type stopKey struct{}
+
+// WithStop returns a copy of ctx that signals the worker to stop once stop is closed.
+// A stopping worker cancels its in-flight requests to BFD then checkpoints its progress, returning a StoppedError.
+func WithStop(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, stopKey{}, stop)
+}
+
+// withStopCancel returns a context, detached from ctx's cancellation, that is cancelled once the worker is stopping
+func withStopCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithCancel(context.Background())
+	stop, ok := ctx.Value(stopKey{}).(<-chan struct{})
+	if !ok {
+		return reqCtx, cancel
+	}
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-reqCtx.Done():
+		}
+	}()
+	return reqCtx, cancel
+}
+
+func isStopping(ctx context.Context) bool {
+	stop, ok := ctx.Value(stopKey{}).(<-chan struct{})
+	if !ok {
+		return false
+	}
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// StoppedError is returned when the worker stopped before it finished the queue job.
+// The queue job can be resumed from the Checkpoint, which is nil when no beneficiaries were written.
+type StoppedError struct {
+	Checkpoint *models.JobCheckpoint
+}
+
+func (e *StoppedError) Error() string {
+	if e.Checkpoint == nil {
+		return "worker stopped before any beneficiaries were written"
+	}
+	return fmt.Sprintf("worker stopped after beneficiary %s", e.Checkpoint.LastBeneficiaryID)
+}
+
 type JobError struct {
 	ErrorString string
 }
@@ -419,4 +680,5 @@ var (
 	ErrNoBasePathSet      = JobError{"empty BBBasePath: Must be set"}
 	ErrParentJobNotFound  = JobError{"parent job not found"}
 	ErrParentJobCancelled = JobError{"parent job cancelled"}
+	ErrBFDUnavailable     = JobError{"blue button unavailable"}
 )
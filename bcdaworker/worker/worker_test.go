@@ -117,7 +117,7 @@ func (s *WorkerTestSuite) TestWriteResourceToFile() {
 		cclfBeneficiaryIDs = append(cclfBeneficiaryIDs, strconv.FormatUint(uint64(cclfBeneficiary.ID), 10))
 		bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(cclfBeneficiary.MBI)).Return(bbc.GetData("Patient", beneID))
 		bbc.On("GetExplanationOfBenefit", beneID, strconv.Itoa(s.jobID), *s.testACO.CMSID, since, transactionTime,
-			claimsWindowMatcher(claimsWindow.LowerBound, claimsWindow.UpperBound)).Return(bbc.GetBundleData("ExplanationOfBenefit", beneID))
+			claimsWindowMatcher(claimsWindow.LowerBound, claimsWindow.UpperBound), true).Return(bbc.GetBundleData("ExplanationOfBenefit", beneID))
 		bbc.On("GetCoverage", beneID, strconv.Itoa(s.jobID), *s.testACO.CMSID, since, transactionTime).Return(bbc.GetBundleData("Coverage", beneID))
 		bbc.On("GetPatient", beneID, strconv.Itoa(s.jobID), *s.testACO.CMSID, since, transactionTime).Return(bbc.GetBundleData("Patient", beneID))
 	}
@@ -194,6 +194,33 @@ func (s *WorkerTestSuite) TestWriteResourceToFile() {
 	bbc.AssertExpectations(s.T())
 }
 
+func (s *WorkerTestSuite) TestWriteEOBExcludeSAMHSA() {
+	conf.SetEnv(s.T(), "BB_EXCLUDE_SAMHSA", "false")
+	defer conf.UnsetEnv(s.T(), "BB_EXCLUDE_SAMHSA")
+
+	transactionTime := time.Now()
+	bbc := client.MockBlueButtonClient{}
+	var cclfBeneficiaryIDs []string
+	for _, beneID := range []string{"a1000003701", "a1000050699"} {
+		bbc.MBI = &beneID
+		cclfBeneficiary := models.CCLFBeneficiary{FileID: s.cclfFile.ID, MBI: beneID, BlueButtonID: beneID}
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &cclfBeneficiary)
+		cclfBeneficiaryIDs = append(cclfBeneficiaryIDs, strconv.FormatUint(uint64(cclfBeneficiary.ID), 10))
+		bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(beneID)).Return(bbc.GetData("Patient", beneID))
+	}
+	// Only the beneficiary that opted out of SAMHSA data sharing should have their SAMHSA claims excluded
+	bbc.On("GetExplanationOfBenefit", "a1000003701", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime,
+		claimsWindowMatcher(), false).Return(bbc.GetBundleData("ExplanationOfBenefit", "a1000003701"))
+	bbc.On("GetExplanationOfBenefit", "a1000050699", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime,
+		claimsWindowMatcher(), true).Return(bbc.GetBundleData("ExplanationOfBenefit", "a1000050699"))
+
+	jobArgs := models.JobEnqueueArgs{ID: s.jobID, ResourceType: "ExplanationOfBenefit", BeneficiaryIDs: cclfBeneficiaryIDs,
+		TransactionTime: transactionTime, ACOID: s.testACO.UUID.String(), SAMHSASuppressedIDs: cclfBeneficiaryIDs[1:]}
+	_, _, err := writeBBDataToFile(context.Background(), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	assert.NoError(s.T(), err)
+	bbc.AssertExpectations(s.T())
+}
+
 // TODO: (BCDA-4339) - Remove this test. Only needed to verify backwards compatibility logic
 func (s *WorkerTestSuite) TestEOBBackwardCompatibility() {
 	beneID := "a1000003701"
@@ -206,7 +233,7 @@ func (s *WorkerTestSuite) TestEOBBackwardCompatibility() {
 	bbc.MBI = &beneID
 	bbc.On("GetPatientByIdentifierHash", mock.Anything).Return(bbc.GetData("Patient", beneID))
 	bbc.On("GetExplanationOfBenefit", beneID, strconv.Itoa(s.jobID), *s.testACO.CMSID, "", time.Time{},
-		claimsWindowMatcher(time.Time{}, jobArgs.ServiceDate)).Return(bbc.GetBundleData("ExplanationOfBenefit", beneID))
+		claimsWindowMatcher(time.Time{}, jobArgs.ServiceDate), true).Return(bbc.GetBundleData("ExplanationOfBenefit", beneID))
 	uuid, size, err := writeBBDataToFile(context.Background(), s.r, &bbc, *s.testACO.CMSID, jobArgs)
 	assert.NoError(s.T(), err)
 	assert.Greater(s.T(), size, int64(0))
@@ -218,7 +245,7 @@ func (s *WorkerTestSuite) TestWriteEmptyResourceToFile() {
 
 	bbc := client.MockBlueButtonClient{}
 	// Set up the mock function to return the expected values
-	bbc.On("GetExplanationOfBenefit", "abcdef12000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(bbc.GetBundleData("ExplanationOfBenefitEmpty", "abcdef12000"))
+	bbc.On("GetExplanationOfBenefit", "abcdef12000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(bbc.GetBundleData("ExplanationOfBenefitEmpty", "abcdef12000"))
 	beneficiaryID := "abcdef12000"
 	var cclfBeneficiaryIDs []string
 
@@ -234,6 +261,166 @@ func (s *WorkerTestSuite) TestWriteEmptyResourceToFile() {
 	assert.NoError(s.T(), err)
 }
 
+func (s *WorkerTestSuite) TestWriteEOBDataToFileBFDUnavailable() {
+	// Even though every request failed, BFD being unavailable should not count towards the failure threshold
+	origFailPct := conf.GetEnv("EXPORT_FAIL_PCT")
+	defer conf.SetEnv(s.T(), "EXPORT_FAIL_PCT", origFailPct)
+	conf.SetEnv(s.T(), "EXPORT_FAIL_PCT", "100")
+	transactionTime := time.Now()
+
+	bbc := client.MockBlueButtonClient{}
+	bbc.On("GetExplanationOfBenefit", "abcdef10000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).
+		Return(nil, errors.New("error"))
+	bbc.On("GetExplanationOfBenefit", "abcdef11000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).
+		Return(nil, fmt.Errorf("blue button request not attempted: %w", client.ErrBFDUnavailable))
+	beneficiaryIDs := []string{"abcdef10000", "abcdef11000", "abcdef12000"}
+	var cclfBeneficiaryIDs []string
+
+	for i := 0; i < len(beneficiaryIDs); i++ {
+		beneficiaryID := beneficiaryIDs[i]
+		bbc.MBI = &beneficiaryID
+		cclfBeneficiary := models.CCLFBeneficiary{FileID: s.cclfFile.ID, MBI: beneficiaryID, BlueButtonID: beneficiaryID}
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &cclfBeneficiary)
+		cclfBeneficiaryIDs = append(cclfBeneficiaryIDs, strconv.FormatUint(uint64(cclfBeneficiary.ID), 10))
+		bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(cclfBeneficiary.MBI)).Return(bbc.GetData("Patient", beneficiaryID))
+	}
+
+	jobArgs := models.JobEnqueueArgs{ID: s.jobID, ResourceType: "ExplanationOfBenefit", BeneficiaryIDs: cclfBeneficiaryIDs, TransactionTime: transactionTime, ACOID: s.testACO.UUID.String()}
+	_, _, err := writeBBDataToFile(context.Background(), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	assert.Equal(s.T(), ErrBFDUnavailable, err)
+	// Remaining beneficiaries are not requested since the queue job will be retried
+	bbc.AssertNotCalled(s.T(), "GetExplanationOfBenefit", "abcdef12000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true)
+
+	// Partial results are discarded
+	files, err := ioutil.ReadDir(s.stagingDir)
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), files)
+}
+
+func (s *WorkerTestSuite) TestWritePatientDataToFileStopped() {
+	transactionTime := time.Now()
+	stop := make(chan struct{})
+
+	bbc := client.MockBlueButtonClient{}
+	beneficiaryIDs := []string{"abcdef10000", "abcdef11000", "abcdef12000"}
+	var cclfBeneficiaryIDs []string
+	for i := 0; i < len(beneficiaryIDs); i++ {
+		beneficiaryID := beneficiaryIDs[i]
+		bbc.MBI = &beneficiaryID
+		cclfBeneficiary := models.CCLFBeneficiary{FileID: s.cclfFile.ID, MBI: beneficiaryID, BlueButtonID: beneficiaryID}
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &cclfBeneficiary)
+		cclfBeneficiaryIDs = append(cclfBeneficiaryIDs, strconv.FormatUint(uint64(cclfBeneficiary.ID), 10))
+		bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(cclfBeneficiary.MBI)).Return(bbc.GetData("Patient", beneficiaryID))
+		call := bbc.On("GetPatient", beneficiaryID, strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime).
+			Return(bbc.GetBundleData("Patient", beneficiaryID))
+		if i == 0 {
+			// The worker is stopped while it is working on the first beneficiary
+			call.Run(func(mock.Arguments) { close(stop) })
+		}
+	}
+
+	jobArgs := models.JobEnqueueArgs{ID: s.jobID, ResourceType: "Patient", BeneficiaryIDs: cclfBeneficiaryIDs,
+		TransactionTime: transactionTime, ACOID: s.testACO.UUID.String()}
+	_, _, err := writeBBDataToFile(WithStop(context.Background(), stop), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	var stopped *StoppedError
+	assert.ErrorAs(s.T(), err, &stopped)
+	assert.NotNil(s.T(), stopped.Checkpoint)
+	// The first beneficiary is finished before the worker stops
+	assert.Equal(s.T(), cclfBeneficiaryIDs[0], stopped.Checkpoint.LastBeneficiaryID)
+	assert.Equal(s.T(), 0, stopped.Checkpoint.ErrorCount)
+	bbc.AssertNotCalled(s.T(), "GetPatient", beneficiaryIDs[1], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime)
+
+	filePath := fmt.Sprintf("%s/%s.ndjson", s.stagingDir, stopped.Checkpoint.FileUUID)
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, strings.Count(string(data), "\n"))
+	assert.Equal(s.T(), int64(len(data)), stopped.Checkpoint.FileOffset)
+	assert.Equal(s.T(), int64(0), stopped.Checkpoint.ErrorFileOffset)
+
+	// A worker that resumed the queue job was killed part way through writing a resource
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(s.T(), err)
+	_, err = f.WriteString(`{"resourceType":"Pat`)
+	assert.NoError(s.T(), err)
+	assert.NoError(s.T(), f.Close())
+
+	// Another worker resumes the queue job, appending the remaining beneficiaries to the same file
+	// after discarding what was written since the checkpoint
+	jobArgs.Checkpoint = stopped.Checkpoint
+	fileUUID, size, err := writeBBDataToFile(context.Background(), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), stopped.Checkpoint.FileUUID, fileUUID)
+	assert.NotEqual(s.T(), int64(0), size)
+	bbc.AssertNumberOfCalls(s.T(), "GetPatient", len(beneficiaryIDs))
+
+	data, err = ioutil.ReadFile(filePath)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), len(beneficiaryIDs), strings.Count(string(data), "\n"))
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		assert.True(s.T(), json.Valid([]byte(line)), line)
+	}
+	files, err := ioutil.ReadDir(s.stagingDir)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), files, 1)
+
+	// The queue job is started over when the checkpoint's file no longer exists
+	assert.NoError(s.T(), os.Remove(filePath))
+	assert.Nil(s.T(), resumableCheckpoint(conf.GetEnv("FHIR_STAGING_DIR"), jobArgs))
+}
+
+func (s *WorkerTestSuite) TestWriteEOBDataToFileStoppedDuringRequest() {
+	transactionTime := time.Now()
+	stop := make(chan struct{})
+
+	bbc := client.MockBlueButtonClient{}
+	beneficiaryIDs := []string{"abcdef10000", "abcdef11000"}
+	var cclfBeneficiaryIDs []string
+	for _, beneficiaryID := range beneficiaryIDs {
+		beneficiaryID := beneficiaryID
+		bbc.MBI = &beneficiaryID
+		cclfBeneficiary := models.CCLFBeneficiary{FileID: s.cclfFile.ID, MBI: beneficiaryID, BlueButtonID: beneficiaryID}
+		postgrestest.CreateCCLFBeneficiary(s.T(), s.db, &cclfBeneficiary)
+		cclfBeneficiaryIDs = append(cclfBeneficiaryIDs, strconv.FormatUint(uint64(cclfBeneficiary.ID), 10))
+		bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(cclfBeneficiary.MBI)).Return(bbc.GetData("Patient", beneficiaryID))
+	}
+	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[0], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).
+		Return(bbc.GetBundleData("ExplanationOfBenefit", beneficiaryIDs[0]))
+	// The worker is stopped while the request for the second beneficiary is in flight, cancelling it
+	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[1], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).
+		Run(func(mock.Arguments) { close(stop) }).
+		Return(nil, fmt.Errorf("ExplanationOfBenefit request cancelled: %w", context.Canceled))
+
+	jobArgs := models.JobEnqueueArgs{ID: s.jobID, ResourceType: "ExplanationOfBenefit", BeneficiaryIDs: cclfBeneficiaryIDs,
+		TransactionTime: transactionTime, ACOID: s.testACO.UUID.String()}
+	_, _, err := writeBBDataToFile(WithStop(context.Background(), stop), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	var stopped *StoppedError
+	assert.ErrorAs(s.T(), err, &stopped)
+	assert.NotNil(s.T(), stopped.Checkpoint)
+	// The cancelled beneficiary is neither checkpointed nor counted as an error
+	assert.Equal(s.T(), cclfBeneficiaryIDs[0], stopped.Checkpoint.LastBeneficiaryID)
+	assert.Equal(s.T(), 0, stopped.Checkpoint.ErrorCount)
+	_, err = os.Stat(fmt.Sprintf("%s/%s-error.ndjson", s.stagingDir, stopped.Checkpoint.FileUUID))
+	assert.True(s.T(), os.IsNotExist(err))
+}
+
+func (s *WorkerTestSuite) TestWriteDataToFileStoppedBeforeStarting() {
+	stop := make(chan struct{})
+	close(stop)
+
+	bbc := client.MockBlueButtonClient{}
+	jobArgs := models.JobEnqueueArgs{ID: s.jobID, ResourceType: "Patient", BeneficiaryIDs: []string{"1", "2"},
+		TransactionTime: time.Now(), ACOID: s.testACO.UUID.String()}
+	_, _, err := writeBBDataToFile(WithStop(context.Background(), stop), s.r, &bbc, *s.testACO.CMSID, jobArgs)
+	var stopped *StoppedError
+	assert.ErrorAs(s.T(), err, &stopped)
+	assert.Nil(s.T(), stopped.Checkpoint)
+
+	// No partial results are left behind since the queue job will be retried from the start
+	files, err := ioutil.ReadDir(s.stagingDir)
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), files)
+}
+
 func (s *WorkerTestSuite) TestWriteEOBDataToFileWithErrorsBelowFailureThreshold() {
 	origFailPct := conf.GetEnv("EXPORT_FAIL_PCT")
 	defer conf.SetEnv(s.T(), "EXPORT_FAIL_PCT", origFailPct)
@@ -242,9 +429,9 @@ func (s *WorkerTestSuite) TestWriteEOBDataToFileWithErrorsBelowFailureThreshold(
 
 	bbc := client.MockBlueButtonClient{}
 	// Set up the mock function to return the expected values
-	bbc.On("GetExplanationOfBenefit", "abcdef10000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(nil, errors.New("error"))
-	bbc.On("GetExplanationOfBenefit", "abcdef11000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(nil, errors.New("error"))
-	bbc.On("GetExplanationOfBenefit", "abcdef12000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(bbc.GetBundleData("ExplanationOfBenefit", "abcdef12000"))
+	bbc.On("GetExplanationOfBenefit", "abcdef10000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(nil, errors.New("error"))
+	bbc.On("GetExplanationOfBenefit", "abcdef11000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(nil, errors.New("error"))
+	bbc.On("GetExplanationOfBenefit", "abcdef12000", strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(bbc.GetBundleData("ExplanationOfBenefit", "abcdef12000"))
 	beneficiaryIDs := []string{"abcdef10000", "abcdef11000", "abcdef12000"}
 	var cclfBeneficiaryIDs []string
 
@@ -286,8 +473,8 @@ func (s *WorkerTestSuite) TestWriteEOBDataToFileWithErrorsAboveFailureThreshold(
 	bbc := client.MockBlueButtonClient{}
 	// Set up the mock function to return the expected values
 	beneficiaryIDs := []string{"a1000089833", "a1000065301", "a1000012463"}
-	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[0], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(nil, errors.New("error"))
-	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[1], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher()).Return(nil, errors.New("error"))
+	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[0], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(nil, errors.New("error"))
+	bbc.On("GetExplanationOfBenefit", beneficiaryIDs[1], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true).Return(nil, errors.New("error"))
 	bbc.MBI = &beneficiaryIDs[0]
 	bbc.On("GetPatientByIdentifierHash", client.HashIdentifier(beneficiaryIDs[0])).Return(bbc.GetData("Patient", beneficiaryIDs[0]))
 	bbc.MBI = &beneficiaryIDs[1]
@@ -323,7 +510,7 @@ func (s *WorkerTestSuite) TestWriteEOBDataToFileWithErrorsAboveFailureThreshold(
 
 	bbc.AssertExpectations(s.T())
 	// should not have requested third beneficiary EOB because failure threshold was reached after second
-	bbc.AssertNotCalled(s.T(), "GetExplanationOfBenefit", beneficiaryIDs[2], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher())
+	bbc.AssertNotCalled(s.T(), "GetExplanationOfBenefit", beneficiaryIDs[2], strconv.Itoa(s.jobID), *s.testACO.CMSID, "", transactionTime, claimsWindowMatcher(), true)
 }
 
 func (s *WorkerTestSuite) TestWriteEOBDataToFile_BlueButtonIDNotFound() {
@@ -534,6 +721,7 @@ func (s *WorkerTestSuite) TestCheckJobCompleteAndCleanup() {
 	}{
 		{"PendingButComplete", models.JobStatusPending, 1, 1, true},
 		{"PendingNotComplete", models.JobStatusPending, 10, 1, false},
+		{"StillPlanning", models.JobStatusInProgress, 0, 1, false},
 		{"AlreadyCompleted", models.JobStatusCompleted, 1, 1, true},
 		{"Cancelled", models.JobStatusCancelled, 1, 1, true},
 		{"Failed", models.JobStatusFailed, 1, 1, true},
@@ -559,7 +747,8 @@ func (s *WorkerTestSuite) TestCheckJobCompleteAndCleanup() {
 			repository.On("GetJobByID", testUtils.CtxMatcher, jobID).Return(j, nil)
 
 			// A job previously marked as a terminal status (Completed, Cancelled, or Failed) will bypass all of these calls
-			if !isTerminalStatus(tt.status) {
+			// A job that is still being planned has no job count and will bypass all of these calls as well
+			if !isTerminalStatus(tt.status) && tt.jobCount > 0 {
 				repository.On("GetJobKeyCount", testUtils.CtxMatcher, jobID).Return(tt.jobKeys, nil)
 				if tt.completed {
 					repository.On("UpdateJobStatus", testUtils.CtxMatcher, j.ID, models.JobStatusCompleted).
@@ -593,7 +782,7 @@ func isTerminalStatus(status models.JobStatus) bool {
 func (s *WorkerTestSuite) TestValidateJob() {
 	ctx := context.Background()
 	r := &repository.MockRepository{}
-	w := &worker{r}
+	w := &worker{r: r}
 
 	noBasePath := models.JobEnqueueArgs{ID: int(rand.Int31())}
 	jobNotFound := models.JobEnqueueArgs{ID: int(rand.Int31()), BBBasePath: uuid.New()}
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TRIGGER set_timestamp ON public.credentials;
+DROP TABLE public.credentials CASCADE;
+COMMIT;
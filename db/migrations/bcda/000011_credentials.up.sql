@@ -0,0 +1,29 @@
+BEGIN;
+
+-- Track the lifecycle of client credentials issued to ACOs
+CREATE TABLE IF NOT EXISTS public.credentials (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    client_id text NOT NULL,
+    system_id text,
+    aco_cms_id character varying(5),
+    last_token_issued_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    UNIQUE (client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_credentials_aco_cms_id ON public.credentials USING btree (aco_cms_id);
+
+ALTER TABLE ONLY public.credentials
+    ADD CONSTRAINT primary_key_credentials PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.credentials_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.credentials.id;
+ALTER TABLE ONLY public.credentials ALTER COLUMN id SET DEFAULT nextval('public.credentials_id_seq');
+
+CREATE TRIGGER set_timestamp
+BEFORE UPDATE ON public.credentials
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
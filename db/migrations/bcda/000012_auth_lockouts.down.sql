@@ -0,0 +1,4 @@
+BEGIN;
+DROP TRIGGER set_timestamp ON public.auth_lockouts;
+DROP TABLE public.auth_lockouts CASCADE;
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Track failed token requests so repeated failures lock out the client ID or source IP address
+CREATE TABLE IF NOT EXISTS public.auth_lockouts (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    -- lockout_type is either client_id or ip_address
+    lockout_type text NOT NULL,
+    subject text NOT NULL,
+    failed_attempts integer DEFAULT 0 NOT NULL,
+    locked_until timestamp with time zone,
+    UNIQUE (lockout_type, subject)
+);
+
+ALTER TABLE ONLY public.auth_lockouts
+    ADD CONSTRAINT primary_key_auth_lockouts PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.auth_lockouts_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.auth_lockouts.id;
+ALTER TABLE ONLY public.auth_lockouts ALTER COLUMN id SET DEFAULT nextval('public.auth_lockouts_id_seq');
+
+CREATE TRIGGER set_timestamp
+BEFORE UPDATE ON public.auth_lockouts
+FOR EACH ROW
+EXECUTE PROCEDURE trigger_set_timestamp();
+
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE public.cclf_beneficiary_xrefs CASCADE;
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- Crosswalk from CCLF9 files linking a beneficiary's current MBI to the identifiers previously issued to them
+CREATE TABLE IF NOT EXISTS public.cclf_beneficiary_xrefs (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    file_id integer NOT NULL,
+    -- xref_indicator is M for MBI crosswalks and H for HICN crosswalks
+    xref_indicator text NOT NULL,
+    current_num character varying(11) NOT NULL,
+    prev_num character varying(11) NOT NULL,
+    prevs_efct_dt text,
+    prevs_obslt_dt text
+);
+
+ALTER TABLE ONLY public.cclf_beneficiary_xrefs
+    ADD CONSTRAINT primary_key_cclf_beneficiary_xrefs PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.cclf_beneficiary_xrefs_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.cclf_beneficiary_xrefs.id;
+ALTER TABLE ONLY public.cclf_beneficiary_xrefs ALTER COLUMN id SET DEFAULT nextval('public.cclf_beneficiary_xrefs_id_seq');
+
+CREATE INDEX IF NOT EXISTS idx_cclf_beneficiary_xrefs_file_id ON public.cclf_beneficiary_xrefs USING btree (file_id);
+
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+DROP INDEX IF EXISTS public.idx_suppressions_beneficiary_link_key;
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Suppressions recorded against different MBIs are linked to the same beneficiary through the beneficiary link key
+CREATE INDEX IF NOT EXISTS idx_suppressions_beneficiary_link_key ON public.suppressions USING btree (beneficiary_link_key);
+
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE public.job_reconciliations CASCADE;
+DROP TABLE public.planned_queue_jobs CASCADE;
+COMMIT;
@@ -0,0 +1,40 @@
+BEGIN;
+
+-- Queue jobs planned for a job that have not been completed. The worker removes a row once its queue job
+-- has been completed, so the remaining rows allow lost queue jobs to be re-enqueued.
+CREATE TABLE IF NOT EXISTS public.planned_queue_jobs (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    job_id integer NOT NULL,
+    -- args contains the JSON encoded models.JobEnqueueArgs supplied to the queue job
+    args jsonb NOT NULL,
+    priority integer NOT NULL
+);
+
+ALTER TABLE ONLY public.planned_queue_jobs
+    ADD CONSTRAINT primary_key_planned_queue_jobs PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.planned_queue_jobs_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.planned_queue_jobs.id;
+ALTER TABLE ONLY public.planned_queue_jobs ALTER COLUMN id SET DEFAULT nextval('public.planned_queue_jobs_id_seq');
+
+CREATE INDEX IF NOT EXISTS idx_planned_queue_jobs_job_id ON public.planned_queue_jobs USING btree (job_id);
+
+-- Actions taken to recover jobs that stopped making progress, along with the reason for each action
+CREATE TABLE IF NOT EXISTS public.job_reconciliations (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    job_id integer NOT NULL,
+    -- action is one of Completed, Requeued, or Failed
+    action text NOT NULL,
+    reason text NOT NULL
+);
+
+ALTER TABLE ONLY public.job_reconciliations
+    ADD CONSTRAINT primary_key_job_reconciliations PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.job_reconciliations_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.job_reconciliations.id;
+ALTER TABLE ONLY public.job_reconciliations ALTER COLUMN id SET DEFAULT nextval('public.job_reconciliations_id_seq');
+
+CREATE INDEX IF NOT EXISTS idx_job_reconciliations_job_id ON public.job_reconciliations USING btree (job_id);
+
+COMMIT;
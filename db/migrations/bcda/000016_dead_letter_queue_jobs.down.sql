@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE public.jobs DROP COLUMN IF EXISTS dead_letter_count;
+DROP TABLE public.dead_letter_queue_jobs CASCADE;
+COMMIT;
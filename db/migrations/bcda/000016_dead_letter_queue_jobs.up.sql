@@ -0,0 +1,30 @@
+BEGIN;
+
+-- Queue jobs that were removed from the queue because they can never be worked on successfully.
+-- Entries are kept until an operator replays or discards them.
+CREATE TABLE IF NOT EXISTS public.dead_letter_queue_jobs (
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    id bigint NOT NULL,
+    -- job_id is null when the queue job's args could not be read
+    job_id integer,
+    que_job_id bigint NOT NULL,
+    -- args contains the args supplied to the queue job as is, they may not be a valid models.JobEnqueueArgs
+    args json NOT NULL,
+    priority integer NOT NULL,
+    error_count integer NOT NULL,
+    last_error text,
+    reason text NOT NULL
+);
+
+ALTER TABLE ONLY public.dead_letter_queue_jobs
+    ADD CONSTRAINT primary_key_dead_letter_queue_jobs PRIMARY KEY (id);
+
+CREATE SEQUENCE IF NOT EXISTS public.dead_letter_queue_jobs_id_seq START WITH 1 INCREMENT BY 1 CACHE 1 OWNED BY public.dead_letter_queue_jobs.id;
+ALTER TABLE ONLY public.dead_letter_queue_jobs ALTER COLUMN id SET DEFAULT nextval('public.dead_letter_queue_jobs_id_seq');
+
+CREATE INDEX IF NOT EXISTS idx_dead_letter_queue_jobs_job_id ON public.dead_letter_queue_jobs USING btree (job_id);
+
+-- Number of the job's queue jobs that are dead-lettered
+ALTER TABLE public.jobs ADD COLUMN IF NOT EXISTS dead_letter_count integer DEFAULT 0 NOT NULL;
+
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE public.job_keys DROP COLUMN IF EXISTS planned_queue_job_id;
+COMMIT;
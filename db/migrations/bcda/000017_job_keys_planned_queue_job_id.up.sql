@@ -0,0 +1,7 @@
+BEGIN;
+
+-- The planned queue job completed by the job key, allowing planned queue jobs that were completed
+-- to be told apart from those that were lost. It is null for queue jobs that were not planned.
+ALTER TABLE public.job_keys ADD COLUMN IF NOT EXISTS planned_queue_job_id bigint;
+
+COMMIT;
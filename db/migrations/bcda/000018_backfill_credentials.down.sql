@@ -0,0 +1,3 @@
+BEGIN;
+-- Backfilled credentials cannot be told apart from those recorded since, so they are kept
+COMMIT;
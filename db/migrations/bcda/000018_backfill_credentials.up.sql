@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Credentials issued before the credentials table existed are only recorded on their ACO.
+-- Their creation time is unknown, so the time the ACO was last updated is used instead.
+INSERT INTO public.credentials (client_id, system_id, aco_cms_id, created_at)
+SELECT client_id, system_id, cms_id, updated_at
+FROM public.acos
+WHERE deleted_at IS NULL AND cms_id IS NOT NULL AND client_id IS NOT NULL
+    AND system_id IS NOT NULL AND system_id <> ''
+ON CONFLICT (client_id) DO NOTHING;
+
+COMMIT;
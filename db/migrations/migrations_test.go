@@ -105,6 +105,11 @@ func (s *MigrationTestSuite) TestBCDAMigration() {
 		"job_keys", "jobs", "suppressions", "suppression_files"}
 
 	migration10Tables := []string{"alr", "alr_meta"}
+	migration11Tables := []string{"credentials"}
+	migration12Tables := []string{"auth_lockouts"}
+	migration13Tables := []string{"cclf_beneficiary_xrefs"}
+	migration15Tables := []string{"planned_queue_jobs", "job_reconciliations"}
+	migration16Tables := []string{"dead_letter_queue_jobs"}
 
 	// Tests should begin with "up" migrations, in order, followed by "down" migrations in reverse order
 	tests := []struct {
@@ -256,6 +261,154 @@ func (s *MigrationTestSuite) TestBCDAMigration() {
 				}
 			},
 		},
+		{
+			"Add credentials table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "11")
+				for _, table := range migration11Tables {
+					assertTableExists(t, true, db, table)
+				}
+			},
+		},
+		{
+			"Add auth_lockouts table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "12")
+				for _, table := range migration12Tables {
+					assertTableExists(t, true, db, table)
+				}
+			},
+		},
+		{
+			"Add cclf_beneficiary_xrefs table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "13")
+				for _, table := range migration13Tables {
+					assertTableExists(t, true, db, table)
+				}
+			},
+		},
+		{
+			"Add suppressions beneficiary_link_key index",
+			func(t *testing.T) {
+				assertIndexExists(t, false, db, "suppressions", "idx_suppressions_beneficiary_link_key")
+				migrator.runMigration(t, "14")
+				assertIndexExists(t, true, db, "suppressions", "idx_suppressions_beneficiary_link_key")
+			},
+		},
+		{
+			"Add planned_queue_jobs and job_reconciliations tables",
+			func(t *testing.T) {
+				migrator.runMigration(t, "15")
+				for _, table := range migration15Tables {
+					assertTableExists(t, true, db, table)
+				}
+			},
+		},
+		{
+			"Add dead_letter_queue_jobs table and jobs dead_letter_count column",
+			func(t *testing.T) {
+				assertColumnExists(t, false, db, "jobs", "dead_letter_count")
+				migrator.runMigration(t, "16")
+				for _, table := range migration16Tables {
+					assertTableExists(t, true, db, table)
+				}
+				assertColumnExists(t, true, db, "jobs", "dead_letter_count")
+			},
+		},
+		{
+			"Add job_keys planned_queue_job_id column",
+			func(t *testing.T) {
+				assertColumnExists(t, false, db, "job_keys", "planned_queue_job_id")
+				migrator.runMigration(t, "17")
+				assertColumnExists(t, true, db, "job_keys", "planned_queue_job_id")
+			},
+		},
+		{
+			"Backfill credentials from acos",
+			func(t *testing.T) {
+				clientID := uuid.New()
+				createTestRow(t, db, "acos", map[string]interface{}{"uuid": uuid.New(), "name": "Backfill ACO",
+					"id": 1000, "client_id": clientID, "system_id": "1000", "cms_id": "A9999"})
+				defer func() {
+					_, err := db.Exec("DELETE FROM acos WHERE id = 1000")
+					assert.NoError(t, err)
+				}()
+
+				migrator.runMigration(t, "18")
+				var cmsID string
+				assert.NoError(t, db.QueryRow("SELECT aco_cms_id FROM credentials WHERE client_id = $1 AND system_id = '1000'",
+					clientID).Scan(&cmsID))
+				assert.Equal(t, "A9999", cmsID)
+			},
+		},
+		{
+			"Keep backfilled credentials",
+			func(t *testing.T) {
+				migrator.runMigration(t, "17")
+				assertTableExists(t, true, db, "credentials")
+			},
+		},
+		{
+			"Remove job_keys planned_queue_job_id column",
+			func(t *testing.T) {
+				migrator.runMigration(t, "16")
+				assertColumnExists(t, false, db, "job_keys", "planned_queue_job_id")
+			},
+		},
+		{
+			"Remove dead_letter_queue_jobs table and jobs dead_letter_count column",
+			func(t *testing.T) {
+				migrator.runMigration(t, "15")
+				for _, table := range migration16Tables {
+					assertTableExists(t, false, db, table)
+				}
+				assertColumnExists(t, false, db, "jobs", "dead_letter_count")
+			},
+		},
+		{
+			"Remove planned_queue_jobs and job_reconciliations tables",
+			func(t *testing.T) {
+				migrator.runMigration(t, "14")
+				for _, table := range migration15Tables {
+					assertTableExists(t, false, db, table)
+				}
+			},
+		},
+		{
+			"Remove suppressions beneficiary_link_key index",
+			func(t *testing.T) {
+				migrator.runMigration(t, "13")
+				assertIndexExists(t, false, db, "suppressions", "idx_suppressions_beneficiary_link_key")
+			},
+		},
+		{
+			"Remove cclf_beneficiary_xrefs table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "12")
+				for _, table := range migration13Tables {
+					assertTableExists(t, false, db, table)
+				}
+			},
+		},
+		{
+			"Remove auth_lockouts table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "11")
+				for _, table := range migration12Tables {
+					assertTableExists(t, false, db, table)
+				}
+			},
+		},
+		{
+			"Remove credentials table",
+			func(t *testing.T) {
+				migrator.runMigration(t, "10")
+				for _, table := range migration11Tables {
+					assertTableExists(t, false, db, table)
+				}
+			},
+		},
 		{
 			"Removing ALR tables",
 			func(t *testing.T) {
@@ -463,6 +616,20 @@ func assertTableExists(t *testing.T, shouldExist bool, db *sql.DB, tableName str
 	assert.Equal(t, expected, count)
 }
 
+func assertIndexExists(t *testing.T, shouldExist bool, db *sql.DB, tableName, indexName string) {
+	sb := sqlFlavor.NewSelectBuilder().Select("COUNT(1)").From("pg_indexes")
+	sb.Where(sb.Equal("tablename", tableName), sb.Equal("indexname", indexName))
+	query, args := sb.Build()
+	var count int
+	assert.NoError(t, db.QueryRow(query, args...).Scan(&count))
+
+	var expected int
+	if shouldExist {
+		expected = 1
+	}
+	assert.Equal(t, expected, count)
+}
+
 func assertColumnDefaultValue(t *testing.T, db *sql.DB, columnName, expectedDefault string, tables []interface{}) {
 	sb := sqlFlavor.NewSelectBuilder()
 	sb.Select("table_name", "column_default").